@@ -87,7 +87,20 @@ type AccessLogRecord struct {
 	BodyBytesSent        int
 	RequestBytesReceived int
 	ExtraHeadersToLog    []string
-	record               []byte
+	// RouterError is the X-Cf-RouterError value of responses generated by
+	// the router
+	RouterError string
+	// Attempts is the number of times the request was sent, including
+	// retries
+	Attempts int
+	// AttemptedEndpoints are the backends the request was sent to, in order
+	AttemptedEndpoints []*route.Endpoint
+	// GrpcStatus is the grpc-status code of gRPC responses
+	GrpcStatus string
+	// CacheStatus tells how the response cache handled requests to routes
+	// that opt in to caching, such as HIT or MISS
+	CacheStatus string
+	record      []byte
 }
 
 func (r *AccessLogRecord) formatStartedAt() string {
@@ -151,6 +164,28 @@ func (r *AccessLogRecord) makeRecord() []byte {
 	b.WriteString(`app_index:`)
 	b.WriteDashOrStringValue(appIndex)
 
+	if r.RouterError != "" {
+		b.WriteString(` x_cf_routererror:`)
+		b.WriteDashOrStringValue(r.RouterError)
+	}
+
+	if r.Attempts > 1 {
+		b.WriteString(` attempts:`)
+		b.WriteString(strconv.Itoa(r.Attempts))
+		b.WriteString(` attempted_endpoints:`)
+		b.WriteDashOrStringValue(r.attemptedAddrs())
+	}
+
+	if r.GrpcStatus != "" {
+		b.WriteString(` grpc_status:`)
+		b.WriteDashOrStringValue(r.GrpcStatus)
+	}
+
+	if r.CacheStatus != "" {
+		b.WriteString(` cache_status:`)
+		b.WriteDashOrStringValue(r.CacheStatus)
+	}
+
 	r.addExtraHeaders(b)
 
 	b.WriteByte('\n')
@@ -158,6 +193,14 @@ func (r *AccessLogRecord) makeRecord() []byte {
 	return b.Bytes()
 }
 
+func (r *AccessLogRecord) attemptedAddrs() string {
+	addrs := make([]string, len(r.AttemptedEndpoints))
+	for i, e := range r.AttemptedEndpoints {
+		addrs[i] = e.CanonicalAddr()
+	}
+	return strings.Join(addrs, ",")
+}
+
 // WriteTo allows the AccessLogRecord to implement the io.WriterTo interface
 func (r *AccessLogRecord) WriteTo(w io.Writer) (int64, error) {
 	bytesWritten, err := w.Write(r.getRecord())
@@ -150,6 +150,105 @@ var _ = Describe("AccessLogRecord", func() {
 			})
 		})
 
+		Context("with a router error", func() {
+			BeforeEach(func() {
+				record.StatusCode = 503
+				record.RouterError = "endpoints_overloaded"
+			})
+			It("appends the router error", func() {
+				recordString := "FakeRequestHost - " +
+					"[2000-01-01T00:00:00.000+0000] " +
+					`"FakeRequestMethod http://example.com/request FakeRequestProto" ` +
+					"503 " +
+					"30 " +
+					"23 " +
+					`"FakeReferer" ` +
+					`"FakeUserAgent" ` +
+					`"FakeRemoteAddr" ` +
+					`"1.2.3.4:1234" ` +
+					`x_forwarded_for:"FakeProxy1, FakeProxy2" ` +
+					`x_forwarded_proto:"FakeOriginalRequestProto" ` +
+					`vcap_request_id:"abc-123-xyz-pdq" ` +
+					`response_time:60 ` +
+					`app_id:"FakeApplicationId" ` +
+					`app_index:"3" ` +
+					`x_cf_routererror:"endpoints_overloaded"` +
+					"\n"
+
+				Expect(record.LogMessage()).To(Equal(recordString))
+			})
+		})
+
+		Context("when the request was retried", func() {
+			BeforeEach(func() {
+				record.Attempts = 2
+				record.AttemptedEndpoints = []*route.Endpoint{
+					route.NewEndpoint("FakeApplicationId", "1.2.3.5", 1234, "", "4", nil, 0, "", models.ModificationTag{}),
+					endpoint,
+				}
+			})
+			It("appends the attempts and attempted endpoints", func() {
+				recordString := "FakeRequestHost - " +
+					"[2000-01-01T00:00:00.000+0000] " +
+					`"FakeRequestMethod http://example.com/request FakeRequestProto" ` +
+					"200 " +
+					"30 " +
+					"23 " +
+					`"FakeReferer" ` +
+					`"FakeUserAgent" ` +
+					`"FakeRemoteAddr" ` +
+					`"1.2.3.4:1234" ` +
+					`x_forwarded_for:"FakeProxy1, FakeProxy2" ` +
+					`x_forwarded_proto:"FakeOriginalRequestProto" ` +
+					`vcap_request_id:"abc-123-xyz-pdq" ` +
+					`response_time:60 ` +
+					`app_id:"FakeApplicationId" ` +
+					`app_index:"3" ` +
+					`attempts:2 ` +
+					`attempted_endpoints:"1.2.3.5:1234,1.2.3.4:1234"` +
+					"\n"
+
+				Expect(record.LogMessage()).To(Equal(recordString))
+			})
+		})
+
+		Context("with a gRPC status", func() {
+			BeforeEach(func() {
+				record.GrpcStatus = "0"
+			})
+			It("appends the gRPC status", func() {
+				recordString := "FakeRequestHost - " +
+					"[2000-01-01T00:00:00.000+0000] " +
+					`"FakeRequestMethod http://example.com/request FakeRequestProto" ` +
+					"200 " +
+					"30 " +
+					"23 " +
+					`"FakeReferer" ` +
+					`"FakeUserAgent" ` +
+					`"FakeRemoteAddr" ` +
+					`"1.2.3.4:1234" ` +
+					`x_forwarded_for:"FakeProxy1, FakeProxy2" ` +
+					`x_forwarded_proto:"FakeOriginalRequestProto" ` +
+					`vcap_request_id:"abc-123-xyz-pdq" ` +
+					`response_time:60 ` +
+					`app_id:"FakeApplicationId" ` +
+					`app_index:"3" ` +
+					`grpc_status:"0"` +
+					"\n"
+
+				Expect(record.LogMessage()).To(Equal(recordString))
+			})
+		})
+
+		Context("with a cache status", func() {
+			BeforeEach(func() {
+				record.CacheStatus = "HIT"
+			})
+			It("appends the cache status", func() {
+				Expect(record.LogMessage()).To(HaveSuffix(`app_index:"3" cache_status:"HIT"` + "\n"))
+			})
+		})
+
 		Context("when extra headers is an empty slice", func() {
 			It("Makes a record with all values", func() {
 				record := schema.AccessLogRecord{
@@ -0,0 +1,425 @@
+package cache
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache statuses of the access log records of requests to cached routes.
+const (
+	// StatusHit is a fresh response served from the cache.
+	StatusHit = "HIT"
+	// StatusMiss is a response not found in the cache.
+	StatusMiss = "MISS"
+	// StatusExpired is a stale response replaced by the response of the
+	// backend.
+	StatusExpired = "EXPIRED"
+	// StatusRevalidated is a stale response the backend confirmed with a
+	// 304 Not Modified.
+	StatusRevalidated = "REVALIDATED"
+	// StatusUpdating is a stale response served while it is revalidated in
+	// the background (stale-while-revalidate).
+	StatusUpdating = "UPDATING"
+	// StatusStale is a stale response served because the backend failed
+	// (stale-if-error).
+	StatusStale = "STALE"
+	// StatusBypass is a request the cache does not apply to.
+	StatusBypass = "BYPASS"
+)
+
+// ServedFromCache returns true if responses with status did not come from a
+// backend.
+func ServedFromCache(status string) bool {
+	return status == StatusHit || status == StatusUpdating || status == StatusStale
+}
+
+// cacheableStatus are the status codes of the responses stored.
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// maxDeltaSeconds caps delta-seconds values so that they fit a
+// time.Duration.
+const maxDeltaSeconds = 1<<31 - 1
+
+// Entry is a stored response.
+type Entry struct {
+	// Key identifies the responses of a URL and Variant the response among
+	// them selected by the request headers the response varies on.
+	Key     string
+	Variant string
+	// Host and Path are those of the URL, for purging.
+	Host string
+	Path string
+	// Vary lists the request headers selecting among the variants of the
+	// URL. Entries with a Vary and no header only point at the variants.
+	Vary []string
+
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	StoredAt time.Time
+	// InitialAge is the age of the response when it was stored.
+	InitialAge time.Duration
+	// Lifetime is how long after it was generated the response is fresh.
+	Lifetime time.Duration
+	// StaleWhileRevalidate and StaleIfError are how long after it became
+	// stale the response may still be served while it is revalidated, or
+	// when the backend fails.
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+	// MustRevalidate forbids serving the response once stale.
+	MustRevalidate bool
+}
+
+// Key returns the key of the responses to req.
+func Key(req *http.Request) string {
+	return strings.ToLower(req.Host) + req.URL.RequestURI()
+}
+
+// Cacheable returns true if the response to req may come from the cache.
+func Cacheable(req *http.Request) bool {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return false
+	}
+	if req.Header.Get("Upgrade") != "" {
+		return false
+	}
+	return !parseDirectives(req.Header).has("no-store")
+}
+
+// Invalidates returns true if req may change the resource it targets, so
+// that the responses stored for its URL must be removed.
+func Invalidates(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return false
+	}
+	return true
+}
+
+// IsConditional returns true if req carries validators of its own.
+func IsConditional(req *http.Request) bool {
+	return req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != ""
+}
+
+// NewEntry returns the entry of a response to req received at responseTime
+// for a request sent at requestTime, or nil if the response may not be
+// stored by a shared cache.
+func NewEntry(
+	req *http.Request,
+	statusCode int,
+	header http.Header,
+	body []byte,
+	requestTime, responseTime time.Time,
+) *Entry {
+	if req.Method != "GET" || !cacheableStatus[statusCode] {
+		return nil
+	}
+
+	d := parseDirectives(header)
+	if d.has("no-store") || d.has("private") || len(header["Set-Cookie"]) > 0 {
+		return nil
+	}
+	if req.Header.Get("Authorization") != "" && !d.has("public") && !d.has("s-maxage") && !d.has("must-revalidate") {
+		return nil
+	}
+
+	vary, ok := varyHeaders(header)
+	if !ok {
+		return nil
+	}
+
+	if _, explicit := freshnessLifetime(d, header, responseTime); !explicit && !hasValidators(header) {
+		return nil
+	}
+
+	e := &Entry{
+		Key:        Key(req),
+		Variant:    variantKey(Key(req), vary, req.Header),
+		Host:       hostWithoutPort(req),
+		Path:       req.URL.Path,
+		Vary:       vary,
+		StatusCode: statusCode,
+		Header:     cloneHeader(header),
+		Body:       body,
+		StoredAt:   responseTime,
+		InitialAge: initialAge(header, requestTime, responseTime),
+	}
+	e.setFreshness(d, responseTime)
+	return e
+}
+
+// Revalidated returns a copy of e updated with the header of a 304 Not
+// Modified response.
+func (e *Entry) Revalidated(header http.Header, requestTime, responseTime time.Time) *Entry {
+	updated := *e
+	updated.Header = cloneHeader(e.Header)
+	for name, values := range header {
+		// the length is that of the empty 304 body
+		if name != "Content-Length" {
+			updated.Header[name] = values
+		}
+	}
+
+	updated.StoredAt = responseTime
+	updated.InitialAge = initialAge(header, requestTime, responseTime)
+	updated.setFreshness(parseDirectives(updated.Header), responseTime)
+	return &updated
+}
+
+func (e *Entry) setFreshness(d directives, responseTime time.Time) {
+	e.Lifetime, _ = freshnessLifetime(d, e.Header, responseTime)
+	if d.has("no-cache") {
+		e.Lifetime = 0
+	}
+	e.MustRevalidate = d.has("no-cache") || d.has("must-revalidate") || d.has("proxy-revalidate")
+	e.StaleWhileRevalidate, _ = d.seconds("stale-while-revalidate")
+	e.StaleIfError, _ = d.seconds("stale-if-error")
+}
+
+// Age returns the age of the response at now.
+func (e *Entry) Age(now time.Time) time.Duration {
+	return e.InitialAge + now.Sub(e.StoredAt)
+}
+
+// Fresh returns true if the response may be served to req without
+// contacting the backend.
+func (e *Entry) Fresh(req *http.Request, now time.Time) bool {
+	d := parseDirectives(req.Header)
+	if d.has("no-cache") || req.Header.Get("Pragma") == "no-cache" {
+		return false
+	}
+
+	age := e.Age(now)
+	if maxAge, ok := d.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+	return age < e.Lifetime
+}
+
+// ServeWhileRevalidating returns true if the stale response may be served to
+// req while it is revalidated in the background.
+func (e *Entry) ServeWhileRevalidating(req *http.Request, now time.Time) bool {
+	d := parseDirectives(req.Header)
+	if d.has("no-cache") || d.has("max-age") || req.Header.Get("Pragma") == "no-cache" {
+		return false
+	}
+	return !e.MustRevalidate && e.staleness(now) <= e.StaleWhileRevalidate
+}
+
+// ServeOnError returns true if the stale response may be served instead of
+// an error of the backend.
+func (e *Entry) ServeOnError(now time.Time) bool {
+	return !e.MustRevalidate && e.staleness(now) <= e.StaleIfError
+}
+
+func (e *Entry) staleness(now time.Time) time.Duration {
+	return e.Age(now) - e.Lifetime
+}
+
+// HasValidators returns true if the backend can revalidate the response.
+func (e *Entry) HasValidators() bool {
+	return hasValidators(e.Header)
+}
+
+// NotModified returns true if req is a conditional request the response
+// satisfies, so that it may be answered with a 304 Not Modified.
+func (e *Entry) NotModified(req *http.Request) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		etag := e.Header.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || weakETag(candidate) == weakETag(etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(e.Header.Get("Last-Modified"))
+	return err == nil && !lastModified.After(ims)
+}
+
+func (e *Entry) isVariantList() bool {
+	return len(e.Vary) > 0 && e.Header == nil
+}
+
+func (e *Entry) size() int64 {
+	size := int64(len(e.Key) + len(e.Variant) + len(e.Body))
+	for name, values := range e.Header {
+		for _, value := range values {
+			size += int64(len(name) + len(value))
+		}
+	}
+	return size
+}
+
+func weakETag(etag string) string {
+	return strings.TrimPrefix(etag, "W/")
+}
+
+func hasValidators(header http.Header) bool {
+	return header.Get("ETag") != "" || header.Get("Last-Modified") != ""
+}
+
+// freshnessLifetime returns the lifetime of a response from its s-maxage,
+// max-age or Expires, and whether it has any of them. An invalid Expires
+// means the response is already stale.
+func freshnessLifetime(d directives, header http.Header, responseTime time.Time) (time.Duration, bool) {
+	if lifetime, ok := d.seconds("s-maxage"); ok {
+		return lifetime, true
+	}
+	if lifetime, ok := d.seconds("max-age"); ok {
+		return lifetime, true
+	}
+
+	expiresValue := header.Get("Expires")
+	if expiresValue == "" {
+		return 0, false
+	}
+	expires, err := http.ParseTime(expiresValue)
+	if err != nil {
+		return 0, true
+	}
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		date = responseTime
+	}
+	if lifetime := expires.Sub(date); lifetime > 0 {
+		return lifetime, true
+	}
+	return 0, true
+}
+
+// initialAge is the corrected initial age of RFC 7234, section 4.2.3. Date
+// has a resolution of seconds, so the apparent age is truncated to whole
+// seconds.
+func initialAge(header http.Header, requestTime, responseTime time.Time) time.Duration {
+	var age time.Duration
+	if date, err := http.ParseTime(header.Get("Date")); err == nil && responseTime.After(date) {
+		age = responseTime.Sub(date) / time.Second * time.Second
+	}
+	if seconds, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		if seconds > maxDeltaSeconds {
+			seconds = maxDeltaSeconds
+		}
+		if ageValue := time.Duration(seconds) * time.Second; ageValue > age {
+			age = ageValue
+		}
+	}
+	return age + responseTime.Sub(requestTime)
+}
+
+// varyHeaders returns the sorted, canonical names of the request headers a
+// response varies on, and false if it varies on everything.
+func varyHeaders(header http.Header) ([]string, bool) {
+	var names []string
+	for _, line := range header["Vary"] {
+		for _, name := range strings.Split(line, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			}
+			if name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+func variantKey(key string, vary []string, header http.Header) string {
+	if len(vary) == 0 {
+		return key
+	}
+
+	parts := []string{key}
+	for _, name := range vary {
+		parts = append(parts, name+":"+strings.Join(header[name], ","))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func hostWithoutPort(req *http.Request) string {
+	host := strings.ToLower(req.Host)
+	if pos := strings.Index(host, ":"); pos >= 0 {
+		host = host[:pos]
+	}
+	return host
+}
+
+func cloneHeader(header http.Header) http.Header {
+	clone := make(http.Header, len(header))
+	for name, values := range header {
+		clone[name] = append([]string(nil), values...)
+	}
+	return clone
+}
+
+// directives are the Cache-Control directives of a request or response by
+// lower case name.
+type directives map[string]string
+
+func parseDirectives(header http.Header) directives {
+	d := directives{}
+	for _, line := range header["Cache-Control"] {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+
+			name, value := part, ""
+			if pos := strings.Index(part, "="); pos >= 0 {
+				name = strings.TrimSpace(part[:pos])
+				value = strings.Trim(strings.TrimSpace(part[pos+1:]), `"`)
+			}
+			d[strings.ToLower(name)] = value
+		}
+	}
+	return d
+}
+
+func (d directives) has(name string) bool {
+	_, ok := d[name]
+	return ok
+}
+
+// seconds returns the value of a delta-seconds directive.
+func (d directives) seconds(name string) (time.Duration, bool) {
+	value, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	if seconds > maxDeltaSeconds {
+		seconds = maxDeltaSeconds
+	}
+	return time.Duration(seconds) * time.Second, true
+}
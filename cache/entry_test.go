@@ -0,0 +1,256 @@
+package cache_test
+
+import (
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/cache"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Entry", func() {
+	var (
+		req    *http.Request
+		header http.Header
+		now    time.Time
+	)
+
+	newEntry := func() *cache.Entry {
+		return cache.NewEntry(req, http.StatusOK, header, []byte("hello"), now, now)
+	}
+
+	BeforeEach(func() {
+		var err error
+		req, err = http.NewRequest("GET", "http://App.Example.com:8080/hello?name=world", nil)
+		Expect(err).ToNot(HaveOccurred())
+		header = http.Header{}
+		header.Set("Cache-Control", "max-age=60")
+		now = time.Now()
+	})
+
+	Describe("NewEntry", func() {
+		It("stores responses with a freshness lifetime", func() {
+			e := newEntry()
+			Expect(e).ToNot(BeNil())
+			Expect(e.Key).To(Equal("app.example.com:8080/hello?name=world"))
+			Expect(e.Host).To(Equal("app.example.com"))
+			Expect(e.Path).To(Equal("/hello"))
+			Expect(e.Lifetime).To(Equal(60 * time.Second))
+			Expect(e.Body).To(Equal([]byte("hello")))
+		})
+
+		It("prefers s-maxage to max-age and max-age to Expires", func() {
+			header.Set("Cache-Control", "max-age=60, s-maxage=120")
+			header.Set("Expires", now.Add(time.Hour).UTC().Format(http.TimeFormat))
+			Expect(newEntry().Lifetime).To(Equal(120 * time.Second))
+
+			header.Set("Cache-Control", "max-age=60")
+			Expect(newEntry().Lifetime).To(Equal(60 * time.Second))
+		})
+
+		It("computes the lifetime from Expires and Date", func() {
+			header.Del("Cache-Control")
+			date := now.Add(-10 * time.Second).UTC()
+			header.Set("Date", date.Format(http.TimeFormat))
+			header.Set("Expires", date.Add(time.Minute).Format(http.TimeFormat))
+
+			e := newEntry()
+			Expect(e.Lifetime).To(Equal(time.Minute))
+			Expect(e.Age(now)).To(BeNumerically("~", 10*time.Second, time.Second))
+		})
+
+		It("treats an invalid Expires as already expired", func() {
+			header.Del("Cache-Control")
+			header.Set("Expires", "0")
+			Expect(newEntry().Fresh(req, now)).To(BeFalse())
+		})
+
+		It("uses the Age of the response", func() {
+			header.Set("Age", "50")
+			e := newEntry()
+			Expect(e.Fresh(req, now.Add(5*time.Second))).To(BeTrue())
+			Expect(e.Fresh(req, now.Add(11*time.Second))).To(BeFalse())
+		})
+
+		It("stores responses without a lifetime only if they can be revalidated", func() {
+			header.Del("Cache-Control")
+			Expect(newEntry()).To(BeNil())
+
+			header.Set("ETag", `"v1"`)
+			e := newEntry()
+			Expect(e).ToNot(BeNil())
+			Expect(e.Fresh(req, now)).To(BeFalse())
+		})
+
+		It("does not store private, no-store or cookie setting responses", func() {
+			header.Set("Cache-Control", "private, max-age=60")
+			Expect(newEntry()).To(BeNil())
+
+			header.Set("Cache-Control", "no-store")
+			Expect(newEntry()).To(BeNil())
+
+			header.Set("Cache-Control", "max-age=60")
+			header.Set("Set-Cookie", "session=1")
+			Expect(newEntry()).To(BeNil())
+		})
+
+		It("does not store responses varying on everything", func() {
+			header.Set("Vary", "*")
+			Expect(newEntry()).To(BeNil())
+		})
+
+		It("does not store responses of other methods or uncacheable statuses", func() {
+			req.Method = "POST"
+			Expect(newEntry()).To(BeNil())
+
+			req.Method = "GET"
+			Expect(cache.NewEntry(req, http.StatusInternalServerError, header, nil, now, now)).To(BeNil())
+		})
+
+		It("stores responses to authorized requests only if they are public", func() {
+			req.Header.Set("Authorization", "Bearer token")
+			Expect(newEntry()).To(BeNil())
+
+			header.Set("Cache-Control", "public, max-age=60")
+			Expect(newEntry()).ToNot(BeNil())
+		})
+
+		It("keys varying responses by the request headers they vary on", func() {
+			header.Set("Vary", "accept-encoding, Accept-Language")
+			req.Header.Set("Accept-Encoding", "gzip")
+
+			e := newEntry()
+			Expect(e.Vary).To(Equal([]string{"Accept-Encoding", "Accept-Language"}))
+			Expect(e.Variant).ToNot(Equal(e.Key))
+
+			req.Header.Set("Accept-Encoding", "br")
+			Expect(newEntry().Variant).ToNot(Equal(e.Variant))
+		})
+	})
+
+	Describe("Fresh", func() {
+		It("is fresh during its lifetime", func() {
+			e := newEntry()
+			Expect(e.Fresh(req, now.Add(59*time.Second))).To(BeTrue())
+			Expect(e.Fresh(req, now.Add(61*time.Second))).To(BeFalse())
+		})
+
+		It("is not fresh for requests that ask for revalidation", func() {
+			e := newEntry()
+
+			req.Header.Set("Cache-Control", "no-cache")
+			Expect(e.Fresh(req, now)).To(BeFalse())
+
+			req.Header.Set("Cache-Control", "max-age=10")
+			Expect(e.Fresh(req, now.Add(5*time.Second))).To(BeTrue())
+			Expect(e.Fresh(req, now.Add(20*time.Second))).To(BeFalse())
+
+			req.Header.Del("Cache-Control")
+			req.Header.Set("Pragma", "no-cache")
+			Expect(e.Fresh(req, now)).To(BeFalse())
+		})
+
+		It("is never fresh with no-cache", func() {
+			header.Set("Cache-Control", "no-cache, max-age=60")
+			header.Set("ETag", `"v1"`)
+			Expect(newEntry().Fresh(req, now)).To(BeFalse())
+		})
+	})
+
+	Describe("serving stale responses", func() {
+		BeforeEach(func() {
+			header.Set("Cache-Control", "max-age=60, stale-while-revalidate=30, stale-if-error=300")
+		})
+
+		It("serves while revalidating within stale-while-revalidate", func() {
+			e := newEntry()
+			Expect(e.ServeWhileRevalidating(req, now.Add(80*time.Second))).To(BeTrue())
+			Expect(e.ServeWhileRevalidating(req, now.Add(100*time.Second))).To(BeFalse())
+
+			req.Header.Set("Cache-Control", "no-cache")
+			Expect(e.ServeWhileRevalidating(req, now.Add(80*time.Second))).To(BeFalse())
+		})
+
+		It("serves on errors within stale-if-error", func() {
+			e := newEntry()
+			Expect(e.ServeOnError(now.Add(300 * time.Second))).To(BeTrue())
+			Expect(e.ServeOnError(now.Add(400 * time.Second))).To(BeFalse())
+		})
+
+		It("never serves stale responses that must be revalidated", func() {
+			header.Set("Cache-Control", "max-age=60, must-revalidate, stale-while-revalidate=30, stale-if-error=300")
+			e := newEntry()
+			Expect(e.ServeWhileRevalidating(req, now.Add(80*time.Second))).To(BeFalse())
+			Expect(e.ServeOnError(now.Add(80 * time.Second))).To(BeFalse())
+		})
+	})
+
+	Describe("Revalidated", func() {
+		It("refreshes the entry with the header of the 304 response", func() {
+			header.Set("ETag", `"v1"`)
+			header.Set("Content-Length", "5")
+			e := newEntry()
+
+			later := now.Add(2 * time.Minute)
+			notModified := http.Header{}
+			notModified.Set("Cache-Control", "max-age=120")
+			notModified.Set("Content-Length", "0")
+			revalidated := e.Revalidated(notModified, later, later)
+
+			Expect(revalidated.Fresh(req, later.Add(time.Minute))).To(BeTrue())
+			Expect(revalidated.Header.Get("Content-Length")).To(Equal("5"))
+			Expect(revalidated.Body).To(Equal([]byte("hello")))
+			Expect(e.Header.Get("Cache-Control")).To(Equal("max-age=60"))
+		})
+	})
+
+	Describe("NotModified", func() {
+		BeforeEach(func() {
+			header.Set("ETag", `W/"v1"`)
+			header.Set("Last-Modified", now.Add(-time.Hour).UTC().Format(http.TimeFormat))
+		})
+
+		It("matches If-None-Match weakly", func() {
+			e := newEntry()
+			Expect(e.NotModified(req)).To(BeFalse())
+
+			req.Header.Set("If-None-Match", `"v0", "v1"`)
+			Expect(e.NotModified(req)).To(BeTrue())
+
+			req.Header.Set("If-None-Match", `"v2"`)
+			Expect(e.NotModified(req)).To(BeFalse())
+		})
+
+		It("compares If-Modified-Since with Last-Modified", func() {
+			e := newEntry()
+			req.Header.Set("If-Modified-Since", now.UTC().Format(http.TimeFormat))
+			Expect(e.NotModified(req)).To(BeTrue())
+
+			req.Header.Set("If-Modified-Since", now.Add(-2*time.Hour).UTC().Format(http.TimeFormat))
+			Expect(e.NotModified(req)).To(BeFalse())
+		})
+	})
+
+	Describe("Cacheable", func() {
+		It("only applies to GET and HEAD requests without no-store or upgrades", func() {
+			Expect(cache.Cacheable(req)).To(BeTrue())
+
+			req.Method = "HEAD"
+			Expect(cache.Cacheable(req)).To(BeTrue())
+
+			req.Method = "POST"
+			Expect(cache.Cacheable(req)).To(BeFalse())
+			Expect(cache.Invalidates(req)).To(BeTrue())
+
+			req.Method = "GET"
+			req.Header.Set("Cache-Control", "no-store")
+			Expect(cache.Cacheable(req)).To(BeFalse())
+			Expect(cache.Invalidates(req)).To(BeFalse())
+
+			req.Header.Del("Cache-Control")
+			req.Header.Set("Upgrade", "websocket")
+			Expect(cache.Cacheable(req)).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// PurgeResult is the response of the purge handler.
+type PurgeResult struct {
+	Purged int `json:"purged"`
+}
+
+// NewPurgeHandler creates a handler removing responses from store on DELETE
+// requests. The host and path query parameters select the responses of a
+// URL, or of every URL of host without a path; without parameters every
+// response is removed.
+func NewPurgeHandler(store *Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "DELETE" {
+			w.Header().Set("Allow", "DELETE")
+			http.Error(w, "Only DELETE is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := req.URL.Query()
+		host, path := strings.ToLower(query.Get("host")), query.Get("path")
+		if path != "" && host == "" {
+			http.Error(w, "A path must come with a host", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(PurgeResult{Purged: store.Purge(host, path)})
+	})
+}
@@ -0,0 +1,74 @@
+package cache_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/cache"
+	"code.cloudfoundry.org/gorouter/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PurgeHandler", func() {
+	var (
+		store   *cache.Store
+		handler http.Handler
+		resp    *httptest.ResponseRecorder
+	)
+
+	purge := func(method, target string) {
+		resp = httptest.NewRecorder()
+		handler.ServeHTTP(resp, newRequest(method, "http://localhost:8080"+target))
+	}
+
+	purged := func() int {
+		var result cache.PurgeResult
+		Expect(json.Unmarshal(resp.Body.Bytes(), &result)).To(Succeed())
+		return result.Purged
+	}
+
+	BeforeEach(func() {
+		var err error
+		store, err = cache.New(config.CacheConfig{MaxMemorySize: 64 * 1024, MaxObjectSize: 1024})
+		Expect(err).ToNot(HaveOccurred())
+		handler = cache.NewPurgeHandler(store)
+
+		store.Put(newEntry(newRequest("GET", "http://a.example.com/x"), "body", nil))
+		store.Put(newEntry(newRequest("GET", "http://a.example.com/y"), "body", nil))
+		store.Put(newEntry(newRequest("GET", "http://b.example.com/x"), "body", nil))
+	})
+
+	It("purges the responses of a URL", func() {
+		purge("DELETE", "/cache?host=A.example.com&path=/x")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(purged()).To(Equal(1))
+		Expect(store.Lookup(newRequest("GET", "http://a.example.com/x"))).To(BeNil())
+		Expect(store.Lookup(newRequest("GET", "http://a.example.com/y"))).ToNot(BeNil())
+	})
+
+	It("purges the responses of a host", func() {
+		purge("DELETE", "/cache?host=a.example.com")
+		Expect(purged()).To(Equal(2))
+	})
+
+	It("purges every response", func() {
+		purge("DELETE", "/cache")
+		Expect(purged()).To(Equal(3))
+	})
+
+	It("requires a host with a path", func() {
+		purge("DELETE", "/cache?path=/x")
+		Expect(resp.Code).To(Equal(http.StatusBadRequest))
+		Expect(store.Lookup(newRequest("GET", "http://a.example.com/x"))).ToNot(BeNil())
+	})
+
+	It("only allows DELETE", func() {
+		purge("GET", "/cache")
+		Expect(resp.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(resp.Header().Get("Allow")).To(Equal("DELETE"))
+		Expect(store.Lookup(newRequest("GET", "http://a.example.com/x"))).ToNot(BeNil())
+	})
+})
@@ -0,0 +1,419 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/gorouter/config"
+)
+
+const entryFileSuffix = ".entry"
+
+// Store holds the responses of cached routes. Responses are kept in memory
+// up to a total size, evicting the least recently used ones first. With a
+// disk path, evicted responses move to files in that directory until those
+// exceed their own size limit, and move back to memory when requested. The
+// files do not outlive the store: they are removed when a store is created
+// on the same directory. Files are read and written without holding the
+// lock of the store, so that lookups served from memory do not wait for them.
+type Store struct {
+	lock          sync.Mutex
+	maxObjectSize int64
+	memory        *lru
+	disk          *lru
+	diskPath      string
+	// urls are the keys of the entries of each URL, by the key of the URL
+	urls map[string]*cachedURL
+
+	// spilling are the entries evicted from memory whose file is being
+	// written, by key. spilled are those to write and dropped the files to
+	// remove once the lock is released.
+	spilling map[string]*Entry
+	spilled  []*Entry
+	dropped  []string
+	reads    fileReads
+	files    uint64
+}
+
+type cachedURL struct {
+	host string
+	path string
+	keys map[string]bool
+}
+
+// New creates an empty store bounded by c.
+func New(c config.CacheConfig) (*Store, error) {
+	s := &Store{
+		maxObjectSize: c.MaxObjectSize,
+		memory:        newLRU(c.MaxMemorySize),
+		urls:          map[string]*cachedURL{},
+		spilling:      map[string]*Entry{},
+		reads:         fileReads{reads: map[string]*fileRead{}},
+	}
+
+	if c.DiskPath != "" {
+		if err := os.MkdirAll(c.DiskPath, 0700); err != nil {
+			return nil, err
+		}
+		files, err := filepath.Glob(filepath.Join(c.DiskPath, "*"+entryFileSuffix))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if err := os.Remove(file); err != nil {
+				return nil, err
+			}
+		}
+		s.disk = newLRU(c.MaxDiskSize)
+		s.diskPath = c.DiskPath
+	}
+	return s, nil
+}
+
+// MaxObjectSize returns the size of the largest response stored.
+func (s *Store) MaxObjectSize() int64 {
+	return s.maxObjectSize
+}
+
+// Lookup returns the response stored for req, or nil if there is none.
+// Entries are shared and must not be modified.
+func (s *Store) Lookup(req *http.Request) *Entry {
+	e := s.get(Key(req))
+	if e != nil && e.isVariantList() {
+		e = s.get(variantKey(e.Key, e.Vary, req.Header))
+	}
+	return e
+}
+
+// Put stores e, replacing the response stored for the same request. Entries
+// larger than the maximum object size are not stored.
+func (s *Store) Put(e *Entry) {
+	s.lock.Lock()
+	defer s.unlock()
+
+	s.remove(e.Variant)
+	if e.size() > s.maxObjectSize {
+		return
+	}
+
+	if len(e.Vary) > 0 {
+		s.remove(e.Key)
+		s.add(&Entry{Key: e.Key, Variant: e.Key, Host: e.Host, Path: e.Path, Vary: e.Vary})
+	} else {
+		// a response that no longer varies replaces the variants
+		s.removeURL(e.Key)
+	}
+	s.add(e)
+}
+
+// Invalidate removes the responses stored for the URL of req.
+func (s *Store) Invalidate(req *http.Request) {
+	s.lock.Lock()
+	defer s.unlock()
+
+	s.removeURL(Key(req))
+}
+
+// Purge removes the responses stored for the URLs of host with path, or for
+// every URL of host if path is empty, or for every URL if host is empty too.
+// It returns the number of URLs removed.
+func (s *Store) Purge(host, path string) int {
+	s.lock.Lock()
+	defer s.unlock()
+
+	var purged int
+	for key, u := range s.urls {
+		if (host == "" || u.host == host) && (path == "" || u.path == path) {
+			s.removeURL(key)
+			purged++
+		}
+	}
+	return purged
+}
+
+// unlock releases the lock of the store, then writes the files of the
+// entries spilled to disk and removes those of the entries dropped from disk.
+func (s *Store) unlock() {
+	spilled, dropped := s.spilled, s.dropped
+	s.spilled, s.dropped = nil, nil
+	s.lock.Unlock()
+
+	for _, file := range dropped {
+		os.Remove(file)
+	}
+	for _, e := range spilled {
+		s.write(e)
+	}
+}
+
+// get returns the entry stored for key, moving it to memory if it is on
+// disk. Concurrent lookups of an entry on disk share the read of its file.
+func (s *Store) get(key string) *Entry {
+	s.lock.Lock()
+	if item := s.memory.get(key); item != nil {
+		s.unlock()
+		return item.entry
+	}
+	if e := s.spilling[key]; e != nil {
+		// the file being written is dropped once written
+		delete(s.spilling, key)
+		s.addToMemory(e)
+		s.unlock()
+		return e
+	}
+	var item *lruItem
+	if s.disk != nil {
+		item = s.disk.get(key)
+	}
+	s.unlock()
+	if item == nil {
+		return nil
+	}
+
+	e, err := s.reads.read(item.file)
+
+	s.lock.Lock()
+	defer s.unlock()
+
+	if s.disk.get(key) != item {
+		// moved to memory by a concurrent lookup, or removed, while read
+		if item := s.memory.get(key); item != nil {
+			return item.entry
+		}
+		return nil
+	}
+	s.disk.remove(key)
+	s.dropped = append(s.dropped, item.file)
+	if err != nil {
+		s.forget(item.entry)
+		return nil
+	}
+	s.addToMemory(e)
+	return e
+}
+
+func (s *Store) add(e *Entry) {
+	u := s.urls[e.Key]
+	if u == nil {
+		u = &cachedURL{host: e.Host, path: e.Path, keys: map[string]bool{}}
+		s.urls[e.Key] = u
+	}
+	u.keys[e.Variant] = true
+	s.addToMemory(e)
+}
+
+func (s *Store) addToMemory(e *Entry) {
+	s.memory.add(&lruItem{key: e.Variant, entry: e, size: e.size()})
+	for s.memory.full() {
+		s.spill(s.memory.removeOldest().entry)
+	}
+}
+
+// spill moves an entry evicted from memory to disk. Its file is written once
+// the lock is released; the entry is served from memory meanwhile.
+func (s *Store) spill(e *Entry) {
+	if s.disk == nil {
+		s.forget(e)
+		return
+	}
+	s.spilling[e.Variant] = e
+	s.spilled = append(s.spilled, e)
+}
+
+// write writes the file of a spilled entry and adds it to disk, unless the
+// entry was removed or moved back to memory while the file was written.
+func (s *Store) write(e *Entry) {
+	var buf bytes.Buffer
+	file := s.newEntryFile()
+	err := gob.NewEncoder(&buf).Encode(e)
+	if err == nil {
+		err = ioutil.WriteFile(file, buf.Bytes(), 0600)
+	}
+
+	s.lock.Lock()
+	defer s.unlock()
+
+	if s.spilling[e.Variant] != e {
+		s.dropped = append(s.dropped, file)
+		return
+	}
+	delete(s.spilling, e.Variant)
+	if err != nil {
+		s.dropped = append(s.dropped, file)
+		s.forget(e)
+		return
+	}
+
+	// only the URL of entries on disk is kept in memory
+	s.disk.add(&lruItem{key: e.Variant, entry: &Entry{Key: e.Key, Variant: e.Variant}, size: int64(buf.Len()), file: file})
+	for s.disk.full() {
+		item := s.disk.removeOldest()
+		s.dropped = append(s.dropped, item.file)
+		s.forget(item.entry)
+	}
+}
+
+// newEntryFile returns a new file name for each spilled entry, so that the
+// file of an entry is never overwritten by the file of its replacement.
+func (s *Store) newEntryFile() string {
+	n := atomic.AddUint64(&s.files, 1)
+	return filepath.Join(s.diskPath, fmt.Sprintf("%d%s", n, entryFileSuffix))
+}
+
+func readEntry(file string) (*Entry, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// fileReads shares the read of a file between concurrent lookups.
+type fileReads struct {
+	lock  sync.Mutex
+	reads map[string]*fileRead
+}
+
+type fileRead struct {
+	done  chan struct{}
+	entry *Entry
+	err   error
+}
+
+func (r *fileReads) read(file string) (*Entry, error) {
+	r.lock.Lock()
+	read, ok := r.reads[file]
+	if !ok {
+		read = &fileRead{done: make(chan struct{})}
+		r.reads[file] = read
+	}
+	r.lock.Unlock()
+
+	if ok {
+		<-read.done
+		return read.entry, read.err
+	}
+
+	read.entry, read.err = readEntry(file)
+	r.lock.Lock()
+	delete(r.reads, file)
+	r.lock.Unlock()
+	close(read.done)
+	return read.entry, read.err
+}
+
+func (s *Store) remove(key string) {
+	if item := s.memory.remove(key); item != nil {
+		s.forget(item.entry)
+		return
+	}
+	if s.disk == nil {
+		return
+	}
+	if e := s.spilling[key]; e != nil {
+		delete(s.spilling, key)
+		s.forget(e)
+		return
+	}
+	if item := s.disk.remove(key); item != nil {
+		s.dropped = append(s.dropped, item.file)
+		s.forget(item.entry)
+	}
+}
+
+func (s *Store) removeURL(key string) {
+	u := s.urls[key]
+	if u == nil {
+		return
+	}
+	for variant := range u.keys {
+		s.remove(variant)
+	}
+}
+
+// forget removes an entry no longer held by any tier from its URL.
+func (s *Store) forget(e *Entry) {
+	u := s.urls[e.Key]
+	if u == nil {
+		return
+	}
+	delete(u.keys, e.Variant)
+	if len(u.keys) == 0 {
+		delete(s.urls, e.Key)
+	}
+}
+
+// lru is a tier of the store holding entries up to a total size.
+type lru struct {
+	maxSize int64
+	size    int64
+	order   *list.List
+	items   map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry *Entry
+	size  int64
+	// file holds the entry of items on disk
+	file string
+}
+
+func newLRU(maxSize int64) *lru {
+	return &lru{
+		maxSize: maxSize,
+		order:   list.New(),
+		items:   map[string]*list.Element{},
+	}
+}
+
+func (l *lru) get(key string) *lruItem {
+	element, ok := l.items[key]
+	if !ok {
+		return nil
+	}
+	l.order.MoveToFront(element)
+	return element.Value.(*lruItem)
+}
+
+func (l *lru) add(item *lruItem) {
+	l.remove(item.key)
+	l.items[item.key] = l.order.PushFront(item)
+	l.size += item.size
+}
+
+func (l *lru) remove(key string) *lruItem {
+	element, ok := l.items[key]
+	if !ok {
+		return nil
+	}
+	return l.removeElement(element)
+}
+
+func (l *lru) removeOldest() *lruItem {
+	return l.removeElement(l.order.Back())
+}
+
+func (l *lru) removeElement(element *list.Element) *lruItem {
+	item := element.Value.(*lruItem)
+	l.order.Remove(element)
+	delete(l.items, item.key)
+	l.size -= item.size
+	return item
+}
+
+func (l *lru) full() bool {
+	return l.size > l.maxSize && l.order.Len() > 0
+}
@@ -0,0 +1,253 @@
+package cache_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/cache"
+	"code.cloudfoundry.org/gorouter/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func newRequest(method, url string) *http.Request {
+	req, err := http.NewRequest(method, url, nil)
+	ExpectWithOffset(1, err).ToNot(HaveOccurred())
+	return req
+}
+
+func newEntry(req *http.Request, body string, header http.Header) *cache.Entry {
+	if header == nil {
+		header = http.Header{}
+	}
+	if header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", "max-age=60")
+	}
+	now := time.Now()
+	e := cache.NewEntry(req, http.StatusOK, header, []byte(body), now, now)
+	ExpectWithOffset(1, e).ToNot(BeNil())
+	return e
+}
+
+var _ = Describe("Store", func() {
+	var (
+		cacheConfig config.CacheConfig
+		store       *cache.Store
+	)
+
+	BeforeEach(func() {
+		cacheConfig = config.CacheConfig{
+			Enabled:       true,
+			MaxMemorySize: 1024,
+			MaxObjectSize: 256,
+		}
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		store, err = cache.New(cacheConfig)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("looks up the stored responses", func() {
+		req := newRequest("GET", "http://example.com/a?b=c")
+		Expect(store.Lookup(req)).To(BeNil())
+
+		store.Put(newEntry(req, "a", nil))
+		Expect(store.Lookup(req).Body).To(Equal([]byte("a")))
+		Expect(store.Lookup(newRequest("GET", "http://EXAMPLE.com/a?b=c"))).ToNot(BeNil())
+		Expect(store.Lookup(newRequest("GET", "http://example.com/a?b=d"))).To(BeNil())
+	})
+
+	It("replaces the response stored for the same request", func() {
+		req := newRequest("GET", "http://example.com/a")
+		store.Put(newEntry(req, "old", nil))
+		store.Put(newEntry(req, "new", nil))
+		Expect(store.Lookup(req).Body).To(Equal([]byte("new")))
+	})
+
+	It("does not store responses larger than the maximum object size", func() {
+		req := newRequest("GET", "http://example.com/a")
+		store.Put(newEntry(req, strings.Repeat("a", 300), nil))
+		Expect(store.Lookup(req)).To(BeNil())
+	})
+
+	It("evicts the least recently used responses", func() {
+		reqs := []*http.Request{
+			newRequest("GET", "http://example.com/1"),
+			newRequest("GET", "http://example.com/2"),
+			newRequest("GET", "http://example.com/3"),
+			newRequest("GET", "http://example.com/4"),
+			newRequest("GET", "http://example.com/5"),
+		}
+		for _, req := range reqs[:4] {
+			store.Put(newEntry(req, strings.Repeat("a", 200), nil))
+		}
+		Expect(store.Lookup(reqs[0])).ToNot(BeNil())
+
+		store.Put(newEntry(reqs[4], strings.Repeat("a", 200), nil))
+		Expect(store.Lookup(reqs[0])).ToNot(BeNil())
+		Expect(store.Lookup(reqs[1])).To(BeNil())
+		Expect(store.Lookup(reqs[4])).ToNot(BeNil())
+	})
+
+	Context("with responses that vary", func() {
+		var gzip, plain *http.Request
+
+		BeforeEach(func() {
+			gzip = newRequest("GET", "http://example.com/a")
+			gzip.Header.Set("Accept-Encoding", "gzip")
+			plain = newRequest("GET", "http://example.com/a")
+		})
+
+		It("stores a response for each variant", func() {
+			header := http.Header{"Vary": {"Accept-Encoding"}}
+			store.Put(newEntry(gzip, "gzip", header))
+			Expect(store.Lookup(plain)).To(BeNil())
+
+			store.Put(newEntry(plain, "plain", http.Header{"Vary": {"Accept-Encoding"}}))
+			Expect(store.Lookup(gzip).Body).To(Equal([]byte("gzip")))
+			Expect(store.Lookup(plain).Body).To(Equal([]byte("plain")))
+		})
+
+		It("replaces the variants with a response that no longer varies", func() {
+			store.Put(newEntry(gzip, "gzip", http.Header{"Vary": {"Accept-Encoding"}}))
+			store.Put(newEntry(plain, "any", nil))
+			Expect(store.Lookup(gzip).Body).To(Equal([]byte("any")))
+			Expect(store.Lookup(plain).Body).To(Equal([]byte("any")))
+		})
+
+		It("invalidates every variant", func() {
+			store.Put(newEntry(gzip, "gzip", http.Header{"Vary": {"Accept-Encoding"}}))
+			store.Put(newEntry(plain, "plain", http.Header{"Vary": {"Accept-Encoding"}}))
+
+			store.Invalidate(newRequest("POST", "http://example.com/a"))
+			Expect(store.Lookup(gzip)).To(BeNil())
+			Expect(store.Lookup(plain)).To(BeNil())
+		})
+	})
+
+	Describe("Purge", func() {
+		BeforeEach(func() {
+			cacheConfig.MaxMemorySize = 64 * 1024
+		})
+
+		JustBeforeEach(func() {
+			for _, url := range []string{
+				"http://a.example.com/x",
+				"http://a.example.com/x?y=z",
+				"http://a.example.com:8080/y",
+				"http://b.example.com/x",
+			} {
+				store.Put(newEntry(newRequest("GET", url), "body", nil))
+			}
+		})
+
+		It("purges the URLs of a host with a path", func() {
+			Expect(store.Purge("a.example.com", "/x")).To(Equal(2))
+			Expect(store.Lookup(newRequest("GET", "http://a.example.com/x"))).To(BeNil())
+			Expect(store.Lookup(newRequest("GET", "http://a.example.com:8080/y"))).ToNot(BeNil())
+			Expect(store.Lookup(newRequest("GET", "http://b.example.com/x"))).ToNot(BeNil())
+		})
+
+		It("purges the URLs of a host", func() {
+			Expect(store.Purge("a.example.com", "")).To(Equal(3))
+			Expect(store.Lookup(newRequest("GET", "http://b.example.com/x"))).ToNot(BeNil())
+		})
+
+		It("purges every URL", func() {
+			Expect(store.Purge("", "")).To(Equal(4))
+			Expect(store.Purge("", "")).To(Equal(0))
+		})
+	})
+
+	Context("with a disk path", func() {
+		var diskPath string
+
+		BeforeEach(func() {
+			var err error
+			diskPath, err = ioutil.TempDir("", "cache")
+			Expect(err).ToNot(HaveOccurred())
+
+			cacheConfig.DiskPath = diskPath
+			cacheConfig.MaxDiskSize = 4096
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(diskPath)
+		})
+
+		entryFiles := func() []string {
+			files, err := filepath.Glob(filepath.Join(diskPath, "*.entry"))
+			Expect(err).ToNot(HaveOccurred())
+			return files
+		}
+
+		It("moves evicted responses to disk and back", func() {
+			first := newRequest("GET", "http://example.com/f")
+			store.Put(newEntry(first, strings.Repeat("a", 150), nil))
+			for i := 0; i < 5; i++ {
+				store.Put(newEntry(newRequest("GET", "http://example.com/"+strings.Repeat("b", i+1)), strings.Repeat("b", 150), nil))
+			}
+			Expect(entryFiles()).ToNot(BeEmpty())
+
+			e := store.Lookup(first)
+			Expect(e).ToNot(BeNil())
+			Expect(e.Body).To(Equal([]byte(strings.Repeat("a", 150))))
+			Expect(e.Header.Get("Cache-Control")).To(Equal("max-age=60"))
+		})
+
+		It("serves concurrent lookups of a response on disk", func() {
+			first := newRequest("GET", "http://example.com/f")
+			store.Put(newEntry(first, strings.Repeat("a", 150), nil))
+			for i := 0; i < 5; i++ {
+				store.Put(newEntry(newRequest("GET", "http://example.com/"+strings.Repeat("b", i+1)), strings.Repeat("b", 150), nil))
+			}
+
+			var wg sync.WaitGroup
+			bodies := make(chan []byte, 10)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					if e := store.Lookup(first); e != nil {
+						bodies <- e.Body
+					}
+				}()
+			}
+			wg.Wait()
+			close(bodies)
+
+			Expect(bodies).To(HaveLen(10))
+			for body := range bodies {
+				Expect(body).To(Equal([]byte(strings.Repeat("a", 150))))
+			}
+		})
+
+		It("removes responses from disk when purged", func() {
+			for i := 0; i < 6; i++ {
+				store.Put(newEntry(newRequest("GET", "http://example.com/"+strings.Repeat("b", i+1)), strings.Repeat("b", 150), nil))
+			}
+			Expect(entryFiles()).ToNot(BeEmpty())
+
+			Expect(store.Purge("example.com", "")).To(Equal(6))
+			Expect(entryFiles()).To(BeEmpty())
+		})
+
+		It("removes the files of a previous store", func() {
+			Expect(ioutil.WriteFile(filepath.Join(diskPath, "old.entry"), []byte("old"), 0600)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(diskPath, "other"), []byte("other"), 0600)).To(Succeed())
+
+			_, err := cache.New(cacheConfig)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entryFiles()).To(BeEmpty())
+			Expect(filepath.Join(diskPath, "other")).To(BeAnExistingFile())
+		})
+	})
+})
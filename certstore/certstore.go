@@ -0,0 +1,205 @@
+package certstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+)
+
+// Store selects the certificate the router presents to TLS clients from the
+// server name they request. Certificates are indexed by their DNS SANs, or
+// their common name if they have none; when several certificates with the
+// same type of key share a name, the first one wins. A name may have both an
+// ECDSA and an RSA certificate, in which case clients offering an ECDSA
+// cipher suite get the ECDSA one.
+type Store struct {
+	lock    sync.RWMutex
+	current *index
+}
+
+type index struct {
+	certs        []*tls.Certificate
+	byName       map[string]*keyedCerts
+	defaultCerts *keyedCerts
+}
+
+// keyedCerts are the certificates of a name by type of key.
+type keyedCerts struct {
+	ecdsa *tls.Certificate
+	other *tls.Certificate
+}
+
+func (k *keyedCerts) add(cert *tls.Certificate) {
+	if _, ok := cert.PrivateKey.(*ecdsa.PrivateKey); ok {
+		if k.ecdsa == nil {
+			k.ecdsa = cert
+		}
+	} else if k.other == nil {
+		k.other = cert
+	}
+}
+
+func (k *keyedCerts) pick(hello *tls.ClientHelloInfo) *tls.Certificate {
+	if k.ecdsa != nil && (k.other == nil || supportsECDSA(hello)) {
+		return k.ecdsa
+	}
+	return k.other
+}
+
+func supportsECDSA(hello *tls.ClientHelloInfo) bool {
+	for _, suite := range hello.CipherSuites {
+		if config.IsECDSACipherSuite(suite) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expiry is the end of the validity of a certificate of the store.
+type Expiry struct {
+	Names            []string  `json:"names"`
+	NotAfter         time.Time `json:"not_after"`
+	ExpiresInSeconds int64     `json:"expires_in_seconds"`
+}
+
+// New indexes certs. Clients that request no name, or a name no certificate
+// matches, get the certificate for defaultName, or the first certificate
+// when defaultName is empty.
+func New(certs []tls.Certificate, defaultName string) (*Store, error) {
+	s := &Store{}
+	if err := s.Update(certs, defaultName); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Update replaces the certificates of the store. Handshakes that already
+// got a certificate are not affected. The current certificates are kept if
+// the new ones cannot be indexed.
+func (s *Store) Update(certs []tls.Certificate, defaultName string) error {
+	idx, err := newIndex(certs, defaultName)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	s.current = idx
+	s.lock.Unlock()
+	return nil
+}
+
+func newIndex(certs []tls.Certificate, defaultName string) (*index, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("no TLS certificates")
+	}
+
+	idx := &index{byName: make(map[string]*keyedCerts)}
+	for i := range certs {
+		cert := certs[i]
+		if len(cert.Certificate) == 0 {
+			return nil, fmt.Errorf("TLS certificate %d is empty", i)
+		}
+
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, err
+		}
+		cert.Leaf = leaf
+
+		idx.certs = append(idx.certs, &cert)
+		for _, name := range names(leaf) {
+			certs, ok := idx.byName[name]
+			if !ok {
+				certs = &keyedCerts{}
+				idx.byName[name] = certs
+			}
+			certs.add(&cert)
+		}
+	}
+
+	if defaultName != "" {
+		certs, ok := idx.byName[strings.ToLower(defaultName)]
+		if !ok {
+			return nil, fmt.Errorf("no TLS certificate for the default name %s", defaultName)
+		}
+		idx.defaultCerts = certs
+	} else if firstNames := names(idx.certs[0].Leaf); len(firstNames) > 0 {
+		idx.defaultCerts = idx.byName[firstNames[0]]
+	} else {
+		idx.defaultCerts = &keyedCerts{}
+		idx.defaultCerts.add(idx.certs[0])
+	}
+
+	return idx, nil
+}
+
+func (s *Store) snapshot() *index {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.current
+}
+
+// GetCertificate returns the certificate for the server name of the client,
+// matching "*.example.com" certificates for names one label below
+// example.com. It is meant for tls.Config.
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return s.snapshot().lookup(hello).pick(hello), nil
+}
+
+func (idx *index) lookup(hello *tls.ClientHelloInfo) *keyedCerts {
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if name == "" {
+		return idx.defaultCerts
+	}
+
+	if certs, ok := idx.byName[name]; ok {
+		return certs
+	}
+
+	if i := strings.Index(name, "."); i > 0 {
+		if certs, ok := idx.byName["*"+name[i:]]; ok {
+			return certs
+		}
+	}
+
+	return idx.defaultCerts
+}
+
+// Expiries returns the end of the validity of each certificate, in the order
+// they were given.
+func (s *Store) Expiries() []Expiry {
+	idx := s.snapshot()
+	now := time.Now()
+
+	expiries := make([]Expiry, 0, len(idx.certs))
+	for _, cert := range idx.certs {
+		expiries = append(expiries, Expiry{
+			Names:            names(cert.Leaf),
+			NotAfter:         cert.Leaf.NotAfter,
+			ExpiresInSeconds: int64(cert.Leaf.NotAfter.Sub(now).Seconds()),
+		})
+	}
+	return expiries
+}
+
+func names(leaf *x509.Certificate) []string {
+	if len(leaf.DNSNames) == 0 {
+		if leaf.Subject.CommonName == "" {
+			return nil
+		}
+		return []string{strings.ToLower(leaf.Subject.CommonName)}
+	}
+
+	names := make([]string, 0, len(leaf.DNSNames))
+	for _, name := range leaf.DNSNames {
+		names = append(names, strings.ToLower(name))
+	}
+	return names
+}
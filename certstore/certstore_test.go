@@ -0,0 +1,147 @@
+package certstore_test
+
+import (
+	"crypto/tls"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/certstore"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		exampleCert  tls.Certificate
+		wildcardCert tls.Certificate
+		otherCert    tls.Certificate
+		store        *certstore.Store
+	)
+
+	BeforeEach(func() {
+		exampleCert = test_util.CreateSignedCertWithRootCA("example.com").TLSCert()
+		wildcardCert = test_util.CreateSignedCertWithRootCA("*.apps.example.com").TLSCert()
+		otherCert = test_util.CreateSignedCertWithRootCA("other.com").TLSCert()
+
+		var err error
+		store, err = certstore.New([]tls.Certificate{exampleCert, wildcardCert, otherCert}, "")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	served := func(serverName string) tls.Certificate {
+		cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		Expect(err).ToNot(HaveOccurred())
+		return *cert
+	}
+
+	It("serves the certificate of the requested name", func() {
+		Expect(served("other.com").Certificate).To(Equal(otherCert.Certificate))
+		Expect(served("Example.COM.").Certificate).To(Equal(exampleCert.Certificate))
+	})
+
+	It("serves wildcard certificates for names one label below", func() {
+		Expect(served("app.apps.example.com").Certificate).To(Equal(wildcardCert.Certificate))
+		Expect(served("a.app.apps.example.com").Certificate).To(Equal(exampleCert.Certificate))
+		Expect(served("apps.example.com").Certificate).To(Equal(exampleCert.Certificate))
+	})
+
+	It("prefers an exact name to a wildcard", func() {
+		exactCert := test_util.CreateSignedCertWithRootCA("app.apps.example.com").TLSCert()
+
+		var err error
+		store, err = certstore.New([]tls.Certificate{wildcardCert, exactCert}, "")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(served("app.apps.example.com").Certificate).To(Equal(exactCert.Certificate))
+	})
+
+	It("serves the first certificate to clients without a matching name", func() {
+		Expect(served("").Certificate).To(Equal(exampleCert.Certificate))
+		Expect(served("unknown.org").Certificate).To(Equal(exampleCert.Certificate))
+	})
+
+	Context("with a default certificate", func() {
+		BeforeEach(func() {
+			var err error
+			store, err = certstore.New([]tls.Certificate{exampleCert, otherCert}, "other.com")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("serves it to clients without a matching name", func() {
+			Expect(served("").Certificate).To(Equal(otherCert.Certificate))
+			Expect(served("unknown.org").Certificate).To(Equal(otherCert.Certificate))
+			Expect(served("example.com").Certificate).To(Equal(exampleCert.Certificate))
+		})
+	})
+
+	It("fails when no certificate has the default name", func() {
+		_, err := certstore.New([]tls.Certificate{exampleCert}, "unknown.org")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("fails without certificates", func() {
+		_, err := certstore.New(nil, "")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("with ECDSA and RSA certificates for the same name", func() {
+		var ecdsaCert tls.Certificate
+
+		BeforeEach(func() {
+			ecdsaCert = test_util.CreateECDSASignedCertWithRootCA("example.com").TLSCert()
+
+			var err error
+			store, err = certstore.New([]tls.Certificate{exampleCert, ecdsaCert}, "")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		certFor := func(serverName string, cipherSuites ...uint16) tls.Certificate {
+			cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName, CipherSuites: cipherSuites})
+			Expect(err).ToNot(HaveOccurred())
+			return *cert
+		}
+
+		It("serves the ECDSA certificate to clients offering an ECDSA cipher suite", func() {
+			cert := certFor("example.com", tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256)
+			Expect(cert.Certificate).To(Equal(ecdsaCert.Certificate))
+
+			cert = certFor("", tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256)
+			Expect(cert.Certificate).To(Equal(ecdsaCert.Certificate))
+		})
+
+		It("serves the RSA certificate to other clients", func() {
+			cert := certFor("example.com", tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+			Expect(cert.Certificate).To(Equal(exampleCert.Certificate))
+		})
+	})
+
+	Describe("Update", func() {
+		It("serves the new certificates", func() {
+			newCert := test_util.CreateSignedCertWithRootCA("example.com").TLSCert()
+			Expect(store.Update([]tls.Certificate{newCert}, "")).To(Succeed())
+
+			Expect(served("example.com").Certificate).To(Equal(newCert.Certificate))
+			Expect(served("other.com").Certificate).To(Equal(newCert.Certificate))
+			Expect(store.Expiries()).To(HaveLen(1))
+		})
+
+		It("keeps the current certificates when the new ones are invalid", func() {
+			Expect(store.Update(nil, "")).NotTo(Succeed())
+			Expect(store.Update([]tls.Certificate{exampleCert}, "unknown.org")).NotTo(Succeed())
+			Expect(store.Update([]tls.Certificate{{Certificate: [][]byte{[]byte("garbage")}}}, "")).NotTo(Succeed())
+
+			Expect(served("other.com").Certificate).To(Equal(otherCert.Certificate))
+			Expect(store.Expiries()).To(HaveLen(3))
+		})
+	})
+
+	It("reports the expiry of each certificate", func() {
+		expiries := store.Expiries()
+		Expect(expiries).To(HaveLen(3))
+
+		Expect(expiries[1].Names).To(Equal([]string{"*.apps.example.com"}))
+		Expect(expiries[1].NotAfter).To(BeTemporally("~", time.Now().Add(24*time.Hour), time.Minute))
+		Expect(expiries[1].ExpiresInSeconds).To(BeNumerically("~", 24*60*60, 60))
+	})
+})
@@ -88,7 +88,10 @@ type VcapComponent struct {
 	Healthz    *health.Healthz `json:"-"`
 	Health     http.Handler
 	InfoRoutes map[string]json.Marshaler `json:"-"`
-	Logger     logger.Logger             `json:"-"`
+	// Handlers serve requests to paths of the status server other than
+	// the info routes.
+	Handlers map[string]http.Handler `json:"-"`
+	Logger   logger.Logger           `json:"-"`
 
 	listener net.Listener
 	statusCh chan error
@@ -243,6 +246,10 @@ func (c *VcapComponent) ListenAndServe() {
 		})
 	}
 
+	for path, handler := range c.Handlers {
+		hs.Handle(path, handler)
+	}
+
 	f := func(user, password string) bool {
 		return user == c.Varz.Credentials[0] && password == c.Varz.Credentials[1]
 	}
@@ -118,6 +118,25 @@ var _ = Describe("Component", func() {
 		Expect(body).To(Equal(`{"key":"value"}` + "\n"))
 	})
 
+	It("serves its handlers to authorized requests", func() {
+		component.Handlers = map[string]http.Handler{
+			"/purge": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Write([]byte(req.Method))
+			}),
+		}
+		serveComponent(component)
+
+		req, err := http.NewRequest("DELETE", "http://"+component.Varz.Host+"/purge", nil)
+		Expect(err).ToNot(HaveOccurred())
+		code, _, _ := doGetRequest(req)
+		Expect(code).To(Equal(401))
+
+		req.SetBasicAuth("username", "password")
+		code, _, body := doGetRequest(req)
+		Expect(code).To(Equal(200))
+		Expect(body).To(Equal("DELETE"))
+	})
+
 	It("updates the uptime statistic", func() {
 		stringMap := make(map[string]interface{})
 		path := "/varz"
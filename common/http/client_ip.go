@@ -0,0 +1,54 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the address of the client. Requests from trusted proxies
+// carry it in X-Forwarded-For: it is the last address there that is not a
+// trusted proxy itself, as earlier ones may be forged by the client.
+func ClientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrustedProxy(net.ParseIP(host), trustedProxies) {
+		return host
+	}
+
+	var hops []string
+	for _, value := range r.Header["X-Forwarded-For"] {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			// addresses before a malformed one cannot be trusted
+			return hops[i]
+		}
+		if !isTrustedProxy(ip, trustedProxies) {
+			return ip.String()
+		}
+		host = ip.String()
+	}
+	return host
+}
+
+func isTrustedProxy(ip net.IP, trustedProxies []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
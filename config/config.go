@@ -1,12 +1,18 @@
 package config
 
 import (
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
 	"net/url"
 
 	"io/ioutil"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -16,8 +22,78 @@ import (
 
 const LOAD_BALANCE_RR string = "round-robin"
 const LOAD_BALANCE_LC string = "least-connection"
+const LOAD_BALANCE_CH string = "consistent-hash"
+const LOAD_BALANCE_EWMA string = "peak-ewma"
 
-var LoadBalancingStrategies = []string{LOAD_BALANCE_RR, LOAD_BALANCE_LC}
+var LoadBalancingStrategies = []string{LOAD_BALANCE_RR, LOAD_BALANCE_LC, LOAD_BALANCE_CH, LOAD_BALANCE_EWMA}
+
+// Request attributes the consistent-hash load balancer can be keyed on. The
+// header and cookie variants are followed by the header or cookie name,
+// e.g. "header:X-User-Id".
+const (
+	HASH_KEY_CLIENT_IP     string = "client_ip"
+	HASH_KEY_HEADER_PREFIX string = "header:"
+	HASH_KEY_COOKIE_PREFIX string = "cookie:"
+)
+
+// Conditions under which the proxy retries a request on another endpoint.
+// Besides these, the status codes listed in RetryStatusCodes may be given.
+// RETRY_ON_IDEMPOTENT_ONLY restricts retries of requests that may have
+// reached the backend to idempotent methods.
+const (
+	RETRY_ON_CONNECT_FAILURE string = "connect-failure"
+	RETRY_ON_RESET           string = "reset"
+	RETRY_ON_IDEMPOTENT_ONLY string = "idempotent-only"
+)
+
+// Client certificates the SSL port asks clients for. Certificates that are
+// requested or required are verified against ClientCACertsPath.
+const (
+	CLIENT_CERT_VALIDATION_NONE    string = "none"
+	CLIENT_CERT_VALIDATION_REQUEST string = "request"
+	CLIENT_CERT_VALIDATION_REQUIRE string = "require"
+)
+
+var ClientCertValidations = []string{CLIENT_CERT_VALIDATION_NONE, CLIENT_CERT_VALIDATION_REQUEST, CLIENT_CERT_VALIDATION_REQUIRE}
+
+// How the X-Forwarded-Client-Cert header reaches backends.
+// ALWAYS_FORWARD passes the header of the client through untouched, FORWARD
+// passes it through only on mutual TLS connections, and SANITIZE_SET replaces
+// it with the verified certificate of the client on mutual TLS connections
+// and removes it from other requests.
+const (
+	ALWAYS_FORWARD string = "always_forward"
+	FORWARD        string = "forward"
+	SANITIZE_SET   string = "sanitize_set"
+)
+
+var ForwardedClientCertModes = []string{ALWAYS_FORWARD, FORWARD, SANITIZE_SET}
+
+// Header rule actions, and the messages header rules apply to.
+const (
+	HEADER_RULE_SET      string = "set"
+	HEADER_RULE_ADD      string = "add"
+	HEADER_RULE_REMOVE   string = "remove"
+	HEADER_RULE_RENAME   string = "rename"
+	HEADER_RULE_REQUEST  string = "request"
+	HEADER_RULE_RESPONSE string = "response"
+)
+
+var HeaderRuleActions = []string{HEADER_RULE_SET, HEADER_RULE_ADD, HEADER_RULE_REMOVE, HEADER_RULE_RENAME}
+
+var RetryStatusCodes = []string{"502", "503", "504"}
+
+var RetryConditions = append([]string{RETRY_ON_CONNECT_FAILURE, RETRY_ON_RESET, RETRY_ON_IDEMPOTENT_ONLY}, RetryStatusCodes...)
+
+// What the rate limiter counts requests by: the IP address of the client,
+// the route, or the app the route is mapped to.
+const (
+	RATE_LIMIT_KEY_CLIENT_IP string = "client_ip"
+	RATE_LIMIT_KEY_ROUTE     string = "route"
+	RATE_LIMIT_KEY_APP_ID    string = "app_id"
+)
+
+var RateLimitKeys = []string{RATE_LIMIT_KEY_CLIENT_IP, RATE_LIMIT_KEY_ROUTE, RATE_LIMIT_KEY_APP_ID}
 
 type StatusConfig struct {
 	Host string `yaml:"host"`
@@ -86,6 +162,194 @@ var defaultLoggingConfig = LoggingConfig{
 	MetronAddress: "localhost:3457",
 }
 
+type OutlierDetectionConfig struct {
+	ConsecutiveErrors  int           `yaml:"consecutive_errors"`
+	BaseEjectionTime   time.Duration `yaml:"base_ejection_time"`
+	MaxEjectionTime    time.Duration `yaml:"max_ejection_time"`
+	MaxEjectionPercent int           `yaml:"max_ejection_percent"`
+}
+
+var defaultOutlierDetectionConfig = OutlierDetectionConfig{
+	BaseEjectionTime:   30 * time.Second,
+	MaxEjectionTime:    5 * time.Minute,
+	MaxEjectionPercent: 10,
+}
+
+type ActiveHealthCheckConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	Interval           time.Duration `yaml:"interval"`
+	Timeout            time.Duration `yaml:"timeout"`
+	HealthyThreshold   int           `yaml:"healthy_threshold"`
+	UnhealthyThreshold int           `yaml:"unhealthy_threshold"`
+	MaxConcurrency     int           `yaml:"max_concurrency"`
+}
+
+var defaultActiveHealthCheckConfig = ActiveHealthCheckConfig{
+	Interval:           10 * time.Second,
+	Timeout:            2 * time.Second,
+	HealthyThreshold:   2,
+	UnhealthyThreshold: 3,
+	MaxConcurrency:     100,
+}
+
+type RetryPolicyConfig struct {
+	MaxAttempts int      `yaml:"max_attempts"`
+	RetryOn     []string `yaml:"retry_on"`
+	// BudgetPercent caps retries at a percentage of the requests proxied
+	// recently. Zero disables the budget.
+	BudgetPercent float64 `yaml:"budget_percent"`
+	// BudgetMinRetries are the retries allowed by the budget whatever the
+	// percentage, so that routes with little traffic can still retry.
+	BudgetMinRetries int `yaml:"budget_min_retries"`
+}
+
+var defaultRetryPolicyConfig = RetryPolicyConfig{
+	MaxAttempts: 3,
+	RetryOn:     []string{RETRY_ON_CONNECT_FAILURE, RETRY_ON_RESET},
+}
+
+type CompressionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MinSize is the smallest Content-Length compressed, in bytes. Responses
+	// of unknown length are always compressed.
+	MinSize int64 `yaml:"min_size"`
+	// ContentTypes lists the media types compressed, without parameters.
+	ContentTypes []string `yaml:"content_types"`
+}
+
+var defaultCompressionConfig = CompressionConfig{
+	MinSize: 1024,
+	ContentTypes: []string{
+		"text/html", "text/plain", "text/css", "text/javascript", "text/xml", "text/csv",
+		"application/javascript", "application/json", "application/xml", "image/svg+xml",
+	},
+}
+
+// CacheConfig bounds the shared response cache. Routes opt in to caching
+// with a registration tag.
+type CacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxMemorySize is the total size of the responses held in memory, in
+	// bytes. The least recently used responses are evicted first.
+	MaxMemorySize int64 `yaml:"max_memory_size"`
+	// MaxObjectSize is the size of the largest response stored, in bytes.
+	MaxObjectSize int64 `yaml:"max_object_size"`
+	// DiskPath is the directory of the on-disk tier, which holds the
+	// responses evicted from memory. The tier is disabled when it is empty.
+	DiskPath    string `yaml:"disk_path"`
+	MaxDiskSize int64  `yaml:"max_disk_size"`
+}
+
+var defaultCacheConfig = CacheConfig{
+	MaxMemorySize: 64 * 1024 * 1024,
+	MaxObjectSize: 1024 * 1024,
+	MaxDiskSize:   1024 * 1024 * 1024,
+}
+
+// RateLimitConfig bounds the rate of requests with a token bucket per key.
+// Routes may override the rate, burst and key with registration tags.
+type RateLimitConfig struct {
+	// Rate is the number of requests per second allowed for each key, and
+	// Burst the number allowed at once. Rate limiting is disabled while Rate
+	// is zero. A zero Burst allows the rate rounded up.
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+	Key   string  `yaml:"key"`
+	// MaxKeys bounds the number of buckets kept. The buckets of the least
+	// recently seen keys are dropped first.
+	MaxKeys int `yaml:"max_keys"`
+}
+
+var defaultRateLimitConfig = RateLimitConfig{
+	Key:     RATE_LIMIT_KEY_CLIENT_IP,
+	MaxKeys: 100000,
+}
+
+// ListenerConfig bounds how long client connections of a listener may
+// take to send requests and receive responses. Zero disables a timeout.
+type ListenerConfig struct {
+	// ReadHeaderTimeout bounds the time to read the request line and
+	// headers. It includes the TLS handshake of new connections.
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	// ReadTimeout bounds the time to read a whole request, body included.
+	ReadTimeout time.Duration `yaml:"read_timeout"`
+	// WriteTimeout bounds the time from the end of the request headers to
+	// the end of the response.
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	// IdleTimeout bounds the time a keep-alive connection waits for its next
+	// request. Zero uses the endpoint timeout.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+}
+
+var defaultListenerConfig = ListenerConfig{
+	ReadHeaderTimeout: 30 * time.Second,
+}
+
+// HeaderRule changes a header of the requests sent to, or the responses
+// received from, backends and route services. Set replaces the values of
+// the header with Value, add appends Value, remove deletes the header and
+// rename moves its values to NewName. Response rules with StatusCodes only
+// apply to responses with one of those codes.
+type HeaderRule struct {
+	On          string `yaml:"on" json:"on"`
+	Action      string `yaml:"action" json:"action"`
+	Name        string `yaml:"name" json:"name"`
+	Value       string `yaml:"value" json:"value,omitempty"`
+	NewName     string `yaml:"new_name" json:"new_name,omitempty"`
+	StatusCodes []int  `yaml:"status_codes" json:"status_codes,omitempty"`
+}
+
+// Validate returns an error describing the first problem of the rule.
+func (r HeaderRule) Validate() error {
+	if r.On != HEADER_RULE_REQUEST && r.On != HEADER_RULE_RESPONSE {
+		return fmt.Errorf("invalid header rule %+v: on must be %s or %s", r, HEADER_RULE_REQUEST, HEADER_RULE_RESPONSE)
+	}
+	if !isOneOf(r.Action, HeaderRuleActions) {
+		return fmt.Errorf("invalid header rule %+v: action must be one of %s", r, HeaderRuleActions)
+	}
+	if r.Name == "" {
+		return fmt.Errorf("invalid header rule %+v: name is required", r)
+	}
+	if r.Action == HEADER_RULE_RENAME && r.NewName == "" {
+		return fmt.Errorf("invalid header rule %+v: new_name is required to rename a header", r)
+	}
+	if len(r.StatusCodes) > 0 && r.On != HEADER_RULE_RESPONSE {
+		return fmt.Errorf("invalid header rule %+v: status_codes only apply to responses", r)
+	}
+	for _, code := range r.StatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid header rule %+v: invalid status code %d", r, code)
+		}
+	}
+	return nil
+}
+
+type CircuitBreakerConfig struct {
+	MaxConcurrentRequests int           `yaml:"max_concurrent_requests"`
+	MaxPendingRequests    int           `yaml:"max_pending_requests"`
+	PendingTimeout        time.Duration `yaml:"pending_timeout"`
+}
+
+var defaultCircuitBreakerConfig = CircuitBreakerConfig{
+	PendingTimeout: time.Second,
+}
+
+// TLSPem is a certificate and private key pair served on the SSL port.
+type TLSPem struct {
+	CertPath string `yaml:"cert_path"`
+	KeyPath  string `yaml:"key_path"`
+}
+
+type BackendConfig struct {
+	// CACertsPath is a PEM bundle of the authorities the certificates of
+	// endpoints registered with a TLS port are verified against. The system
+	// roots are used when it is empty.
+	CACertsPath string `yaml:"ca_certs_path"`
+
+	// CAPool is populated by the `Process` function.
+	CAPool *x509.CertPool `yaml:"-"`
+}
+
 type Config struct {
 	Status                   StatusConfig  `yaml:"status"`
 	Nats                     []NatsConfig  `yaml:"nats"`
@@ -102,15 +366,38 @@ type Config struct {
 	EnablePROXY              bool          `yaml:"enable_proxy"`
 	EnableSSL                bool          `yaml:"enable_ssl"`
 	SSLPort                  uint16        `yaml:"ssl_port"`
+	EnableHTTP2              bool          `yaml:"enable_http2"`
 	SSLCertPath              string        `yaml:"ssl_cert_path"`
 	SSLKeyPath               string        `yaml:"ssl_key_path"`
 	SSLCertificate           tls.Certificate
+	TLSCertificates          []TLSPem `yaml:"tls_certificates"`
+	TLSCertificatesDir       string   `yaml:"tls_certificates_dir"`
+	DefaultTLSCertificate    string   `yaml:"default_tls_certificate"`
+	SSLCertificates          []tls.Certificate
 	SkipSSLValidation        bool `yaml:"skip_ssl_validation"`
 	ForceForwardedProtoHttps bool `yaml:"force_forwarded_proto_https"`
 
 	CipherString string `yaml:"cipher_suites"`
 	CipherSuites []uint16
 
+	// MinTLSVersionString and MaxTLSVersionString bound the TLS versions the
+	// SSL port negotiates, e.g. "TLSv1.2". An empty maximum allows the highest
+	// version Go supports. CurveString lists the elliptic curves of ECDHE
+	// handshakes by preference, separated by colons.
+	MinTLSVersionString string `yaml:"min_tls_version"`
+	MaxTLSVersionString string `yaml:"max_tls_version"`
+	CurveString         string `yaml:"curve_preferences"`
+	// These fields are populated by the `Process` function.
+	MinTLSVersion    uint16        `yaml:"-"`
+	MaxTLSVersion    uint16        `yaml:"-"`
+	CurvePreferences []tls.CurveID `yaml:"-"`
+
+	ClientCertValidation string `yaml:"client_cert_validation"`
+	ClientCACertsPath    string `yaml:"client_ca_certs_path"`
+	ForwardedClientCert  string `yaml:"forwarded_client_cert"`
+	// ClientCAPool is populated by the `Process` function.
+	ClientCAPool *x509.CertPool `yaml:"-"`
+
 	LoadBalancerHealthyThreshold    time.Duration `yaml:"load_balancer_healthy_threshold"`
 	PublishStartMessageInterval     time.Duration `yaml:"publish_start_message_interval"`
 	SuspendPruningIfNatsUnavailable bool          `yaml:"suspend_pruning_if_nats_unavailable"`
@@ -143,8 +430,83 @@ type Config struct {
 	TokenFetcherRetryInterval                 time.Duration `yaml:"token_fetcher_retry_interval"`
 	TokenFetcherExpirationBufferTimeInSeconds int64         `yaml:"token_fetcher_expiration_buffer_time"`
 
-	PidFile     string `yaml:"pid_file"`
-	LoadBalance string `yaml:"balancing_algorithm"`
+	PidFile           string `yaml:"pid_file"`
+	LoadBalance       string `yaml:"balancing_algorithm"`
+	ConsistentHashKey string `yaml:"consistent_hash_key"`
+
+	// TrustedProxies lists the addresses and CIDR ranges of the load
+	// balancers in front of the router. The client IP of requests they send,
+	// which consistent hashing and rate limiting are keyed on, is taken from
+	// X-Forwarded-For.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// TrustedProxyNets is populated by the `Process` function.
+	TrustedProxyNets []*net.IPNet `yaml:"-"`
+
+	// ZoneSpilloverThreshold is the share of available endpoints of a route
+	// that must be in Zone for requests to stay within the zone.
+	ZoneSpilloverThreshold float64 `yaml:"zone_spillover_threshold"`
+
+	// SlowStartDuration is the time over which a newly registered endpoint
+	// ramps up to its full share of traffic. Zero disables slow start.
+	SlowStartDuration time.Duration `yaml:"slow_start_duration"`
+
+	// OutlierDetection ejects endpoints after consecutive 5xx responses or
+	// timeouts. It is disabled while ConsecutiveErrors is zero.
+	OutlierDetection OutlierDetectionConfig `yaml:"outlier_detection"`
+
+	// ActiveHealthCheck probes endpoints registered with a health check path.
+	ActiveHealthCheck ActiveHealthCheckConfig `yaml:"active_health_check"`
+
+	// CircuitBreaker limits concurrent requests per endpoint. It is disabled
+	// while MaxConcurrentRequests is zero.
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+
+	// RetryPolicy decides which failed requests are retried on another
+	// endpoint. Routes may override it with registration tags.
+	RetryPolicy RetryPolicyConfig `yaml:"retry_policy"`
+
+	// Backends sets how the router connects to endpoints registered with a
+	// TLS port.
+	Backends BackendConfig `yaml:"backends"`
+
+	// HeaderRules are applied to every route, before the rules of the route.
+	HeaderRules []HeaderRule `yaml:"header_rules"`
+
+	// Compression compresses responses of backends for clients that accept
+	// it. Routes may enable or disable it with a registration tag.
+	Compression CompressionConfig `yaml:"compression"`
+
+	// Cache stores responses of backends for routes that opt in to caching.
+	Cache CacheConfig `yaml:"cache"`
+
+	// RateLimit rejects requests above a rate per client IP, route or app.
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+
+	// MaxHeaderBytes bounds the size of the request line and headers of a
+	// request. Larger requests are rejected with a 431. Requests more than
+	// 4 KB over the limit are rejected by the servers of the router before
+	// they reach the proxy.
+	MaxHeaderBytes int `yaml:"max_header_bytes"`
+
+	// MaxRequestBodySize bounds the size in bytes of request bodies. Larger
+	// bodies are rejected with a 413. Zero disables the limit. Routes may
+	// override it with a registration tag.
+	MaxRequestBodySize int64 `yaml:"max_request_body_size"`
+
+	// HTTPListener and HTTPSListener set the timeouts of client connections
+	// to the HTTP and SSL ports.
+	HTTPListener  ListenerConfig `yaml:"http_listener"`
+	HTTPSListener ListenerConfig `yaml:"https_listener"`
+
+	// MaxConnsPerClientIP bounds the number of connections each client IP
+	// may hold open across both ports. Zero disables the limit.
+	MaxConnsPerClientIP int `yaml:"max_conns_per_client_ip"`
+
+	// TLSCertificatesPollInterval is how often the files of the certificates
+	// served on the SSL port are checked for changes. Zero disables polling;
+	// certificates are also reloaded on SIGHUP.
+	TLSCertificatesPollInterval time.Duration `yaml:"tls_certificates_poll_interval"`
 
 	DisableKeepAlives   bool `yaml:"disable_keep_alives"`
 	MaxIdleConns        int  `yaml:"max_idle_conns"`
@@ -163,6 +525,12 @@ var defaultConfig = Config{
 	EnableSSL:   false,
 	SSLPort:     443,
 
+	MinTLSVersionString: "TLSv1.2",
+
+	TLSCertificatesPollInterval: 30 * time.Second,
+	ClientCertValidation:        CLIENT_CERT_VALIDATION_NONE,
+	ForwardedClientCert:         SANITIZE_SET,
+
 	EndpointTimeout:     60 * time.Second,
 	RouteServiceTimeout: 60 * time.Second,
 
@@ -177,6 +545,17 @@ var defaultConfig = Config{
 
 	HealthCheckUserAgent: "HTTP-Monitor/1.1",
 	LoadBalance:          LOAD_BALANCE_RR,
+	ConsistentHashKey:    HASH_KEY_CLIENT_IP,
+	OutlierDetection:     defaultOutlierDetectionConfig,
+	ActiveHealthCheck:    defaultActiveHealthCheckConfig,
+	CircuitBreaker:       defaultCircuitBreakerConfig,
+	RetryPolicy:          defaultRetryPolicyConfig,
+	Compression:          defaultCompressionConfig,
+	Cache:                defaultCacheConfig,
+	RateLimit:            defaultRateLimitConfig,
+	MaxHeaderBytes:       1 << 20,
+	HTTPListener:         defaultListenerConfig,
+	HTTPSListener:        defaultListenerConfig,
 
 	DisableKeepAlives:   true,
 	MaxIdleConns:        100,
@@ -220,11 +599,58 @@ func (c *Config) Process() {
 
 	if c.EnableSSL {
 		c.CipherSuites = c.processCipherSuites()
-		cert, err := tls.LoadX509KeyPair(c.SSLCertPath, c.SSLKeyPath)
+		certs, err := c.LoadTLSCertificates()
 		if err != nil {
 			panic(err)
 		}
-		c.SSLCertificate = cert
+		if len(certs) == 0 {
+			panic("No TLS certificates configured. Set ssl_cert_path, tls_certificates or tls_certificates_dir")
+		}
+		if c.SSLCertPath != "" {
+			c.SSLCertificate = certs[0]
+		}
+		c.SSLCertificates = certs
+		c.checkCertificateKeys()
+	}
+
+	c.processTLSVersions()
+	c.CurvePreferences = c.processCurvePreferences()
+
+	if !isOneOf(c.ClientCertValidation, ClientCertValidations) {
+		errMsg := fmt.Sprintf("Invalid client cert validation %s. Allowed values are %s", c.ClientCertValidation, ClientCertValidations)
+		panic(errMsg)
+	}
+
+	if c.ClientCertValidation != CLIENT_CERT_VALIDATION_NONE {
+		if c.ClientCACertsPath == "" {
+			panic("client_ca_certs_path is required to verify client certificates")
+		}
+		caCerts, err := ioutil.ReadFile(c.ClientCACertsPath)
+		if err != nil {
+			panic(err)
+		}
+		c.ClientCAPool = x509.NewCertPool()
+		if !c.ClientCAPool.AppendCertsFromPEM(caCerts) {
+			errMsg := fmt.Sprintf("No certificates found in client CA bundle %s", c.ClientCACertsPath)
+			panic(errMsg)
+		}
+	}
+
+	if !isOneOf(c.ForwardedClientCert, ForwardedClientCertModes) {
+		errMsg := fmt.Sprintf("Invalid forwarded client cert mode %s. Allowed values are %s", c.ForwardedClientCert, ForwardedClientCertModes)
+		panic(errMsg)
+	}
+
+	if c.Backends.CACertsPath != "" {
+		caCerts, err := ioutil.ReadFile(c.Backends.CACertsPath)
+		if err != nil {
+			panic(err)
+		}
+		c.Backends.CAPool = x509.NewCertPool()
+		if !c.Backends.CAPool.AppendCertsFromPEM(caCerts) {
+			errMsg := fmt.Sprintf("No certificates found in backend CA bundle %s", c.Backends.CACertsPath)
+			panic(errMsg)
+		}
 	}
 
 	if c.RouteServiceSecret != "" {
@@ -232,30 +658,308 @@ func (c *Config) Process() {
 	}
 
 	// check if valid load balancing strategy
-	validLb := false
-	for _, lb := range LoadBalancingStrategies {
-		if c.LoadBalance == lb {
-			validLb = true
-			break
+	if !IsLoadBalancingAlgorithmValid(c.LoadBalance) {
+		errMsg := fmt.Sprintf("Invalid load balancing algorithm %s. Allowed values are %s", c.LoadBalance, LoadBalancingStrategies)
+		panic(errMsg)
+	}
+
+	if !isHashKeyValid(c.ConsistentHashKey) {
+		errMsg := fmt.Sprintf("Invalid consistent hash key %s. Allowed values are %s, %s<name> and %s<name>",
+			c.ConsistentHashKey, HASH_KEY_CLIENT_IP, HASH_KEY_HEADER_PREFIX, HASH_KEY_COOKIE_PREFIX)
+		panic(errMsg)
+	}
+
+	if c.ZoneSpilloverThreshold < 0 || c.ZoneSpilloverThreshold > 1 {
+		errMsg := fmt.Sprintf("Invalid zone spillover threshold %v. It must be between 0 and 1", c.ZoneSpilloverThreshold)
+		panic(errMsg)
+	}
+
+	if c.SlowStartDuration < 0 {
+		errMsg := fmt.Sprintf("Invalid slow start duration %s. It must not be negative", c.SlowStartDuration)
+		panic(errMsg)
+	}
+
+	if c.OutlierDetection.ConsecutiveErrors < 0 ||
+		c.OutlierDetection.MaxEjectionPercent < 0 || c.OutlierDetection.MaxEjectionPercent > 100 {
+		errMsg := fmt.Sprintf("Invalid outlier detection config %+v", c.OutlierDetection)
+		panic(errMsg)
+	}
+
+	if hc := c.ActiveHealthCheck; hc.Enabled &&
+		(hc.Interval <= 0 || hc.Timeout <= 0 || hc.HealthyThreshold < 1 || hc.UnhealthyThreshold < 1 || hc.MaxConcurrency < 1) {
+		errMsg := fmt.Sprintf("Invalid active health check config %+v", hc)
+		panic(errMsg)
+	}
+
+	if cb := c.CircuitBreaker; cb.MaxConcurrentRequests < 0 || cb.MaxPendingRequests < 0 ||
+		(cb.MaxPendingRequests > 0 && cb.PendingTimeout <= 0) {
+		errMsg := fmt.Sprintf("Invalid circuit breaker config %+v", cb)
+		panic(errMsg)
+	}
+
+	if c.RetryPolicy.MaxAttempts < 1 || c.RetryPolicy.BudgetPercent < 0 || c.RetryPolicy.BudgetPercent > 100 || c.RetryPolicy.BudgetMinRetries < 0 {
+		errMsg := fmt.Sprintf("Invalid retry policy config %+v", c.RetryPolicy)
+		panic(errMsg)
+	}
+
+	for _, condition := range c.RetryPolicy.RetryOn {
+		if !IsRetryConditionValid(condition) {
+			errMsg := fmt.Sprintf("Invalid retry condition %s. Allowed values are %s", condition, RetryConditions)
+			panic(errMsg)
 		}
 	}
-	if !validLb {
-		errMsg := fmt.Sprintf("Invalid load balancing algorithm %s. Allowed values are %s", c.LoadBalance, LoadBalancingStrategies)
+
+	if c.Compression.MinSize < 0 {
+		errMsg := fmt.Sprintf("Invalid compression min size %d. It must not be negative", c.Compression.MinSize)
+		panic(errMsg)
+	}
+
+	if c.Cache.MaxMemorySize < 0 || c.Cache.MaxObjectSize < 0 || c.Cache.MaxDiskSize < 0 {
+		errMsg := fmt.Sprintf("Invalid cache sizes %+v. They must not be negative", c.Cache)
+		panic(errMsg)
+	}
+
+	if rl := c.RateLimit; rl.Rate < 0 || rl.Burst < 0 || rl.MaxKeys < 1 {
+		errMsg := fmt.Sprintf("Invalid rate limit config %+v", rl)
+		panic(errMsg)
+	}
+
+	if !IsRateLimitKeyValid(c.RateLimit.Key) {
+		errMsg := fmt.Sprintf("Invalid rate limit key %s. Allowed values are %s", c.RateLimit.Key, RateLimitKeys)
+		panic(errMsg)
+	}
+
+	if c.MaxHeaderBytes < 1 || c.MaxRequestBodySize < 0 {
+		errMsg := fmt.Sprintf("Invalid request size limits: max header bytes %d, max request body size %d", c.MaxHeaderBytes, c.MaxRequestBodySize)
 		panic(errMsg)
 	}
 
+	for _, l := range []ListenerConfig{c.HTTPListener, c.HTTPSListener} {
+		if l.ReadHeaderTimeout < 0 || l.ReadTimeout < 0 || l.WriteTimeout < 0 || l.IdleTimeout < 0 {
+			errMsg := fmt.Sprintf("Invalid listener timeouts %+v. They must not be negative", l)
+			panic(errMsg)
+		}
+	}
+
+	if c.MaxConnsPerClientIP < 0 {
+		errMsg := fmt.Sprintf("Invalid max connections per client IP %d. It must not be negative", c.MaxConnsPerClientIP)
+		panic(errMsg)
+	}
+
+	c.TrustedProxyNets = nil
+	for _, proxy := range c.TrustedProxies {
+		ipNet, err := parseIPNet(proxy)
+		if err != nil {
+			panic(err.Error())
+		}
+		c.TrustedProxyNets = append(c.TrustedProxyNets, ipNet)
+	}
+
+	for _, rule := range c.HeaderRules {
+		if err := rule.Validate(); err != nil {
+			panic(err.Error())
+		}
+	}
+
 	if c.RouterGroupName != "" && !c.RoutingApiEnabled() {
 		errMsg := fmt.Sprintf("Routing API must be enabled to assign Router Group")
 		panic(errMsg)
 	}
 }
 
-func (c *Config) processCipherSuites() []uint16 {
-	cipherMap := map[string]uint16{
-		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256": 0xc02f,
-		"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384": 0xc030,
+// IsLoadBalancingAlgorithmValid returns true if lb is one of the supported
+// LoadBalancingStrategies.
+func IsLoadBalancingAlgorithmValid(lb string) bool {
+	for _, strategy := range LoadBalancingStrategies {
+		if lb == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRetryConditionValid returns true if condition is one of the supported
+// RetryConditions.
+func IsRetryConditionValid(condition string) bool {
+	for _, c := range RetryConditions {
+		if condition == c {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRateLimitKeyValid returns true if key is one of the supported
+// RateLimitKeys.
+func IsRateLimitKeyValid(key string) bool {
+	return isOneOf(key, RateLimitKeys)
+}
+
+// parseIPNet reads a CIDR range, or a single address as a range of one.
+func parseIPNet(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trusted proxy %s: %s", value, err)
+		}
+		return ipNet, nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("Invalid trusted proxy %s", value)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+func isOneOf(value string, allowed []string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+func isHashKeyValid(key string) bool {
+	switch {
+	case key == HASH_KEY_CLIENT_IP:
+		return true
+	case strings.HasPrefix(key, HASH_KEY_HEADER_PREFIX):
+		return len(key) > len(HASH_KEY_HEADER_PREFIX)
+	case strings.HasPrefix(key, HASH_KEY_COOKIE_PREFIX):
+		return len(key) > len(HASH_KEY_COOKIE_PREFIX)
 	}
+	return false
+}
+
+// LoadTLSCertificates reads the certificates served on the SSL port from the
+// files listed by TLSPems.
+func (c *Config) LoadTLSCertificates() ([]tls.Certificate, error) {
+	pems, err := c.TLSPems()
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make([]tls.Certificate, 0, len(pems))
+	for _, pem := range pems {
+		cert, err := tls.LoadX509KeyPair(pem.CertPath, pem.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading TLS certificate %s: %s", pem.CertPath, err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// TLSPems lists the certificate files served on the SSL port: the
+// ssl_cert_path pair, the tls_certificates pairs, and the pairs of
+// <name>.crt and <name>.key files in tls_certificates_dir sorted by name.
+func (c *Config) TLSPems() ([]TLSPem, error) {
+	pems := []TLSPem{}
+	if c.SSLCertPath != "" {
+		pems = append(pems, TLSPem{CertPath: c.SSLCertPath, KeyPath: c.SSLKeyPath})
+	}
+	pems = append(pems, c.TLSCertificates...)
+
+	if c.TLSCertificatesDir != "" {
+		certPaths, err := filepath.Glob(filepath.Join(c.TLSCertificatesDir, "*.crt"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(certPaths)
+		for _, certPath := range certPaths {
+			keyPath := strings.TrimSuffix(certPath, ".crt") + ".key"
+			pems = append(pems, TLSPem{CertPath: certPath, KeyPath: keyPath})
+		}
+	}
+	return pems, nil
+}
+
+// cipherSuites maps the IANA and OpenSSL names of the cipher suites the SSL
+// port supports to their IDs. RC4 suites are left out as insecure.
+var cipherSuites = map[string]uint16{
+	"TLS_RSA_WITH_3DES_EDE_CBC_SHA":                 tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_CBC_SHA":                  tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":                  tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_CBC_SHA256":               tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":               tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":               tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA":           tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256":       tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256":         tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":       tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384":       tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":          tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305":        tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256":   tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256": tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+
+	"DES-CBC3-SHA":                  tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+	"AES128-SHA":                    tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"AES256-SHA":                    tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"AES128-SHA256":                 tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+	"AES128-GCM-SHA256":             tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"AES256-GCM-SHA384":             tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"ECDHE-ECDSA-AES128-SHA":        tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"ECDHE-ECDSA-AES256-SHA":        tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"ECDHE-RSA-DES-CBC3-SHA":        tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+	"ECDHE-RSA-AES128-SHA":          tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"ECDHE-RSA-AES256-SHA":          tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"ECDHE-ECDSA-AES128-SHA256":     tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+	"ECDHE-RSA-AES128-SHA256":       tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+	"ECDHE-RSA-AES128-GCM-SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"ECDHE-ECDSA-AES128-GCM-SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"ECDHE-RSA-AES256-GCM-SHA384":   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"ECDHE-ECDSA-AES256-GCM-SHA384": tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"ECDHE-RSA-CHACHA20-POLY1305":   tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"ECDHE-ECDSA-CHACHA20-POLY1305": tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+}
+
+// ecdsaCipherSuites are the cipher suites that authenticate the server with
+// an ECDSA certificate; the others need an RSA certificate.
+var ecdsaCipherSuites = map[uint16]bool{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA:    true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA:    true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256: true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256: true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384: true,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305:  true,
+}
+
+// IsECDSACipherSuite returns true if suite authenticates the server with an
+// ECDSA certificate.
+func IsECDSACipherSuite(suite uint16) bool {
+	return ecdsaCipherSuites[suite]
+}
 
+var tlsVersions = map[string]uint16{
+	"TLSv1.0": tls.VersionTLS10,
+	"TLSv1.1": tls.VersionTLS11,
+	"TLSv1.2": tls.VersionTLS12,
+}
+
+var curves = map[string]tls.CurveID{
+	"X25519":     tls.X25519,
+	"P-256":      tls.CurveP256,
+	"prime256v1": tls.CurveP256,
+	"secp256r1":  tls.CurveP256,
+	"P-384":      tls.CurveP384,
+	"secp384r1":  tls.CurveP384,
+	"P-521":      tls.CurveP521,
+	"secp521r1":  tls.CurveP521,
+}
+
+func (c *Config) processCipherSuites() []uint16 {
 	var ciphers []string
 
 	if len(strings.TrimSpace(c.CipherString)) == 0 {
@@ -264,19 +968,20 @@ func (c *Config) processCipherSuites() []uint16 {
 		ciphers = strings.Split(c.CipherString, ":")
 	}
 
-	return convertCipherStringToInt(ciphers, cipherMap)
+	return convertCipherStringToInt(ciphers, cipherSuites)
 }
 
 func convertCipherStringToInt(cipherStrs []string, cipherMap map[string]uint16) []uint16 {
 	ciphers := []uint16{}
 	for _, cipher := range cipherStrs {
-		if val, ok := cipherMap[cipher]; ok {
+		if val, ok := cipherMap[strings.TrimSpace(cipher)]; ok {
 			ciphers = append(ciphers, val)
 		} else {
 			var supportedCipherSuites = []string{}
 			for key, _ := range cipherMap {
 				supportedCipherSuites = append(supportedCipherSuites, key)
 			}
+			sort.Strings(supportedCipherSuites)
 			errMsg := fmt.Sprintf("invalid cipher string configuration: %s, please choose from %v", cipher, supportedCipherSuites)
 			panic(errMsg)
 		}
@@ -285,6 +990,97 @@ func convertCipherStringToInt(cipherStrs []string, cipherMap map[string]uint16)
 	return ciphers
 }
 
+func (c *Config) processTLSVersions() {
+	c.MinTLSVersion = parseTLSVersion("min_tls_version", c.MinTLSVersionString)
+	c.MaxTLSVersion = parseTLSVersion("max_tls_version", c.MaxTLSVersionString)
+
+	if c.MaxTLSVersion != 0 && c.MinTLSVersion > c.MaxTLSVersion {
+		errMsg := fmt.Sprintf("min_tls_version %s is greater than max_tls_version %s", c.MinTLSVersionString, c.MaxTLSVersionString)
+		panic(errMsg)
+	}
+}
+
+// parseTLSVersion returns 0, the lowest or highest version Go supports, for
+// an empty version.
+func parseTLSVersion(key, version string) uint16 {
+	if version == "" {
+		return 0
+	}
+
+	v, ok := tlsVersions[version]
+	if !ok {
+		errMsg := fmt.Sprintf("Invalid %s %s. Allowed values are %s", key, version, sortedKeys(tlsVersions))
+		panic(errMsg)
+	}
+	return v
+}
+
+func (c *Config) processCurvePreferences() []tls.CurveID {
+	if strings.TrimSpace(c.CurveString) == "" {
+		return nil
+	}
+
+	prefs := []tls.CurveID{}
+	for _, name := range strings.Split(c.CurveString, ":") {
+		curve, ok := curves[strings.TrimSpace(name)]
+		if !ok {
+			var supported []string
+			for key := range curves {
+				supported = append(supported, key)
+			}
+			sort.Strings(supported)
+			errMsg := fmt.Sprintf("Invalid curve %s in curve_preferences. Allowed values are %s", name, supported)
+			panic(errMsg)
+		}
+		prefs = append(prefs, curve)
+	}
+	return prefs
+}
+
+// checkCertificateKeys makes sure each certificate can be served with one of
+// the cipher suites: ECDSA certificates need an ECDHE-ECDSA suite and RSA
+// certificates one of the others.
+func (c *Config) checkCertificateKeys() {
+	var ecdsaSuite, rsaSuite bool
+	for _, suite := range c.CipherSuites {
+		if IsECDSACipherSuite(suite) {
+			ecdsaSuite = true
+		} else {
+			rsaSuite = true
+		}
+	}
+
+	pems, _ := c.TLSPems()
+	for i, cert := range c.SSLCertificates {
+		name := fmt.Sprintf("%d", i)
+		if i < len(pems) {
+			name = pems[i].CertPath
+		}
+
+		switch cert.PrivateKey.(type) {
+		case *ecdsa.PrivateKey:
+			if !ecdsaSuite {
+				errMsg := fmt.Sprintf("TLS certificate %s has an ECDSA key but cipher_suites has no ECDHE-ECDSA suite", name)
+				panic(errMsg)
+			}
+		case *rsa.PrivateKey:
+			if !rsaSuite {
+				errMsg := fmt.Sprintf("TLS certificate %s has an RSA key but cipher_suites only has ECDHE-ECDSA suites", name)
+				panic(errMsg)
+			}
+		}
+	}
+}
+
+func sortedKeys(m map[string]uint16) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (c *Config) NatsServers() []string {
 	var natsServers []string
 	for _, info := range c.Nats {
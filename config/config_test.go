@@ -2,8 +2,12 @@ package config_test
 
 import (
 	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	. "code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/test_util"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -39,6 +43,542 @@ balancing_algorithm: least-connection
 				cfg := DefaultConfig()
 				var b = []byte(`
 balancing_algorithm: foo-bar
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("keys consistent hashing on the client ip by default", func() {
+				Expect(config.ConsistentHashKey).To(Equal(HASH_KEY_CLIENT_IP))
+			})
+
+			It("can key consistent hashing on a header or a cookie", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+balancing_algorithm: consistent-hash
+consistent_hash_key: header:X-User-Id
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.LoadBalance).To(Equal(LOAD_BALANCE_CH))
+				Expect(cfg.ConsistentHashKey).To(Equal("header:X-User-Id"))
+
+				cfg = DefaultConfig()
+				b = []byte(`
+consistent_hash_key: cookie:session
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).ToNot(Panic())
+			})
+
+			It("sets the trusted proxies", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.TrustedProxies).To(BeEmpty())
+
+				var b = []byte(`
+trusted_proxies: [10.0.0.0/8, 192.168.1.1, "fd00::/8"]
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.TrustedProxyNets).To(HaveLen(3))
+				Expect(cfg.TrustedProxyNets[0].String()).To(Equal("10.0.0.0/8"))
+				Expect(cfg.TrustedProxyNets[1].String()).To(Equal("192.168.1.1/32"))
+				Expect(cfg.TrustedProxyNets[2].String()).To(Equal("fd00::/8"))
+			})
+
+			It("does not allow an invalid trusted proxy", func() {
+				cfg := DefaultConfig()
+				cfg.TrustedProxies = []string{"10.0.0.0/33"}
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("can set the zone spillover threshold", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+zone: z1
+zone_spillover_threshold: 0.25
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.Zone).To(Equal("z1"))
+				Expect(cfg.ZoneSpilloverThreshold).To(Equal(0.25))
+			})
+
+			It("does not allow a zone spillover threshold above 1", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+zone_spillover_threshold: 1.5
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("can set the slow start duration", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.SlowStartDuration).To(BeZero())
+
+				var b = []byte(`
+slow_start_duration: 30s
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.SlowStartDuration).To(Equal(30 * time.Second))
+			})
+
+			It("disables outlier detection by default", func() {
+				Expect(config.OutlierDetection.ConsecutiveErrors).To(BeZero())
+				Expect(config.OutlierDetection.BaseEjectionTime).To(Equal(30 * time.Second))
+				Expect(config.OutlierDetection.MaxEjectionTime).To(Equal(5 * time.Minute))
+				Expect(config.OutlierDetection.MaxEjectionPercent).To(Equal(10))
+			})
+
+			It("can configure outlier detection", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+outlier_detection:
+  consecutive_errors: 5
+  base_ejection_time: 10s
+  max_ejection_time: 1m
+  max_ejection_percent: 30
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.OutlierDetection).To(Equal(OutlierDetectionConfig{
+					ConsecutiveErrors:  5,
+					BaseEjectionTime:   10 * time.Second,
+					MaxEjectionTime:    time.Minute,
+					MaxEjectionPercent: 30,
+				}))
+			})
+
+			It("does not allow an ejection percentage above 100", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+outlier_detection:
+  max_ejection_percent: 150
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("disables active health checking by default", func() {
+				Expect(config.ActiveHealthCheck.Enabled).To(BeFalse())
+				Expect(config.ActiveHealthCheck.Interval).To(Equal(10 * time.Second))
+				Expect(config.ActiveHealthCheck.Timeout).To(Equal(2 * time.Second))
+				Expect(config.ActiveHealthCheck.HealthyThreshold).To(Equal(2))
+				Expect(config.ActiveHealthCheck.UnhealthyThreshold).To(Equal(3))
+				Expect(config.ActiveHealthCheck.MaxConcurrency).To(Equal(100))
+			})
+
+			It("can configure active health checking", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+active_health_check:
+  enabled: true
+  interval: 5s
+  timeout: 1s
+  healthy_threshold: 1
+  unhealthy_threshold: 2
+  max_concurrency: 10
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.ActiveHealthCheck).To(Equal(ActiveHealthCheckConfig{
+					Enabled:            true,
+					Interval:           5 * time.Second,
+					Timeout:            time.Second,
+					HealthyThreshold:   1,
+					UnhealthyThreshold: 2,
+					MaxConcurrency:     10,
+				}))
+			})
+
+			It("does not allow an invalid active health check interval", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+active_health_check:
+  enabled: true
+  interval: 0s
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("disables the circuit breaker by default", func() {
+				Expect(config.CircuitBreaker).To(Equal(CircuitBreakerConfig{
+					PendingTimeout: time.Second,
+				}))
+			})
+
+			It("can configure the circuit breaker", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+circuit_breaker:
+  max_concurrent_requests: 100
+  max_pending_requests: 10
+  pending_timeout: 500ms
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.CircuitBreaker).To(Equal(CircuitBreakerConfig{
+					MaxConcurrentRequests: 100,
+					MaxPendingRequests:    10,
+					PendingTimeout:        500 * time.Millisecond,
+				}))
+			})
+
+			It("does not allow a negative concurrent request limit", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+circuit_breaker:
+  max_concurrent_requests: -1
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("retries connect failures and resets by default", func() {
+				Expect(config.RetryPolicy).To(Equal(RetryPolicyConfig{
+					MaxAttempts: 3,
+					RetryOn:     []string{RETRY_ON_CONNECT_FAILURE, RETRY_ON_RESET},
+				}))
+			})
+
+			It("can configure the retry policy", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+retry_policy:
+  max_attempts: 2
+  retry_on: [connect-failure, "503", idempotent-only]
+  budget_percent: 20
+  budget_min_retries: 5
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.RetryPolicy).To(Equal(RetryPolicyConfig{
+					MaxAttempts:      2,
+					RetryOn:          []string{RETRY_ON_CONNECT_FAILURE, "503", RETRY_ON_IDEMPOTENT_ONLY},
+					BudgetPercent:    20,
+					BudgetMinRetries: 5,
+				}))
+			})
+
+			It("does not allow an invalid retry condition", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+retry_policy:
+  retry_on: [timeout]
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("does not allow less than one attempt", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+retry_policy:
+  max_attempts: 0
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("loads the backend CA bundle", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+backends:
+  ca_certs_path: ../test/assets/certs/uaa-ca.pem
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.Backends.CAPool).NotTo(BeNil())
+				Expect(cfg.Backends.CAPool.Subjects()).To(HaveLen(1))
+			})
+
+			It("uses the system roots for backends by default", func() {
+				cfg := DefaultConfig()
+				cfg.Process()
+				Expect(cfg.Backends.CAPool).To(BeNil())
+			})
+
+			It("polls the TLS certificate files every 30 seconds by default", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.TLSCertificatesPollInterval).To(Equal(30 * time.Second))
+
+				var b = []byte(`
+tls_certificates_poll_interval: 0s
+`)
+				cfg.Initialize(b)
+				Expect(cfg.TLSCertificatesPollInterval).To(BeZero())
+			})
+
+			It("does not ask for client certificates and sanitizes forwarded ones by default", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.ClientCertValidation).To(Equal(CLIENT_CERT_VALIDATION_NONE))
+				Expect(cfg.ForwardedClientCert).To(Equal(SANITIZE_SET))
+				Expect(cfg.ClientCAPool).To(BeNil())
+			})
+
+			It("loads the client CA bundle when client certificates are verified", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+client_cert_validation: require
+client_ca_certs_path: ../test/assets/certs/uaa-ca.pem
+forwarded_client_cert: forward
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.ClientCertValidation).To(Equal(CLIENT_CERT_VALIDATION_REQUIRE))
+				Expect(cfg.ForwardedClientCert).To(Equal(FORWARD))
+				Expect(cfg.ClientCAPool).NotTo(BeNil())
+				Expect(cfg.ClientCAPool.Subjects()).To(HaveLen(1))
+			})
+
+			It("does not allow verifying client certificates without a CA bundle", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+client_cert_validation: request
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("does not allow an invalid client cert validation", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+client_cert_validation: optional
+client_ca_certs_path: ../test/assets/certs/uaa-ca.pem
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("does not allow an invalid forwarded client cert mode", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+forwarded_client_cert: strip
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("sets the header rules", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+header_rules:
+- on: response
+  action: set
+  name: Strict-Transport-Security
+  value: max-age=31536000
+- on: response
+  action: remove
+  name: Server
+  status_codes: [200, 404]
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.HeaderRules).To(Equal([]HeaderRule{
+					{On: HEADER_RULE_RESPONSE, Action: HEADER_RULE_SET, Name: "Strict-Transport-Security", Value: "max-age=31536000"},
+					{On: HEADER_RULE_RESPONSE, Action: HEADER_RULE_REMOVE, Name: "Server", StatusCodes: []int{200, 404}},
+				}))
+			})
+
+			It("does not allow a header rule without a name", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+header_rules:
+- on: request
+  action: remove
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("does not allow renaming a header without a new name", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+header_rules:
+- on: response
+  action: rename
+  name: Server
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("sets the compression config", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.Compression.Enabled).To(BeFalse())
+				Expect(cfg.Compression.MinSize).To(Equal(int64(1024)))
+				Expect(cfg.Compression.ContentTypes).To(ContainElement("application/json"))
+
+				var b = []byte(`
+compression:
+  enabled: true
+  min_size: 256
+  content_types: [application/json]
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.Compression).To(Equal(CompressionConfig{
+					Enabled:      true,
+					MinSize:      256,
+					ContentTypes: []string{"application/json"},
+				}))
+			})
+
+			It("does not allow a negative compression min size", func() {
+				cfg := DefaultConfig()
+				cfg.Compression.MinSize = -1
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("sets the cache config", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.Cache).To(Equal(CacheConfig{
+					MaxMemorySize: 64 * 1024 * 1024,
+					MaxObjectSize: 1024 * 1024,
+					MaxDiskSize:   1024 * 1024 * 1024,
+				}))
+
+				var b = []byte(`
+cache:
+  enabled: true
+  max_memory_size: 1048576
+  max_object_size: 65536
+  disk_path: /var/vcap/data/gorouter/cache
+  max_disk_size: 10485760
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.Cache).To(Equal(CacheConfig{
+					Enabled:       true,
+					MaxMemorySize: 1048576,
+					MaxObjectSize: 65536,
+					DiskPath:      "/var/vcap/data/gorouter/cache",
+					MaxDiskSize:   10485760,
+				}))
+			})
+
+			It("does not allow negative cache sizes", func() {
+				cfg := DefaultConfig()
+				cfg.Cache.MaxObjectSize = -1
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("sets the rate limit config", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.RateLimit.Rate).To(BeZero())
+				Expect(cfg.RateLimit.Key).To(Equal(RATE_LIMIT_KEY_CLIENT_IP))
+				Expect(cfg.RateLimit.MaxKeys).To(Equal(100000))
+
+				var b = []byte(`
+rate_limit:
+  rate: 2.5
+  burst: 10
+  key: app_id
+  max_keys: 1000
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.RateLimit.Rate).To(Equal(2.5))
+				Expect(cfg.RateLimit.Burst).To(Equal(10))
+				Expect(cfg.RateLimit.Key).To(Equal(RATE_LIMIT_KEY_APP_ID))
+				Expect(cfg.RateLimit.MaxKeys).To(Equal(1000))
+			})
+
+			It("does not allow an invalid rate limit key", func() {
+				cfg := DefaultConfig()
+				cfg.RateLimit.Key = "header"
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("does not allow negative rate limits", func() {
+				cfg := DefaultConfig()
+				cfg.RateLimit.Rate = -1
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("sets the request size limits", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.MaxHeaderBytes).To(Equal(1 << 20))
+				Expect(cfg.MaxRequestBodySize).To(BeZero())
+
+				var b = []byte(`
+max_header_bytes: 8192
+max_request_body_size: 1048576
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.MaxHeaderBytes).To(Equal(8192))
+				Expect(cfg.MaxRequestBodySize).To(Equal(int64(1048576)))
+			})
+
+			It("does not allow invalid request size limits", func() {
+				cfg := DefaultConfig()
+				cfg.MaxHeaderBytes = 0
+				Expect(cfg.Process).To(Panic())
+
+				cfg = DefaultConfig()
+				cfg.MaxRequestBodySize = -1
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("sets the listener timeouts", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.HTTPListener.ReadHeaderTimeout).To(Equal(30 * time.Second))
+				Expect(cfg.HTTPSListener.ReadHeaderTimeout).To(Equal(30 * time.Second))
+				Expect(cfg.HTTPListener.ReadTimeout).To(BeZero())
+				Expect(cfg.MaxConnsPerClientIP).To(BeZero())
+
+				var b = []byte(`
+http_listener:
+  read_header_timeout: 5s
+  read_timeout: 1m
+  write_timeout: 2m
+  idle_timeout: 90s
+https_listener:
+  read_header_timeout: 10s
+max_conns_per_client_ip: 100
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.HTTPListener).To(Equal(ListenerConfig{
+					ReadHeaderTimeout: 5 * time.Second,
+					ReadTimeout:       time.Minute,
+					WriteTimeout:      2 * time.Minute,
+					IdleTimeout:       90 * time.Second,
+				}))
+				Expect(cfg.HTTPSListener).To(Equal(ListenerConfig{ReadHeaderTimeout: 10 * time.Second}))
+				Expect(cfg.MaxConnsPerClientIP).To(Equal(100))
+			})
+
+			It("does not allow negative listener timeouts", func() {
+				cfg := DefaultConfig()
+				cfg.HTTPSListener.WriteTimeout = -time.Second
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("does not allow a negative max connections per client IP", func() {
+				cfg := DefaultConfig()
+				cfg.MaxConnsPerClientIP = -1
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("does not allow a backend CA bundle without certificates", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+backends:
+  ca_certs_path: ../test/assets/certs/server.key
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("does not allow an invalid consistent hash key", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+consistent_hash_key: header:
 `)
 				cfg.Initialize(b)
 				Expect(cfg.Process).To(Panic())
@@ -204,6 +744,7 @@ access_log:
     file: "/tmp/access_log"
 ssl_port: 4443
 enable_ssl: true
+enable_http2: true
 `)
 
 			err := config.Initialize(b)
@@ -217,6 +758,7 @@ enable_ssl: true
 			Expect(config.AccessLog.EnableStreaming).To(BeFalse())
 			Expect(config.EnableSSL).To(Equal(true))
 			Expect(config.SSLPort).To(Equal(uint16(4443)))
+			Expect(config.EnableHTTP2).To(BeTrue())
 			Expect(config.RouteServiceRecommendHttps).To(BeFalse())
 		})
 
@@ -711,6 +1253,86 @@ cipher_suites: TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
 				})
 			})
 
+			Context("When it is given a list of certificates", func() {
+				var b = []byte(`
+enable_ssl: true
+ssl_cert_path: ../test/assets/certs/server.pem
+ssl_key_path: ../test/assets/certs/server.key
+tls_certificates:
+- cert_path: ../test/assets/certs/server.pem
+  key_path: ../test/assets/certs/server.key
+default_tls_certificate: example.com
+cipher_suites: TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+`)
+
+				It("loads every certificate after the ssl_cert_path one", func() {
+					expectedCertificate, err := tls.LoadX509KeyPair("../test/assets/certs/server.pem", "../test/assets/certs/server.key")
+					Expect(err).ToNot(HaveOccurred())
+
+					err = config.Initialize(b)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(config.DefaultTLSCertificate).To(Equal("example.com"))
+
+					config.Process()
+					Expect(config.SSLCertificate).To(Equal(expectedCertificate))
+					Expect(config.SSLCertificates).To(Equal([]tls.Certificate{expectedCertificate, expectedCertificate}))
+				})
+			})
+
+			Context("When it is given a certificate directory", func() {
+				var dir string
+
+				BeforeEach(func() {
+					var err error
+					dir, err = ioutil.TempDir("", "certs")
+					Expect(err).ToNot(HaveOccurred())
+
+					cert, err := ioutil.ReadFile("../test/assets/certs/server.pem")
+					Expect(err).ToNot(HaveOccurred())
+					key, err := ioutil.ReadFile("../test/assets/certs/server.key")
+					Expect(err).ToNot(HaveOccurred())
+
+					for _, name := range []string{"b", "a"} {
+						Expect(ioutil.WriteFile(filepath.Join(dir, name+".crt"), cert, 0600)).To(Succeed())
+						Expect(ioutil.WriteFile(filepath.Join(dir, name+".key"), key, 0600)).To(Succeed())
+					}
+				})
+
+				AfterEach(func() {
+					os.RemoveAll(dir)
+				})
+
+				It("loads the certificate and key pairs of the directory", func() {
+					config.EnableSSL = true
+					config.CipherString = "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"
+					config.TLSCertificatesDir = dir
+
+					certs, err := config.LoadTLSCertificates()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(certs).To(HaveLen(2))
+
+					config.Process()
+					Expect(config.SSLCertificates).To(HaveLen(2))
+				})
+
+				It("fails when a certificate has no key", func() {
+					Expect(os.Remove(filepath.Join(dir, "a.key"))).To(Succeed())
+					config.TLSCertificatesDir = dir
+
+					_, err := config.LoadTLSCertificates()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("a.crt"))
+				})
+			})
+
+			Context("When it is given no certificate", func() {
+				It("panics", func() {
+					config.EnableSSL = true
+					config.CipherString = "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"
+					Expect(config.Process).To(Panic())
+				})
+			})
+
 			Context("When it is given valid cipher suites", func() {
 				var b = []byte(`
 enable_ssl: true
@@ -766,6 +1388,124 @@ cipher_suites: TLS_RSA_WITH_RC4_128_SHA
 				})
 			})
 
+			Context("When it is given OpenSSL cipher names", func() {
+				var b = []byte(`
+enable_ssl: true
+ssl_cert_path: ../test/assets/certs/server.pem
+ssl_key_path: ../test/assets/certs/server.key
+cipher_suites: ECDHE-RSA-AES128-GCM-SHA256:ECDHE-RSA-CHACHA20-POLY1305:AES256-SHA
+`)
+
+				It("constructs the same cipher suites as their IANA names", func() {
+					err := config.Initialize(b)
+					Expect(err).ToNot(HaveOccurred())
+
+					config.Process()
+
+					Expect(config.CipherSuites).To(Equal([]uint16{
+						tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+						tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+						tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+					}))
+				})
+			})
+
+			Context("When it is given an ECDSA certificate", func() {
+				var dir string
+
+				BeforeEach(func() {
+					var err error
+					dir, err = ioutil.TempDir("", "certs")
+					Expect(err).ToNot(HaveOccurred())
+
+					chain := test_util.CreateECDSASignedCertWithRootCA("example.com")
+					Expect(ioutil.WriteFile(filepath.Join(dir, "ecdsa.crt"), chain.CertPEM, 0600)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(dir, "ecdsa.key"), chain.KeyPEM, 0600)).To(Succeed())
+
+					config.EnableSSL = true
+					config.TLSCertificatesDir = dir
+				})
+
+				AfterEach(func() {
+					os.RemoveAll(dir)
+				})
+
+				It("accepts ECDHE-ECDSA cipher suites", func() {
+					config.CipherString = "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256:ECDHE-ECDSA-AES256-GCM-SHA384"
+					config.Process()
+
+					Expect(config.SSLCertificates).To(HaveLen(1))
+					Expect(config.CipherSuites).To(Equal([]uint16{
+						tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+						tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+					}))
+				})
+
+				It("panics without an ECDHE-ECDSA cipher suite", func() {
+					config.CipherString = "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"
+					Expect(config.Process).To(Panic())
+				})
+			})
+
+			It("panics when an RSA certificate has no RSA cipher suite", func() {
+				var b = []byte(`
+enable_ssl: true
+ssl_cert_path: ../test/assets/certs/server.pem
+ssl_key_path: ../test/assets/certs/server.key
+cipher_suites: TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process).To(Panic())
+			})
+
+			Context("When it is given TLS versions and curves", func() {
+				var b = []byte(`
+enable_ssl: true
+ssl_cert_path: ../test/assets/certs/server.pem
+ssl_key_path: ../test/assets/certs/server.key
+cipher_suites: TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+min_tls_version: TLSv1.1
+max_tls_version: TLSv1.2
+curve_preferences: X25519:prime256v1:P-384
+`)
+
+				It("constructs the TLS policy", func() {
+					err := config.Initialize(b)
+					Expect(err).ToNot(HaveOccurred())
+
+					config.Process()
+
+					Expect(config.MinTLSVersion).To(Equal(uint16(tls.VersionTLS11)))
+					Expect(config.MaxTLSVersion).To(Equal(uint16(tls.VersionTLS12)))
+					Expect(config.CurvePreferences).To(Equal([]tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384}))
+				})
+			})
+
+			It("defaults to TLS 1.2 or later and the curves of Go", func() {
+				config.Process()
+
+				Expect(config.MinTLSVersion).To(Equal(uint16(tls.VersionTLS12)))
+				Expect(config.MaxTLSVersion).To(BeZero())
+				Expect(config.CurvePreferences).To(BeNil())
+			})
+
+			It("panics on an invalid TLS version", func() {
+				config.MinTLSVersionString = "SSLv3"
+				Expect(config.Process).To(Panic())
+			})
+
+			It("panics when the minimum TLS version is above the maximum", func() {
+				config.MaxTLSVersionString = "TLSv1.1"
+				Expect(config.Process).To(Panic())
+			})
+
+			It("panics on an invalid curve", func() {
+				config.CurveString = "X25519:P-224"
+				Expect(config.Process).To(Panic())
+			})
+
 		})
 
 		Context("When given no cipher suites", func() {
@@ -48,6 +48,7 @@ func (a *accessLog) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http
 	alr.BodyBytesSent = proxyWriter.Size()
 	alr.FinishedAt = time.Now()
 	alr.StatusCode = proxyWriter.Status()
+	alr.GrpcStatus = grpcStatus(proxyWriter.Header())
 	a.accessLogger.Log(*alr)
 }
 
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/cache"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type responseCache struct {
+	store  *cache.Store
+	logger logger.Logger
+
+	lock sync.Mutex
+	// updating are the variants being revalidated in the background
+	updating map[string]bool
+}
+
+// NewCache creates a handler serving the requests of routes that opt in to
+// caching from store, and storing the responses of their backends. Requests
+// bound for a route service are not cached. Without a store every request
+// goes to the backends.
+func NewCache(store *cache.Store, logger logger.Logger) negroni.Handler {
+	return &responseCache{
+		store:    store,
+		logger:   logger,
+		updating: map[string]bool{},
+	}
+}
+
+func (c *responseCache) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	routePool, _ := r.Context().Value("RoutePool").(*route.Pool)
+	if c.store == nil || routePool == nil || !routePool.Caches() {
+		next(rw, r)
+		return
+	}
+
+	accessLog, _ := r.Context().Value("AccessLogRecord").(*schema.AccessLogRecord)
+	if accessLog == nil {
+		c.logger.Error("AccessLogRecord-not-set-on-context", zap.Error(errors.New("failed-to-access-log-record")))
+		next(rw, r)
+		return
+	}
+
+	if r.Context().Value(RouteServiceURLCtxKey) != nil || !cache.Cacheable(r) {
+		if cache.Invalidates(r) {
+			c.store.Invalidate(r)
+		}
+		accessLog.CacheStatus = cache.StatusBypass
+		next(rw, r)
+		return
+	}
+
+	now := time.Now()
+	entry := c.store.Lookup(r)
+	switch {
+	case entry == nil:
+		accessLog.CacheStatus = c.forward(rw, r, next, nil)
+	case entry.Fresh(r, now):
+		accessLog.CacheStatus = cache.StatusHit
+		serveEntry(rw, r, entry, now)
+	case entry.ServeWhileRevalidating(r, now):
+		accessLog.CacheStatus = cache.StatusUpdating
+		c.revalidate(r, entry, next)
+		serveEntry(rw, r, entry, now)
+	default:
+		accessLog.CacheStatus = c.forward(rw, r, next, entry)
+	}
+}
+
+// forward sends r to the backend, revalidating the stale entry if there is
+// one, and stores the response. It returns the cache status of the request.
+func (c *responseCache) forward(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc, entry *cache.Entry) string {
+	requestHeader := cloneHeader(r.Header)
+	requestURL := *r.URL
+
+	// the backend is asked to confirm the stale entry, unless the client
+	// asks it to confirm a response of its own
+	conditional := entry != nil && entry.HasValidators() && !cache.IsConditional(r)
+	if conditional {
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			r.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	status := cache.StatusMiss
+	if entry != nil {
+		status = cache.StatusExpired
+	}
+
+	requestTime := time.Now()
+	writer := newCacheWriter(rw.(utils.ProxyResponseWriter), c.store.MaxObjectSize(), func(statusCode int) (bool, bool) {
+		switch {
+		case conditional && statusCode == http.StatusNotModified:
+			status = cache.StatusRevalidated
+			return false, false
+		case entry != nil && isServerError(statusCode) && entry.ServeOnError(time.Now()):
+			status = cache.StatusStale
+			return false, false
+		}
+		return true, r.Method == "GET"
+	})
+	next(writer, r.WithContext(context.WithValue(r.Context(), ProxyResponseWriterCtxKey, writer)))
+	responseTime := time.Now()
+	if conditional {
+		r.Header.Del("If-None-Match")
+		r.Header.Del("If-Modified-Since")
+	}
+
+	switch status {
+	case cache.StatusRevalidated:
+		entry = entry.Revalidated(writer.header, requestTime, responseTime)
+		c.store.Put(entry)
+		serveEntry(rw, r, entry, responseTime)
+	case cache.StatusStale:
+		serveEntry(rw, r, entry, responseTime)
+	default:
+		if writer.recorded() {
+			recorded := &http.Request{Method: r.Method, Host: r.Host, URL: &requestURL, Header: requestHeader}
+			if e := cache.NewEntry(recorded, writer.status, writer.header, writer.body.Bytes(), requestTime, responseTime); e != nil {
+				c.store.Put(e)
+			}
+		}
+	}
+	return status
+}
+
+// revalidate refreshes the stale entry in the background. The request is
+// sent as if it came from r, which is answered from the cache meanwhile.
+func (c *responseCache) revalidate(r *http.Request, entry *cache.Entry, next http.HandlerFunc) {
+	c.lock.Lock()
+	if c.updating[entry.Variant] {
+		c.lock.Unlock()
+		return
+	}
+	c.updating[entry.Variant] = true
+	c.lock.Unlock()
+
+	url := *r.URL
+	background := &http.Request{
+		Method:     r.Method,
+		URL:        &url,
+		Proto:      r.Proto,
+		ProtoMajor: r.ProtoMajor,
+		ProtoMinor: r.ProtoMinor,
+		Header:     cloneHeader(r.Header),
+		Body:       http.NoBody,
+		Host:       r.Host,
+		RemoteAddr: r.RemoteAddr,
+		RequestURI: r.RequestURI,
+		TLS:        r.TLS,
+	}
+	background.Header.Del("If-None-Match")
+	background.Header.Del("If-Modified-Since")
+
+	// the client may be gone before the backend responds, so the request
+	// does not share its context
+	ctx := context.WithValue(context.Background(), "RoutePool", r.Context().Value("RoutePool"))
+	ctx = context.WithValue(ctx, "AccessLogRecord", &schema.AccessLogRecord{Request: background, StartedAt: time.Now()})
+	background = background.WithContext(ctx)
+
+	go func() {
+		defer func() {
+			c.lock.Lock()
+			delete(c.updating, entry.Variant)
+			c.lock.Unlock()
+		}()
+
+		status := c.forward(&backgroundWriter{header: http.Header{}}, background, next, entry)
+		c.logger.Debug("cache-revalidated-in-background", zap.String("url", entry.Key), zap.String("cache-status", status))
+	}()
+}
+
+// serveEntry writes the stored response, or a 304 Not Modified if it
+// satisfies the validators of r.
+func serveEntry(rw http.ResponseWriter, r *http.Request, entry *cache.Entry, now time.Time) {
+	header := rw.Header()
+	for name, values := range entry.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set("Age", strconv.FormatInt(int64(entry.Age(now)/time.Second), 10))
+
+	if entry.NotModified(r) {
+		header.Del("Content-Length")
+		header.Del("Content-Type")
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	rw.WriteHeader(entry.StatusCode)
+	if r.Method != "HEAD" {
+		rw.Write(entry.Body)
+	}
+}
+
+func isServerError(statusCode int) bool {
+	switch statusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func cloneHeader(header http.Header) http.Header {
+	clone := make(http.Header, len(header))
+	for name, values := range header {
+		clone[name] = append([]string(nil), values...)
+	}
+	return clone
+}
+
+// cacheWriter records the response of a backend. Once the status is known,
+// decide tells whether the response goes on to the client, and whether its
+// body is recorded for storage.
+type cacheWriter struct {
+	utils.ProxyResponseWriter
+	decide func(statusCode int) (forward bool, record bool)
+	limit  int64
+
+	header  http.Header
+	status  int
+	forward bool
+	record  bool
+	body    bytes.Buffer
+	done    bool
+}
+
+func newCacheWriter(w utils.ProxyResponseWriter, limit int64, decide func(int) (bool, bool)) *cacheWriter {
+	return &cacheWriter{
+		ProxyResponseWriter: w,
+		decide:              decide,
+		limit:               limit,
+		header:              http.Header{},
+	}
+}
+
+func (w *cacheWriter) Header() http.Header {
+	// trailers are set on the header of the client after the body
+	if w.forward {
+		return w.ProxyResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *cacheWriter) WriteHeader(s int) {
+	if w.done || w.status != 0 {
+		return
+	}
+	w.status = s
+	w.forward, w.record = w.decide(s)
+	if !w.forward {
+		return
+	}
+
+	clientHeader := w.ProxyResponseWriter.Header()
+	for name, values := range w.header {
+		clientHeader[name] = values
+	}
+	w.ProxyResponseWriter.WriteHeader(s)
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if w.done {
+		return 0, nil
+	}
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.record {
+		if int64(w.body.Len()+len(b)) > w.limit {
+			// too large to be stored
+			w.record = false
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(b)
+		}
+	}
+	if !w.forward {
+		return len(b), nil
+	}
+	return w.ProxyResponseWriter.Write(b)
+}
+
+func (w *cacheWriter) Flush() {
+	if w.forward {
+		w.ProxyResponseWriter.Flush()
+	}
+}
+
+func (w *cacheWriter) Done() {
+	w.done = true
+	if w.forward {
+		w.ProxyResponseWriter.Done()
+	}
+}
+
+func (w *cacheWriter) Status() int {
+	return w.status
+}
+
+// recorded returns true if the complete response was recorded.
+func (w *cacheWriter) recorded() bool {
+	return w.record && !w.done
+}
+
+// backgroundWriter discards the response to a background revalidation.
+type backgroundWriter struct {
+	header http.Header
+	status int
+	size   int
+}
+
+func (w *backgroundWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *backgroundWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("background requests cannot hijack")
+}
+
+func (w *backgroundWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.size += len(b)
+	return len(b), nil
+}
+
+func (w *backgroundWriter) WriteHeader(s int) {
+	if w.status == 0 {
+		w.status = s
+	}
+}
+
+func (w *backgroundWriter) Done() {}
+
+func (w *backgroundWriter) Flush() {}
+
+func (w *backgroundWriter) Status() int {
+	return w.status
+}
+
+func (w *backgroundWriter) Size() int {
+	return w.size
+}
+
+func (w *backgroundWriter) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
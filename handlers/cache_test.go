@@ -0,0 +1,170 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/cache"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("Cache", func() {
+	var (
+		handler negroni.Handler
+		store   *cache.Store
+
+		routePool    *route.Pool
+		cacheControl string
+		calls        int32
+	)
+
+	nextHandler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		call := atomic.AddInt32(&calls, 1)
+		rw.Header().Set("Cache-Control", cacheControl)
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte(fmt.Sprintf("response %d", call)))
+	})
+
+	serve := func(req *http.Request) (*httptest.ResponseRecorder, *schema.AccessLogRecord) {
+		resp := httptest.NewRecorder()
+		alr := &schema.AccessLogRecord{StartedAt: time.Now()}
+		ctx := context.WithValue(req.Context(), "AccessLogRecord", alr)
+		ctx = context.WithValue(ctx, "RoutePool", routePool)
+		handler.ServeHTTP(utils.NewProxyResponseWriter(resp), req.WithContext(ctx), nextHandler)
+		return resp, alr
+	}
+
+	get := func() (*httptest.ResponseRecorder, *schema.AccessLogRecord) {
+		req, err := http.NewRequest("GET", "http://example.com/path", nil)
+		Expect(err).ToNot(HaveOccurred())
+		return serve(req)
+	}
+
+	BeforeEach(func() {
+		var err error
+		store, err = cache.New(config.CacheConfig{Enabled: true, MaxMemorySize: 1024 * 1024, MaxObjectSize: 1024})
+		Expect(err).ToNot(HaveOccurred())
+		handler = handlers.NewCache(store, new(logger_fakes.FakeLogger))
+
+		routePool = route.NewPool(1*time.Second, "")
+		routePool.Put(route.NewEndpoint("appID", "1.1.1.1", 1234, "id", "1",
+			map[string]string{route.CacheTag: "on"}, 0, "", models.ModificationTag{}))
+
+		cacheControl = "max-age=60"
+		atomic.StoreInt32(&calls, 0)
+	})
+
+	It("stores responses and serves them while fresh", func() {
+		resp, alr := get()
+		Expect(resp.Body.String()).To(Equal("response 1"))
+		Expect(alr.CacheStatus).To(Equal(cache.StatusMiss))
+
+		resp, alr = get()
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body.String()).To(Equal("response 1"))
+		Expect(resp.Header().Get("Cache-Control")).To(Equal("max-age=60"))
+		Expect(resp.Header().Get("Age")).To(Equal("0"))
+		Expect(alr.CacheStatus).To(Equal(cache.StatusHit))
+		Expect(atomic.LoadInt32(&calls)).To(Equal(int32(1)))
+	})
+
+	It("does not write a body for HEAD requests", func() {
+		get()
+
+		req, err := http.NewRequest("HEAD", "http://example.com/path", nil)
+		Expect(err).ToNot(HaveOccurred())
+		resp, alr := serve(req)
+		Expect(resp.Body.Len()).To(BeZero())
+		Expect(alr.CacheStatus).To(Equal(cache.StatusHit))
+	})
+
+	It("replaces expired responses", func() {
+		cacheControl = "max-age=0"
+		get()
+
+		cacheControl = "max-age=60"
+		resp, alr := get()
+		Expect(resp.Body.String()).To(Equal("response 2"))
+		Expect(alr.CacheStatus).To(Equal(cache.StatusExpired))
+
+		resp, _ = get()
+		Expect(resp.Body.String()).To(Equal("response 2"))
+	})
+
+	It("serves stale responses while revalidating them in the background", func() {
+		cacheControl = "max-age=0, stale-while-revalidate=60"
+		get()
+
+		cacheControl = "max-age=60"
+		resp, alr := get()
+		Expect(resp.Body.String()).To(Equal("response 1"))
+		Expect(alr.CacheStatus).To(Equal(cache.StatusUpdating))
+
+		Eventually(func() string {
+			resp, _ := get()
+			return resp.Body.String()
+		}).Should(Equal("response 2"))
+		Expect(atomic.LoadInt32(&calls)).To(Equal(int32(2)))
+	})
+
+	It("bypasses requests bound for a route service", func() {
+		req, err := http.NewRequest("GET", "http://example.com/path", nil)
+		Expect(err).ToNot(HaveOccurred())
+		rsURL, err := url.Parse("https://route-service.example.com")
+		Expect(err).ToNot(HaveOccurred())
+		req = req.WithContext(context.WithValue(req.Context(), handlers.RouteServiceURLCtxKey, rsURL))
+
+		_, alr := serve(req)
+		Expect(alr.CacheStatus).To(Equal(cache.StatusBypass))
+		_, alr = get()
+		Expect(alr.CacheStatus).To(Equal(cache.StatusMiss))
+	})
+
+	It("invalidates responses on unsafe requests", func() {
+		get()
+
+		req, err := http.NewRequest("DELETE", "http://example.com/path", nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, alr := serve(req)
+		Expect(alr.CacheStatus).To(Equal(cache.StatusBypass))
+
+		resp, _ := get()
+		Expect(resp.Body.String()).To(Equal("response 3"))
+	})
+
+	It("passes requests of routes that do not opt in through", func() {
+		routePool = route.NewPool(1*time.Second, "")
+		get()
+		resp, alr := get()
+		Expect(resp.Body.String()).To(Equal("response 2"))
+		Expect(alr.CacheStatus).To(BeEmpty())
+	})
+
+	Context("without a store", func() {
+		BeforeEach(func() {
+			handler = handlers.NewCache(nil, new(logger_fakes.FakeLogger))
+		})
+
+		It("passes every request through", func() {
+			get()
+			resp, alr := get()
+			Expect(resp.Body.String()).To(Equal("response 2"))
+			Expect(alr.CacheStatus).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"github.com/urfave/negroni"
+)
+
+const (
+	ForwardedClientCertHeader = "X-Forwarded-Client-Cert"
+)
+
+type clientCert struct {
+	forwardedClientCert string
+}
+
+// NewClientCert creates a handler that sets the X-Forwarded-Client-Cert
+// header of requests according to forwardedClientCert, one of
+// config.ForwardedClientCertModes. Requests are on a mutual TLS connection
+// when the client presented a certificate the router verified.
+func NewClientCert(forwardedClientCert string) negroni.Handler {
+	return &clientCert{
+		forwardedClientCert: forwardedClientCert,
+	}
+}
+
+func (c *clientCert) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	mutualTLS := r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+
+	switch c.forwardedClientCert {
+	case config.ALWAYS_FORWARD:
+	case config.FORWARD:
+		if !mutualTLS {
+			r.Header.Del(ForwardedClientCertHeader)
+		}
+	default:
+		r.Header.Del(ForwardedClientCertHeader)
+		if mutualTLS {
+			r.Header.Set(ForwardedClientCertHeader, forwardedClientCert(r.TLS.PeerCertificates[0]))
+		}
+	}
+
+	next(rw, r)
+}
+
+// forwardedClientCert describes cert as an element of the
+// X-Forwarded-Client-Cert header: the SHA-256 hash of its DER encoding, its
+// URL-encoded PEM encoding, its subject and its DNS SANs.
+func forwardedClientCert(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+
+	elements := []string{
+		"Hash=" + hex.EncodeToString(hash[:]),
+		`Cert="` + url.QueryEscape(string(certPEM)) + `"`,
+		`Subject="` + subject(cert.Subject) + `"`,
+	}
+	for _, name := range cert.DNSNames {
+		elements = append(elements, "DNS="+name)
+	}
+	return strings.Join(elements, ";")
+}
+
+// subject formats name as a comma separated list of attributes, most
+// specific first, like "CN=client,OU=apps,O=example".
+func subject(name pkix.Name) string {
+	attributes := []struct {
+		key    string
+		values []string
+	}{
+		{"CN", []string{name.CommonName}},
+		{"SERIALNUMBER", []string{name.SerialNumber}},
+		{"OU", name.OrganizationalUnit},
+		{"O", name.Organization},
+		{"STREET", name.StreetAddress},
+		{"L", name.Locality},
+		{"ST", name.Province},
+		{"POSTALCODE", name.PostalCode},
+		{"C", name.Country},
+	}
+
+	var parts []string
+	for _, attribute := range attributes {
+		for _, value := range attribute.values {
+			if value != "" {
+				parts = append(parts, attribute.key+"="+escapeAttribute(value))
+			}
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+var attributeEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `+`, `\+`, `"`, `\"`, `<`, `\<`, `>`, `\>`, `;`, `\;`, `=`, `\=`)
+
+func escapeAttribute(value string) string {
+	return attributeEscaper.Replace(value)
+}
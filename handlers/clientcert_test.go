@@ -0,0 +1,130 @@
+package handlers_test
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("Client Cert", func() {
+	var (
+		handler    negroni.Handler
+		req        *http.Request
+		clientCert *x509.Certificate
+		chain      test_util.CertChain
+		forwarded  []string
+		nextCalled bool
+	)
+
+	nextHandler := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header[handlers.ForwardedClientCertHeader]
+		nextCalled = true
+	})
+
+	mutualTLS := func() {
+		req.TLS = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{clientCert},
+			VerifiedChains:   [][]*x509.Certificate{{clientCert}},
+		}
+	}
+
+	BeforeEach(func() {
+		chain = test_util.CreateSignedClientCertWithRootCA("client.example.com")
+		var err error
+		clientCert, err = x509.ParseCertificate(chain.TLSCert().Certificate[0])
+		Expect(err).ToNot(HaveOccurred())
+
+		req = test_util.NewRequest("GET", "example.com", "/", nil)
+		req.Header.Set(handlers.ForwardedClientCertHeader, "spoofed")
+		forwarded = nil
+		nextCalled = false
+	})
+
+	JustBeforeEach(func() {
+		handler.ServeHTTP(httptest.NewRecorder(), req, nextHandler)
+		Expect(nextCalled).To(BeTrue())
+	})
+
+	Context("when set to sanitize_set", func() {
+		BeforeEach(func() {
+			handler = handlers.NewClientCert(config.SANITIZE_SET)
+		})
+
+		It("strips the header of plain requests", func() {
+			Expect(forwarded).To(BeEmpty())
+		})
+
+		Context("on a TLS connection without a client certificate", func() {
+			BeforeEach(func() {
+				req.TLS = &tls.ConnectionState{}
+			})
+
+			It("strips the header", func() {
+				Expect(forwarded).To(BeEmpty())
+			})
+		})
+
+		Context("on a mutual TLS connection", func() {
+			BeforeEach(mutualTLS)
+
+			It("replaces the header with the client certificate", func() {
+				Expect(forwarded).To(HaveLen(1))
+				elements := strings.Split(forwarded[0], ";")
+
+				hash := sha256.Sum256(clientCert.Raw)
+				Expect(elements).To(ContainElement("Hash=" + hex.EncodeToString(hash[:])))
+				Expect(elements).To(ContainElement(`Subject="CN=client.example.com"`))
+				Expect(elements).To(ContainElement("DNS=client.example.com"))
+				Expect(elements).To(ContainElement(`Cert="` + url.QueryEscape(string(chain.CertPEM)) + `"`))
+			})
+		})
+	})
+
+	Context("when set to forward", func() {
+		BeforeEach(func() {
+			handler = handlers.NewClientCert(config.FORWARD)
+		})
+
+		It("strips the header of plain requests", func() {
+			Expect(forwarded).To(BeEmpty())
+		})
+
+		Context("on a mutual TLS connection", func() {
+			BeforeEach(mutualTLS)
+
+			It("passes the header through", func() {
+				Expect(forwarded).To(Equal([]string{"spoofed"}))
+			})
+		})
+	})
+
+	Context("when set to always_forward", func() {
+		BeforeEach(func() {
+			handler = handlers.NewClientCert(config.ALWAYS_FORWARD)
+		})
+
+		It("passes the header of plain requests through", func() {
+			Expect(forwarded).To(Equal([]string{"spoofed"}))
+		})
+
+		Context("on a mutual TLS connection", func() {
+			BeforeEach(mutualTLS)
+
+			It("passes the header through", func() {
+				Expect(forwarded).To(Equal([]string{"spoofed"}))
+			})
+		})
+	})
+})
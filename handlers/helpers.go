@@ -28,6 +28,15 @@ func writeStatus(rw http.ResponseWriter, code int, message string, alr interface
 	}
 }
 
+// grpcStatus returns the gRPC status code of a response from its grpc-status
+// trailer, or from its header for responses without messages.
+func grpcStatus(header http.Header) string {
+	if status := header.Get("Grpc-Status"); status != "" {
+		return status
+	}
+	return header.Get(http.TrailerPrefix + "Grpc-Status")
+}
+
 func hostWithoutPort(req *http.Request) string {
 	host := req.Host
 
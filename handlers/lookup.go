@@ -78,7 +78,9 @@ func (l *lookupHandler) lookup(r *http.Request) *route.Pool {
 		return l.registry.LookupWithInstance(uri, appID, appIndex)
 	}
 
-	return l.registry.Lookup(uri)
+	return l.registry.LookupMatching(uri, func(rule route.MatchRule) bool {
+		return rule.Matches(r)
+	})
 }
 
 func validateCfAppInstance(appInstanceHeader string) (string, string, error) {
@@ -86,7 +86,7 @@ var _ = Describe("Lookup", func() {
 
 		BeforeEach(func() {
 			pool = route.NewPool(2*time.Minute, "example.com")
-			reg.LookupReturns(pool)
+			reg.LookupMatchingReturns(pool)
 		})
 
 		JustBeforeEach(func() {
@@ -98,6 +98,26 @@ var _ = Describe("Lookup", func() {
 			Expect(nextRequest.Context().Value("RoutePool")).To(Equal(pool))
 		})
 
+		It("evaluates match rules against the request", func() {
+			Expect(reg.LookupMatchingCallCount()).To(Equal(1))
+			uri, matches := reg.LookupMatchingArgsForCall(0)
+			Expect(uri.String()).To(Equal("example.com"))
+
+			Expect(matches(route.MatchRule{Header: "X-Canary", Value: "true"})).To(BeFalse())
+		})
+
+		Context("when the request carries a header used in a match rule", func() {
+			BeforeEach(func() {
+				req.Header.Set("X-Canary", "true")
+			})
+
+			It("accepts the matching rule", func() {
+				_, matches := reg.LookupMatchingArgsForCall(0)
+				Expect(matches(route.MatchRule{Header: "X-Canary", Value: "true"})).To(BeTrue())
+				Expect(matches(route.MatchRule{Cookie: "beta", Value: "1"})).To(BeFalse())
+			})
+		})
+
 		Context("when a specific instance is requested", func() {
 			BeforeEach(func() {
 				req.Header.Add("X-CF-App-Instance", "app-guid:instance-id")
@@ -15,19 +15,22 @@ import (
 )
 
 type protocolCheck struct {
-	logger logger.Logger
+	logger      logger.Logger
+	enableHTTP2 bool
 }
 
 // NewProtocolCheck creates a handler responsible for checking the protocol of
-// the request
-func NewProtocolCheck(logger logger.Logger) negroni.Handler {
+// the request. HTTP/2 requests are only supported over TLS, and only when
+// enableHTTP2 is set.
+func NewProtocolCheck(logger logger.Logger, enableHTTP2 bool) negroni.Handler {
 	return &protocolCheck{
-		logger: logger,
+		logger:      logger,
+		enableHTTP2: enableHTTP2,
 	}
 }
 
 func (p *protocolCheck) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	if !isProtocolSupported(r) {
+	if !p.isProtocolSupported(r) {
 		alr := r.Context().Value("AccessLogRecord")
 		accessLogRecord, ok := alr.(*schema.AccessLogRecord)
 		if accessLogRecord == nil || !ok {
@@ -66,6 +69,9 @@ func (p *protocolCheck) hijack(rw http.ResponseWriter) (net.Conn, *bufio.ReadWri
 	return hijacker.Hijack()
 }
 
-func isProtocolSupported(request *http.Request) bool {
+func (p *protocolCheck) isProtocolSupported(request *http.Request) bool {
+	if p.enableHTTP2 && request.ProtoMajor == 2 && request.ProtoMinor == 0 && request.TLS != nil {
+		return true
+	}
 	return request.ProtoMajor == 1 && (request.ProtoMinor == 0 || request.ProtoMinor == 1)
 }
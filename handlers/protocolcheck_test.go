@@ -3,8 +3,10 @@ package handlers_test
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
+	"net/http/httptest"
 
 	"code.cloudfoundry.org/gorouter/access_log/schema"
 	"code.cloudfoundry.org/gorouter/handlers"
@@ -24,9 +26,15 @@ var _ = Describe("Protocolcheck", func() {
 		nextCalled bool
 		server     *ghttp.Server
 		n          *negroni.Negroni
+
+		enableHTTP2 bool
 	)
 
 	BeforeEach(func() {
+		enableHTTP2 = false
+	})
+
+	JustBeforeEach(func() {
 		logger = test_util.NewTestZapLogger("protocolcheck")
 		nextCalled = false
 		alr = &schema.AccessLogRecord{}
@@ -38,7 +46,7 @@ var _ = Describe("Protocolcheck", func() {
 			req = req.WithContext(context.WithValue(req.Context(), "AccessLogRecord", alr))
 			next(rw, req)
 		})
-		n.Use(handlers.NewProtocolCheck(logger))
+		n.Use(handlers.NewProtocolCheck(logger, enableHTTP2))
 		n.UseHandlerFunc(func(http.ResponseWriter, *http.Request) {
 			nextCalled = true
 		})
@@ -113,5 +121,33 @@ var _ = Describe("Protocolcheck", func() {
 			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
 			Expect(alr.StatusCode).To(Equal(http.StatusBadRequest))
 		})
+
+		Context("when HTTP/2 is enabled", func() {
+			var req *http.Request
+
+			BeforeEach(func() {
+				enableHTTP2 = true
+
+				req = test_util.NewRequest("GET", "example.com", "/", nil)
+				req.Proto = "HTTP/2.0"
+				req.ProtoMajor = 2
+				req.ProtoMinor = 0
+			})
+
+			It("passes requests received over TLS through", func() {
+				req.TLS = &tls.ConnectionState{NegotiatedProtocol: "h2"}
+
+				n.ServeHTTP(httptest.NewRecorder(), req)
+				Expect(nextCalled).To(BeTrue())
+			})
+
+			It("returns a 400 bad request for cleartext requests", func() {
+				resp := httptest.NewRecorder()
+				n.ServeHTTP(resp, req)
+
+				Expect(resp.Code).To(Equal(http.StatusBadRequest))
+				Expect(nextCalled).To(BeFalse())
+			})
+		})
 	})
 })
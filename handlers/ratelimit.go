@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/ratelimit"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type rateLimitHandler struct {
+	limiter        *ratelimit.Limiter
+	defaultLimit   route.RateLimit
+	trustedProxies []*net.IPNet
+	reporter       metrics.CombinedReporter
+	logger         logger.Logger
+}
+
+// NewRateLimit creates a handler rejecting requests above the rate limit of
+// their route with a 429 Too Many Requests. Requests are counted by client
+// IP within each route, by route, or by app. The client IP of requests sent by
+// trustedProxies is taken from X-Forwarded-For.
+func NewRateLimit(c config.RateLimitConfig, trustedProxies []*net.IPNet, reporter metrics.CombinedReporter, logger logger.Logger) negroni.Handler {
+	return &rateLimitHandler{
+		limiter: ratelimit.NewLimiter(c.MaxKeys),
+		defaultLimit: route.RateLimit{
+			Rate:  c.Rate,
+			Burst: c.Burst,
+			Key:   c.Key,
+		},
+		trustedProxies: trustedProxies,
+		reporter:       reporter,
+		logger:         logger,
+	}
+}
+
+func (h *rateLimitHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	routePool, _ := r.Context().Value("RoutePool").(*route.Pool)
+	if routePool == nil {
+		next(rw, r)
+		return
+	}
+
+	limit := routePool.RateLimit(h.defaultLimit)
+	if !limit.Enabled() {
+		next(rw, r)
+		return
+	}
+
+	key := h.key(r, routePool, limit.Key)
+	allowed, wait := h.limiter.Allow(key, limit.Rate, limit.Burst, time.Now())
+	if allowed {
+		next(rw, r)
+		return
+	}
+
+	h.reporter.CaptureRateLimitedRequest()
+	h.logger.Info("rate-limited", zap.String("host", r.Host), zap.String("key", limit.Key))
+
+	alr := r.Context().Value("AccessLogRecord")
+	if accessLog, ok := alr.(*schema.AccessLogRecord); ok {
+		accessLog.RouterError = "rate_limited"
+	}
+
+	rw.Header().Set(router_http.CfRouterError, "rate_limited")
+	rw.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+	writeStatus(rw, http.StatusTooManyRequests, "Rate limit exceeded.", alr, h.logger)
+}
+
+// key returns the bucket of the request. Routes are told apart by host and
+// context path, so that a route keeps its buckets when its pool is created
+// again. Buckets of client IPs are kept apart for each route, so that routes
+// overriding the rate do not share them.
+func (h *rateLimitHandler) key(r *http.Request, routePool *route.Pool, kind string) string {
+	routeKey := "route:" + string(route.Uri(hostWithoutPort(r)+routePool.ContextPath()).RouteKey())
+	switch kind {
+	case config.RATE_LIMIT_KEY_APP_ID:
+		if appID := routePool.ApplicationId(); appID != "" {
+			return "app:" + appID
+		}
+		return routeKey
+	case config.RATE_LIMIT_KEY_ROUTE:
+		return routeKey
+	default:
+		return routeKey + "|ip:" + router_http.ClientIP(r, h.trustedProxies)
+	}
+}
+
+// retryAfterSeconds rounds wait up to whole seconds, as Retry-After has no
+// finer resolution.
+func retryAfterSeconds(wait time.Duration) int {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
@@ -0,0 +1,209 @@
+package handlers_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	metrics_fakes "code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("RateLimit", func() {
+	var (
+		handler negroni.Handler
+
+		rateLimitConfig config.RateLimitConfig
+		trustedProxies  []*net.IPNet
+		fakeReporter    *metrics_fakes.FakeCombinedReporter
+		routePool       *route.Pool
+
+		nextCalls int
+	)
+
+	nextHandler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		nextCalls++
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	newPool := func(appID string, tags map[string]string) *route.Pool {
+		pool := route.NewPool(2*time.Minute, "")
+		pool.Put(route.NewEndpoint(appID, "1.2.3.4", 5678, "", "", tags, -1, "", models.ModificationTag{}))
+		return pool
+	}
+
+	serve := func(pool *route.Pool, remoteAddr, xff string) (*httptest.ResponseRecorder, *schema.AccessLogRecord) {
+		req, err := http.NewRequest("GET", "http://example.com/", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.RemoteAddr = remoteAddr
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+
+		alr := &schema.AccessLogRecord{StartedAt: time.Now()}
+		ctx := context.WithValue(req.Context(), "AccessLogRecord", alr)
+		ctx = context.WithValue(ctx, "RoutePool", pool)
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req.WithContext(ctx), nextHandler)
+		return resp, alr
+	}
+
+	BeforeEach(func() {
+		rateLimitConfig = config.RateLimitConfig{
+			Rate:    1,
+			Burst:   2,
+			Key:     config.RATE_LIMIT_KEY_CLIENT_IP,
+			MaxKeys: 100,
+		}
+		trustedProxies = nil
+		fakeReporter = new(metrics_fakes.FakeCombinedReporter)
+		routePool = newPool("app-1", nil)
+		nextCalls = 0
+	})
+
+	JustBeforeEach(func() {
+		handler = handlers.NewRateLimit(rateLimitConfig, trustedProxies, fakeReporter, new(logger_fakes.FakeLogger))
+	})
+
+	It("rejects requests above the rate with a 429", func() {
+		serve(routePool, "10.0.0.1:1234", "")
+		serve(routePool, "10.0.0.1:1234", "")
+		resp, alr := serve(routePool, "10.0.0.1:1234", "")
+
+		Expect(nextCalls).To(Equal(2))
+		Expect(resp.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(resp.Header().Get("Retry-After")).To(Equal("1"))
+		Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("rate_limited"))
+		Expect(alr.StatusCode).To(Equal(http.StatusTooManyRequests))
+		Expect(alr.RouterError).To(Equal("rate_limited"))
+		Expect(fakeReporter.CaptureRateLimitedRequestCallCount()).To(Equal(1))
+	})
+
+	It("counts client IPs apart", func() {
+		serve(routePool, "10.0.0.1:1234", "")
+		serve(routePool, "10.0.0.1:1234", "")
+		resp, _ := serve(routePool, "10.0.0.2:1234", "")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+	})
+
+	It("counts the same client apart for each route", func() {
+		serve(routePool, "10.0.0.1:1234", "")
+		serve(routePool, "10.0.0.1:1234", "")
+
+		other := route.NewPool(2*time.Minute, "/other")
+		other.Put(route.NewEndpoint("app-1", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{}))
+		resp, _ := serve(other, "10.0.0.1:1234", "")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+	})
+
+	It("keeps counting a route whose pool is created again", func() {
+		serve(routePool, "10.0.0.1:1234", "")
+		serve(routePool, "10.0.0.1:1234", "")
+		resp, _ := serve(newPool("app-1", nil), "10.0.0.1:1234", "")
+		Expect(resp.Code).To(Equal(http.StatusTooManyRequests))
+	})
+
+	It("ignores X-Forwarded-For from untrusted clients", func() {
+		serve(routePool, "10.0.0.1:1234", "1.1.1.1")
+		serve(routePool, "10.0.0.1:1234", "2.2.2.2")
+		resp, _ := serve(routePool, "10.0.0.1:1234", "3.3.3.3")
+		Expect(resp.Code).To(Equal(http.StatusTooManyRequests))
+	})
+
+	Context("with trusted proxies", func() {
+		BeforeEach(func() {
+			_, ipNet, err := net.ParseCIDR("10.0.0.0/8")
+			Expect(err).ToNot(HaveOccurred())
+			trustedProxies = []*net.IPNet{ipNet}
+		})
+
+		It("takes the client IP from X-Forwarded-For", func() {
+			serve(routePool, "10.0.0.1:1234", "1.1.1.1")
+			serve(routePool, "10.0.0.2:1234", "1.1.1.1, 10.0.0.1")
+			resp, _ := serve(routePool, "10.0.0.3:1234", "2.2.2.2")
+			Expect(resp.Code).To(Equal(http.StatusOK))
+
+			resp, _ = serve(routePool, "10.0.0.3:1234", "1.1.1.1")
+			Expect(resp.Code).To(Equal(http.StatusTooManyRequests))
+		})
+
+		It("does not trust addresses added by the client", func() {
+			serve(routePool, "10.0.0.1:1234", "1.1.1.1")
+			serve(routePool, "10.0.0.1:1234", "1.1.1.1")
+			resp, _ := serve(routePool, "10.0.0.1:1234", "9.9.9.9, 1.1.1.1")
+			Expect(resp.Code).To(Equal(http.StatusTooManyRequests))
+		})
+	})
+
+	Context("when keyed by app", func() {
+		BeforeEach(func() {
+			rateLimitConfig.Key = config.RATE_LIMIT_KEY_APP_ID
+		})
+
+		It("counts the requests of every route of the app together", func() {
+			serve(routePool, "10.0.0.1:1234", "")
+			serve(newPool("app-1", nil), "10.0.0.2:1234", "")
+			resp, _ := serve(newPool("app-1", nil), "10.0.0.3:1234", "")
+			Expect(resp.Code).To(Equal(http.StatusTooManyRequests))
+
+			resp, _ = serve(newPool("app-2", nil), "10.0.0.3:1234", "")
+			Expect(resp.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Context("when keyed by route", func() {
+		BeforeEach(func() {
+			rateLimitConfig.Key = config.RATE_LIMIT_KEY_ROUTE
+		})
+
+		It("counts the requests of every client together", func() {
+			serve(routePool, "10.0.0.1:1234", "")
+			serve(routePool, "10.0.0.2:1234", "")
+			resp, _ := serve(routePool, "10.0.0.3:1234", "")
+			Expect(resp.Code).To(Equal(http.StatusTooManyRequests))
+		})
+	})
+
+	Context("when the route overrides the limit", func() {
+		It("applies the limit of the route", func() {
+			routePool = newPool("app-1", map[string]string{route.RateLimitBurstTag: "1"})
+			serve(routePool, "10.0.0.1:1234", "")
+			resp, _ := serve(routePool, "10.0.0.1:1234", "")
+			Expect(resp.Code).To(Equal(http.StatusTooManyRequests))
+		})
+
+		It("can disable the limit", func() {
+			routePool = newPool("app-1", map[string]string{route.RateLimitTag: "off"})
+			for i := 0; i < 5; i++ {
+				serve(routePool, "10.0.0.1:1234", "")
+			}
+			Expect(nextCalls).To(Equal(5))
+		})
+	})
+
+	Context("when rate limiting is disabled", func() {
+		BeforeEach(func() {
+			rateLimitConfig.Rate = 0
+		})
+
+		It("passes every request through", func() {
+			for i := 0; i < 5; i++ {
+				serve(routePool, "10.0.0.1:1234", "")
+			}
+			Expect(nextCalls).To(Equal(5))
+			Expect(fakeReporter.CaptureRateLimitedRequestCallCount()).To(BeZero())
+		})
+	})
+})
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/cache"
 	"code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/metrics"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
@@ -38,13 +39,24 @@ func (rh *reporterHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request, ne
 		return
 	}
 	accessLog := alr.(*schema.AccessLogRecord)
+	proxyWriter := rw.(utils.ProxyResponseWriter)
+
+	if accessLog.CacheStatus != "" {
+		rh.reporter.CaptureCacheResponse(accessLog.CacheStatus)
+	}
 
 	if accessLog.RouteEndpoint == nil {
+		// responses served from the cache count without an endpoint
+		if cache.ServedFromCache(accessLog.CacheStatus) {
+			rh.reporter.CaptureRoutingResponse(proxyWriter.Status())
+		}
 		return
 	}
 
-	proxyWriter := rw.(utils.ProxyResponseWriter)
 	rh.reporter.CaptureRoutingResponse(proxyWriter.Status())
+	if status := grpcStatus(proxyWriter.Header()); status != "" {
+		rh.reporter.CaptureGrpcResponse(status)
+	}
 	rh.reporter.CaptureRoutingResponseLatency(
 		accessLog.RouteEndpoint, proxyWriter.Status(),
 		accessLog.StartedAt, time.Since(accessLog.StartedAt),
@@ -99,6 +99,22 @@ var _ = Describe("Reporter Handler", func() {
 		Expect(latency).To(BeNumerically("<", 10*time.Millisecond))
 	})
 
+	It("does not emit gRPC response metrics for other responses", func() {
+		handler.ServeHTTP(proxyWriter, req, alrHandler)
+
+		Expect(fakeReporter.CaptureGrpcResponseCallCount()).To(Equal(0))
+	})
+
+	It("emits the gRPC status of gRPC responses", func() {
+		handler.ServeHTTP(proxyWriter, req, func(rw http.ResponseWriter, req *http.Request) {
+			alrHandler(rw, req)
+			rw.Header().Set(http.TrailerPrefix+"Grpc-Status", "14")
+		})
+
+		Expect(fakeReporter.CaptureGrpcResponseCallCount()).To(Equal(1))
+		Expect(fakeReporter.CaptureGrpcResponseArgsForCall(0)).To(Equal("14"))
+	})
+
 	Context("when endpoint is nil", func() {
 		It("does not emit routing response metrics", func() {
 			handler.ServeHTTP(proxyWriter, req, nextHandler)
@@ -109,6 +125,35 @@ var _ = Describe("Reporter Handler", func() {
 		})
 	})
 
+	Context("when the response is served from the cache", func() {
+		It("emits the cache status and the routing response without an endpoint", func() {
+			handler.ServeHTTP(proxyWriter, req, func(rw http.ResponseWriter, req *http.Request) {
+				req.Context().Value("AccessLogRecord").(*schema.AccessLogRecord).CacheStatus = "HIT"
+				nextHandler(rw, req)
+			})
+
+			Expect(fakeReporter.CaptureCacheResponseCallCount()).To(Equal(1))
+			Expect(fakeReporter.CaptureCacheResponseArgsForCall(0)).To(Equal("HIT"))
+			Expect(fakeReporter.CaptureRoutingResponseCallCount()).To(Equal(1))
+			Expect(fakeReporter.CaptureRoutingResponseArgsForCall(0)).To(Equal(http.StatusTeapot))
+			Expect(fakeReporter.CaptureRoutingResponseLatencyCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the response of a cached route comes from the backend", func() {
+		It("emits the cache status and the routing response metrics", func() {
+			handler.ServeHTTP(proxyWriter, req, func(rw http.ResponseWriter, req *http.Request) {
+				req.Context().Value("AccessLogRecord").(*schema.AccessLogRecord).CacheStatus = "MISS"
+				alrHandler(rw, req)
+			})
+
+			Expect(fakeReporter.CaptureCacheResponseCallCount()).To(Equal(1))
+			Expect(fakeReporter.CaptureCacheResponseArgsForCall(0)).To(Equal("MISS"))
+			Expect(fakeReporter.CaptureRoutingResponseCallCount()).To(Equal(1))
+			Expect(fakeReporter.CaptureRoutingResponseLatencyCallCount()).To(Equal(1))
+		})
+	})
+
 	Context("when access log record is not set on the request context", func() {
 		BeforeEach(func() {
 			body := bytes.NewBufferString("What are you?")
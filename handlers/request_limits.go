@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+// ErrRequestBodyTooLarge is returned when reading the body of a request past
+// the maximum body size of its route.
+var ErrRequestBodyTooLarge = errors.New("request body too large")
+
+type requestLimitsHandler struct {
+	maxHeaderBytes int
+	maxBodySize    int64
+	reporter       metrics.CombinedReporter
+	logger         logger.Logger
+}
+
+// NewRequestLimits creates a handler rejecting requests with headers larger
+// than maxHeaderBytes with a 431 Request Header Fields Too Large, and
+// requests with bodies larger than the maximum body size of their route with
+// a 413 Request Entity Too Large. Bodies are rejected up front when their
+// Content-Length is too large; streamed bodies fail once they exceed it.
+func NewRequestLimits(maxHeaderBytes int, maxBodySize int64, reporter metrics.CombinedReporter, logger logger.Logger) negroni.Handler {
+	return &requestLimitsHandler{
+		maxHeaderBytes: maxHeaderBytes,
+		maxBodySize:    maxBodySize,
+		reporter:       reporter,
+		logger:         logger,
+	}
+}
+
+func (h *requestLimitsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if h.maxHeaderBytes > 0 && headerSize(r) > h.maxHeaderBytes {
+		h.reject(rw, r, http.StatusRequestHeaderFieldsTooLarge, "request_header_too_large", "Request header too large.")
+		return
+	}
+
+	maxBodySize := h.maxBodySize
+	if routePool, ok := r.Context().Value("RoutePool").(*route.Pool); ok {
+		maxBodySize = routePool.MaxRequestBodySize(h.maxBodySize)
+	}
+	if maxBodySize <= 0 || r.Body == nil || r.ContentLength == 0 {
+		next(rw, r)
+		return
+	}
+
+	if r.ContentLength > maxBodySize {
+		h.reject(rw, r, http.StatusRequestEntityTooLarge, "request_body_too_large", "Request body too large.")
+		return
+	}
+
+	body := &limitedBody{ReadCloser: r.Body, remaining: maxBodySize}
+	r.Body = body
+	next(rw, r.WithContext(context.WithValue(r.Context(), RequestBodyLimitCtxKey, body)))
+}
+
+func (h *requestLimitsHandler) reject(rw http.ResponseWriter, r *http.Request, code int, routerError, message string) {
+	h.reporter.CaptureOversizedRequest()
+	h.logger.Info("request-too-large", zap.String("host", r.Host), zap.String("router-error", routerError))
+
+	alr := r.Context().Value("AccessLogRecord")
+	if accessLog, ok := alr.(*schema.AccessLogRecord); ok {
+		accessLog.RouterError = routerError
+	}
+
+	rw.Header().Set(router_http.CfRouterError, routerError)
+	writeStatus(rw, code, message, alr, h.logger)
+}
+
+// RequestBodyTooLarge returns true if the body of request was read past the
+// maximum body size of its route.
+func RequestBodyTooLarge(request *http.Request) bool {
+	body, ok := request.Context().Value(RequestBodyLimitCtxKey).(*limitedBody)
+	return ok && body.isExceeded()
+}
+
+// headerSize returns the size of the request line and headers of r as they
+// were received.
+func headerSize(r *http.Request) int {
+	size := len(r.Method) + 1 + len(r.RequestURI) + 1 + len(r.Proto) + 2
+	size += len("Host: \r\n") + len(r.Host)
+	for name, values := range r.Header {
+		for _, value := range values {
+			size += len(name) + len(": \r\n") + len(value)
+		}
+	}
+	return size + 2
+}
+
+// limitedBody fails reads past the remaining bytes. It is read by the
+// transport while the round tripper may check whether it was exceeded.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  int32
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.isExceeded() {
+		return 0, ErrRequestBodyTooLarge
+	}
+
+	// read one byte more than remains to tell a body ending at the limit
+	// from one going over it
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		atomic.StoreInt32(&b.exceeded, 1)
+		return n, ErrRequestBodyTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// isExceeded returns true if the body was read past its limit.
+func (b *limitedBody) isExceeded() bool {
+	return atomic.LoadInt32(&b.exceeded) == 1
+}
@@ -0,0 +1,172 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	metrics_fakes "code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("RequestLimits", func() {
+	var (
+		handler negroni.Handler
+
+		maxHeaderBytes int
+		maxBodySize    int64
+		fakeReporter   *metrics_fakes.FakeCombinedReporter
+		routePool      *route.Pool
+
+		req *http.Request
+		alr *schema.AccessLogRecord
+
+		nextCalled bool
+		nextBody   []byte
+		nextErr    error
+	)
+
+	nextHandler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		nextCalled = true
+		nextBody, nextErr = ioutil.ReadAll(r.Body)
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	newRequest := func(body string, contentLength int64) *http.Request {
+		request, err := http.NewRequest("POST", "http://example.com/upload", ioutil.NopCloser(strings.NewReader(body)))
+		Expect(err).ToNot(HaveOccurred())
+		request.RequestURI = "/upload"
+		request.ContentLength = contentLength
+		return request
+	}
+
+	serve := func() *httptest.ResponseRecorder {
+		ctx := context.WithValue(req.Context(), "AccessLogRecord", alr)
+		if routePool != nil {
+			ctx = context.WithValue(ctx, "RoutePool", routePool)
+		}
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req.WithContext(ctx), nextHandler)
+		return resp
+	}
+
+	BeforeEach(func() {
+		maxHeaderBytes = 1024
+		maxBodySize = 10
+		fakeReporter = new(metrics_fakes.FakeCombinedReporter)
+		routePool = nil
+		alr = &schema.AccessLogRecord{StartedAt: time.Now()}
+		nextCalled = false
+		nextBody = nil
+		nextErr = nil
+	})
+
+	JustBeforeEach(func() {
+		handler = handlers.NewRequestLimits(maxHeaderBytes, maxBodySize, fakeReporter, new(logger_fakes.FakeLogger))
+	})
+
+	It("passes requests within the limits through", func() {
+		req = newRequest("0123456789", 10)
+		resp := serve()
+
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(nextErr).ToNot(HaveOccurred())
+		Expect(string(nextBody)).To(Equal("0123456789"))
+		Expect(fakeReporter.CaptureOversizedRequestCallCount()).To(BeZero())
+	})
+
+	It("rejects requests with large headers with a 431", func() {
+		req = newRequest("", 0)
+		req.Header.Set("X-Large", string(bytes.Repeat([]byte("a"), 1024)))
+		resp := serve()
+
+		Expect(nextCalled).To(BeFalse())
+		Expect(resp.Code).To(Equal(http.StatusRequestHeaderFieldsTooLarge))
+		Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("request_header_too_large"))
+		Expect(alr.StatusCode).To(Equal(http.StatusRequestHeaderFieldsTooLarge))
+		Expect(alr.RouterError).To(Equal("request_header_too_large"))
+		Expect(fakeReporter.CaptureOversizedRequestCallCount()).To(Equal(1))
+	})
+
+	It("rejects requests with a large Content-Length with a 413 before reading the body", func() {
+		req = newRequest("0123456789a", 11)
+		resp := serve()
+
+		Expect(nextCalled).To(BeFalse())
+		Expect(resp.Code).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("request_body_too_large"))
+		Expect(alr.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(alr.RouterError).To(Equal("request_body_too_large"))
+		Expect(fakeReporter.CaptureOversizedRequestCallCount()).To(Equal(1))
+	})
+
+	Context("when the body is streamed", func() {
+		It("fails reading the body past the limit", func() {
+			var limited *http.Request
+			req = newRequest("0123456789a", -1)
+			handler.ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, r *http.Request) {
+				limited = r
+				nextBody, nextErr = ioutil.ReadAll(r.Body)
+			})
+
+			Expect(nextErr).To(MatchError(handlers.ErrRequestBodyTooLarge))
+			Expect(string(nextBody)).To(Equal("0123456789"))
+			Expect(handlers.RequestBodyTooLarge(limited)).To(BeTrue())
+		})
+
+		It("reads a body at the limit", func() {
+			var limited *http.Request
+			req = newRequest("0123456789", -1)
+			handler.ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, r *http.Request) {
+				limited = r
+				nextBody, nextErr = ioutil.ReadAll(r.Body)
+			})
+
+			Expect(nextErr).ToNot(HaveOccurred())
+			Expect(string(nextBody)).To(Equal("0123456789"))
+			Expect(handlers.RequestBodyTooLarge(limited)).To(BeFalse())
+		})
+	})
+
+	Context("when the route overrides the body size", func() {
+		BeforeEach(func() {
+			routePool = route.NewPool(2*time.Minute, "")
+			routePool.Put(route.NewEndpoint("app-1", "1.2.3.4", 5678, "", "",
+				map[string]string{route.MaxRequestBodySizeTag: "20"}, -1, "", models.ModificationTag{}))
+		})
+
+		It("applies the size of the route", func() {
+			req = newRequest("0123456789abcdef", 16)
+			resp := serve()
+			Expect(resp.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Context("when the body size is not limited", func() {
+		BeforeEach(func() {
+			maxBodySize = 0
+		})
+
+		It("passes large bodies through", func() {
+			req = newRequest("0123456789abcdef", -1)
+			resp := serve()
+
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(nextErr).ToNot(HaveOccurred())
+			Expect(string(nextBody)).To(Equal("0123456789abcdef"))
+		})
+	})
+})
@@ -9,3 +9,7 @@ const ProxyResponseWriterCtxKey key = "ProxyResponseWriter"
 // RouteServiceURLCtxKey is a key used to store the route service url
 // to indicate that this request is destined for a route service
 const RouteServiceURLCtxKey key = "RouteServiceURL"
+
+// RequestBodyLimitCtxKey is a key used to store the limit on the body of a
+// request, to tell whether it failed because the body was too large
+const RequestBodyLimitCtxKey key = "RequestBodyLimit"
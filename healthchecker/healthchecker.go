@@ -0,0 +1,199 @@
+package healthchecker
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+)
+
+// HealthChecker periodically probes the endpoints registered with a health
+// check path and reports endpoints that cross the healthy or unhealthy
+// threshold to the registry.
+type HealthChecker struct {
+	registry registry.Registry
+	logger   logger.Logger
+	client   *http.Client
+	// tlsConfig verifies endpoints registered with a TLS port like the
+	// proxy does
+	tlsConfig *tls.Config
+
+	interval           time.Duration
+	healthyThreshold   int
+	unhealthyThreshold int
+	maxConcurrency     int
+	userAgent          string
+
+	lock    sync.Mutex
+	results map[string]*result
+}
+
+type result struct {
+	healthy   bool
+	successes int
+	failures  int
+}
+
+func NewHealthChecker(logger logger.Logger, registry registry.Registry, cfg *config.Config) *HealthChecker {
+	hc := cfg.ActiveHealthCheck
+	return &HealthChecker{
+		registry: registry,
+		logger:   logger,
+		client:   newClient(hc.Timeout, nil),
+		tlsConfig: &tls.Config{
+			CipherSuites: cfg.CipherSuites,
+			RootCAs:      cfg.Backends.CAPool,
+		},
+		interval:           hc.Interval,
+		healthyThreshold:   hc.HealthyThreshold,
+		unhealthyThreshold: hc.UnhealthyThreshold,
+		maxConcurrency:     hc.MaxConcurrency,
+		userAgent:          cfg.HealthCheckUserAgent,
+		results:            make(map[string]*result),
+	}
+}
+
+func newClient(timeout time.Duration, tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			TLSClientConfig:   tlsConfig,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (h *HealthChecker) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	close(ready)
+	for {
+		select {
+		case <-ticker.C:
+			h.Check()
+		case <-signals:
+			h.logger.Info("exited")
+			return nil
+		}
+	}
+}
+
+// Check probes every target once and waits for the results.
+func (h *HealthChecker) Check() {
+	targets := h.registry.HealthCheckTargets()
+	h.forget(targets)
+
+	addrs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < h.maxConcurrency && i < len(targets); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range addrs {
+				h.record(addr, h.probe(addr, targets[addr]))
+			}
+		}()
+	}
+
+	for addr := range targets {
+		addrs <- addr
+	}
+	close(addrs)
+	wg.Wait()
+}
+
+// probe requests the health check path of the target. Endpoints registered
+// with a TLS port are probed over TLS and must present a certificate for
+// their ServerCertDomainSAN.
+func (h *HealthChecker) probe(addr string, target route.HealthCheckTarget) bool {
+	path := target.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	scheme, client := "http", h.client
+	if target.ServerCertDomainSAN != "" {
+		tlsConfig := h.tlsConfig.Clone()
+		tlsConfig.ServerName = target.ServerCertDomainSAN
+		scheme, client = "https", newClient(h.client.Timeout, tlsConfig)
+	}
+
+	req, err := http.NewRequest("GET", scheme+"://"+addr+path, nil)
+	if err != nil {
+		h.logger.Error("health-check-request-failed", zap.String("addr", addr), zap.Error(err))
+		return false
+	}
+	req.Header.Set("User-Agent", h.userAgent)
+
+	res, err := client.Do(req)
+	if err != nil {
+		h.logger.Debug("health-check-failed", zap.String("addr", addr), zap.Error(err))
+		return false
+	}
+	res.Body.Close()
+
+	return res.StatusCode >= 200 && res.StatusCode < 400
+}
+
+// record counts the result of a probe. The health of an endpoint is reported
+// to the registry after its first probe, and again whenever it changes.
+func (h *HealthChecker) record(addr string, success bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	r, found := h.results[addr]
+	if !found {
+		// endpoints are assumed healthy until proven otherwise
+		r = &result{healthy: true}
+		h.results[addr] = r
+	}
+
+	if success {
+		r.successes++
+		r.failures = 0
+	} else {
+		r.failures++
+		r.successes = 0
+	}
+
+	changed := true
+	switch {
+	case !r.healthy && r.successes >= h.healthyThreshold:
+		r.healthy = true
+	case r.healthy && r.failures >= h.unhealthyThreshold:
+		r.healthy = false
+	default:
+		changed = false
+	}
+
+	if changed {
+		h.logger.Info("endpoint-health-changed", zap.String("addr", addr), zap.Bool("healthy", r.healthy))
+	}
+	if changed || !found {
+		h.registry.SetEndpointHealth(addr, r.healthy)
+	}
+}
+
+// forget drops the results of endpoints that are no longer targets.
+func (h *HealthChecker) forget(targets map[string]route.HealthCheckTarget) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for addr := range h.results {
+		if _, found := targets[addr]; !found {
+			delete(h.results, addr)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package healthchecker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestHealthChecker(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "HealthChecker Suite")
+}
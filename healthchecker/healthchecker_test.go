@@ -0,0 +1,198 @@
+package healthchecker_test
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	. "code.cloudfoundry.org/gorouter/healthchecker"
+	"code.cloudfoundry.org/gorouter/logger"
+	testRegistry "code.cloudfoundry.org/gorouter/registry/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthChecker", func() {
+	var (
+		cfg      *config.Config
+		registry *testRegistry.FakeRegistry
+		checker  *HealthChecker
+		logger   logger.Logger
+		backend  *httptest.Server
+		status   int32
+		path     atomic.Value
+		agent    atomic.Value
+		addr     string
+	)
+
+	BeforeEach(func() {
+		logger = test_util.NewTestZapLogger("test")
+		cfg = config.DefaultConfig()
+		cfg.ActiveHealthCheck.Enabled = true
+		cfg.ActiveHealthCheck.Interval = 10 * time.Millisecond
+		cfg.ActiveHealthCheck.Timeout = 100 * time.Millisecond
+		cfg.ActiveHealthCheck.HealthyThreshold = 2
+		cfg.ActiveHealthCheck.UnhealthyThreshold = 3
+
+		atomic.StoreInt32(&status, http.StatusOK)
+		backend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path.Store(r.URL.Path)
+			agent.Store(r.Header.Get("User-Agent"))
+			w.WriteHeader(int(atomic.LoadInt32(&status)))
+		}))
+		addr = strings.TrimPrefix(backend.URL, "http://")
+
+		registry = &testRegistry.FakeRegistry{}
+		registry.HealthCheckTargetsReturns(map[string]route.HealthCheckTarget{addr: {Path: "/healthz"}})
+	})
+
+	JustBeforeEach(func() {
+		checker = NewHealthChecker(logger, registry, cfg)
+	})
+
+	AfterEach(func() {
+		backend.Close()
+	})
+
+	checkTimes := func(n int) {
+		for i := 0; i < n; i++ {
+			checker.Check()
+		}
+	}
+
+	It("probes the health check path of the targets", func() {
+		checker.Check()
+
+		Expect(path.Load()).To(Equal("/healthz"))
+		Expect(agent.Load()).To(Equal(cfg.HealthCheckUserAgent))
+	})
+
+	It("reports the health of an endpoint after its first probe only", func() {
+		checkTimes(5)
+
+		Expect(registry.SetEndpointHealthCallCount()).To(Equal(1))
+		a, healthy := registry.SetEndpointHealthArgsForCall(0)
+		Expect(a).To(Equal(addr))
+		Expect(healthy).To(BeTrue())
+	})
+
+	It("reports an endpoint unhealthy after consecutive failures", func() {
+		atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+
+		checkTimes(2)
+		Expect(registry.SetEndpointHealthCallCount()).To(Equal(1))
+
+		checker.Check()
+		Expect(registry.SetEndpointHealthCallCount()).To(Equal(2))
+		a, healthy := registry.SetEndpointHealthArgsForCall(1)
+		Expect(a).To(Equal(addr))
+		Expect(healthy).To(BeFalse())
+
+		checkTimes(3)
+		Expect(registry.SetEndpointHealthCallCount()).To(Equal(2))
+	})
+
+	It("reports an endpoint healthy again after consecutive successes", func() {
+		atomic.StoreInt32(&status, http.StatusInternalServerError)
+		checkTimes(3)
+
+		atomic.StoreInt32(&status, http.StatusOK)
+		checker.Check()
+		Expect(registry.SetEndpointHealthCallCount()).To(Equal(2))
+
+		checker.Check()
+		Expect(registry.SetEndpointHealthCallCount()).To(Equal(3))
+		_, healthy := registry.SetEndpointHealthArgsForCall(2)
+		Expect(healthy).To(BeTrue())
+	})
+
+	It("treats unreachable endpoints as failures", func() {
+		backend.Close()
+
+		checkTimes(3)
+
+		Expect(registry.SetEndpointHealthCallCount()).To(Equal(2))
+		_, healthy := registry.SetEndpointHealthArgsForCall(1)
+		Expect(healthy).To(BeFalse())
+	})
+
+	It("forgets endpoints that are no longer targets", func() {
+		atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+		checkTimes(2)
+
+		registry.HealthCheckTargetsReturns(map[string]route.HealthCheckTarget{})
+		checker.Check()
+		registry.HealthCheckTargetsReturns(map[string]route.HealthCheckTarget{addr: {Path: "/healthz"}})
+		checkTimes(2)
+
+		Expect(registry.SetEndpointHealthCallCount()).To(Equal(2))
+		for i := 0; i < 2; i++ {
+			_, healthy := registry.SetEndpointHealthArgsForCall(i)
+			Expect(healthy).To(BeTrue())
+		}
+	})
+
+	Context("when the endpoint is registered with a TLS port", func() {
+		var tlsBackend *httptest.Server
+
+		BeforeEach(func() {
+			tlsBackend = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path.Store(r.URL.Path)
+				w.WriteHeader(int(atomic.LoadInt32(&status)))
+			}))
+			addr = strings.TrimPrefix(tlsBackend.URL, "https://")
+
+			cfg.Backends.CAPool = x509.NewCertPool()
+			cfg.Backends.CAPool.AddCert(tlsBackend.Certificate())
+		})
+
+		AfterEach(func() {
+			tlsBackend.Close()
+		})
+
+		It("probes the endpoint over TLS", func() {
+			registry.HealthCheckTargetsReturns(map[string]route.HealthCheckTarget{
+				addr: {Path: "/healthz", ServerCertDomainSAN: "example.com"},
+			})
+
+			checkTimes(3)
+
+			Expect(path.Load()).To(Equal("/healthz"))
+			Expect(registry.SetEndpointHealthCallCount()).To(Equal(1))
+			_, healthy := registry.SetEndpointHealthArgsForCall(0)
+			Expect(healthy).To(BeTrue())
+		})
+
+		It("fails endpoints whose certificate does not match", func() {
+			registry.HealthCheckTargetsReturns(map[string]route.HealthCheckTarget{
+				addr: {Path: "/healthz", ServerCertDomainSAN: "other.example.org"},
+			})
+
+			checkTimes(3)
+
+			Expect(registry.SetEndpointHealthCallCount()).To(Equal(2))
+			_, healthy := registry.SetEndpointHealthArgsForCall(1)
+			Expect(healthy).To(BeFalse())
+		})
+	})
+
+	Describe("Run", func() {
+		It("checks the targets periodically until signaled", func() {
+			process := ifrit.Invoke(checker)
+
+			Eventually(registry.HealthCheckTargetsCallCount).Should(BeNumerically(">=", 2))
+
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+		})
+	})
+})
@@ -9,10 +9,12 @@ import (
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/debugserver"
 	"code.cloudfoundry.org/gorouter/access_log"
+	"code.cloudfoundry.org/gorouter/cache"
 	"code.cloudfoundry.org/gorouter/common/schema"
 	"code.cloudfoundry.org/gorouter/common/secure"
 	"code.cloudfoundry.org/gorouter/common/uuid"
 	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/healthchecker"
 	goRouterLogger "code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/mbus"
 	"code.cloudfoundry.org/gorouter/proxy"
@@ -35,6 +37,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime"
 	"syscall"
 	"time"
@@ -114,12 +117,21 @@ func main() {
 		}
 	}
 
-	proxy := buildProxy(logger.Session("proxy"), c, registry, accessLogger, compositeReporter, crypto, cryptoPrev)
+	var responseCache *cache.Store
+	if c.Cache.Enabled {
+		responseCache, err = cache.New(c.Cache)
+		if err != nil {
+			logger.Fatal("error-creating-response-cache", zap.Error(err))
+		}
+	}
+
+	proxy := buildProxy(logger.Session("proxy"), c, registry, accessLogger, compositeReporter, crypto, cryptoPrev, responseCache)
 	healthCheck = 0
-	router, err := router.NewRouter(logger.Session("router"), c, proxy, natsClient, registry, varz, &healthCheck, logCounter, nil)
+	router, err := router.NewRouter(logger.Session("router"), c, proxy, natsClient, registry, varz, compositeReporter, &healthCheck, logCounter, nil, responseCache)
 	if err != nil {
 		logger.Fatal("initialize-router-error", zap.Error(err))
 	}
+	reloadCertificatesOnHUP(router)
 	members := grouper.Members{}
 
 	var routerGroupGuid string
@@ -162,6 +174,10 @@ func main() {
 	subscriber := createSubscriber(logger, c, natsClient, registry, startMsgChan, routerGroupGuid)
 
 	members = append(members, grouper.Member{Name: "subscriber", Runner: subscriber})
+	if c.ActiveHealthCheck.Enabled {
+		healthChecker := healthchecker.NewHealthChecker(logger.Session("health-checker"), registry, c)
+		members = append(members, grouper.Member{Name: "health-checker", Runner: healthChecker})
+	}
 	members = append(members, grouper.Member{Name: "router", Runner: router})
 
 	group := grouper.NewOrdered(os.Interrupt, members)
@@ -177,6 +193,19 @@ func main() {
 	os.Exit(0)
 }
 
+// reloadCertificatesOnHUP reloads the TLS certificates of the router on
+// SIGHUP. The signal is not passed to the process group, which stops on any
+// signal it receives.
+func reloadCertificatesOnHUP(r *router.Router) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			r.ReloadCertificates()
+		}
+	}()
+}
+
 func createCrypto(logger goRouterLogger.Logger, secret string) *secure.AesGCM {
 	// generate secure encryption key using key derivation function (pbkdf2)
 	secretPbkdf2 := secure.NewPbkdf2([]byte(secret), 16)
@@ -187,7 +216,7 @@ func createCrypto(logger goRouterLogger.Logger, secret string) *secure.AesGCM {
 	return crypto
 }
 
-func buildProxy(logger goRouterLogger.Logger, c *config.Config, registry rregistry.Registry, accessLogger access_log.AccessLogger, reporter metrics.CombinedReporter, crypto secure.Crypto, cryptoPrev secure.Crypto) proxy.Proxy {
+func buildProxy(logger goRouterLogger.Logger, c *config.Config, registry rregistry.Registry, accessLogger access_log.AccessLogger, reporter metrics.CombinedReporter, crypto secure.Crypto, cryptoPrev secure.Crypto, responseCache *cache.Store) proxy.Proxy {
 	routeServiceConfig := routeservice.NewRouteServiceConfig(
 		logger,
 		c.RouteServiceEnabled,
@@ -203,7 +232,7 @@ func buildProxy(logger goRouterLogger.Logger, c *config.Config, registry rregist
 	}
 
 	return proxy.NewProxy(logger, accessLogger, c, registry,
-		reporter, routeServiceConfig, tlsConfig, &healthCheck)
+		reporter, routeServiceConfig, tlsConfig, &healthCheck, responseCache)
 }
 
 func setupRoutingApiClient(c *config.Config) routing_api.Client {
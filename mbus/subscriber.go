@@ -3,10 +3,12 @@ package mbus
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"strings"
 
 	"code.cloudfoundry.org/gorouter/common"
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/route"
@@ -29,19 +31,30 @@ type RegistryMessage struct {
 	PrivateInstanceID       string            `json:"private_instance_id"`
 	PrivateInstanceIndex    string            `json:"private_instance_index"`
 	RouterGroupGuid         string            `json:"router_group_guid"`
+	TLSPort                 uint16            `json:"tls_port"`
+	ServerCertDomainSAN     string            `json:"server_cert_domain_san"`
 }
 
 func (rm *RegistryMessage) makeEndpoint() *route.Endpoint {
-	return route.NewEndpoint(
+	port := rm.Port
+	if rm.TLSPort != 0 {
+		port = rm.TLSPort
+	}
+
+	endpoint := route.NewEndpoint(
 		rm.App,
 		rm.Host,
-		rm.Port,
+		port,
 		rm.PrivateInstanceID,
 		rm.PrivateInstanceIndex,
 		rm.Tags,
 		rm.StaleThresholdInSeconds,
 		rm.RouteServiceURL,
 		models.ModificationTag{})
+	if rm.TLSPort != 0 {
+		endpoint.ServerCertDomainSAN = rm.ServerCertDomainSAN
+	}
+	return endpoint
 }
 
 // ValidateMessage checks to ensure the registry message is valid
@@ -223,5 +236,53 @@ func createRegistryMessage(data []byte) (*RegistryMessage, error) {
 		return nil, errors.New("Unable to validate message. route_service_url must be https")
 	}
 
+	if _, err := route.ParseMatchRule(msg.Tags); err != nil {
+		return nil, err
+	}
+
+	if lb, ok := msg.Tags[route.LoadBalancingAlgorithmTag]; ok && !config.IsLoadBalancingAlgorithmValid(lb) {
+		return nil, fmt.Errorf("Invalid load balancing algorithm %s. Allowed values are %s", lb, config.LoadBalancingStrategies)
+	}
+
+	if _, err := route.ParseMaxConcurrentRequests(msg.Tags); err != nil {
+		return nil, err
+	}
+
+	if _, err := route.ParseRetryPolicy(msg.Tags); err != nil {
+		return nil, err
+	}
+
+	if _, err := route.ParseHedgePolicy(msg.Tags); err != nil {
+		return nil, err
+	}
+
+	if _, err := route.ParseBackendProtocol(msg.Tags); err != nil {
+		return nil, err
+	}
+
+	if _, err := route.ParseHeaderRules(msg.Tags); err != nil {
+		return nil, err
+	}
+
+	if _, err := route.ParseCompression(msg.Tags); err != nil {
+		return nil, err
+	}
+
+	if _, err := route.ParseCache(msg.Tags); err != nil {
+		return nil, err
+	}
+
+	if _, err := route.ParseRateLimit(msg.Tags); err != nil {
+		return nil, err
+	}
+
+	if _, err := route.ParseMaxRequestBodySize(msg.Tags); err != nil {
+		return nil, err
+	}
+
+	if msg.TLSPort != 0 && msg.ServerCertDomainSAN == "" {
+		return nil, errors.New("Unable to validate message. server_cert_domain_san is required with tls_port")
+	}
+
 	return &msg, nil
 }
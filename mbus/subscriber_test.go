@@ -257,6 +257,249 @@ var _ = Describe("Subscriber", func() {
 				Consistently(registry.RegisterCallCount).Should(BeZero())
 			})
 		})
+
+		Context("when the message contains an invalid load balancing algorithm", func() {
+			It("does not update the registry", func() {
+				msg := mbus.RegistryMessage{
+					Host: "host",
+					App:  "app",
+					Port: 1111,
+					Uris: []route.Uri{"test.example.com"},
+					Tags: map[string]string{"balancing_algorithm": "fastest"},
+				}
+
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = natsClient.Publish("router.register", data)
+				Expect(err).ToNot(HaveOccurred())
+
+				Consistently(registry.RegisterCallCount).Should(BeZero())
+			})
+		})
+
+		Context("when the message contains an invalid retry policy", func() {
+			It("does not update the registry", func() {
+				msg := mbus.RegistryMessage{
+					Host: "host",
+					App:  "app",
+					Port: 1111,
+					Uris: []route.Uri{"test.example.com"},
+					Tags: map[string]string{"retry_on": "connect-failure,500"},
+				}
+
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = natsClient.Publish("router.register", data)
+				Expect(err).ToNot(HaveOccurred())
+
+				Consistently(registry.RegisterCallCount).Should(BeZero())
+			})
+		})
+
+		Context("when the message contains an invalid hedge delay", func() {
+			It("does not update the registry", func() {
+				msg := mbus.RegistryMessage{
+					Host: "host",
+					App:  "app",
+					Port: 1111,
+					Uris: []route.Uri{"test.example.com"},
+					Tags: map[string]string{"hedge_delay": "p100"},
+				}
+
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = natsClient.Publish("router.register", data)
+				Expect(err).ToNot(HaveOccurred())
+
+				Consistently(registry.RegisterCallCount).Should(BeZero())
+			})
+		})
+
+		Context("when the message contains invalid header rules", func() {
+			It("does not update the registry", func() {
+				msg := mbus.RegistryMessage{
+					Host: "host",
+					App:  "app",
+					Port: 1111,
+					Uris: []route.Uri{"test.example.com"},
+					Tags: map[string]string{"header_rules": `[{"on":"response","action":"strip","name":"Server"}]`},
+				}
+
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = natsClient.Publish("router.register", data)
+				Expect(err).ToNot(HaveOccurred())
+
+				Consistently(registry.RegisterCallCount).Should(BeZero())
+			})
+		})
+
+		Context("when the message contains an invalid cache tag", func() {
+			It("does not update the registry", func() {
+				msg := mbus.RegistryMessage{
+					Host: "host",
+					App:  "app",
+					Port: 1111,
+					Uris: []route.Uri{"test.example.com"},
+					Tags: map[string]string{"cache": "yes"},
+				}
+
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = natsClient.Publish("router.register", data)
+				Expect(err).ToNot(HaveOccurred())
+
+				Consistently(registry.RegisterCallCount).Should(BeZero())
+			})
+		})
+
+		Context("when the message contains an invalid rate limit tag", func() {
+			It("does not update the registry", func() {
+				msg := mbus.RegistryMessage{
+					Host: "host",
+					App:  "app",
+					Port: 1111,
+					Uris: []route.Uri{"test.example.com"},
+					Tags: map[string]string{"rate_limit": "-5"},
+				}
+
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = natsClient.Publish("router.register", data)
+				Expect(err).ToNot(HaveOccurred())
+
+				Consistently(registry.RegisterCallCount).Should(BeZero())
+			})
+		})
+
+		Context("when the message contains an invalid max concurrent requests tag", func() {
+			It("does not update the registry", func() {
+				msg := mbus.RegistryMessage{
+					Host: "host",
+					App:  "app",
+					Port: 1111,
+					Uris: []route.Uri{"test.example.com"},
+					Tags: map[string]string{"max_concurrent_requests": "-1"},
+				}
+
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = natsClient.Publish("router.register", data)
+				Expect(err).ToNot(HaveOccurred())
+
+				Consistently(registry.RegisterCallCount).Should(BeZero())
+			})
+		})
+
+		Context("when the message contains an invalid max request body size tag", func() {
+			It("does not update the registry", func() {
+				msg := mbus.RegistryMessage{
+					Host: "host",
+					App:  "app",
+					Port: 1111,
+					Uris: []route.Uri{"test.example.com"},
+					Tags: map[string]string{"max_request_body_size": "lots"},
+				}
+
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = natsClient.Publish("router.register", data)
+				Expect(err).ToNot(HaveOccurred())
+
+				Consistently(registry.RegisterCallCount).Should(BeZero())
+			})
+		})
+
+		Context("when the message contains an invalid backend protocol", func() {
+			It("does not update the registry", func() {
+				msg := mbus.RegistryMessage{
+					Host: "host",
+					App:  "app",
+					Port: 1111,
+					Uris: []route.Uri{"test.example.com"},
+					Tags: map[string]string{"backend_protocol": "spdy"},
+				}
+
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = natsClient.Publish("router.register", data)
+				Expect(err).ToNot(HaveOccurred())
+
+				Consistently(registry.RegisterCallCount).Should(BeZero())
+			})
+		})
+
+		Context("when the message contains a TLS port", func() {
+			It("registers the endpoint on the TLS port with its server name", func() {
+				msg := mbus.RegistryMessage{
+					Host:                "host",
+					App:                 "app",
+					Port:                1111,
+					TLSPort:             1443,
+					ServerCertDomainSAN: "instance-guid",
+					Uris:                []route.Uri{"test.example.com"},
+				}
+
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = natsClient.Publish("router.register", data)
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(registry.RegisterCallCount).Should(Equal(1))
+				_, endpoint := registry.RegisterArgsForCall(0)
+				Expect(endpoint.CanonicalAddr()).To(Equal("host:1443"))
+				Expect(endpoint.ServerCertDomainSAN).To(Equal("instance-guid"))
+				Expect(endpoint.IsTLS()).To(BeTrue())
+			})
+
+			It("does not update the registry without a server name", func() {
+				msg := mbus.RegistryMessage{
+					Host:    "host",
+					App:     "app",
+					Port:    1111,
+					TLSPort: 1443,
+					Uris:    []route.Uri{"test.example.com"},
+				}
+
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = natsClient.Publish("router.register", data)
+				Expect(err).ToNot(HaveOccurred())
+
+				Consistently(registry.RegisterCallCount).Should(BeZero())
+			})
+		})
+
+		Context("when the message contains an invalid match rule", func() {
+			It("does not update the registry", func() {
+				msg := mbus.RegistryMessage{
+					Host: "host",
+					App:  "app",
+					Port: 1111,
+					Uris: []route.Uri{"test.example.com"},
+					Tags: map[string]string{"match_header": "X-Canary"},
+				}
+
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = natsClient.Publish("router.register", data)
+				Expect(err).ToNot(HaveOccurred())
+
+				Consistently(registry.RegisterCallCount).Should(BeZero())
+			})
+		})
 	})
 
 	Context("when a route is unregistered through NATS", func() {
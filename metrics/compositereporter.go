@@ -13,17 +13,33 @@ import (
 type VarzReporter interface {
 	CaptureBadRequest()
 	CaptureBadGateway()
+	CaptureOverloadedRequest()
+	CaptureRateLimitedRequest()
+	CaptureOversizedRequest()
+	CaptureConnectionTimeout(timeout string)
+	CaptureRejectedConnection()
 	CaptureRoutingRequest(b *route.Endpoint)
 	CaptureRoutingResponseLatency(b *route.Endpoint, statusCode int, t time.Time, d time.Duration)
+	CaptureHedgedRequest()
+	CaptureHedgeWon()
+	CaptureCacheResponse(status string)
 }
 
 //go:generate counterfeiter -o fakes/fake_proxyreporter.go . ProxyReporter
 type ProxyReporter interface {
 	CaptureBadRequest()
 	CaptureBadGateway()
+	CaptureOverloadedRequest()
+	CaptureRateLimitedRequest()
+	CaptureOversizedRequest()
+	CaptureConnectionTimeout(timeout string)
+	CaptureRejectedConnection()
 	CaptureRoutingRequest(b *route.Endpoint)
+	CaptureCrossZoneRequest(b *route.Endpoint)
 	CaptureRoutingResponse(statusCode int)
 	CaptureRoutingResponseLatency(b *route.Endpoint, d time.Duration)
+	CaptureGrpcResponse(status string)
+	CaptureCacheResponse(status string)
 	CaptureRouteServiceResponse(res *http.Response)
 	CaptureWebSocketUpdate()
 	CaptureWebSocketFailure()
@@ -39,15 +55,27 @@ type RouteRegistryReporter interface {
 	CaptureLookupTime(t time.Duration)
 	CaptureRegistryMessage(msg ComponentTagged)
 	CaptureUnregistryMessage(msg ComponentTagged)
+	CaptureEndpointEjected()
+	CaptureEndpointRestored()
 }
 
 //go:generate counterfeiter -o fakes/fake_combinedreporter.go . CombinedReporter
 type CombinedReporter interface {
 	CaptureBadRequest()
 	CaptureBadGateway()
+	CaptureOverloadedRequest()
+	CaptureRateLimitedRequest()
+	CaptureOversizedRequest()
+	CaptureConnectionTimeout(timeout string)
+	CaptureRejectedConnection()
 	CaptureRoutingRequest(b *route.Endpoint)
+	CaptureCrossZoneRequest(b *route.Endpoint)
 	CaptureRoutingResponse(statusCode int)
 	CaptureRoutingResponseLatency(b *route.Endpoint, statusCode int, t time.Time, d time.Duration)
+	CaptureHedgedRequest()
+	CaptureHedgeWon()
+	CaptureGrpcResponse(status string)
+	CaptureCacheResponse(status string)
 	CaptureRouteServiceResponse(res *http.Response)
 	CaptureWebSocketUpdate()
 	CaptureWebSocketFailure()
@@ -75,11 +103,40 @@ func (c *CompositeReporter) CaptureBadGateway() {
 	c.proxyReporter.CaptureBadGateway()
 }
 
+func (c *CompositeReporter) CaptureOverloadedRequest() {
+	c.varzReporter.CaptureOverloadedRequest()
+	c.proxyReporter.CaptureOverloadedRequest()
+}
+
+func (c *CompositeReporter) CaptureRateLimitedRequest() {
+	c.varzReporter.CaptureRateLimitedRequest()
+	c.proxyReporter.CaptureRateLimitedRequest()
+}
+
+func (c *CompositeReporter) CaptureOversizedRequest() {
+	c.varzReporter.CaptureOversizedRequest()
+	c.proxyReporter.CaptureOversizedRequest()
+}
+
+func (c *CompositeReporter) CaptureConnectionTimeout(timeout string) {
+	c.varzReporter.CaptureConnectionTimeout(timeout)
+	c.proxyReporter.CaptureConnectionTimeout(timeout)
+}
+
+func (c *CompositeReporter) CaptureRejectedConnection() {
+	c.varzReporter.CaptureRejectedConnection()
+	c.proxyReporter.CaptureRejectedConnection()
+}
+
 func (c *CompositeReporter) CaptureRoutingRequest(b *route.Endpoint) {
 	c.varzReporter.CaptureRoutingRequest(b)
 	c.proxyReporter.CaptureRoutingRequest(b)
 }
 
+func (c *CompositeReporter) CaptureCrossZoneRequest(b *route.Endpoint) {
+	c.proxyReporter.CaptureCrossZoneRequest(b)
+}
+
 func (c *CompositeReporter) CaptureRouteServiceResponse(res *http.Response) {
 	c.proxyReporter.CaptureRouteServiceResponse(res)
 }
@@ -93,6 +150,23 @@ func (c *CompositeReporter) CaptureRoutingResponseLatency(b *route.Endpoint, sta
 	c.proxyReporter.CaptureRoutingResponseLatency(b, d)
 }
 
+func (c *CompositeReporter) CaptureHedgedRequest() {
+	c.varzReporter.CaptureHedgedRequest()
+}
+
+func (c *CompositeReporter) CaptureHedgeWon() {
+	c.varzReporter.CaptureHedgeWon()
+}
+
+func (c *CompositeReporter) CaptureGrpcResponse(status string) {
+	c.proxyReporter.CaptureGrpcResponse(status)
+}
+
+func (c *CompositeReporter) CaptureCacheResponse(status string) {
+	c.varzReporter.CaptureCacheResponse(status)
+	c.proxyReporter.CaptureCacheResponse(status)
+}
+
 func (c *CompositeReporter) CaptureWebSocketUpdate() {
 	c.proxyReporter.CaptureWebSocketUpdate()
 }
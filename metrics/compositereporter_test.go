@@ -60,6 +60,72 @@ var _ = Describe("CompositeReporter", func() {
 		Expect(callEndpoint).To(Equal(endpoint))
 	})
 
+	It("forwards CaptureOverloadedRequest to both reporters", func() {
+		composite.CaptureOverloadedRequest()
+		Expect(fakeVarzReporter.CaptureOverloadedRequestCallCount()).To(Equal(1))
+		Expect(fakeProxyReporter.CaptureOverloadedRequestCallCount()).To(Equal(1))
+	})
+
+	It("forwards CaptureRateLimitedRequest to both reporters", func() {
+		composite.CaptureRateLimitedRequest()
+		Expect(fakeVarzReporter.CaptureRateLimitedRequestCallCount()).To(Equal(1))
+		Expect(fakeProxyReporter.CaptureRateLimitedRequestCallCount()).To(Equal(1))
+	})
+
+	It("forwards CaptureOversizedRequest to both reporters", func() {
+		composite.CaptureOversizedRequest()
+		Expect(fakeVarzReporter.CaptureOversizedRequestCallCount()).To(Equal(1))
+		Expect(fakeProxyReporter.CaptureOversizedRequestCallCount()).To(Equal(1))
+	})
+
+	It("forwards CaptureHedgedRequest to varz reporter", func() {
+		composite.CaptureHedgedRequest()
+		Expect(fakeVarzReporter.CaptureHedgedRequestCallCount()).To(Equal(1))
+	})
+
+	It("forwards CaptureHedgeWon to varz reporter", func() {
+		composite.CaptureHedgeWon()
+		Expect(fakeVarzReporter.CaptureHedgeWonCallCount()).To(Equal(1))
+	})
+
+	It("forwards CaptureGrpcResponse to proxy reporter", func() {
+		composite.CaptureGrpcResponse("0")
+
+		Expect(fakeProxyReporter.CaptureGrpcResponseCallCount()).To(Equal(1))
+		Expect(fakeProxyReporter.CaptureGrpcResponseArgsForCall(0)).To(Equal("0"))
+	})
+
+	It("forwards CaptureConnectionTimeout to both reporters", func() {
+		composite.CaptureConnectionTimeout("idle")
+
+		Expect(fakeVarzReporter.CaptureConnectionTimeoutCallCount()).To(Equal(1))
+		Expect(fakeVarzReporter.CaptureConnectionTimeoutArgsForCall(0)).To(Equal("idle"))
+		Expect(fakeProxyReporter.CaptureConnectionTimeoutCallCount()).To(Equal(1))
+		Expect(fakeProxyReporter.CaptureConnectionTimeoutArgsForCall(0)).To(Equal("idle"))
+	})
+
+	It("forwards CaptureRejectedConnection to both reporters", func() {
+		composite.CaptureRejectedConnection()
+		Expect(fakeVarzReporter.CaptureRejectedConnectionCallCount()).To(Equal(1))
+		Expect(fakeProxyReporter.CaptureRejectedConnectionCallCount()).To(Equal(1))
+	})
+
+	It("forwards CaptureCacheResponse to both reporters", func() {
+		composite.CaptureCacheResponse("HIT")
+
+		Expect(fakeVarzReporter.CaptureCacheResponseCallCount()).To(Equal(1))
+		Expect(fakeVarzReporter.CaptureCacheResponseArgsForCall(0)).To(Equal("HIT"))
+		Expect(fakeProxyReporter.CaptureCacheResponseCallCount()).To(Equal(1))
+		Expect(fakeProxyReporter.CaptureCacheResponseArgsForCall(0)).To(Equal("HIT"))
+	})
+
+	It("forwards CaptureCrossZoneRequest to proxy reporter", func() {
+		composite.CaptureCrossZoneRequest(endpoint)
+
+		Expect(fakeProxyReporter.CaptureCrossZoneRequestCallCount()).To(Equal(1))
+		Expect(fakeProxyReporter.CaptureCrossZoneRequestArgsForCall(0)).To(Equal(endpoint))
+	})
+
 	It("forwards CaptureRoutingResponseLatency to both reporters", func() {
 		composite.CaptureRoutingResponseLatency(endpoint, response.StatusCode, responseTime, responseDuration)
 
@@ -11,15 +11,23 @@ import (
 )
 
 type FakeCombinedReporter struct {
-	CaptureBadRequestStub            func()
-	captureBadRequestMutex           sync.RWMutex
-	captureBadRequestArgsForCall     []struct{}
-	CaptureBadGatewayStub            func()
-	captureBadGatewayMutex           sync.RWMutex
-	captureBadGatewayArgsForCall     []struct{}
-	CaptureRoutingRequestStub        func(b *route.Endpoint)
-	captureRoutingRequestMutex       sync.RWMutex
-	captureRoutingRequestArgsForCall []struct {
+	CaptureBadRequestStub               func()
+	captureBadRequestMutex              sync.RWMutex
+	captureBadRequestArgsForCall        []struct{}
+	CaptureBadGatewayStub               func()
+	captureBadGatewayMutex              sync.RWMutex
+	captureBadGatewayArgsForCall        []struct{}
+	CaptureOverloadedRequestStub        func()
+	captureOverloadedRequestMutex       sync.RWMutex
+	captureOverloadedRequestArgsForCall []struct{}
+	CaptureRoutingRequestStub           func(b *route.Endpoint)
+	captureRoutingRequestMutex          sync.RWMutex
+	captureRoutingRequestArgsForCall    []struct {
+		b *route.Endpoint
+	}
+	CaptureCrossZoneRequestStub        func(b *route.Endpoint)
+	captureCrossZoneRequestMutex       sync.RWMutex
+	captureCrossZoneRequestArgsForCall []struct {
 		b *route.Endpoint
 	}
 	CaptureRoutingResponseStub        func(statusCode int)
@@ -35,6 +43,17 @@ type FakeCombinedReporter struct {
 		t          time.Time
 		d          time.Duration
 	}
+	CaptureHedgedRequestStub        func()
+	captureHedgedRequestMutex       sync.RWMutex
+	captureHedgedRequestArgsForCall []struct{}
+	CaptureHedgeWonStub             func()
+	captureHedgeWonMutex            sync.RWMutex
+	captureHedgeWonArgsForCall      []struct{}
+	CaptureGrpcResponseStub         func(status string)
+	captureGrpcResponseMutex        sync.RWMutex
+	captureGrpcResponseArgsForCall  []struct {
+		status string
+	}
 	CaptureRouteServiceResponseStub        func(res *http.Response)
 	captureRouteServiceResponseMutex       sync.RWMutex
 	captureRouteServiceResponseArgsForCall []struct {
@@ -46,6 +65,25 @@ type FakeCombinedReporter struct {
 	CaptureWebSocketFailureStub        func()
 	captureWebSocketFailureMutex       sync.RWMutex
 	captureWebSocketFailureArgsForCall []struct{}
+	CaptureCacheResponseStub           func(status string)
+	captureCacheResponseMutex          sync.RWMutex
+	captureCacheResponseArgsForCall    []struct {
+		status string
+	}
+	CaptureRateLimitedRequestStub        func()
+	captureRateLimitedRequestMutex       sync.RWMutex
+	captureRateLimitedRequestArgsForCall []struct{}
+	CaptureOversizedRequestStub          func()
+	captureOversizedRequestMutex         sync.RWMutex
+	captureOversizedRequestArgsForCall   []struct{}
+	CaptureConnectionTimeoutStub         func(timeout string)
+	captureConnectionTimeoutMutex        sync.RWMutex
+	captureConnectionTimeoutArgsForCall  []struct {
+		timeout string
+	}
+	CaptureRejectedConnectionStub        func()
+	captureRejectedConnectionMutex       sync.RWMutex
+	captureRejectedConnectionArgsForCall []struct{}
 }
 
 func (fake *FakeCombinedReporter) CaptureBadRequest() {
@@ -78,6 +116,21 @@ func (fake *FakeCombinedReporter) CaptureBadGatewayCallCount() int {
 	return len(fake.captureBadGatewayArgsForCall)
 }
 
+func (fake *FakeCombinedReporter) CaptureOverloadedRequest() {
+	fake.captureOverloadedRequestMutex.Lock()
+	fake.captureOverloadedRequestArgsForCall = append(fake.captureOverloadedRequestArgsForCall, struct{}{})
+	fake.captureOverloadedRequestMutex.Unlock()
+	if fake.CaptureOverloadedRequestStub != nil {
+		fake.CaptureOverloadedRequestStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureOverloadedRequestCallCount() int {
+	fake.captureOverloadedRequestMutex.RLock()
+	defer fake.captureOverloadedRequestMutex.RUnlock()
+	return len(fake.captureOverloadedRequestArgsForCall)
+}
+
 func (fake *FakeCombinedReporter) CaptureRoutingRequest(b *route.Endpoint) {
 	fake.captureRoutingRequestMutex.Lock()
 	fake.captureRoutingRequestArgsForCall = append(fake.captureRoutingRequestArgsForCall, struct {
@@ -101,6 +154,29 @@ func (fake *FakeCombinedReporter) CaptureRoutingRequestArgsForCall(i int) *route
 	return fake.captureRoutingRequestArgsForCall[i].b
 }
 
+func (fake *FakeCombinedReporter) CaptureCrossZoneRequest(b *route.Endpoint) {
+	fake.captureCrossZoneRequestMutex.Lock()
+	fake.captureCrossZoneRequestArgsForCall = append(fake.captureCrossZoneRequestArgsForCall, struct {
+		b *route.Endpoint
+	}{b})
+	fake.captureCrossZoneRequestMutex.Unlock()
+	if fake.CaptureCrossZoneRequestStub != nil {
+		fake.CaptureCrossZoneRequestStub(b)
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureCrossZoneRequestCallCount() int {
+	fake.captureCrossZoneRequestMutex.RLock()
+	defer fake.captureCrossZoneRequestMutex.RUnlock()
+	return len(fake.captureCrossZoneRequestArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureCrossZoneRequestArgsForCall(i int) *route.Endpoint {
+	fake.captureCrossZoneRequestMutex.RLock()
+	defer fake.captureCrossZoneRequestMutex.RUnlock()
+	return fake.captureCrossZoneRequestArgsForCall[i].b
+}
+
 func (fake *FakeCombinedReporter) CaptureRoutingResponse(statusCode int) {
 	fake.captureRoutingResponseMutex.Lock()
 	fake.captureRoutingResponseArgsForCall = append(fake.captureRoutingResponseArgsForCall, struct {
@@ -150,6 +226,59 @@ func (fake *FakeCombinedReporter) CaptureRoutingResponseLatencyArgsForCall(i int
 	return fake.captureRoutingResponseLatencyArgsForCall[i].b, fake.captureRoutingResponseLatencyArgsForCall[i].statusCode, fake.captureRoutingResponseLatencyArgsForCall[i].t, fake.captureRoutingResponseLatencyArgsForCall[i].d
 }
 
+func (fake *FakeCombinedReporter) CaptureHedgedRequest() {
+	fake.captureHedgedRequestMutex.Lock()
+	fake.captureHedgedRequestArgsForCall = append(fake.captureHedgedRequestArgsForCall, struct{}{})
+	fake.captureHedgedRequestMutex.Unlock()
+	if fake.CaptureHedgedRequestStub != nil {
+		fake.CaptureHedgedRequestStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureHedgedRequestCallCount() int {
+	fake.captureHedgedRequestMutex.RLock()
+	defer fake.captureHedgedRequestMutex.RUnlock()
+	return len(fake.captureHedgedRequestArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureHedgeWon() {
+	fake.captureHedgeWonMutex.Lock()
+	fake.captureHedgeWonArgsForCall = append(fake.captureHedgeWonArgsForCall, struct{}{})
+	fake.captureHedgeWonMutex.Unlock()
+	if fake.CaptureHedgeWonStub != nil {
+		fake.CaptureHedgeWonStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureHedgeWonCallCount() int {
+	fake.captureHedgeWonMutex.RLock()
+	defer fake.captureHedgeWonMutex.RUnlock()
+	return len(fake.captureHedgeWonArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureGrpcResponse(status string) {
+	fake.captureGrpcResponseMutex.Lock()
+	fake.captureGrpcResponseArgsForCall = append(fake.captureGrpcResponseArgsForCall, struct {
+		status string
+	}{status})
+	fake.captureGrpcResponseMutex.Unlock()
+	if fake.CaptureGrpcResponseStub != nil {
+		fake.CaptureGrpcResponseStub(status)
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureGrpcResponseCallCount() int {
+	fake.captureGrpcResponseMutex.RLock()
+	defer fake.captureGrpcResponseMutex.RUnlock()
+	return len(fake.captureGrpcResponseArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureGrpcResponseArgsForCall(i int) string {
+	fake.captureGrpcResponseMutex.RLock()
+	defer fake.captureGrpcResponseMutex.RUnlock()
+	return fake.captureGrpcResponseArgsForCall[i].status
+}
+
 func (fake *FakeCombinedReporter) CaptureRouteServiceResponse(res *http.Response) {
 	fake.captureRouteServiceResponseMutex.Lock()
 	fake.captureRouteServiceResponseArgsForCall = append(fake.captureRouteServiceResponseArgsForCall, struct {
@@ -203,4 +332,95 @@ func (fake *FakeCombinedReporter) CaptureWebSocketFailureCallCount() int {
 	return len(fake.captureWebSocketFailureArgsForCall)
 }
 
+func (fake *FakeCombinedReporter) CaptureCacheResponse(status string) {
+	fake.captureCacheResponseMutex.Lock()
+	fake.captureCacheResponseArgsForCall = append(fake.captureCacheResponseArgsForCall, struct {
+		status string
+	}{status})
+	fake.captureCacheResponseMutex.Unlock()
+	if fake.CaptureCacheResponseStub != nil {
+		fake.CaptureCacheResponseStub(status)
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureCacheResponseCallCount() int {
+	fake.captureCacheResponseMutex.RLock()
+	defer fake.captureCacheResponseMutex.RUnlock()
+	return len(fake.captureCacheResponseArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureCacheResponseArgsForCall(i int) string {
+	fake.captureCacheResponseMutex.RLock()
+	defer fake.captureCacheResponseMutex.RUnlock()
+	return fake.captureCacheResponseArgsForCall[i].status
+}
+
+func (fake *FakeCombinedReporter) CaptureRateLimitedRequest() {
+	fake.captureRateLimitedRequestMutex.Lock()
+	fake.captureRateLimitedRequestArgsForCall = append(fake.captureRateLimitedRequestArgsForCall, struct{}{})
+	fake.captureRateLimitedRequestMutex.Unlock()
+	if fake.CaptureRateLimitedRequestStub != nil {
+		fake.CaptureRateLimitedRequestStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureRateLimitedRequestCallCount() int {
+	fake.captureRateLimitedRequestMutex.RLock()
+	defer fake.captureRateLimitedRequestMutex.RUnlock()
+	return len(fake.captureRateLimitedRequestArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureOversizedRequest() {
+	fake.captureOversizedRequestMutex.Lock()
+	fake.captureOversizedRequestArgsForCall = append(fake.captureOversizedRequestArgsForCall, struct{}{})
+	fake.captureOversizedRequestMutex.Unlock()
+	if fake.CaptureOversizedRequestStub != nil {
+		fake.CaptureOversizedRequestStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureOversizedRequestCallCount() int {
+	fake.captureOversizedRequestMutex.RLock()
+	defer fake.captureOversizedRequestMutex.RUnlock()
+	return len(fake.captureOversizedRequestArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureConnectionTimeout(timeout string) {
+	fake.captureConnectionTimeoutMutex.Lock()
+	fake.captureConnectionTimeoutArgsForCall = append(fake.captureConnectionTimeoutArgsForCall, struct {
+		timeout string
+	}{timeout})
+	fake.captureConnectionTimeoutMutex.Unlock()
+	if fake.CaptureConnectionTimeoutStub != nil {
+		fake.CaptureConnectionTimeoutStub(timeout)
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureConnectionTimeoutCallCount() int {
+	fake.captureConnectionTimeoutMutex.RLock()
+	defer fake.captureConnectionTimeoutMutex.RUnlock()
+	return len(fake.captureConnectionTimeoutArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureConnectionTimeoutArgsForCall(i int) string {
+	fake.captureConnectionTimeoutMutex.RLock()
+	defer fake.captureConnectionTimeoutMutex.RUnlock()
+	return fake.captureConnectionTimeoutArgsForCall[i].timeout
+}
+
+func (fake *FakeCombinedReporter) CaptureRejectedConnection() {
+	fake.captureRejectedConnectionMutex.Lock()
+	fake.captureRejectedConnectionArgsForCall = append(fake.captureRejectedConnectionArgsForCall, struct{}{})
+	fake.captureRejectedConnectionMutex.Unlock()
+	if fake.CaptureRejectedConnectionStub != nil {
+		fake.CaptureRejectedConnectionStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureRejectedConnectionCallCount() int {
+	fake.captureRejectedConnectionMutex.RLock()
+	defer fake.captureRejectedConnectionMutex.RUnlock()
+	return len(fake.captureRejectedConnectionArgsForCall)
+}
+
 var _ metrics.CombinedReporter = new(FakeCombinedReporter)
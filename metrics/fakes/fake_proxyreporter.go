@@ -11,15 +11,23 @@ import (
 )
 
 type FakeProxyReporter struct {
-	CaptureBadRequestStub            func()
-	captureBadRequestMutex           sync.RWMutex
-	captureBadRequestArgsForCall     []struct{}
-	CaptureBadGatewayStub            func()
-	captureBadGatewayMutex           sync.RWMutex
-	captureBadGatewayArgsForCall     []struct{}
-	CaptureRoutingRequestStub        func(b *route.Endpoint)
-	captureRoutingRequestMutex       sync.RWMutex
-	captureRoutingRequestArgsForCall []struct {
+	CaptureBadRequestStub               func()
+	captureBadRequestMutex              sync.RWMutex
+	captureBadRequestArgsForCall        []struct{}
+	CaptureBadGatewayStub               func()
+	captureBadGatewayMutex              sync.RWMutex
+	captureBadGatewayArgsForCall        []struct{}
+	CaptureOverloadedRequestStub        func()
+	captureOverloadedRequestMutex       sync.RWMutex
+	captureOverloadedRequestArgsForCall []struct{}
+	CaptureRoutingRequestStub           func(b *route.Endpoint)
+	captureRoutingRequestMutex          sync.RWMutex
+	captureRoutingRequestArgsForCall    []struct {
+		b *route.Endpoint
+	}
+	CaptureCrossZoneRequestStub        func(b *route.Endpoint)
+	captureCrossZoneRequestMutex       sync.RWMutex
+	captureCrossZoneRequestArgsForCall []struct {
 		b *route.Endpoint
 	}
 	CaptureRoutingResponseStub        func(statusCode int)
@@ -33,6 +41,11 @@ type FakeProxyReporter struct {
 		b *route.Endpoint
 		d time.Duration
 	}
+	CaptureGrpcResponseStub        func(status string)
+	captureGrpcResponseMutex       sync.RWMutex
+	captureGrpcResponseArgsForCall []struct {
+		status string
+	}
 	CaptureRouteServiceResponseStub        func(res *http.Response)
 	captureRouteServiceResponseMutex       sync.RWMutex
 	captureRouteServiceResponseArgsForCall []struct {
@@ -44,6 +57,25 @@ type FakeProxyReporter struct {
 	CaptureWebSocketFailureStub        func()
 	captureWebSocketFailureMutex       sync.RWMutex
 	captureWebSocketFailureArgsForCall []struct{}
+	CaptureCacheResponseStub           func(status string)
+	captureCacheResponseMutex          sync.RWMutex
+	captureCacheResponseArgsForCall    []struct {
+		status string
+	}
+	CaptureRateLimitedRequestStub        func()
+	captureRateLimitedRequestMutex       sync.RWMutex
+	captureRateLimitedRequestArgsForCall []struct{}
+	CaptureOversizedRequestStub          func()
+	captureOversizedRequestMutex         sync.RWMutex
+	captureOversizedRequestArgsForCall   []struct{}
+	CaptureConnectionTimeoutStub         func(timeout string)
+	captureConnectionTimeoutMutex        sync.RWMutex
+	captureConnectionTimeoutArgsForCall  []struct {
+		timeout string
+	}
+	CaptureRejectedConnectionStub        func()
+	captureRejectedConnectionMutex       sync.RWMutex
+	captureRejectedConnectionArgsForCall []struct{}
 }
 
 func (fake *FakeProxyReporter) CaptureBadRequest() {
@@ -76,6 +108,21 @@ func (fake *FakeProxyReporter) CaptureBadGatewayCallCount() int {
 	return len(fake.captureBadGatewayArgsForCall)
 }
 
+func (fake *FakeProxyReporter) CaptureOverloadedRequest() {
+	fake.captureOverloadedRequestMutex.Lock()
+	fake.captureOverloadedRequestArgsForCall = append(fake.captureOverloadedRequestArgsForCall, struct{}{})
+	fake.captureOverloadedRequestMutex.Unlock()
+	if fake.CaptureOverloadedRequestStub != nil {
+		fake.CaptureOverloadedRequestStub()
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureOverloadedRequestCallCount() int {
+	fake.captureOverloadedRequestMutex.RLock()
+	defer fake.captureOverloadedRequestMutex.RUnlock()
+	return len(fake.captureOverloadedRequestArgsForCall)
+}
+
 func (fake *FakeProxyReporter) CaptureRoutingRequest(b *route.Endpoint) {
 	fake.captureRoutingRequestMutex.Lock()
 	fake.captureRoutingRequestArgsForCall = append(fake.captureRoutingRequestArgsForCall, struct {
@@ -99,6 +146,29 @@ func (fake *FakeProxyReporter) CaptureRoutingRequestArgsForCall(i int) *route.En
 	return fake.captureRoutingRequestArgsForCall[i].b
 }
 
+func (fake *FakeProxyReporter) CaptureCrossZoneRequest(b *route.Endpoint) {
+	fake.captureCrossZoneRequestMutex.Lock()
+	fake.captureCrossZoneRequestArgsForCall = append(fake.captureCrossZoneRequestArgsForCall, struct {
+		b *route.Endpoint
+	}{b})
+	fake.captureCrossZoneRequestMutex.Unlock()
+	if fake.CaptureCrossZoneRequestStub != nil {
+		fake.CaptureCrossZoneRequestStub(b)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureCrossZoneRequestCallCount() int {
+	fake.captureCrossZoneRequestMutex.RLock()
+	defer fake.captureCrossZoneRequestMutex.RUnlock()
+	return len(fake.captureCrossZoneRequestArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureCrossZoneRequestArgsForCall(i int) *route.Endpoint {
+	fake.captureCrossZoneRequestMutex.RLock()
+	defer fake.captureCrossZoneRequestMutex.RUnlock()
+	return fake.captureCrossZoneRequestArgsForCall[i].b
+}
+
 func (fake *FakeProxyReporter) CaptureRoutingResponse(statusCode int) {
 	fake.captureRoutingResponseMutex.Lock()
 	fake.captureRoutingResponseArgsForCall = append(fake.captureRoutingResponseArgsForCall, struct {
@@ -146,6 +216,29 @@ func (fake *FakeProxyReporter) CaptureRoutingResponseLatencyArgsForCall(i int) (
 	return fake.captureRoutingResponseLatencyArgsForCall[i].b, fake.captureRoutingResponseLatencyArgsForCall[i].d
 }
 
+func (fake *FakeProxyReporter) CaptureGrpcResponse(status string) {
+	fake.captureGrpcResponseMutex.Lock()
+	fake.captureGrpcResponseArgsForCall = append(fake.captureGrpcResponseArgsForCall, struct {
+		status string
+	}{status})
+	fake.captureGrpcResponseMutex.Unlock()
+	if fake.CaptureGrpcResponseStub != nil {
+		fake.CaptureGrpcResponseStub(status)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureGrpcResponseCallCount() int {
+	fake.captureGrpcResponseMutex.RLock()
+	defer fake.captureGrpcResponseMutex.RUnlock()
+	return len(fake.captureGrpcResponseArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureGrpcResponseArgsForCall(i int) string {
+	fake.captureGrpcResponseMutex.RLock()
+	defer fake.captureGrpcResponseMutex.RUnlock()
+	return fake.captureGrpcResponseArgsForCall[i].status
+}
+
 func (fake *FakeProxyReporter) CaptureRouteServiceResponse(res *http.Response) {
 	fake.captureRouteServiceResponseMutex.Lock()
 	fake.captureRouteServiceResponseArgsForCall = append(fake.captureRouteServiceResponseArgsForCall, struct {
@@ -199,4 +292,95 @@ func (fake *FakeProxyReporter) CaptureWebSocketFailureCallCount() int {
 	return len(fake.captureWebSocketFailureArgsForCall)
 }
 
+func (fake *FakeProxyReporter) CaptureCacheResponse(status string) {
+	fake.captureCacheResponseMutex.Lock()
+	fake.captureCacheResponseArgsForCall = append(fake.captureCacheResponseArgsForCall, struct {
+		status string
+	}{status})
+	fake.captureCacheResponseMutex.Unlock()
+	if fake.CaptureCacheResponseStub != nil {
+		fake.CaptureCacheResponseStub(status)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureCacheResponseCallCount() int {
+	fake.captureCacheResponseMutex.RLock()
+	defer fake.captureCacheResponseMutex.RUnlock()
+	return len(fake.captureCacheResponseArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureCacheResponseArgsForCall(i int) string {
+	fake.captureCacheResponseMutex.RLock()
+	defer fake.captureCacheResponseMutex.RUnlock()
+	return fake.captureCacheResponseArgsForCall[i].status
+}
+
+func (fake *FakeProxyReporter) CaptureRateLimitedRequest() {
+	fake.captureRateLimitedRequestMutex.Lock()
+	fake.captureRateLimitedRequestArgsForCall = append(fake.captureRateLimitedRequestArgsForCall, struct{}{})
+	fake.captureRateLimitedRequestMutex.Unlock()
+	if fake.CaptureRateLimitedRequestStub != nil {
+		fake.CaptureRateLimitedRequestStub()
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureRateLimitedRequestCallCount() int {
+	fake.captureRateLimitedRequestMutex.RLock()
+	defer fake.captureRateLimitedRequestMutex.RUnlock()
+	return len(fake.captureRateLimitedRequestArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureOversizedRequest() {
+	fake.captureOversizedRequestMutex.Lock()
+	fake.captureOversizedRequestArgsForCall = append(fake.captureOversizedRequestArgsForCall, struct{}{})
+	fake.captureOversizedRequestMutex.Unlock()
+	if fake.CaptureOversizedRequestStub != nil {
+		fake.CaptureOversizedRequestStub()
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureOversizedRequestCallCount() int {
+	fake.captureOversizedRequestMutex.RLock()
+	defer fake.captureOversizedRequestMutex.RUnlock()
+	return len(fake.captureOversizedRequestArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureConnectionTimeout(timeout string) {
+	fake.captureConnectionTimeoutMutex.Lock()
+	fake.captureConnectionTimeoutArgsForCall = append(fake.captureConnectionTimeoutArgsForCall, struct {
+		timeout string
+	}{timeout})
+	fake.captureConnectionTimeoutMutex.Unlock()
+	if fake.CaptureConnectionTimeoutStub != nil {
+		fake.CaptureConnectionTimeoutStub(timeout)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureConnectionTimeoutCallCount() int {
+	fake.captureConnectionTimeoutMutex.RLock()
+	defer fake.captureConnectionTimeoutMutex.RUnlock()
+	return len(fake.captureConnectionTimeoutArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureConnectionTimeoutArgsForCall(i int) string {
+	fake.captureConnectionTimeoutMutex.RLock()
+	defer fake.captureConnectionTimeoutMutex.RUnlock()
+	return fake.captureConnectionTimeoutArgsForCall[i].timeout
+}
+
+func (fake *FakeProxyReporter) CaptureRejectedConnection() {
+	fake.captureRejectedConnectionMutex.Lock()
+	fake.captureRejectedConnectionArgsForCall = append(fake.captureRejectedConnectionArgsForCall, struct{}{})
+	fake.captureRejectedConnectionMutex.Unlock()
+	if fake.CaptureRejectedConnectionStub != nil {
+		fake.CaptureRejectedConnectionStub()
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureRejectedConnectionCallCount() int {
+	fake.captureRejectedConnectionMutex.RLock()
+	defer fake.captureRejectedConnectionMutex.RUnlock()
+	return len(fake.captureRejectedConnectionArgsForCall)
+}
+
 var _ metrics.ProxyReporter = new(FakeProxyReporter)
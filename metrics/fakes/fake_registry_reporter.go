@@ -30,6 +30,12 @@ type FakeRouteRegistryReporter struct {
 	captureUnregistryMessageArgsForCall []struct {
 		msg metrics.ComponentTagged
 	}
+	CaptureEndpointEjectedStub         func()
+	captureEndpointEjectedMutex        sync.RWMutex
+	captureEndpointEjectedArgsForCall  []struct{}
+	CaptureEndpointRestoredStub        func()
+	captureEndpointRestoredMutex       sync.RWMutex
+	captureEndpointRestoredArgsForCall []struct{}
 }
 
 func (fake *FakeRouteRegistryReporter) CaptureRouteStats(totalRoutes int, msSinceLastUpdate uint64) {
@@ -125,4 +131,34 @@ func (fake *FakeRouteRegistryReporter) CaptureUnregistryMessageArgsForCall(i int
 	return fake.captureUnregistryMessageArgsForCall[i].msg
 }
 
+func (fake *FakeRouteRegistryReporter) CaptureEndpointEjected() {
+	fake.captureEndpointEjectedMutex.Lock()
+	fake.captureEndpointEjectedArgsForCall = append(fake.captureEndpointEjectedArgsForCall, struct{}{})
+	fake.captureEndpointEjectedMutex.Unlock()
+	if fake.CaptureEndpointEjectedStub != nil {
+		fake.CaptureEndpointEjectedStub()
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureEndpointEjectedCallCount() int {
+	fake.captureEndpointEjectedMutex.RLock()
+	defer fake.captureEndpointEjectedMutex.RUnlock()
+	return len(fake.captureEndpointEjectedArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureEndpointRestored() {
+	fake.captureEndpointRestoredMutex.Lock()
+	fake.captureEndpointRestoredArgsForCall = append(fake.captureEndpointRestoredArgsForCall, struct{}{})
+	fake.captureEndpointRestoredMutex.Unlock()
+	if fake.CaptureEndpointRestoredStub != nil {
+		fake.CaptureEndpointRestoredStub()
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureEndpointRestoredCallCount() int {
+	fake.captureEndpointRestoredMutex.RLock()
+	defer fake.captureEndpointRestoredMutex.RUnlock()
+	return len(fake.captureEndpointRestoredArgsForCall)
+}
+
 var _ metrics.RouteRegistryReporter = new(FakeRouteRegistryReporter)
@@ -10,15 +10,18 @@ import (
 )
 
 type FakeVarzReporter struct {
-	CaptureBadRequestStub            func()
-	captureBadRequestMutex           sync.RWMutex
-	captureBadRequestArgsForCall     []struct{}
-	CaptureBadGatewayStub            func()
-	captureBadGatewayMutex           sync.RWMutex
-	captureBadGatewayArgsForCall     []struct{}
-	CaptureRoutingRequestStub        func(b *route.Endpoint)
-	captureRoutingRequestMutex       sync.RWMutex
-	captureRoutingRequestArgsForCall []struct {
+	CaptureBadRequestStub               func()
+	captureBadRequestMutex              sync.RWMutex
+	captureBadRequestArgsForCall        []struct{}
+	CaptureBadGatewayStub               func()
+	captureBadGatewayMutex              sync.RWMutex
+	captureBadGatewayArgsForCall        []struct{}
+	CaptureOverloadedRequestStub        func()
+	captureOverloadedRequestMutex       sync.RWMutex
+	captureOverloadedRequestArgsForCall []struct{}
+	CaptureRoutingRequestStub           func(b *route.Endpoint)
+	captureRoutingRequestMutex          sync.RWMutex
+	captureRoutingRequestArgsForCall    []struct {
 		b *route.Endpoint
 	}
 	CaptureRoutingResponseLatencyStub        func(b *route.Endpoint, statusCode int, t time.Time, d time.Duration)
@@ -29,6 +32,31 @@ type FakeVarzReporter struct {
 		t          time.Time
 		d          time.Duration
 	}
+	CaptureHedgedRequestStub        func()
+	captureHedgedRequestMutex       sync.RWMutex
+	captureHedgedRequestArgsForCall []struct{}
+	CaptureHedgeWonStub             func()
+	captureHedgeWonMutex            sync.RWMutex
+	captureHedgeWonArgsForCall      []struct{}
+	CaptureCacheResponseStub        func(status string)
+	captureCacheResponseMutex       sync.RWMutex
+	captureCacheResponseArgsForCall []struct {
+		status string
+	}
+	CaptureRateLimitedRequestStub        func()
+	captureRateLimitedRequestMutex       sync.RWMutex
+	captureRateLimitedRequestArgsForCall []struct{}
+	CaptureOversizedRequestStub          func()
+	captureOversizedRequestMutex         sync.RWMutex
+	captureOversizedRequestArgsForCall   []struct{}
+	CaptureConnectionTimeoutStub         func(timeout string)
+	captureConnectionTimeoutMutex        sync.RWMutex
+	captureConnectionTimeoutArgsForCall  []struct {
+		timeout string
+	}
+	CaptureRejectedConnectionStub        func()
+	captureRejectedConnectionMutex       sync.RWMutex
+	captureRejectedConnectionArgsForCall []struct{}
 }
 
 func (fake *FakeVarzReporter) CaptureBadRequest() {
@@ -61,6 +89,21 @@ func (fake *FakeVarzReporter) CaptureBadGatewayCallCount() int {
 	return len(fake.captureBadGatewayArgsForCall)
 }
 
+func (fake *FakeVarzReporter) CaptureOverloadedRequest() {
+	fake.captureOverloadedRequestMutex.Lock()
+	fake.captureOverloadedRequestArgsForCall = append(fake.captureOverloadedRequestArgsForCall, struct{}{})
+	fake.captureOverloadedRequestMutex.Unlock()
+	if fake.CaptureOverloadedRequestStub != nil {
+		fake.CaptureOverloadedRequestStub()
+	}
+}
+
+func (fake *FakeVarzReporter) CaptureOverloadedRequestCallCount() int {
+	fake.captureOverloadedRequestMutex.RLock()
+	defer fake.captureOverloadedRequestMutex.RUnlock()
+	return len(fake.captureOverloadedRequestArgsForCall)
+}
+
 func (fake *FakeVarzReporter) CaptureRoutingRequest(b *route.Endpoint) {
 	fake.captureRoutingRequestMutex.Lock()
 	fake.captureRoutingRequestArgsForCall = append(fake.captureRoutingRequestArgsForCall, struct {
@@ -110,4 +153,125 @@ func (fake *FakeVarzReporter) CaptureRoutingResponseLatencyArgsForCall(i int) (*
 	return fake.captureRoutingResponseLatencyArgsForCall[i].b, fake.captureRoutingResponseLatencyArgsForCall[i].statusCode, fake.captureRoutingResponseLatencyArgsForCall[i].t, fake.captureRoutingResponseLatencyArgsForCall[i].d
 }
 
+func (fake *FakeVarzReporter) CaptureHedgedRequest() {
+	fake.captureHedgedRequestMutex.Lock()
+	fake.captureHedgedRequestArgsForCall = append(fake.captureHedgedRequestArgsForCall, struct{}{})
+	fake.captureHedgedRequestMutex.Unlock()
+	if fake.CaptureHedgedRequestStub != nil {
+		fake.CaptureHedgedRequestStub()
+	}
+}
+
+func (fake *FakeVarzReporter) CaptureHedgedRequestCallCount() int {
+	fake.captureHedgedRequestMutex.RLock()
+	defer fake.captureHedgedRequestMutex.RUnlock()
+	return len(fake.captureHedgedRequestArgsForCall)
+}
+
+func (fake *FakeVarzReporter) CaptureHedgeWon() {
+	fake.captureHedgeWonMutex.Lock()
+	fake.captureHedgeWonArgsForCall = append(fake.captureHedgeWonArgsForCall, struct{}{})
+	fake.captureHedgeWonMutex.Unlock()
+	if fake.CaptureHedgeWonStub != nil {
+		fake.CaptureHedgeWonStub()
+	}
+}
+
+func (fake *FakeVarzReporter) CaptureHedgeWonCallCount() int {
+	fake.captureHedgeWonMutex.RLock()
+	defer fake.captureHedgeWonMutex.RUnlock()
+	return len(fake.captureHedgeWonArgsForCall)
+}
+
+func (fake *FakeVarzReporter) CaptureCacheResponse(status string) {
+	fake.captureCacheResponseMutex.Lock()
+	fake.captureCacheResponseArgsForCall = append(fake.captureCacheResponseArgsForCall, struct {
+		status string
+	}{status})
+	fake.captureCacheResponseMutex.Unlock()
+	if fake.CaptureCacheResponseStub != nil {
+		fake.CaptureCacheResponseStub(status)
+	}
+}
+
+func (fake *FakeVarzReporter) CaptureCacheResponseCallCount() int {
+	fake.captureCacheResponseMutex.RLock()
+	defer fake.captureCacheResponseMutex.RUnlock()
+	return len(fake.captureCacheResponseArgsForCall)
+}
+
+func (fake *FakeVarzReporter) CaptureCacheResponseArgsForCall(i int) string {
+	fake.captureCacheResponseMutex.RLock()
+	defer fake.captureCacheResponseMutex.RUnlock()
+	return fake.captureCacheResponseArgsForCall[i].status
+}
+
+func (fake *FakeVarzReporter) CaptureRateLimitedRequest() {
+	fake.captureRateLimitedRequestMutex.Lock()
+	fake.captureRateLimitedRequestArgsForCall = append(fake.captureRateLimitedRequestArgsForCall, struct{}{})
+	fake.captureRateLimitedRequestMutex.Unlock()
+	if fake.CaptureRateLimitedRequestStub != nil {
+		fake.CaptureRateLimitedRequestStub()
+	}
+}
+
+func (fake *FakeVarzReporter) CaptureRateLimitedRequestCallCount() int {
+	fake.captureRateLimitedRequestMutex.RLock()
+	defer fake.captureRateLimitedRequestMutex.RUnlock()
+	return len(fake.captureRateLimitedRequestArgsForCall)
+}
+
+func (fake *FakeVarzReporter) CaptureOversizedRequest() {
+	fake.captureOversizedRequestMutex.Lock()
+	fake.captureOversizedRequestArgsForCall = append(fake.captureOversizedRequestArgsForCall, struct{}{})
+	fake.captureOversizedRequestMutex.Unlock()
+	if fake.CaptureOversizedRequestStub != nil {
+		fake.CaptureOversizedRequestStub()
+	}
+}
+
+func (fake *FakeVarzReporter) CaptureOversizedRequestCallCount() int {
+	fake.captureOversizedRequestMutex.RLock()
+	defer fake.captureOversizedRequestMutex.RUnlock()
+	return len(fake.captureOversizedRequestArgsForCall)
+}
+
+func (fake *FakeVarzReporter) CaptureConnectionTimeout(timeout string) {
+	fake.captureConnectionTimeoutMutex.Lock()
+	fake.captureConnectionTimeoutArgsForCall = append(fake.captureConnectionTimeoutArgsForCall, struct {
+		timeout string
+	}{timeout})
+	fake.captureConnectionTimeoutMutex.Unlock()
+	if fake.CaptureConnectionTimeoutStub != nil {
+		fake.CaptureConnectionTimeoutStub(timeout)
+	}
+}
+
+func (fake *FakeVarzReporter) CaptureConnectionTimeoutCallCount() int {
+	fake.captureConnectionTimeoutMutex.RLock()
+	defer fake.captureConnectionTimeoutMutex.RUnlock()
+	return len(fake.captureConnectionTimeoutArgsForCall)
+}
+
+func (fake *FakeVarzReporter) CaptureConnectionTimeoutArgsForCall(i int) string {
+	fake.captureConnectionTimeoutMutex.RLock()
+	defer fake.captureConnectionTimeoutMutex.RUnlock()
+	return fake.captureConnectionTimeoutArgsForCall[i].timeout
+}
+
+func (fake *FakeVarzReporter) CaptureRejectedConnection() {
+	fake.captureRejectedConnectionMutex.Lock()
+	fake.captureRejectedConnectionArgsForCall = append(fake.captureRejectedConnectionArgsForCall, struct{}{})
+	fake.captureRejectedConnectionMutex.Unlock()
+	if fake.CaptureRejectedConnectionStub != nil {
+		fake.CaptureRejectedConnectionStub()
+	}
+}
+
+func (fake *FakeVarzReporter) CaptureRejectedConnectionCallCount() int {
+	fake.captureRejectedConnectionMutex.RLock()
+	defer fake.captureRejectedConnectionMutex.RUnlock()
+	return len(fake.captureRejectedConnectionArgsForCall)
+}
+
 var _ metrics.VarzReporter = new(FakeVarzReporter)
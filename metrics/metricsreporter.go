@@ -30,6 +30,29 @@ func (m *MetricsReporter) CaptureBadGateway() {
 	m.batcher.BatchIncrementCounter("bad_gateways")
 }
 
+func (m *MetricsReporter) CaptureOverloadedRequest() {
+	m.batcher.BatchIncrementCounter("overloaded_requests")
+}
+
+func (m *MetricsReporter) CaptureRateLimitedRequest() {
+	m.batcher.BatchIncrementCounter("rate_limited_requests")
+}
+
+func (m *MetricsReporter) CaptureOversizedRequest() {
+	m.batcher.BatchIncrementCounter("oversized_requests")
+}
+
+// CaptureConnectionTimeout counts client connections closed by a timeout of
+// the listeners, by timeout.
+func (m *MetricsReporter) CaptureConnectionTimeout(timeout string) {
+	m.batcher.BatchIncrementCounter(fmt.Sprintf("connection_timeouts.%s", timeout))
+	m.batcher.BatchIncrementCounter("connection_timeouts")
+}
+
+func (m *MetricsReporter) CaptureRejectedConnection() {
+	m.batcher.BatchIncrementCounter("rejected_connections")
+}
+
 func (m *MetricsReporter) CaptureRoutingRequest(b *route.Endpoint) {
 	m.batcher.BatchIncrementCounter("total_requests")
 
@@ -42,6 +65,11 @@ func (m *MetricsReporter) CaptureRoutingRequest(b *route.Endpoint) {
 	}
 }
 
+func (m *MetricsReporter) CaptureCrossZoneRequest(b *route.Endpoint) {
+	m.batcher.BatchIncrementCounter("cross_zone_requests")
+	m.batcher.BatchIncrementCounter(fmt.Sprintf("cross_zone_requests.%s", b.Zone))
+}
+
 func (m *MetricsReporter) CaptureRouteServiceResponse(res *http.Response) {
 	var statusCode int
 	if res != nil {
@@ -56,6 +84,17 @@ func (m *MetricsReporter) CaptureRoutingResponse(statusCode int) {
 	m.batcher.BatchIncrementCounter("responses")
 }
 
+func (m *MetricsReporter) CaptureGrpcResponse(status string) {
+	m.batcher.BatchIncrementCounter(fmt.Sprintf("responses.grpc.%s", status))
+	m.batcher.BatchIncrementCounter("responses.grpc")
+}
+
+// CaptureCacheResponse counts requests to cached routes by cache status.
+func (m *MetricsReporter) CaptureCacheResponse(status string) {
+	m.batcher.BatchIncrementCounter(fmt.Sprintf("responses.cache.%s", strings.ToLower(status)))
+	m.batcher.BatchIncrementCounter("responses.cache")
+}
+
 func (m *MetricsReporter) CaptureRoutingResponseLatency(b *route.Endpoint, d time.Duration) {
 	latency := float64(d / time.Millisecond)
 	unit := "ms"
@@ -97,6 +136,14 @@ func (m *MetricsReporter) CaptureUnregistryMessage(msg ComponentTagged) {
 	m.sender.IncrementCounter(componentName)
 }
 
+func (m *MetricsReporter) CaptureEndpointEjected() {
+	m.batcher.BatchIncrementCounter("endpoint_ejections")
+}
+
+func (m *MetricsReporter) CaptureEndpointRestored() {
+	m.batcher.BatchIncrementCounter("endpoint_restorations")
+}
+
 func (m *MetricsReporter) CaptureWebSocketUpdate() {
 	m.batcher.BatchIncrementCounter("websocket_upgrades")
 }
@@ -52,6 +52,58 @@ var _ = Describe("MetricsReporter", func() {
 		Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("bad_gateways"))
 	})
 
+	It("increments the overloaded_requests metric", func() {
+		metricReporter.CaptureOverloadedRequest()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("overloaded_requests"))
+	})
+
+	It("increments the rate_limited_requests metric", func() {
+		metricReporter.CaptureRateLimitedRequest()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("rate_limited_requests"))
+	})
+
+	It("increments the oversized_requests metric", func() {
+		metricReporter.CaptureOversizedRequest()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("oversized_requests"))
+	})
+
+	It("increments the gRPC response metrics", func() {
+		metricReporter.CaptureGrpcResponse("14")
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(2))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("responses.grpc.14"))
+		Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("responses.grpc"))
+	})
+
+	It("increments the connection timeout metrics", func() {
+		metricReporter.CaptureConnectionTimeout("read_header")
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(2))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("connection_timeouts.read_header"))
+		Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("connection_timeouts"))
+	})
+
+	It("increments the rejected_connections metric", func() {
+		metricReporter.CaptureRejectedConnection()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("rejected_connections"))
+	})
+
+	It("increments the cache response metrics", func() {
+		metricReporter.CaptureCacheResponse("HIT")
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(2))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("responses.cache.hit"))
+		Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("responses.cache"))
+	})
+
 	Context("increments the request metrics", func() {
 		It("increments the total requests metric", func() {
 			metricReporter.CaptureRoutingRequest(&route.Endpoint{})
@@ -107,6 +159,24 @@ var _ = Describe("MetricsReporter", func() {
 
 			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(8))
 		})
+
+		It("increments the outlier detection metrics", func() {
+			metricReporter.CaptureEndpointEjected()
+			metricReporter.CaptureEndpointRestored()
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(2))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("endpoint_ejections"))
+			Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("endpoint_restorations"))
+		})
+
+		It("increments the cross zone requests metrics", func() {
+			endpoint.Zone = "z2"
+			metricReporter.CaptureCrossZoneRequest(endpoint)
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(2))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("cross_zone_requests"))
+			Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("cross_zone_requests.z2"))
+		})
 	})
 
 	Context("increments the response metrics for route services", func() {
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	encodingGzip   = "gzip"
+	encodingBrotli = "br"
+)
+
+// compressor compresses the responses of backends for clients that accept
+// gzip or brotli.
+type compressor struct {
+	config       config.CompressionConfig
+	contentTypes map[string]bool
+}
+
+func newCompressor(c config.CompressionConfig) *compressor {
+	contentTypes := make(map[string]bool, len(c.ContentTypes))
+	for _, contentType := range c.ContentTypes {
+		contentTypes[strings.ToLower(contentType)] = true
+	}
+	return &compressor{config: c, contentTypes: contentTypes}
+}
+
+// compress replaces the body of res with its compressed form when the
+// route, the request and the response allow it.
+func (c *compressor) compress(res *http.Response) {
+	req := res.Request
+	routePool, _ := req.Context().Value("RoutePool").(*route.Pool)
+	if routePool == nil || !routePool.Compresses(c.config.Enabled) || !c.eligible(res) {
+		return
+	}
+
+	encoding := negotiateEncoding(req.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return
+	}
+
+	res.Body = newCompressedBody(res.Body, encoding)
+	res.Header.Set("Content-Encoding", encoding)
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Header.Add("Vary", "Accept-Encoding")
+	// the compressed body is no longer the representation of a strong ETag
+	if etag := res.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		res.Header.Set("ETag", "W/"+etag)
+	}
+}
+
+func (c *compressor) eligible(res *http.Response) bool {
+	req := res.Request
+	if req.Method == "HEAD" || req.Header.Get("Range") != "" {
+		return false
+	}
+
+	switch res.StatusCode {
+	case http.StatusNoContent, http.StatusPartialContent, http.StatusNotModified:
+		return false
+	}
+	if res.StatusCode < 200 {
+		return false
+	}
+
+	if encoding := res.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return false
+	}
+	if strings.Contains(strings.ToLower(res.Header.Get("Cache-Control")), "no-transform") {
+		return false
+	}
+	if res.ContentLength >= 0 && res.ContentLength < c.config.MinSize {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	return err == nil && c.contentTypes[mediaType]
+}
+
+// negotiateEncoding returns the encoding of the Accept-Encoding header with
+// the highest quality, preferring brotli over gzip, or "" if the client
+// accepts neither.
+func negotiateEncoding(acceptEncoding string) string {
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				parsed, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					parsed = 0
+				}
+				q = parsed
+			}
+		}
+		qualities[coding] = q
+	}
+
+	quality := func(encoding string) float64 {
+		if q, ok := qualities[encoding]; ok {
+			return q
+		}
+		if q, ok := qualities["*"]; ok {
+			return q
+		}
+		return 0
+	}
+
+	br, gz := quality(encodingBrotli), quality(encodingGzip)
+	switch {
+	case br > 0 && br >= gz:
+		return encodingBrotli
+	case gz > 0:
+		return encodingGzip
+	}
+	return ""
+}
+
+// compressedBody reads the compressed form of a body. The body is compressed
+// as it is read, and what has been read from the backend is flushed at once
+// so that streamed responses are not held back by the compressor.
+type compressedBody struct {
+	*io.PipeReader
+	body io.ReadCloser
+}
+
+type flushWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func newCompressedBody(body io.ReadCloser, encoding string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer body.Close()
+
+		var w flushWriter
+		if encoding == encodingBrotli {
+			w = brotli.NewWriterLevel(pw, brotli.DefaultCompression)
+		} else {
+			w = gzip.NewWriter(pw)
+		}
+
+		err := copyFlushing(w, body)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+	return &compressedBody{PipeReader: pr, body: body}
+}
+
+func copyFlushing(w flushWriter, body io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			if ferr := w.Flush(); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Close stops the compression of a body that has not been read to the end.
+func (b *compressedBody) Close() error {
+	b.PipeReader.Close()
+	return b.body.Close()
+}
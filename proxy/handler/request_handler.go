@@ -26,6 +26,7 @@ const (
 )
 
 var NoEndpointsAvailable = errors.New("No endpoints available")
+var EndpointsOverloaded = errors.New("All endpoints are at their concurrent request limit")
 
 type RequestHandler struct {
 	logger    logger.Logger
@@ -2,7 +2,9 @@ package proxy
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -12,6 +14,7 @@ import (
 
 	"code.cloudfoundry.org/gorouter/access_log"
 	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/cache"
 	router_http "code.cloudfoundry.org/gorouter/common/http"
 	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
@@ -25,11 +28,14 @@ import (
 	"code.cloudfoundry.org/gorouter/routeservice"
 	"github.com/uber-go/zap"
 	"github.com/urfave/negroni"
+	"golang.org/x/net/http2"
 )
 
 const (
 	VcapCookieId    = "__VCAP_ID__"
 	StickyCookieKey = "JSESSIONID"
+
+	dialTimeout = 5 * time.Second
 )
 
 type Proxy interface {
@@ -40,6 +46,7 @@ type proxy struct {
 	ip                       string
 	traceKey                 string
 	logger                   logger.Logger
+	registry                 registry.Registry
 	reporter                 metrics.CombinedReporter
 	accessLogger             access_log.AccessLogger
 	secureCookies            bool
@@ -48,7 +55,13 @@ type proxy struct {
 	healthCheckUserAgent     string
 	forceForwardedProtoHttps bool
 	defaultLoadBalance       string
+	consistentHashKey        string
+	trustedProxies           []*net.IPNet
+	retryPolicy              route.RetryPolicy
+	retryBudget              *round_tripper.RetryBudget
 	bufferPool               httputil.BufferPool
+	headerRules              []config.HeaderRule
+	compressor               *compressor
 }
 
 func NewProxy(
@@ -60,6 +73,7 @@ func NewProxy(
 	routeServiceConfig *routeservice.RouteServiceConfig,
 	tlsConfig *tls.Config,
 	heartbeatOK *int32,
+	responseCache *cache.Store,
 ) Proxy {
 
 	p := &proxy{
@@ -67,6 +81,7 @@ func NewProxy(
 		traceKey:                 c.TraceKey,
 		ip:                       c.Ip,
 		logger:                   logger,
+		registry:                 registry,
 		reporter:                 reporter,
 		secureCookies:            c.SecureCookies,
 		heartbeatOK:              heartbeatOK, // 1->true, 0->false
@@ -74,31 +89,68 @@ func NewProxy(
 		healthCheckUserAgent:     c.HealthCheckUserAgent,
 		forceForwardedProtoHttps: c.ForceForwardedProtoHttps,
 		defaultLoadBalance:       c.LoadBalance,
-		bufferPool:               NewBufferPool(),
+		consistentHashKey:        c.ConsistentHashKey,
+		trustedProxies:           c.TrustedProxyNets,
+		retryPolicy: route.RetryPolicy{
+			MaxAttempts: c.RetryPolicy.MaxAttempts,
+			RetryOn:     c.RetryPolicy.RetryOn,
+		},
+		bufferPool:  NewBufferPool(),
+		headerRules: c.HeaderRules,
+		compressor:  newCompressor(c.Compression),
+	}
+	if c.RetryPolicy.BudgetPercent > 0 {
+		p.retryBudget = round_tripper.NewRetryBudget(c.RetryPolicy.BudgetPercent, c.RetryPolicy.BudgetMinRetries)
 	}
 
-	httpTransport := &http.Transport{
-		Dial: func(network, addr string) (net.Conn, error) {
-			conn, err := net.DialTimeout(network, addr, 5*time.Second)
-			if err != nil {
+	newTransport := func(tlsConfig *tls.Config) round_tripper.ProxyRoundTripper {
+		httpTransport := &http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				conn, err := net.DialTimeout(network, addr, dialTimeout)
+				if err != nil {
+					return conn, err
+				}
+				if c.EndpointTimeout > 0 {
+					err = conn.SetDeadline(time.Now().Add(c.EndpointTimeout))
+				}
 				return conn, err
-			}
-			if c.EndpointTimeout > 0 {
-				err = conn.SetDeadline(time.Now().Add(c.EndpointTimeout))
-			}
-			return conn, err
-		},
-		DisableKeepAlives:   c.DisableKeepAlives,
-		MaxIdleConns:        c.MaxIdleConns,
-		IdleConnTimeout:     90 * time.Second, // setting the value to golang default transport
-		MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
-		DisableCompression:  true,
-		TLSClientConfig:     tlsConfig,
+			},
+			DisableKeepAlives:   c.DisableKeepAlives,
+			MaxIdleConns:        c.MaxIdleConns,
+			IdleConnTimeout:     90 * time.Second, // setting the value to golang default transport
+			MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
+			DisableCompression:  true,
+			TLSClientConfig:     tlsConfig,
+		}
+		// connections to HTTP/2 backends carry many requests at once, so
+		// unlike HTTP/1.1 connections they get no deadline; the protocol
+		// transport bounds each request instead. They are closed once idle
+		// like HTTP/1.1 connections.
+		h2Transport := &http2.Transport{
+			TLSClientConfig: tlsConfig,
+			DialTLS:         dialH2,
+			IdleConnTimeout: httpTransport.IdleConnTimeout,
+		}
+		h2cTransport := &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return net.DialTimeout(network, addr, dialTimeout)
+			},
+			IdleConnTimeout: httpTransport.IdleConnTimeout,
+		}
+		return round_tripper.NewProtocolTransport(httpTransport, h2Transport, h2cTransport, c.EndpointTimeout)
 	}
 
+	transport := newTransport(tlsConfig)
+	tlsTransports := round_tripper.NewTLSTransports(func(serverName string) round_tripper.ProxyRoundTripper {
+		return round_tripper.NewDropsondeRoundTripper(
+			newTransport(backendTLSConfig(tlsConfig, c.Backends.CAPool, serverName)),
+		)
+	})
+
 	rproxy := &ReverseProxy{
 		Director:       p.setupProxyRequest,
-		Transport:      p.proxyRoundTripper(httpTransport),
+		Transport:      p.proxyRoundTripper(transport, tlsTransports),
 		FlushInterval:  50 * time.Millisecond,
 		BufferPool:     p.bufferPool,
 		ModifyResponse: p.modifyResponse,
@@ -113,15 +165,44 @@ func NewProxy(
 
 	n.Use(handlers.NewProxyHealthcheck(c.HealthCheckUserAgent, p.heartbeatOK, logger))
 	n.Use(zipkinHandler)
-	n.Use(handlers.NewProtocolCheck(logger))
+	n.Use(handlers.NewProtocolCheck(logger, c.EnableHTTP2))
+	n.Use(handlers.NewClientCert(c.ForwardedClientCert))
 	n.Use(handlers.NewLookup(registry, reporter, logger))
+	n.Use(handlers.NewRequestLimits(c.MaxHeaderBytes, c.MaxRequestBodySize, reporter, logger))
+	n.Use(handlers.NewRateLimit(c.RateLimit, c.TrustedProxyNets, reporter, logger))
 	n.Use(handlers.NewRouteService(routeServiceConfig, logger))
+	n.Use(handlers.NewCache(responseCache, logger))
 	n.Use(p)
 	n.UseHandler(rproxy)
 
 	return n
 }
 
+// backendTLSConfig returns the configuration of connections to endpoints
+// registered with a TLS port. Their certificates are verified against the
+// backend CA bundle and serverName even when SSL validation is skipped.
+func backendTLSConfig(tlsConfig *tls.Config, caPool *x509.CertPool, serverName string) *tls.Config {
+	cfg := tlsConfig.Clone()
+	cfg.InsecureSkipVerify = false
+	cfg.RootCAs = caPool
+	cfg.ServerName = serverName
+	return cfg
+}
+
+// dialH2 connects to an HTTP/2 backend over TLS.
+func dialH2(network, addr string, cfg *tls.Config) (net.Conn, error) {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, network, addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol := conn.ConnectionState().NegotiatedProtocol; protocol != http2.NextProtoTLS {
+		conn.Close()
+		return nil, fmt.Errorf("backend %s negotiated %q instead of %s", addr, protocol, http2.NextProtoTLS)
+	}
+	return conn, nil
+}
+
 func hostWithoutPort(req *http.Request) string {
 	host := req.Host
 
@@ -134,10 +215,14 @@ func hostWithoutPort(req *http.Request) string {
 	return host
 }
 
-func (p *proxy) proxyRoundTripper(transport round_tripper.ProxyRoundTripper) round_tripper.ProxyRoundTripper {
+func (p *proxy) proxyRoundTripper(
+	transport round_tripper.ProxyRoundTripper,
+	tlsTransports *round_tripper.TLSTransports,
+) round_tripper.ProxyRoundTripper {
 	return round_tripper.NewProxyRoundTripper(
-		round_tripper.NewDropsondeRoundTripper(transport),
+		round_tripper.NewDropsondeRoundTripper(transport), tlsTransports, p.registry,
 		p.logger, p.traceKey, p.ip, p.defaultLoadBalance,
+		p.consistentHashKey, p.trustedProxies, p.retryPolicy, p.retryBudget,
 		p.reporter, p.secureCookies,
 	)
 }
@@ -185,13 +270,17 @@ func (p *proxy) ServeHTTP(responseWriter http.ResponseWriter, request *http.Requ
 	routePool := rp.(*route.Pool)
 
 	stickyEndpointId := getStickySession(request)
+	hashKey := route.HashKey(p.consistentHashKey, request, p.trustedProxies)
 	iter := &wrappedIterator{
-		nested: routePool.Endpoints(p.defaultLoadBalance, stickyEndpointId),
+		nested: routePool.EndpointsWithHashKey(p.defaultLoadBalance, stickyEndpointId, hashKey),
 
 		afterNext: func(endpoint *route.Endpoint) {
 			if endpoint != nil {
 				accessLog.RouteEndpoint = endpoint
 				p.reporter.CaptureRoutingRequest(endpoint)
+				if routePool.IsCrossZone(endpoint) {
+					p.reporter.CaptureCrossZoneRequest(endpoint)
+				}
 			}
 		},
 	}
@@ -220,6 +309,13 @@ func (p *proxy) setupProxyRequest(target *http.Request) {
 		target.Header.Set("X-Forwarded-Proto", scheme)
 	}
 
+	// requests received over HTTP/2 are proxied to backends over HTTP/1.1
+	if target.ProtoMajor == 2 {
+		target.Proto = "HTTP/1.1"
+		target.ProtoMajor = 1
+		target.ProtoMinor = 1
+	}
+
 	target.URL.Scheme = "http"
 	target.URL.Host = target.Host
 	target.URL.Opaque = target.RequestURI
@@ -228,12 +324,35 @@ func (p *proxy) setupProxyRequest(target *http.Request) {
 
 	handler.SetRequestXRequestStart(target)
 	target.Header.Del(router_http.CfAppInstance)
+
+	route.ApplyRequestHeaderRules(p.routeHeaderRules(target), target.Header)
 }
 
 func (p *proxy) modifyResponse(backendResp *http.Response) error {
+	if backendResp.Request != nil {
+		p.compressor.compress(backendResp)
+		route.ApplyResponseHeaderRules(p.routeHeaderRules(backendResp.Request), backendResp.Header, backendResp.StatusCode)
+	}
 	return nil
 }
 
+// routeHeaderRules returns the router-wide header rules followed by the
+// rules of the route of the request.
+func (p *proxy) routeHeaderRules(request *http.Request) []config.HeaderRule {
+	routePool, ok := request.Context().Value("RoutePool").(*route.Pool)
+	if !ok || routePool == nil {
+		return p.headerRules
+	}
+
+	routeRules := routePool.HeaderRules()
+	if len(routeRules) == 0 {
+		return p.headerRules
+	}
+	rules := make([]config.HeaderRule, 0, len(p.headerRules)+len(routeRules))
+	rules = append(rules, p.headerRules...)
+	return append(rules, routeRules...)
+}
+
 type wrappedIterator struct {
 	nested    route.EndpointIterator
 	afterNext func(*route.Endpoint)
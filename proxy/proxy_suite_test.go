@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"code.cloudfoundry.org/gorouter/access_log"
+	"code.cloudfoundry.org/gorouter/cache"
 	"code.cloudfoundry.org/gorouter/common/secure"
 	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/logger"
@@ -40,6 +41,7 @@ var (
 	recommendHttps bool
 	heartbeatOK    int32
 	fakeEmitter    *fake.FakeEventEmitter
+	responseCache  *cache.Store
 )
 
 func TestProxy(t *testing.T) {
@@ -91,7 +93,12 @@ var _ = JustBeforeEach(func() {
 		cryptoPrev,
 		recommendHttps,
 	)
-	p = proxy.NewProxy(testLogger, accessLog, conf, r, fakeReporter, routeServiceConfig, tlsConfig, &heartbeatOK)
+	responseCache = nil
+	if conf.Cache.Enabled {
+		responseCache, err = cache.New(conf.Cache)
+		Expect(err).NotTo(HaveOccurred())
+	}
+	p = proxy.NewProxy(testLogger, accessLog, conf, r, fakeReporter, routeServiceConfig, tlsConfig, &heartbeatOK, responseCache)
 
 	proxyServer, err = net.Listen("tcp", "127.0.0.1:0")
 	Expect(err).NotTo(HaveOccurred())
@@ -2,6 +2,7 @@ package proxy_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"crypto/tls"
 	"fmt"
 	"io"
@@ -19,17 +20,20 @@ import (
 	"time"
 
 	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/test_util"
 	"code.cloudfoundry.org/routing-api/models"
+	"github.com/andybalholm/brotli"
 	"github.com/cloudfoundry/dropsonde/factories"
 	"github.com/cloudfoundry/sonde-go/events"
 	uuid "github.com/nu7hatch/gouuid"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"golang.org/x/net/http2"
 )
 
 type connHandler func(*test_util.HttpConn)
@@ -712,6 +716,622 @@ var _ = Describe("Proxy", func() {
 		})
 	})
 
+	Context("with header rules", func() {
+		BeforeEach(func() {
+			conf.HeaderRules = []config.HeaderRule{
+				{On: config.HEADER_RULE_REQUEST, Action: config.HEADER_RULE_REMOVE, Name: "X-Internal-Token"},
+				{On: config.HEADER_RULE_RESPONSE, Action: config.HEADER_RULE_SET, Name: "Strict-Transport-Security", Value: "max-age=31536000"},
+				{On: config.HEADER_RULE_RESPONSE, Action: config.HEADER_RULE_REMOVE, Name: "Server"},
+			}
+		})
+
+		It("applies the router-wide rules to requests and responses", func() {
+			done := make(chan http.Header, 1)
+			ln := registerHandler(r, "app", func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				done <- req.Header
+
+				resp := test_util.NewResponse(http.StatusOK)
+				resp.Header.Set("Server", "backend/1.0")
+				conn.WriteResponse(resp)
+				conn.Close()
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("GET", "app", "/", nil)
+			req.Header.Set("X-Internal-Token", "secret")
+			conn.WriteRequest(req)
+
+			var header http.Header
+			Eventually(done).Should(Receive(&header))
+			Expect(header).NotTo(HaveKey("X-Internal-Token"))
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Strict-Transport-Security")).To(Equal("max-age=31536000"))
+			Expect(resp.Header).NotTo(HaveKey("Server"))
+		})
+
+		It("applies the rules of the route after the router-wide ones", func() {
+			done := make(chan http.Header, 1)
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer ln.Close()
+			go runBackendInstance(ln, func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				done <- req.Header
+
+				resp := test_util.NewResponse(http.StatusNotFound)
+				resp.Header.Set("X-Backend", "v2")
+				conn.WriteResponse(resp)
+				conn.Close()
+			})
+
+			host, portStr, err := net.SplitHostPort(ln.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			port, err := strconv.Atoi(portStr)
+			Expect(err).NotTo(HaveOccurred())
+			tags := map[string]string{route.HeaderRulesTag: `[
+				{"on":"request","action":"rename","name":"X-User","new_name":"X-Remote-User"},
+				{"on":"response","action":"set","name":"Strict-Transport-Security","value":"max-age=60"},
+				{"on":"response","action":"add","name":"Cache-Control","value":"no-store","status_codes":[404]},
+				{"on":"response","action":"remove","name":"X-Backend","status_codes":[200]}
+			]`}
+			r.Register(route.Uri("tagged"), route.NewEndpoint("", host, uint16(port), "", "", tags, -1, "", models.ModificationTag{}))
+
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("GET", "tagged", "/", nil)
+			req.Header.Set("X-User", "alice")
+			conn.WriteRequest(req)
+
+			var header http.Header
+			Eventually(done).Should(Receive(&header))
+			Expect(header.Get("X-Remote-User")).To(Equal("alice"))
+			Expect(header).NotTo(HaveKey("X-User"))
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(resp.Header.Get("Strict-Transport-Security")).To(Equal("max-age=60"))
+			Expect(resp.Header.Get("Cache-Control")).To(Equal("no-store"))
+			Expect(resp.Header.Get("X-Backend")).To(Equal("v2"))
+		})
+	})
+
+	Context("with compression", func() {
+		var (
+			body        string
+			contentType string
+			backendResp func(*http.Request) *http.Response
+		)
+
+		BeforeEach(func() {
+			conf.Compression.Enabled = true
+			body = strings.Repeat(`{"name":"compressible"},`, 100)
+			contentType = "application/json; charset=utf-8"
+			backendResp = func(*http.Request) *http.Response {
+				resp := test_util.NewResponse(http.StatusOK)
+				resp.Header.Set("Content-Type", contentType)
+				resp.Header.Set("ETag", `"v1"`)
+				resp.Body = ioutil.NopCloser(strings.NewReader(body))
+				resp.ContentLength = int64(len(body))
+				return resp
+			}
+		})
+
+		registerBackend := func(uri string, tags map[string]string) net.Listener {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			go runBackendInstance(ln, func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				if err != nil {
+					return
+				}
+				conn.WriteResponse(backendResp(req))
+				conn.Close()
+			})
+
+			host, portStr, err := net.SplitHostPort(ln.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			port, err := strconv.Atoi(portStr)
+			Expect(err).NotTo(HaveOccurred())
+			r.Register(route.Uri(uri), route.NewEndpoint("", host, uint16(port), "", "", tags, -1, "", models.ModificationTag{}))
+			return ln
+		}
+
+		get := func(uri string, header http.Header) (*http.Response, string) {
+			conn := dialProxy(proxyServer)
+			req := test_util.NewRequest("GET", uri, "/", nil)
+			for name, values := range header {
+				req.Header[name] = values
+			}
+			conn.WriteRequest(req)
+			return conn.ReadResponse()
+		}
+
+		gunzip := func(compressed string) string {
+			reader, err := gzip.NewReader(strings.NewReader(compressed))
+			Expect(err).NotTo(HaveOccurred())
+			uncompressed, err := ioutil.ReadAll(reader)
+			Expect(err).NotTo(HaveOccurred())
+			return string(uncompressed)
+		}
+
+		It("gzips eligible responses for clients that accept it", func() {
+			ln := registerBackend("app", nil)
+			defer ln.Close()
+
+			resp, compressed := get("app", http.Header{"Accept-Encoding": {"gzip, deflate"}})
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(resp.Header.Get("Vary")).To(Equal("Accept-Encoding"))
+			Expect(resp.Header.Get("ETag")).To(Equal(`W/"v1"`))
+			Expect(len(compressed)).To(BeNumerically("<", len(body)))
+			Expect(gunzip(compressed)).To(Equal(body))
+
+			var payload []byte
+			Eventually(func() int {
+				accessLogFile.Read(&payload)
+				return len(payload)
+			}).ShouldNot(BeZero())
+			Expect(string(payload)).To(ContainSubstring(fmt.Sprintf(`" 200 0 %d "`, len(compressed))))
+		})
+
+		It("prefers brotli when the client accepts it", func() {
+			ln := registerBackend("app", nil)
+			defer ln.Close()
+
+			resp, compressed := get("app", http.Header{"Accept-Encoding": {"gzip;q=0.8, br"}})
+			Expect(resp.Header.Get("Content-Encoding")).To(Equal("br"))
+			uncompressed, err := ioutil.ReadAll(brotli.NewReader(strings.NewReader(compressed)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(uncompressed)).To(Equal(body))
+		})
+
+		It("does not compress for clients that do not accept it", func() {
+			ln := registerBackend("app", nil)
+			defer ln.Close()
+
+			resp, uncompressed := get("app", http.Header{"Accept-Encoding": {"gzip;q=0, identity"}})
+			Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+			Expect(uncompressed).To(Equal(body))
+		})
+
+		It("does not compress small responses", func() {
+			body = `{"name":"small"}`
+			ln := registerBackend("app", nil)
+			defer ln.Close()
+
+			resp, uncompressed := get("app", http.Header{"Accept-Encoding": {"gzip"}})
+			Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+			Expect(uncompressed).To(Equal(body))
+		})
+
+		It("does not compress content types outside the allowlist", func() {
+			contentType = "image/png"
+			ln := registerBackend("app", nil)
+			defer ln.Close()
+
+			resp, _ := get("app", http.Header{"Accept-Encoding": {"gzip"}})
+			Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+		})
+
+		It("leaves responses already encoded by the backend alone", func() {
+			backendResp = func(*http.Request) *http.Response {
+				resp := test_util.NewResponse(http.StatusOK)
+				resp.Header.Set("Content-Type", contentType)
+				resp.Header.Set("Content-Encoding", "deflate")
+				resp.Body = ioutil.NopCloser(strings.NewReader(body))
+				return resp
+			}
+			ln := registerBackend("app", nil)
+			defer ln.Close()
+
+			resp, _ := get("app", http.Header{"Accept-Encoding": {"gzip, deflate"}})
+			Expect(resp.Header.Get("Content-Encoding")).To(Equal("deflate"))
+		})
+
+		It("does not compress range requests", func() {
+			ln := registerBackend("app", nil)
+			defer ln.Close()
+
+			resp, uncompressed := get("app", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-99"}})
+			Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+			Expect(uncompressed).To(Equal(body))
+		})
+
+		It("can be disabled per route", func() {
+			ln := registerBackend("app", map[string]string{route.CompressionTag: "off"})
+			defer ln.Close()
+
+			resp, _ := get("app", http.Header{"Accept-Encoding": {"gzip"}})
+			Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+		})
+
+		Context("when it is disabled router-wide", func() {
+			BeforeEach(func() {
+				conf.Compression.Enabled = false
+			})
+
+			It("only compresses routes that enable it", func() {
+				ln := registerBackend("app", nil)
+				defer ln.Close()
+				resp, _ := get("app", http.Header{"Accept-Encoding": {"gzip"}})
+				Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+
+				enabledLn := registerBackend("enabled", map[string]string{route.CompressionTag: "on"})
+				defer enabledLn.Close()
+				resp, compressed := get("enabled", http.Header{"Accept-Encoding": {"gzip"}})
+				Expect(resp.Header.Get("Content-Encoding")).To(Equal("gzip"))
+				Expect(gunzip(compressed)).To(Equal(body))
+			})
+		})
+	})
+
+	Context("with a response cache", func() {
+		var (
+			requests    int32
+			backendResp func(*http.Request) *http.Response
+		)
+
+		BeforeEach(func() {
+			conf.Cache.Enabled = true
+			atomic.StoreInt32(&requests, 0)
+			backendResp = func(req *http.Request) *http.Response {
+				resp := test_util.NewResponse(http.StatusOK)
+				resp.Header.Set("Cache-Control", "max-age=60")
+				resp.Header.Set("ETag", `"v1"`)
+				body := fmt.Sprintf("response %d", atomic.LoadInt32(&requests))
+				resp.Body = ioutil.NopCloser(strings.NewReader(body))
+				resp.ContentLength = int64(len(body))
+				return resp
+			}
+		})
+
+		registerBackend := func(uri string, tags map[string]string) net.Listener {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			go runBackendInstance(ln, func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				if err != nil {
+					return
+				}
+				atomic.AddInt32(&requests, 1)
+				conn.WriteResponse(backendResp(req))
+				conn.Close()
+			})
+
+			host, portStr, err := net.SplitHostPort(ln.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			port, err := strconv.Atoi(portStr)
+			Expect(err).NotTo(HaveOccurred())
+			r.Register(route.Uri(uri), route.NewEndpoint("", host, uint16(port), "", "", tags, -1, "", models.ModificationTag{}))
+			return ln
+		}
+
+		cached := map[string]string{route.CacheTag: "on"}
+
+		request := func(method, uri string, header http.Header) (*http.Response, string) {
+			conn := dialProxy(proxyServer)
+			req := test_util.NewRequest(method, uri, "/", nil)
+			for name, values := range header {
+				req.Header[name] = values
+			}
+			conn.WriteRequest(req)
+			return conn.ReadResponse()
+		}
+
+		get := func(uri string, header http.Header) (*http.Response, string) {
+			return request("GET", uri, header)
+		}
+
+		lastAccessLog := func() string {
+			var payload []byte
+			Eventually(func() int {
+				accessLogFile.Read(&payload)
+				return len(payload)
+			}).ShouldNot(BeZero())
+			return string(payload)
+		}
+
+		It("serves fresh responses from the cache", func() {
+			ln := registerBackend("app", cached)
+			defer ln.Close()
+
+			resp, body := get("app", nil)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("response 1"))
+			Eventually(lastAccessLog).Should(ContainSubstring(`cache_status:"MISS"`))
+
+			resp, body = get("app", nil)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("response 1"))
+			Expect(resp.Header.Get("Age")).ToNot(BeEmpty())
+			Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+			Eventually(lastAccessLog).Should(ContainSubstring(`cache_status:"HIT"`))
+		})
+
+		It("answers conditional requests from the cache", func() {
+			ln := registerBackend("app", cached)
+			defer ln.Close()
+
+			get("app", nil)
+			resp, body := get("app", http.Header{"If-None-Match": {`"v1"`}})
+			Expect(resp.StatusCode).To(Equal(http.StatusNotModified))
+			Expect(body).To(BeEmpty())
+			Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+		})
+
+		It("does not cache routes that do not opt in", func() {
+			ln := registerBackend("app", nil)
+			defer ln.Close()
+
+			get("app", nil)
+			_, body := get("app", nil)
+			Expect(body).To(Equal("response 2"))
+			Expect(lastAccessLog()).ToNot(ContainSubstring("cache_status"))
+		})
+
+		It("invalidates the cached responses on unsafe requests", func() {
+			ln := registerBackend("app", cached)
+			defer ln.Close()
+
+			get("app", nil)
+			request("POST", "app", nil)
+			Eventually(lastAccessLog).Should(ContainSubstring(`cache_status:"BYPASS"`))
+
+			_, body := get("app", nil)
+			Expect(body).To(Equal("response 3"))
+		})
+
+		It("stores a response for each variant", func() {
+			backendResp = func(req *http.Request) *http.Response {
+				resp := test_util.NewResponse(http.StatusOK)
+				resp.Header.Set("Cache-Control", "max-age=60")
+				resp.Header.Set("Vary", "Accept-Language")
+				body := "hello " + req.Header.Get("Accept-Language")
+				resp.Body = ioutil.NopCloser(strings.NewReader(body))
+				resp.ContentLength = int64(len(body))
+				return resp
+			}
+			ln := registerBackend("app", cached)
+			defer ln.Close()
+
+			_, body := get("app", http.Header{"Accept-Language": {"en"}})
+			Expect(body).To(Equal("hello en"))
+			_, body = get("app", http.Header{"Accept-Language": {"fr"}})
+			Expect(body).To(Equal("hello fr"))
+			_, body = get("app", http.Header{"Accept-Language": {"en"}})
+			Expect(body).To(Equal("hello en"))
+			Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+		})
+
+		It("does not store responses that set cookies", func() {
+			backendResp = func(*http.Request) *http.Response {
+				resp := test_util.NewResponse(http.StatusOK)
+				resp.Header.Set("Cache-Control", "max-age=60")
+				resp.Header.Set("Set-Cookie", "session=1")
+				return resp
+			}
+			ln := registerBackend("app", cached)
+			defer ln.Close()
+
+			get("app", nil)
+			get("app", nil)
+			Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+		})
+
+		Context("when the response is stale", func() {
+			var status int
+
+			BeforeEach(func() {
+				status = http.StatusOK
+				backendResp = func(req *http.Request) *http.Response {
+					if atomic.LoadInt32(&requests) == 1 {
+						resp := test_util.NewResponse(http.StatusOK)
+						resp.Header.Set("Cache-Control", "max-age=0, stale-if-error=60")
+						resp.Header.Set("ETag", `"v1"`)
+						resp.Body = ioutil.NopCloser(strings.NewReader("original"))
+						resp.ContentLength = int64(len("original"))
+						return resp
+					}
+
+					resp := test_util.NewResponse(status)
+					if status == http.StatusOK && req.Header.Get("If-None-Match") == `"v1"` {
+						resp = test_util.NewResponse(http.StatusNotModified)
+						resp.Header.Set("Cache-Control", "max-age=60")
+					}
+					return resp
+				}
+			})
+
+			It("revalidates it with the backend", func() {
+				ln := registerBackend("app", cached)
+				defer ln.Close()
+
+				get("app", nil)
+				resp, body := get("app", nil)
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(body).To(Equal("original"))
+				Eventually(lastAccessLog).Should(ContainSubstring(`cache_status:"REVALIDATED"`))
+
+				_, body = get("app", nil)
+				Expect(body).To(Equal("original"))
+				Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+			})
+
+			It("serves it when the backend fails", func() {
+				status = http.StatusServiceUnavailable
+				ln := registerBackend("app", cached)
+				defer ln.Close()
+
+				get("app", nil)
+				resp, body := get("app", nil)
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(body).To(Equal("original"))
+				Eventually(lastAccessLog).Should(ContainSubstring(`cache_status:"STALE"`))
+			})
+		})
+
+		Context("when the cache is disabled", func() {
+			BeforeEach(func() {
+				conf.Cache.Enabled = false
+			})
+
+			It("sends every request to the backend", func() {
+				ln := registerBackend("app", cached)
+				defer ln.Close()
+
+				get("app", nil)
+				get("app", nil)
+				Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+			})
+		})
+	})
+
+	Context("with rate limiting", func() {
+		BeforeEach(func() {
+			conf.RateLimit.Rate = 1
+			conf.RateLimit.Burst = 1
+		})
+
+		It("rejects requests above the rate with a 429", func() {
+			ln := registerHandler(r, "app", func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				if err != nil {
+					return
+				}
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				conn.Close()
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+			conn.WriteRequest(test_util.NewRequest("GET", "app", "/", nil))
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			conn = dialProxy(proxyServer)
+			conn.WriteRequest(test_util.NewRequest("GET", "app", "/", nil))
+			resp, _ = conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+			Expect(resp.Header.Get("Retry-After")).To(Equal("1"))
+			Expect(resp.Header.Get("X-Cf-RouterError")).To(Equal("rate_limited"))
+
+			var payload []byte
+			Eventually(func() string {
+				accessLogFile.Read(&payload)
+				return string(payload)
+			}).Should(ContainSubstring(`x_cf_routererror:"rate_limited"`))
+		})
+	})
+
+	Context("with request size limits", func() {
+		BeforeEach(func() {
+			conf.MaxRequestBodySize = 10
+		})
+
+		It("rejects bodies with a large Content-Length with a 413", func() {
+			backendCalled := false
+			ln := registerHandler(r, "app", func(conn *test_util.HttpConn) {
+				backendCalled = true
+				conn.Close()
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+			conn.WriteRequest(test_util.NewRequest("POST", "app", "/", strings.NewReader("0123456789abcdef")))
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+			Expect(resp.Header.Get("X-Cf-RouterError")).To(Equal("request_body_too_large"))
+			Expect(backendCalled).To(BeFalse())
+
+			var payload []byte
+			Eventually(func() string {
+				accessLogFile.Read(&payload)
+				return string(payload)
+			}).Should(ContainSubstring(`x_cf_routererror:"request_body_too_large"`))
+		})
+
+		It("aborts streamed bodies past the limit with a 413", func() {
+			ln := registerHandler(r, "app", func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				if err != nil {
+					return
+				}
+				_, err = ioutil.ReadAll(req.Body)
+				if err == nil {
+					conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				}
+				conn.Close()
+			})
+			defer ln.Close()
+
+			req := test_util.NewRequest("POST", "app", "/", strings.NewReader("0123456789abcdef"))
+			req.ContentLength = -1
+			req.TransferEncoding = []string{"chunked"}
+
+			conn := dialProxy(proxyServer)
+			conn.WriteRequest(req)
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+			Expect(resp.Header.Get("X-Cf-RouterError")).To(Equal("request_body_too_large"))
+		})
+
+		It("applies the body size of the route", func() {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer ln.Close()
+			go runBackendInstance(ln, func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				if err != nil {
+					return
+				}
+				ioutil.ReadAll(req.Body)
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				conn.Close()
+			})
+
+			host, portStr, err := net.SplitHostPort(ln.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			port, err := strconv.Atoi(portStr)
+			Expect(err).NotTo(HaveOccurred())
+			tags := map[string]string{route.MaxRequestBodySizeTag: "100"}
+			r.Register(route.Uri("large-uploads"), route.NewEndpoint("", host, uint16(port), "", "", tags, -1, "", models.ModificationTag{}))
+
+			conn := dialProxy(proxyServer)
+			conn.WriteRequest(test_util.NewRequest("POST", "large-uploads", "/", strings.NewReader("0123456789abcdef")))
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		Context("when the headers are too large", func() {
+			BeforeEach(func() {
+				conf.MaxHeaderBytes = 1024
+			})
+
+			It("rejects the request with a 431", func() {
+				ln := registerHandler(r, "app", func(conn *test_util.HttpConn) {
+					conn.Close()
+				})
+				defer ln.Close()
+
+				req := test_util.NewRequest("GET", "app", "/", nil)
+				req.Header.Set("X-Large", strings.Repeat("a", 1024))
+
+				conn := dialProxy(proxyServer)
+				conn.WriteRequest(req)
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusRequestHeaderFieldsTooLarge))
+				Expect(resp.Header.Get("X-Cf-RouterError")).To(Equal("request_header_too_large"))
+			})
+		})
+	})
+
 	It("emits HTTP startstop events", func() {
 		done := make(chan struct{})
 		var vcapHeader string
@@ -1067,6 +1687,260 @@ var _ = Describe("Proxy", func() {
 		Expect(resp.TransferEncoding).To(BeNil())
 	})
 
+	It("forwards response trailers", func() {
+		ln := registerHandler(r, "trailers", func(conn *test_util.HttpConn) {
+			_, err := http.ReadRequest(conn.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp := test_util.NewResponse(http.StatusOK)
+			resp.TransferEncoding = []string{"chunked"}
+			resp.Body = ioutil.NopCloser(strings.NewReader("hello"))
+			resp.Trailer = http.Header{"X-Checksum": []string{"abc"}}
+			resp.Write(conn)
+		})
+		defer ln.Close()
+
+		conn := dialProxy(proxyServer)
+
+		req := test_util.NewRequest("GET", "trailers", "/", nil)
+		conn.WriteRequest(req)
+
+		resp, err := http.ReadResponse(conn.Reader, req)
+		Expect(err).NotTo(HaveOccurred())
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(Equal("hello"))
+		Expect(resp.Trailer.Get("X-Checksum")).To(Equal("abc"))
+	})
+
+	Context("when the backend speaks h2c", func() {
+		var (
+			ln    net.Listener
+			delay time.Duration
+		)
+
+		BeforeEach(func() {
+			delay = 0
+		})
+
+		JustBeforeEach(func() {
+			var err error
+			ln, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				defer GinkgoRecover()
+				Expect(req.ProtoMajor).To(Equal(2))
+				Expect(req.Header.Get("Te")).To(Equal("trailers"))
+
+				body, err := ioutil.ReadAll(req.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(Equal("ping"))
+
+				time.Sleep(delay)
+				w.Header().Set("Content-Type", "application/grpc")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("pong"))
+				w.Header().Set(http.TrailerPrefix+"Grpc-Status", "5")
+			})
+
+			go func() {
+				server := &http2.Server{}
+				for {
+					conn, err := ln.Accept()
+					if err != nil {
+						return
+					}
+					go server.ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+				}
+			}()
+
+			host, portStr, err := net.SplitHostPort(ln.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			port, err := strconv.Atoi(portStr)
+			Expect(err).NotTo(HaveOccurred())
+
+			tags := map[string]string{route.BackendProtocolTag: route.BackendProtocolH2C}
+			r.Register(route.Uri("grpc"), route.NewEndpoint("", host, uint16(port), "", "", tags, -1, "", models.ModificationTag{}))
+		})
+
+		AfterEach(func() {
+			ln.Close()
+		})
+
+		It("proxies the request over HTTP/2 and forwards the trailers", func() {
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("POST", "grpc", "/pkg.Service/Method", strings.NewReader("ping"))
+			req.Header.Set("Content-Type", "application/grpc")
+			req.Header.Set("Te", "trailers")
+			conn.WriteRequest(req)
+
+			resp, err := http.ReadResponse(conn.Reader, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(Equal("pong"))
+			Expect(resp.Trailer.Get("Grpc-Status")).To(Equal("5"))
+		})
+
+		It("logs the gRPC status", func() {
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("POST", "grpc", "/pkg.Service/Method", strings.NewReader("ping"))
+			req.Header.Set("Te", "trailers")
+			conn.WriteRequest(req)
+
+			resp, err := http.ReadResponse(conn.Reader, req)
+			Expect(err).NotTo(HaveOccurred())
+			ioutil.ReadAll(resp.Body)
+
+			var payload []byte
+			Eventually(func() int {
+				accessLogFile.Read(&payload)
+				return len(payload)
+			}).ShouldNot(BeZero())
+			Expect(string(payload)).To(ContainSubstring(`grpc_status:"5"`))
+		})
+
+		Context("when the backend is slow to respond", func() {
+			BeforeEach(func() {
+				delay = time.Second
+			})
+
+			It("fails the request after the endpoint timeout", func() {
+				conn := dialProxy(proxyServer)
+
+				req := test_util.NewRequest("POST", "grpc", "/pkg.Service/Method", strings.NewReader("ping"))
+				req.Header.Set("Te", "trailers")
+
+				started := time.Now()
+				conn.WriteRequest(req)
+
+				resp, err := http.ReadResponse(conn.Reader, req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadGateway))
+				Expect(time.Since(started)).To(BeNumerically("<", 800*time.Millisecond))
+			})
+		})
+	})
+
+	Context("when the backend is registered with a TLS port", func() {
+		var (
+			ln         net.Listener
+			chain      test_util.CertChain
+			serverName string
+		)
+
+		BeforeEach(func() {
+			chain = test_util.CreateSignedCertWithRootCA("instance-1")
+			conf.Backends.CAPool = chain.CACertPool()
+			serverName = "instance-1"
+
+			var err error
+			ln, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			ln = tls.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{chain.TLSCert()}})
+
+			// connections the router rejects fail their handshake here
+			go runBackendInstance(ln, func(conn *test_util.HttpConn) {
+				defer conn.Close()
+				if _, err := http.ReadRequest(conn.Reader); err != nil {
+					return
+				}
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			})
+		})
+
+		JustBeforeEach(func() {
+			host, portStr, err := net.SplitHostPort(ln.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			port, err := strconv.Atoi(portStr)
+			Expect(err).NotTo(HaveOccurred())
+
+			endpoint := route.NewEndpoint("", host, uint16(port), "", "", nil, -1, "", models.ModificationTag{})
+			endpoint.ServerCertDomainSAN = serverName
+			r.Register(route.Uri("tls-app"), endpoint)
+		})
+
+		AfterEach(func() {
+			ln.Close()
+		})
+
+		It("proxies the request over TLS", func() {
+			conn := dialProxy(proxyServer)
+
+			conn.WriteRequest(test_util.NewRequest("GET", "tls-app", "/", nil))
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		Context("when the certificate of the backend is for another name", func() {
+			BeforeEach(func() {
+				serverName = "instance-2"
+			})
+
+			It("does not serve the request from the backend", func() {
+				conn := dialProxy(proxyServer)
+
+				conn.WriteRequest(test_util.NewRequest("GET", "tls-app", "/", nil))
+
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+				Expect(resp.Header.Get(router_http.CfRouterError)).To(Equal("endpoint_name_mismatch"))
+			})
+
+			It("unregisters the backend and prunes the route left empty", func() {
+				conn := dialProxy(proxyServer)
+
+				conn.WriteRequest(test_util.NewRequest("GET", "tls-app", "/", nil))
+				conn.ReadResponse()
+
+				Expect(r.Lookup(route.Uri("tls-app"))).To(BeNil())
+			})
+
+			It("retries the request on another backend of the route", func() {
+				other, err := net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).NotTo(HaveOccurred())
+				other = tls.NewListener(other, &tls.Config{Certificates: []tls.Certificate{chain.TLSCert()}})
+				defer other.Close()
+				go runBackendInstance(other, func(conn *test_util.HttpConn) {
+					defer conn.Close()
+					if _, err := http.ReadRequest(conn.Reader); err != nil {
+						return
+					}
+					conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				})
+
+				host, portStr, err := net.SplitHostPort(other.Addr().String())
+				Expect(err).NotTo(HaveOccurred())
+				port, err := strconv.Atoi(portStr)
+				Expect(err).NotTo(HaveOccurred())
+				endpoint := route.NewEndpoint("", host, uint16(port), "", "", nil, -1, "", models.ModificationTag{})
+				endpoint.ServerCertDomainSAN = "instance-1"
+				r.Register(route.Uri("tls-app"), endpoint)
+
+				// round robin sends one of the requests to the stale backend
+				for i := 0; i < 2; i++ {
+					conn := dialProxy(proxyServer)
+					conn.WriteRequest(test_util.NewRequest("GET", "tls-app", "/", nil))
+
+					resp, _ := conn.ReadResponse()
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				}
+
+				var endpoints []*route.Endpoint
+				r.Lookup(route.Uri("tls-app")).Each(func(e *route.Endpoint) {
+					endpoints = append(endpoints, e)
+				})
+				Expect(endpoints).To(ConsistOf(endpoint))
+			})
+		})
+	})
+
 	It("maintains percent-encoded values in URLs", func() {
 		shouldEcho("/abc%2b%2f%25%20%22%3F%5Edef", "/abc%2b%2f%25%20%22%3F%5Edef") // +, /, %, <space>, ", £, ^
 	})
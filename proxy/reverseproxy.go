@@ -18,6 +18,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"code.cloudfoundry.org/gorouter/proxy/utils"
 )
 
 // onExitFlushLoop is a callback set by tests to detect the state of the
@@ -194,6 +196,12 @@ func (p *ReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	// TE is a hop-by-hop header, but gRPC backends require requests to state
+	// that they accept trailers, the only value of TE allowed in HTTP/2.
+	if headerValuesContainToken(req.Header["Te"], "trailers") {
+		outreq.Header.Set("Te", "trailers")
+	}
+
 	if clientIP, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
 		// If we aren't the first proxy retain prior
 		// X-Forwarded-For information as a comma+space
@@ -237,7 +245,8 @@ func (p *ReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 
 	// The "Trailer" header isn't included in the Transport's response,
 	// at least for *http.Transport. Build it up from Trailer.
-	if len(res.Trailer) > 0 {
+	announcedTrailers := len(res.Trailer)
+	if announcedTrailers > 0 {
 		trailerKeys := make([]string, 0, len(res.Trailer))
 		for k := range res.Trailer {
 			trailerKeys = append(trailerKeys, k)
@@ -245,8 +254,10 @@ func (p *ReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		rw.Header().Add("Trailer", strings.Join(trailerKeys, ", "))
 	}
 
+	flushInterval := p.flushInterval(res)
+
 	rw.WriteHeader(res.StatusCode)
-	if len(res.Trailer) > 0 {
+	if len(res.Trailer) > 0 || flushInterval < 0 {
 		// Force chunking if we saw a response trailer.
 		// This prevents net/http from calculating the length for short
 		// bodies and adding a Content-Length.
@@ -254,17 +265,50 @@ func (p *ReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 			fl.Flush()
 		}
 	}
-	p.copyResponse(rw, res.Body)
+	if err := p.copyResponse(rw, res.Body, flushInterval); err != nil {
+		// nothing more is written, so that a truncated body is not taken
+		// for the complete response
+		if proxyWriter, ok := rw.(utils.ProxyResponseWriter); ok {
+			proxyWriter.Done()
+		}
+	}
 	res.Body.Close() // close now, instead of defer, to populate res.Trailer
-	copyHeader(rw.Header(), res.Trailer)
+
+	if len(res.Trailer) == announcedTrailers {
+		copyHeader(rw.Header(), res.Trailer)
+		return
+	}
+
+	// Trailers that were not announced before the header was written, as
+	// gRPC backends send them, are set with the trailer prefix.
+	for k, vv := range res.Trailer {
+		k = http.TrailerPrefix + k
+		for _, v := range vv {
+			rw.Header().Add(k, v)
+		}
+	}
+}
+
+// flushInterval returns the interval at which the response is flushed to
+// the client, or -1 to flush after every write. gRPC responses stream
+// messages, so they are not held back.
+func (p *ReverseProxy) flushInterval(res *http.Response) time.Duration {
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "application/grpc") {
+		return -1
+	}
+	return p.FlushInterval
 }
 
-func (p *ReverseProxy) copyResponse(dst io.Writer, src io.Reader) {
-	if p.FlushInterval != 0 {
+func (p *ReverseProxy) copyResponse(dst io.Writer, src io.Reader, flushInterval time.Duration) error {
+	if flushInterval < 0 {
+		if wf, ok := dst.(writeFlusher); ok {
+			dst = &flushingWriter{dst: wf}
+		}
+	} else if flushInterval != 0 {
 		if wf, ok := dst.(writeFlusher); ok {
 			mlw := &maxLatencyWriter{
 				dst:     wf,
-				latency: p.FlushInterval,
+				latency: flushInterval,
 				done:    make(chan bool),
 			}
 			go mlw.flushLoop()
@@ -277,10 +321,14 @@ func (p *ReverseProxy) copyResponse(dst io.Writer, src io.Reader) {
 	if p.BufferPool != nil {
 		buf = p.BufferPool.Get()
 	}
-	p.copyBuffer(dst, src, buf)
+	_, err := p.copyBuffer(dst, src, buf)
 	if p.BufferPool != nil {
 		p.BufferPool.Put(buf)
 	}
+	if err == io.EOF {
+		return nil
+	}
+	return err
 }
 
 func (p *ReverseProxy) copyBuffer(dst io.Writer, src io.Reader, buf []byte) (int64, error) {
@@ -357,3 +405,27 @@ func (m *maxLatencyWriter) flushLoop() {
 }
 
 func (m *maxLatencyWriter) stop() { m.done <- true }
+
+// flushingWriter flushes after every write.
+type flushingWriter struct {
+	dst writeFlusher
+}
+
+func (f *flushingWriter) Write(p []byte) (int, error) {
+	n, err := f.dst.Write(p)
+	f.dst.Flush()
+	return n, err
+}
+
+// headerValuesContainToken returns true if any of the comma separated values
+// is the token, compared case-insensitively.
+func headerValuesContainToken(values []string, token string) bool {
+	for _, v := range values {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -25,3 +25,7 @@ func (d *dropsondeRoundTripper) RoundTrip(r *http.Request) (*http.Response, erro
 func (d *dropsondeRoundTripper) CancelRequest(r *http.Request) {
 	d.p.CancelRequest(r)
 }
+
+func (d *dropsondeRoundTripper) CloseIdleConnections() {
+	closeIdleConnections(d.p)
+}
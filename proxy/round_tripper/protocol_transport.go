@@ -0,0 +1,121 @@
+package round_tripper
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NewProtocolTransport returns a transport sending requests to backends over
+// the protocol the round tripper selected for the endpoint: requests with
+// ProtoMajor 2 are sent with h2 when their scheme is https and with h2c
+// otherwise. Other requests are sent with http1. HTTP/2 requests, whose
+// connections carry many requests and so get no deadline, must complete
+// within timeout unless it is zero.
+func NewProtocolTransport(http1 ProxyRoundTripper, h2, h2c http.RoundTripper, timeout time.Duration) ProxyRoundTripper {
+	return &protocolTransport{
+		http1:   http1,
+		h2:      h2,
+		h2c:     h2c,
+		timeout: timeout,
+		cancels: make(map[*http.Request]context.CancelFunc),
+	}
+}
+
+type protocolTransport struct {
+	http1 ProxyRoundTripper
+	h2    http.RoundTripper
+	h2c   http.RoundTripper
+	// timeout bounds HTTP/2 requests, from sending them until their
+	// response body is closed
+	timeout time.Duration
+
+	lock sync.Mutex
+	// cancels holds the HTTP/2 requests in flight, which are cancelled
+	// through their context
+	cancels map[*http.Request]context.CancelFunc
+}
+
+func (t *protocolTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.ProtoMajor != 2 {
+		return t.http1.RoundTrip(request)
+	}
+
+	transport := t.h2c
+	if request.URL.Scheme == "https" {
+		transport = t.h2
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if t.timeout > 0 {
+		ctx, cancel = context.WithTimeout(request.Context(), t.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(request.Context())
+	}
+	t.lock.Lock()
+	t.cancels[request] = cancel
+	t.lock.Unlock()
+
+	done := func() {
+		t.lock.Lock()
+		delete(t.cancels, request)
+		t.lock.Unlock()
+		cancel()
+	}
+
+	res, err := transport.RoundTrip(request.WithContext(ctx))
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	// the request stays cancellable until its response has been read
+	res.Body = &closeNotifyingBody{ReadCloser: res.Body, onClose: done}
+	return res, nil
+}
+
+// CloseIdleConnections closes the connections of each protocol that are not
+// carrying a request.
+func (t *protocolTransport) CloseIdleConnections() {
+	closeIdleConnections(t.http1)
+	closeIdleConnections(t.h2)
+	closeIdleConnections(t.h2c)
+}
+
+func (t *protocolTransport) CancelRequest(request *http.Request) {
+	t.lock.Lock()
+	cancel, ok := t.cancels[request]
+	t.lock.Unlock()
+
+	if ok {
+		cancel()
+		return
+	}
+	t.http1.CancelRequest(request)
+}
+
+// closeIdleConnections closes the idle connections of transports that pool
+// them, such as http.Transport and http2.Transport.
+func closeIdleConnections(transport http.RoundTripper) {
+	if closer, ok := transport.(interface {
+		CloseIdleConnections()
+	}); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// closeNotifyingBody calls onClose once the body is closed.
+type closeNotifyingBody struct {
+	io.ReadCloser
+	onClose func()
+	once    sync.Once
+}
+
+func (b *closeNotifyingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.onClose)
+	return err
+}
@@ -0,0 +1,125 @@
+package round_tripper_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
+	roundtripperfakes "code.cloudfoundry.org/gorouter/proxy/round_tripper/fakes"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type idleCloser struct {
+	*roundtripperfakes.FakeProxyRoundTripper
+	closed int
+}
+
+func (c *idleCloser) CloseIdleConnections() {
+	c.closed++
+}
+
+var _ = Describe("ProtocolTransport", func() {
+	var (
+		http1, h2, h2c *roundtripperfakes.FakeProxyRoundTripper
+		transport      round_tripper.ProxyRoundTripper
+		req            *http.Request
+	)
+
+	BeforeEach(func() {
+		http1 = new(roundtripperfakes.FakeProxyRoundTripper)
+		h2 = new(roundtripperfakes.FakeProxyRoundTripper)
+		h2c = new(roundtripperfakes.FakeProxyRoundTripper)
+		for _, t := range []*roundtripperfakes.FakeProxyRoundTripper{http1, h2, h2c} {
+			t.RoundTripStub = func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			}
+		}
+		transport = round_tripper.NewProtocolTransport(http1, h2, h2c, time.Second)
+
+		req = test_util.NewRequest("GET", "myapp.com", "/", nil)
+	})
+
+	It("sends HTTP/1.1 requests with the HTTP/1.1 transport", func() {
+		_, err := transport.RoundTrip(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(http1.RoundTripCallCount()).To(Equal(1))
+		Expect(http1.RoundTripArgsForCall(0)).To(BeIdenticalTo(req))
+	})
+
+	It("closes the idle connections of the transports of each protocol", func() {
+		closers := []*idleCloser{
+			{FakeProxyRoundTripper: http1},
+			{FakeProxyRoundTripper: h2},
+			{FakeProxyRoundTripper: h2c},
+		}
+		transport = round_tripper.NewProtocolTransport(closers[0], closers[1], closers[2], time.Second)
+
+		transport.(interface {
+			CloseIdleConnections()
+		}).CloseIdleConnections()
+
+		for _, closer := range closers {
+			Expect(closer.closed).To(Equal(1))
+		}
+	})
+
+	Context("with HTTP/2 requests", func() {
+		BeforeEach(func() {
+			req.ProtoMajor = 2
+		})
+
+		It("sends cleartext requests with h2c", func() {
+			_, err := transport.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(h2c.RoundTripCallCount()).To(Equal(1))
+			Expect(h2.RoundTripCallCount()).To(Equal(0))
+		})
+
+		It("sends https requests with h2", func() {
+			req.URL.Scheme = "https"
+
+			_, err := transport.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(h2.RoundTripCallCount()).To(Equal(1))
+			Expect(h2c.RoundTripCallCount()).To(Equal(0))
+		})
+
+		It("gives requests the timeout as a deadline", func() {
+			_, err := transport.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+
+			deadline, ok := h2c.RoundTripArgsForCall(0).Context().Deadline()
+			Expect(ok).To(BeTrue())
+			Expect(deadline).To(BeTemporally("~", time.Now().Add(time.Second), 100*time.Millisecond))
+		})
+
+		It("gives requests no deadline without a timeout", func() {
+			transport = round_tripper.NewProtocolTransport(http1, h2, h2c, 0)
+
+			_, err := transport.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, ok := h2c.RoundTripArgsForCall(0).Context().Deadline()
+			Expect(ok).To(BeFalse())
+		})
+
+		It("cancels requests through their context until the response is closed", func() {
+			res, err := transport.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			sent := h2c.RoundTripArgsForCall(0)
+
+			transport.CancelRequest(req)
+			Expect(sent.Context().Done()).To(BeClosed())
+			Expect(http1.CancelRequestCallCount()).To(Equal(0))
+
+			res.Body.Close()
+			transport.CancelRequest(req)
+			Expect(http1.CancelRequestCallCount()).To(Equal(1))
+		})
+	})
+})
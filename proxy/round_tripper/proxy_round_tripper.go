@@ -1,11 +1,15 @@
 package round_tripper
 
 import (
+	"context"
+	"crypto/x509"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/uber-go/zap"
 
@@ -16,14 +20,22 @@ import (
 	"code.cloudfoundry.org/gorouter/metrics"
 	"code.cloudfoundry.org/gorouter/proxy/handler"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/route"
 )
 
 const (
-	VcapCookieId      = "__VCAP_ID__"
-	StickyCookieKey   = "JSESSIONID"
-	CookieHeader      = "Set-Cookie"
-	BadGatewayMessage = "502 Bad Gateway: Registered endpoint failed to handle the request."
+	VcapCookieId        = "__VCAP_ID__"
+	StickyCookieKey     = "JSESSIONID"
+	CookieHeader        = "Set-Cookie"
+	BadGatewayMessage   = "502 Bad Gateway: Registered endpoint failed to handle the request."
+	OverloadedMessage   = "503 Service Unavailable: All registered endpoints are at their concurrent request limit."
+	NameMismatchMessage = "503 Service Unavailable: Registered endpoint presented a certificate for another name."
+	BodyTooLargeMessage = "413 Request Entity Too Large: Request body too large."
+
+	// maxHedgeSelections is the number of endpoints selected to find one to
+	// hedge a request to before the request is left to its first endpoint.
+	maxHedgeSelections = 3
 )
 
 //go:generate counterfeiter -o fakes/fake_proxy_round_tripper.go . ProxyRoundTripper
@@ -36,19 +48,31 @@ type AfterRoundTrip func(req *http.Request, rsp *http.Response, endpoint *route.
 
 func NewProxyRoundTripper(
 	transport ProxyRoundTripper,
+	tlsTransports *TLSTransports,
+	registry registry.Registry,
 	logger logger.Logger,
 	traceKey string,
 	routerIP string,
 	defaultLoadBalance string,
+	hashKeySource string,
+	trustedProxies []*net.IPNet,
+	retryPolicy route.RetryPolicy,
+	retryBudget *RetryBudget,
 	combinedReporter metrics.CombinedReporter,
 	secureCookies bool,
 ) ProxyRoundTripper {
 	return &roundTripper{
 		logger:             logger,
 		transport:          transport,
+		tlsTransports:      tlsTransports,
+		registry:           registry,
 		traceKey:           traceKey,
 		routerIP:           routerIP,
 		defaultLoadBalance: defaultLoadBalance,
+		hashKeySource:      hashKeySource,
+		trustedProxies:     trustedProxies,
+		retryPolicy:        retryPolicy,
+		retryBudget:        retryBudget,
 		combinedReporter:   combinedReporter,
 		secureCookies:      secureCookies,
 	}
@@ -56,10 +80,16 @@ func NewProxyRoundTripper(
 
 type roundTripper struct {
 	transport          ProxyRoundTripper
+	tlsTransports      *TLSTransports
+	registry           registry.Registry
 	logger             logger.Logger
 	traceKey           string
 	routerIP           string
 	defaultLoadBalance string
+	hashKeySource      string
+	trustedProxies     []*net.IPNet
+	retryPolicy        route.RetryPolicy
+	retryBudget        *RetryBudget
 	combinedReporter   metrics.CombinedReporter
 	secureCookies      bool
 }
@@ -69,11 +99,14 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 	var res *http.Response
 	var endpoint *route.Endpoint
 
+	var requestBody io.Closer
 	if request.Body != nil {
-		closer := request.Body
+		requestBody = request.Body
 		request.Body = ioutil.NopCloser(request.Body)
 		defer func() {
-			closer.Close()
+			if requestBody != nil {
+				requestBody.Close()
+			}
 		}()
 	}
 
@@ -101,23 +134,62 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 
 	routePool := rp.(*route.Pool)
 	stickyEndpointID := getStickySession(request)
-	iter := routePool.Endpoints(rt.defaultLoadBalance, stickyEndpointID)
+	hashKey := route.HashKey(rt.hashKeySource, request, rt.trustedProxies)
+	iter := routePool.EndpointsWithHashKey(rt.defaultLoadBalance, stickyEndpointID, hashKey)
+
+	policy := routePool.RetryPolicy(rt.retryPolicy)
+	rt.retryBudget.RecordRequest()
+
+	// endpoints whose address now belongs to another instance are stale
+	// registrations, unregistered once the request is done with the pool
+	var staleEndpoints []*route.Endpoint
 
 	logger := rt.logger
-	for retry := 0; retry < handler.MaxRetries; retry++ {
+	for retry := 0; ; retry++ {
+		accessLogRecord.Attempts++
 
 		if routeServiceURL == nil {
 			logger.Debug("backend", zap.Int("attempt", retry))
-			endpoint, err = rt.selectEndpoint(iter, request)
+			endpoint, err = rt.acquireEndpoint(routePool, iter, request)
 			if err != nil {
 				break
 			}
+			accessLogRecord.AttemptedEndpoints = append(accessLogRecord.AttemptedEndpoints, endpoint)
 			logger = logger.With(zap.Nest("route-endpoint", endpoint.ToLogData()...))
-			res, err = rt.backendRoundTrip(request, endpoint, iter)
-			if err == nil || !retryableError(err) {
+			if routePool.IsCrossZone(endpoint) {
+				rt.combinedReporter.CaptureCrossZoneRequest(endpoint)
+			}
+			if delay, ok := hedgeDelay(routePool, request); ok {
+				var hedge *route.Endpoint
+				res, endpoint, hedge, err = rt.hedgedRoundTrip(routePool, iter, request, endpoint, delay, logger)
+				if hedge != nil {
+					accessLogRecord.Attempts++
+					accessLogRecord.AttemptedEndpoints = append(accessLogRecord.AttemptedEndpoints, hedge)
+				}
+			} else {
+				res, err = rt.attempt(routePool, iter, request, endpoint)
+			}
+			recordOutlierResult(routePool, endpoint, res, err)
+			if err == nil {
+				if res == nil || !policy.RetriesStatus(request, res.StatusCode) || !rt.retryAllowed(policy, retry, logger) {
+					break
+				}
+				logger.Info("backend-endpoint-retryable-status", zap.Int("status-code", res.StatusCode))
+				if res.Body != nil {
+					res.Body.Close()
+				}
+				routePool.EndpointFailed(endpoint)
+				continue
+			}
+			if isNameMismatch(err) {
+				logger.Error("backend-endpoint-name-mismatch", zap.Error(err))
+				staleEndpoints = append(staleEndpoints, endpoint)
+			}
+			if !retryableError(policy, request, err) {
 				break
 			}
-			iter.EndpointFailed()
+			// the iterator may have selected a hedge since the endpoint
+			routePool.EndpointFailed(endpoint)
 			logger.Error("backend-endpoint-failed", zap.Error(err))
 		} else {
 			logger.Debug(
@@ -140,20 +212,88 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 				}
 				break
 			}
-			if !retryableError(err) {
+			if !retryableError(policy, request, err) {
 				break
 			}
 			logger.Error("route-service-connection-failed", zap.Error(err))
 		}
+
+		if !rt.retryAllowed(policy, retry, logger) {
+			break
+		}
+	}
+
+	for _, e := range staleEndpoints {
+		rt.registry.Unregister(routePool.RouteKey(), e)
 	}
 
 	accessLogRecord.RouteEndpoint = endpoint
 
+	if err == handler.EndpointsOverloaded {
+		responseWriter := rw.(utils.ProxyResponseWriter)
+		responseWriter.Header().Set(router_http.CfRouterError, "endpoints_overloaded")
+
+		accessLogRecord.StatusCode = http.StatusServiceUnavailable
+		accessLogRecord.RouterError = "endpoints_overloaded"
+
+		logger.Info("status", zap.String("body", OverloadedMessage))
+
+		http.Error(responseWriter, OverloadedMessage, http.StatusServiceUnavailable)
+		responseWriter.Header().Del("Connection")
+
+		logger.Error("endpoints-overloaded", zap.Error(err))
+
+		rt.combinedReporter.CaptureOverloadedRequest()
+
+		responseWriter.Done()
+
+		return nil, err
+	}
+
+	if isNameMismatch(err) {
+		responseWriter := rw.(utils.ProxyResponseWriter)
+		responseWriter.Header().Set(router_http.CfRouterError, "endpoint_name_mismatch")
+
+		accessLogRecord.StatusCode = http.StatusServiceUnavailable
+		accessLogRecord.RouterError = "endpoint_name_mismatch"
+
+		logger.Info("status", zap.String("body", NameMismatchMessage))
+
+		http.Error(responseWriter, NameMismatchMessage, http.StatusServiceUnavailable)
+		responseWriter.Header().Del("Connection")
+
+		logger.Error("endpoint-name-mismatch", zap.Error(err))
+
+		responseWriter.Done()
+
+		return nil, err
+	}
+
+	if err != nil && handlers.RequestBodyTooLarge(request) {
+		responseWriter := rw.(utils.ProxyResponseWriter)
+		responseWriter.Header().Set(router_http.CfRouterError, "request_body_too_large")
+
+		accessLogRecord.StatusCode = http.StatusRequestEntityTooLarge
+		accessLogRecord.RouterError = "request_body_too_large"
+
+		logger.Info("status", zap.String("body", BodyTooLargeMessage))
+
+		http.Error(responseWriter, BodyTooLargeMessage, http.StatusRequestEntityTooLarge)
+		responseWriter.Header().Del("Connection")
+
+		rt.combinedReporter.CaptureOversizedRequest()
+
+		responseWriter.Done()
+
+		return nil, err
+	}
+
 	if err != nil {
 		responseWriter := rw.(utils.ProxyResponseWriter)
 		responseWriter.Header().Set(router_http.CfRouterError, "endpoint_failure")
 
 		accessLogRecord.StatusCode = http.StatusBadGateway
+		accessLogRecord.RouterError = "endpoint_failure"
 
 		logger.Info("status", zap.String("body", BadGatewayMessage))
 
@@ -181,6 +321,13 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 		setupStickySession(res, endpoint, stickyEndpointID, rt.secureCookies, routePool.ContextPath())
 	}
 
+	// streaming requests keep sending their body while the response is
+	// read, so it is closed along with the response
+	if requestBody != nil && res != nil && res.Body != nil {
+		res.Body = &closeNotifyingBody{ReadCloser: res.Body, onClose: func() { requestBody.Close() }}
+		requestBody = nil
+	}
+
 	return res, nil
 }
 
@@ -194,6 +341,7 @@ func (rt *roundTripper) backendRoundTrip(
 	iter route.EndpointIterator,
 ) (*http.Response, error) {
 	request.URL.Host = endpoint.CanonicalAddr()
+	setBackendProtocol(request, endpoint)
 	request.Header.Set("X-CF-ApplicationID", endpoint.ApplicationId)
 	handler.SetRequestXCfInstanceId(request, endpoint)
 
@@ -201,13 +349,138 @@ func (rt *roundTripper) backendRoundTrip(
 	iter.PreRequest(endpoint)
 
 	rt.combinedReporter.CaptureRoutingRequest(endpoint)
-	res, err := rt.transport.RoundTrip(request)
+	res, err := rt.transportFor(endpoint).RoundTrip(request)
 
 	// decrement connection stats
 	iter.PostRequest(endpoint)
 	return res, err
 }
 
+// attempt sends the request to an endpoint holding a request slot of the pool
+// and records the response latency. The slot is released once the response
+// body is closed, as the endpoint keeps serving the request until then.
+func (rt *roundTripper) attempt(
+	pool *route.Pool,
+	iter route.EndpointIterator,
+	request *http.Request,
+	endpoint *route.Endpoint,
+) (*http.Response, error) {
+	start := time.Now()
+	res, err := rt.backendRoundTrip(request, endpoint, iter)
+	if err != nil || res == nil || res.Body == nil {
+		pool.Release(endpoint)
+		return res, err
+	}
+
+	pool.ObserveLatency(time.Since(start))
+	res.Body = &closeNotifyingBody{ReadCloser: res.Body, onClose: func() { pool.Release(endpoint) }}
+	return res, nil
+}
+
+type attemptResult struct {
+	request  *http.Request
+	cancel   context.CancelFunc
+	endpoint *route.Endpoint
+	res      *http.Response
+	err      error
+}
+
+// response returns the response of the attempt, which keeps the context of
+// the attempt until its body is closed.
+func (r attemptResult) response() *http.Response {
+	if r.res == nil || r.res.Body == nil {
+		r.cancel()
+		return r.res
+	}
+	r.res.Body = &closeNotifyingBody{ReadCloser: r.res.Body, onClose: r.cancel}
+	return r.res
+}
+
+// hedgedRoundTrip sends the request to endpoint and, if it has not responded
+// within delay, a copy of the request to another endpoint. The first response
+// wins and the other attempt is cancelled. It returns the endpoint that
+// answered, and the endpoint the copy was sent to or nil if none was. An
+// attempt failing while the other is still running is marked failed in the
+// pool.
+func (rt *roundTripper) hedgedRoundTrip(
+	pool *route.Pool,
+	iter route.EndpointIterator,
+	request *http.Request,
+	endpoint *route.Endpoint,
+	delay time.Duration,
+	logger logger.Logger,
+) (*http.Response, *route.Endpoint, *route.Endpoint, error) {
+	results := make(chan attemptResult, 2)
+	send := func(e *route.Endpoint) (*http.Request, context.CancelFunc) {
+		// the attempts run concurrently, so each gets its own copy of the
+		// request, with a context cancelling it whatever the protocol of the
+		// transport
+		ctx, cancel := context.WithCancel(request.Context())
+		req := cloneRequest(request).WithContext(ctx)
+		go func() {
+			res, err := rt.attempt(pool, iter, req, e)
+			results <- attemptResult{request: req, cancel: cancel, endpoint: e, res: res, err: err}
+		}()
+		return req, cancel
+	}
+
+	primaryRequest, cancelPrimary := send(endpoint)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case r := <-results:
+		return r.response(), r.endpoint, nil, r.err
+	case <-timer.C:
+	}
+
+	hedge := selectHedgeEndpoint(pool, iter, endpoint)
+	if hedge == nil {
+		r := <-results
+		return r.response(), r.endpoint, nil, r.err
+	}
+
+	logger.Debug("hedged-request", zap.Nest("hedge-endpoint", hedge.ToLogData()...))
+	rt.combinedReporter.CaptureHedgedRequest()
+	hedgeRequest, cancelHedge := send(hedge)
+
+	winner := <-results
+	if winner.err != nil {
+		winner.cancel()
+		recordOutlierResult(pool, winner.endpoint, winner.res, winner.err)
+		pool.EndpointFailed(winner.endpoint)
+		winner = <-results
+	} else {
+		loserRequest, loserEndpoint, cancelLoser := hedgeRequest, hedge, cancelHedge
+		if winner.request == hedgeRequest {
+			loserRequest, loserEndpoint, cancelLoser = primaryRequest, endpoint, cancelPrimary
+		}
+		cancelLoser()
+		rt.transportFor(loserEndpoint).CancelRequest(loserRequest)
+		go func() {
+			loser := <-results
+			if loser.res != nil && loser.res.Body != nil {
+				loser.res.Body.Close()
+			}
+		}()
+	}
+
+	if winner.err == nil && winner.endpoint == hedge {
+		rt.combinedReporter.CaptureHedgeWon()
+	}
+	return winner.response(), winner.endpoint, hedge, winner.err
+}
+
+// transportFor returns the transport of requests to the endpoint. Endpoints
+// registered with a TLS port get the transport verifying their server name.
+func (rt *roundTripper) transportFor(endpoint *route.Endpoint) ProxyRoundTripper {
+	if endpoint.IsTLS() && rt.tlsTransports != nil {
+		return rt.tlsTransports.Get(endpoint.ServerCertDomainSAN)
+	}
+	return rt.transport
+}
+
 func (rt *roundTripper) selectEndpoint(iter route.EndpointIterator, request *http.Request) (*route.Endpoint, error) {
 	endpoint := iter.Next()
 	if endpoint == nil {
@@ -217,6 +490,32 @@ func (rt *roundTripper) selectEndpoint(iter route.EndpointIterator, request *htt
 	return endpoint, nil
 }
 
+// acquireEndpoint selects an endpoint and reserves a request slot on it. When
+// every endpoint is at its limit of concurrent requests, the request waits as
+// a pending request of the pool and selects again once a slot is released.
+// The overloaded endpoint is returned along with EndpointsOverloaded when the
+// request cannot wait.
+func (rt *roundTripper) acquireEndpoint(pool *route.Pool, iter route.EndpointIterator, request *http.Request) (*route.Endpoint, error) {
+	var waitingSince time.Time
+	for {
+		endpoint, err := rt.selectEndpoint(iter, request)
+		if err != nil {
+			return nil, err
+		}
+
+		if pool.Acquire(endpoint) {
+			return endpoint, nil
+		}
+
+		if waitingSince.IsZero() {
+			waitingSince = time.Now()
+		}
+		if !pool.WaitForCapacity(waitingSince, request.Context().Done()) {
+			return endpoint, handler.EndpointsOverloaded
+		}
+	}
+}
+
 func setupStickySession(
 	response *http.Response,
 	endpoint *route.Endpoint,
@@ -273,14 +572,133 @@ func getStickySession(request *http.Request) string {
 	return ""
 }
 
-func retryableError(err error) bool {
-	ne, netErr := err.(*net.OpError)
-	if netErr && (ne.Op == "dial" || ne.Op == "read" && ne.Err.Error() == "read: connection reset by peer") {
+// hedgeDelay returns the delay after which the request is hedged, and false
+// if it is not. Only requests without a body that are safe to send twice are
+// hedged.
+func hedgeDelay(pool *route.Pool, request *http.Request) (time.Duration, bool) {
+	if request.Method != "GET" && request.Method != "HEAD" {
+		return 0, false
+	}
+	if request.ContentLength != 0 {
+		return 0, false
+	}
+	return pool.HedgeDelay()
+}
+
+// selectHedgeEndpoint selects an endpoint other than the one the request was
+// first sent to and reserves a request slot on it. It returns nil if there is
+// none available.
+func selectHedgeEndpoint(pool *route.Pool, iter route.EndpointIterator, first *route.Endpoint) *route.Endpoint {
+	for i := 0; i < maxHedgeSelections; i++ {
+		e := iter.Next()
+		if e == nil {
+			return nil
+		}
+		if e.CanonicalAddr() != first.CanonicalAddr() && pool.Acquire(e) {
+			return e
+		}
+	}
+	return nil
+}
+
+// setBackendProtocol marks the request for the transport of the protocol the
+// endpoint speaks.
+func setBackendProtocol(request *http.Request, endpoint *route.Endpoint) {
+	request.URL.Scheme = "http"
+	if endpoint.IsTLS() {
+		request.URL.Scheme = "https"
+	}
+	request.Proto, request.ProtoMajor, request.ProtoMinor = "HTTP/1.1", 1, 1
+
+	switch endpoint.BackendProtocol {
+	case route.BackendProtocolH2:
+		request.URL.Scheme = "https"
+		request.Proto, request.ProtoMajor, request.ProtoMinor = "HTTP/2.0", 2, 0
+	case route.BackendProtocolH2C:
+		request.Proto, request.ProtoMajor, request.ProtoMinor = "HTTP/2.0", 2, 0
+	}
+}
+
+// cloneRequest copies the parts of the request an attempt modifies.
+func cloneRequest(request *http.Request) *http.Request {
+	clone := new(http.Request)
+	*clone = *request
+
+	u := *request.URL
+	clone.URL = &u
+
+	clone.Header = make(http.Header, len(request.Header))
+	for k, v := range request.Header {
+		clone.Header[k] = append([]string(nil), v...)
+	}
+	return clone
+}
+
+// retryableError returns true if the policy retries attempts failing with
+// err. Attempts rejected for their server name are always retried, as the
+// request was never sent.
+func retryableError(policy route.RetryPolicy, request *http.Request, err error) bool {
+	// the body was cut off at its limit and cannot be sent again
+	if handlers.RequestBodyTooLarge(request) {
+		return false
+	}
+
+	if isNameMismatch(err) {
 		return true
 	}
+
+	ne, netErr := err.(*net.OpError)
+	if !netErr {
+		return false
+	}
+
+	switch {
+	case ne.Op == "dial":
+		return policy.RetriesConnectFailure()
+	case ne.Op == "read" && ne.Err.Error() == "read: connection reset by peer":
+		return policy.RetriesReset(request)
+	}
 	return false
 }
 
+// retryAllowed returns true if the attempt with index retry can be followed
+// by another one within the attempts of the policy and the retry budget.
+func (rt *roundTripper) retryAllowed(policy route.RetryPolicy, retry int, logger logger.Logger) bool {
+	if retry+1 >= policy.MaxAttempts {
+		return false
+	}
+
+	if !rt.retryBudget.AllowRetry() {
+		logger.Info("retry-budget-exhausted")
+		return false
+	}
+	return true
+}
+
+// recordOutlierResult reports 5xx responses and timeouts of the backend to
+// outlier detection. Other errors are left to the failure handling of the
+// iterator.
+func recordOutlierResult(pool *route.Pool, endpoint *route.Endpoint, res *http.Response, err error) {
+	if err == nil {
+		if res != nil {
+			pool.RecordResult(endpoint, res.StatusCode >= http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		pool.RecordResult(endpoint, true)
+	}
+}
+
+// isNameMismatch returns true if err is the certificate of a TLS endpoint not
+// matching the server name it was registered with. The transport wraps the
+// error of the certificate verification.
+func isNameMismatch(err error) bool {
+	var hostnameErr x509.HostnameError
+	return errors.As(err, &hostnameErr)
+}
+
 func newRouteServiceEndpoint() *route.Endpoint {
 	return &route.Endpoint{
 		Tags: map[string]string{},
@@ -2,23 +2,29 @@ package round_tripper_test
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
 	"syscall"
 	"time"
 
 	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/metrics/fakes"
 	"code.cloudfoundry.org/gorouter/proxy/handler"
 	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
 	roundtripperfakes "code.cloudfoundry.org/gorouter/proxy/round_tripper/fakes"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
+	registryfakes "code.cloudfoundry.org/gorouter/registry/fakes"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/test_util"
 	"code.cloudfoundry.org/routing-api/models"
@@ -33,18 +39,34 @@ import (
 
 type nullVarz struct{}
 
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
 var _ = Describe("ProxyRoundTripper", func() {
 	Context("RoundTrip", func() {
 		var (
 			proxyRoundTripper round_tripper.ProxyRoundTripper
 			routePool         *route.Pool
 			transport         *roundtripperfakes.FakeProxyRoundTripper
+			tlsTransport      *roundtripperfakes.FakeProxyRoundTripper
+			tlsTransports     *round_tripper.TLSTransports
+			registry          *registryfakes.FakeRegistry
+			tlsServerNames    []string
 			logger            *test_util.TestZapLogger
 			req               *http.Request
 			resp              *httptest.ResponseRecorder
 			alr               *schema.AccessLogRecord
 			routerIP          string
 			combinedReporter  *fakes.FakeCombinedReporter
+			retryPolicy       route.RetryPolicy
+			retryBudget       *round_tripper.RetryBudget
 
 			endpoint *route.Endpoint
 
@@ -60,6 +82,7 @@ var _ = Describe("ProxyRoundTripper", func() {
 
 		BeforeEach(func() {
 			routePool = route.NewPool(1*time.Second, "")
+			routePool.SetRouteKey("myapp.com")
 			resp = httptest.NewRecorder()
 			alr = &schema.AccessLogRecord{}
 			proxyWriter := utils.NewProxyResponseWriter(resp)
@@ -72,6 +95,13 @@ var _ = Describe("ProxyRoundTripper", func() {
 
 			logger = test_util.NewTestZapLogger("test")
 			transport = new(roundtripperfakes.FakeProxyRoundTripper)
+			tlsTransport = new(roundtripperfakes.FakeProxyRoundTripper)
+			tlsServerNames = nil
+			tlsTransports = round_tripper.NewTLSTransports(func(serverName string) round_tripper.ProxyRoundTripper {
+				tlsServerNames = append(tlsServerNames, serverName)
+				return tlsTransport
+			})
+			registry = new(registryfakes.FakeRegistry)
 			routerIP = "127.0.0.1"
 
 			endpoint = route.NewEndpoint("appId", "1.1.1.1", uint16(9090), "id", "1",
@@ -82,9 +112,17 @@ var _ = Describe("ProxyRoundTripper", func() {
 
 			combinedReporter = new(fakes.FakeCombinedReporter)
 
+			retryPolicy = route.RetryPolicy{
+				MaxAttempts: 3,
+				RetryOn:     []string{config.RETRY_ON_CONNECT_FAILURE, config.RETRY_ON_RESET},
+			}
+			retryBudget = nil
+		})
+
+		JustBeforeEach(func() {
 			proxyRoundTripper = round_tripper.NewProxyRoundTripper(
-				transport, logger, "my_trace_key", routerIP, "",
-				combinedReporter, false,
+				transport, tlsTransports, registry, logger, "my_trace_key", routerIP, "",
+				"", nil, retryPolicy, retryBudget, combinedReporter, false,
 			)
 		})
 
@@ -167,6 +205,156 @@ var _ = Describe("ProxyRoundTripper", func() {
 			})
 		})
 
+		Context("when the pool prefers a zone", func() {
+			BeforeEach(func() {
+				transport.RoundTripReturns(resp.Result(), nil)
+			})
+
+			It("captures requests to endpoints in other zones", func() {
+				endpoint.Zone = "z2"
+				routePool.PreferZone("z1", 0)
+
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(combinedReporter.CaptureCrossZoneRequestCallCount()).To(Equal(1))
+				Expect(combinedReporter.CaptureCrossZoneRequestArgsForCall(0)).To(Equal(endpoint))
+			})
+
+			It("does not capture requests to endpoints in the same zone", func() {
+				endpoint.Zone = "z1"
+				routePool.PreferZone("z1", 0)
+
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(combinedReporter.CaptureCrossZoneRequestCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the pool detects outliers", func() {
+			var second *route.Endpoint
+
+			BeforeEach(func() {
+				second = route.NewEndpoint("appId", "2.2.2.2", uint16(9090), "id-2", "2",
+					map[string]string{}, 0, "", models.ModificationTag{})
+				routePool.Put(second)
+				routePool.SetOutlierDetection(route.OutlierDetection{
+					ConsecutiveErrors:  2,
+					BaseEjectionTime:   time.Minute,
+					MaxEjectionPercent: 50,
+				})
+			})
+
+			It("ejects an endpoint that keeps responding with server errors", func() {
+				transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+					code := http.StatusOK
+					if req.URL.Host == endpoint.CanonicalAddr() {
+						code = http.StatusServiceUnavailable
+					}
+					return &http.Response{StatusCode: code, Header: http.Header{}}, nil
+				}
+
+				for i := 0; i < 4; i++ {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+				}
+
+				for i := 0; i < 4; i++ {
+					res, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+				}
+			})
+		})
+
+		Context("when endpoints are limited to concurrent requests", func() {
+			BeforeEach(func() {
+				transport.RoundTripReturns(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil)
+				routePool.SetCircuitBreaker(route.CircuitBreaker{
+					MaxConcurrentRequests: 1,
+					PendingTimeout:        time.Second,
+				})
+				Expect(routePool.Acquire(endpoint)).To(BeTrue())
+			})
+
+			It("selects an endpoint below its limit", func() {
+				second := route.NewEndpoint("appId", "2.2.2.2", uint16(9090), "id-2", "2",
+					map[string]string{}, 0, "", models.ModificationTag{})
+				routePool.Put(second)
+
+				for i := 0; i < 3; i++ {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(transport.RoundTripArgsForCall(i).URL.Host).To(Equal("2.2.2.2:9090"))
+				}
+			})
+
+			It("holds the slot of the endpoint until the response body is closed", func() {
+				routePool.Release(endpoint)
+				transport.RoundTripStub = func(*http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{},
+						Body:       ioutil.NopCloser(strings.NewReader("hello")),
+					}, nil
+				}
+
+				res, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(routePool.Acquire(endpoint)).To(BeFalse())
+
+				res.Body.Close()
+				Expect(routePool.Acquire(endpoint)).To(BeTrue())
+			})
+
+			Context("when every endpoint is at its limit", func() {
+				It("fails fast with service unavailable", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(MatchError(handler.EndpointsOverloaded))
+					Expect(transport.RoundTripCallCount()).To(Equal(0))
+
+					Expect(resp.Code).To(Equal(http.StatusServiceUnavailable))
+					Expect(resp.Header().Get(router_http.CfRouterError)).To(Equal("endpoints_overloaded"))
+					bodyBytes, err := ioutil.ReadAll(resp.Body)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(bodyBytes)).To(ContainSubstring(round_tripper.OverloadedMessage))
+					Expect(alr.StatusCode).To(Equal(http.StatusServiceUnavailable))
+					Expect(alr.RouterError).To(Equal("endpoints_overloaded"))
+				})
+
+				It("captures the rejection in the metrics reporter", func() {
+					proxyRoundTripper.RoundTrip(req)
+
+					Expect(combinedReporter.CaptureOverloadedRequestCallCount()).To(Equal(1))
+					Expect(combinedReporter.CaptureBadGatewayCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when requests may wait for a free slot", func() {
+				BeforeEach(func() {
+					routePool.SetCircuitBreaker(route.CircuitBreaker{
+						MaxConcurrentRequests: 1,
+						MaxPendingRequests:    1,
+						PendingTimeout:        time.Second,
+					})
+				})
+
+				It("sends the request once a slot is released", func() {
+					go func() {
+						defer GinkgoRecover()
+						time.Sleep(50 * time.Millisecond)
+						routePool.Release(endpoint)
+					}()
+
+					res, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+					Expect(transport.RoundTripCallCount()).To(Equal(1))
+				})
+			})
+		})
+
 		Context("when backend is unavailable due to non-retryable error", func() {
 			BeforeEach(func() {
 				transport.RoundTripReturns(nil, errors.New("error"))
@@ -184,6 +372,7 @@ var _ = Describe("ProxyRoundTripper", func() {
 				Expect(string(bodyBytes)).To(ContainSubstring(round_tripper.BadGatewayMessage))
 				Expect(alr.StatusCode).To(Equal(http.StatusBadGateway))
 				Expect(alr.RouteEndpoint).To(Equal(endpoint))
+				Expect(alr.RouterError).To(Equal("endpoint_failure"))
 			})
 
 			It("captures each routing request to the backend", func() {
@@ -226,6 +415,8 @@ var _ = Describe("ProxyRoundTripper", func() {
 				_, err := proxyRoundTripper.RoundTrip(req)
 				Expect(err).To(MatchError(dialError))
 				Expect(transport.RoundTripCallCount()).To(Equal(3))
+				Expect(alr.Attempts).To(Equal(3))
+				Expect(alr.AttemptedEndpoints).To(Equal([]*route.Endpoint{endpoint, endpoint, endpoint}))
 
 				Expect(resp.Code).To(Equal(http.StatusBadGateway))
 				Expect(resp.Header().Get(router_http.CfRouterError)).To(Equal("endpoint_failure"))
@@ -329,6 +520,47 @@ var _ = Describe("ProxyRoundTripper", func() {
 			})
 		})
 
+		Context("when the request body exceeds its limit", func() {
+			BeforeEach(func() {
+				req.Body = ioutil.NopCloser(strings.NewReader("0123456789abcdef"))
+				req.ContentLength = -1
+
+				limits := handlers.NewRequestLimits(0, 10, combinedReporter, logger)
+				limits.ServeHTTP(resp, req, func(rw http.ResponseWriter, r *http.Request) {
+					req = r
+				})
+
+				transport.RoundTripStub = func(r *http.Request) (*http.Response, error) {
+					_, err := ioutil.ReadAll(r.Body)
+					Expect(err).To(MatchError(handlers.ErrRequestBodyTooLarge))
+					return nil, connResetError
+				}
+			})
+
+			It("does not retry and returns status request entity too large", func() {
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).To(MatchError(connResetError))
+				Expect(transport.RoundTripCallCount()).To(Equal(1))
+
+				Expect(resp.Code).To(Equal(http.StatusRequestEntityTooLarge))
+				Expect(resp.Header().Get(router_http.CfRouterError)).To(Equal("request_body_too_large"))
+				bodyBytes, err := ioutil.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(bodyBytes)).To(ContainSubstring(round_tripper.BodyTooLargeMessage))
+
+				Expect(alr.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+				Expect(alr.RouterError).To(Equal("request_body_too_large"))
+			})
+
+			It("captures the rejection in the metrics reporter", func() {
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).To(HaveOccurred())
+
+				Expect(combinedReporter.CaptureOversizedRequestCallCount()).To(Equal(1))
+				Expect(combinedReporter.CaptureBadGatewayCallCount()).To(BeZero())
+			})
+		})
+
 		Context("when there are no more endpoints available", func() {
 			BeforeEach(func() {
 				removed := routePool.Remove(endpoint)
@@ -433,6 +665,420 @@ var _ = Describe("ProxyRoundTripper", func() {
 			})
 		})
 
+		Context("with a retry policy", func() {
+			var second *route.Endpoint
+
+			BeforeEach(func() {
+				second = route.NewEndpoint("appId", "2.2.2.2", uint16(9090), "id-2", "2",
+					map[string]string{}, 0, "", models.ModificationTag{})
+				routePool.Put(second)
+			})
+
+			Context("when the policy retries status codes", func() {
+				BeforeEach(func() {
+					retryPolicy.RetryOn = append(retryPolicy.RetryOn, "503")
+				})
+
+				It("retries on another endpoint and records the attempts", func() {
+					transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+						if transport.RoundTripCallCount() == 1 {
+							return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}, nil
+						}
+						return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil
+					}
+
+					res, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+					Expect(transport.RoundTripCallCount()).To(Equal(2))
+					Expect(alr.Attempts).To(Equal(2))
+					Expect(alr.AttemptedEndpoints).To(HaveLen(2))
+					Expect(alr.AttemptedEndpoints[0]).ToNot(Equal(alr.AttemptedEndpoints[1]))
+					Expect(logger.Buffer()).To(gbytes.Say(`backend-endpoint-retryable-status`))
+				})
+
+				It("returns the response of the last attempt", func() {
+					transport.RoundTripReturns(&http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}, nil)
+
+					res, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(res.StatusCode).To(Equal(http.StatusServiceUnavailable))
+					Expect(transport.RoundTripCallCount()).To(Equal(3))
+					Expect(alr.Attempts).To(Equal(3))
+				})
+
+				It("does not retry status codes that are not listed", func() {
+					transport.RoundTripReturns(&http.Response{StatusCode: http.StatusBadGateway, Header: http.Header{}}, nil)
+
+					res, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(res.StatusCode).To(Equal(http.StatusBadGateway))
+					Expect(transport.RoundTripCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when the policy only retries idempotent requests", func() {
+				BeforeEach(func() {
+					retryPolicy.RetryOn = append(retryPolicy.RetryOn, config.RETRY_ON_IDEMPOTENT_ONLY)
+				})
+
+				It("does not retry a reset POST request", func() {
+					transport.RoundTripReturns(nil, connResetError)
+					req.Method = "POST"
+
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(MatchError(connResetError))
+					Expect(transport.RoundTripCallCount()).To(Equal(1))
+				})
+
+				It("retries a reset GET request", func() {
+					transport.RoundTripReturns(nil, connResetError)
+
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(MatchError(connResetError))
+					Expect(transport.RoundTripCallCount()).To(Equal(3))
+				})
+
+				It("retries a POST request that failed to connect", func() {
+					transport.RoundTripReturns(nil, dialError)
+					req.Method = "POST"
+
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(MatchError(dialError))
+					Expect(transport.RoundTripCallCount()).To(Equal(3))
+				})
+			})
+
+			Context("when the route overrides the policy", func() {
+				BeforeEach(func() {
+					override := route.NewEndpoint("appId", "2.2.2.2", uint16(9090), "id-2", "2",
+						map[string]string{route.RetryMaxAttemptsTag: "1"}, 0, "", models.ModificationTag{})
+					routePool.Put(override)
+					transport.RoundTripReturns(nil, dialError)
+				})
+
+				It("uses the attempts of the route", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(MatchError(dialError))
+					Expect(transport.RoundTripCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when the retry budget is exhausted", func() {
+				BeforeEach(func() {
+					retryBudget = round_tripper.NewRetryBudget(50, 0)
+					transport.RoundTripReturns(nil, dialError)
+				})
+
+				It("stops retrying", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(MatchError(dialError))
+					Expect(transport.RoundTripCallCount()).To(Equal(1))
+					Expect(logger.Buffer()).To(gbytes.Say(`retry-budget-exhausted`))
+				})
+
+				It("allows retries within the budget", func() {
+					for i := 0; i < 3; i++ {
+						retryBudget.RecordRequest()
+					}
+
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(MatchError(dialError))
+					Expect(transport.RoundTripCallCount()).To(Equal(3))
+				})
+			})
+		})
+
+		Context("when the route hedges requests", func() {
+			var cancelled chan struct{}
+
+			BeforeEach(func() {
+				endpoint = route.NewEndpoint("appId", "1.1.1.1", uint16(9090), "id", "1",
+					map[string]string{route.HedgeDelayTag: "20ms"}, 0, "", models.ModificationTag{})
+				routePool.Put(endpoint)
+
+				hedged := route.NewEndpoint("appId", "2.2.2.2", uint16(9090), "id-2", "2",
+					map[string]string{route.HedgeDelayTag: "20ms"}, 0, "", models.ModificationTag{})
+				routePool.Put(hedged)
+
+				cancelled = make(chan struct{})
+				transport.CancelRequestStub = func(*http.Request) {
+					close(cancelled)
+				}
+			})
+
+			Context("when the first endpoint is slow to respond", func() {
+				BeforeEach(func() {
+					transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+						if transport.RoundTripCallCount() == 1 {
+							select {
+							case <-cancelled:
+								return nil, errors.New("request canceled")
+							case <-time.After(time.Second):
+								return &http.Response{StatusCode: http.StatusTeapot, Header: http.Header{}}, nil
+							}
+						}
+						return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil
+					}
+				})
+
+				It("returns the response of the second endpoint and cancels the first", func() {
+					res, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+					Expect(transport.RoundTripCallCount()).To(Equal(2))
+					Expect(transport.RoundTripArgsForCall(0).URL.Host).ToNot(Equal(transport.RoundTripArgsForCall(1).URL.Host))
+					Expect(transport.CancelRequestCallCount()).To(Equal(1))
+					Expect(transport.CancelRequestArgsForCall(0)).To(BeIdenticalTo(transport.RoundTripArgsForCall(0)))
+				})
+
+				It("cancels the context of the first attempt", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(transport.RoundTripArgsForCall(0).Context().Err()).To(Equal(context.Canceled))
+					Expect(transport.RoundTripArgsForCall(1).Context().Err()).ToNot(HaveOccurred())
+				})
+
+				It("records both attempts", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(alr.Attempts).To(Equal(2))
+					Expect(alr.AttemptedEndpoints).To(HaveLen(2))
+					Expect(alr.RouteEndpoint).To(Equal(alr.AttemptedEndpoints[1]))
+				})
+
+				It("captures the hedged request and its win", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(combinedReporter.CaptureHedgedRequestCallCount()).To(Equal(1))
+					Expect(combinedReporter.CaptureHedgeWonCallCount()).To(Equal(1))
+				})
+
+				It("releases the request slots of both endpoints", func() {
+					routePool.SetCircuitBreaker(route.CircuitBreaker{MaxConcurrentRequests: 1})
+
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					Eventually(func() bool {
+						ok := true
+						routePool.Each(func(e *route.Endpoint) {
+							if routePool.Acquire(e) {
+								routePool.Release(e)
+							} else {
+								ok = false
+							}
+						})
+						return ok
+					}).Should(BeTrue())
+				})
+			})
+
+			Context("when the first endpoint fails after the request was hedged", func() {
+				BeforeEach(func() {
+					transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+						if req.URL.Host == "1.1.1.1:9090" {
+							time.Sleep(50 * time.Millisecond)
+							return nil, dialError
+						}
+						time.Sleep(100 * time.Millisecond)
+						return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil
+					}
+				})
+
+				It("returns the response of the second endpoint and marks the first failed", func() {
+					res, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+					iter := routePool.Endpoints("", "")
+					for i := 0; i < 4; i++ {
+						Expect(iter.Next().CanonicalAddr()).To(Equal("2.2.2.2:9090"))
+					}
+				})
+			})
+
+			Context("when the first endpoint responds within the delay", func() {
+				BeforeEach(func() {
+					transport.RoundTripReturns(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil)
+				})
+
+				It("does not hedge the request", func() {
+					res, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+					Expect(transport.RoundTripCallCount()).To(Equal(1))
+					Expect(transport.CancelRequestCallCount()).To(Equal(0))
+					Expect(alr.Attempts).To(Equal(1))
+					Expect(combinedReporter.CaptureHedgedRequestCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the request is not idempotent", func() {
+				BeforeEach(func() {
+					req.Method = "POST"
+					transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+						time.Sleep(50 * time.Millisecond)
+						return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil
+					}
+				})
+
+				It("does not hedge the request", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(transport.RoundTripCallCount()).To(Equal(1))
+					Expect(combinedReporter.CaptureHedgedRequestCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when the endpoint speaks HTTP/2", func() {
+			BeforeEach(func() {
+				routePool.Remove(endpoint)
+				endpoint = route.NewEndpoint("appId", "1.1.1.1", uint16(9090), "id", "1",
+					map[string]string{route.BackendProtocolTag: route.BackendProtocolH2}, 0, "", models.ModificationTag{})
+				routePool.Put(endpoint)
+
+				transport.RoundTripReturns(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}, nil)
+			})
+
+			It("marks the request for the HTTP/2 transport", func() {
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+
+				sent := transport.RoundTripArgsForCall(0)
+				Expect(sent.ProtoMajor).To(Equal(2))
+				Expect(sent.URL.Scheme).To(Equal("https"))
+			})
+
+			It("keeps the request body open until the response is closed", func() {
+				body := &closeRecorder{Reader: strings.NewReader("stream")}
+				req.Body = body
+
+				res, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(body.closed).To(BeFalse())
+
+				res.Body.Close()
+				Expect(body.closed).To(BeTrue())
+			})
+		})
+
+		Context("when the endpoint is registered with a TLS port", func() {
+			var nameMismatch error
+
+			BeforeEach(func() {
+				routePool.Remove(endpoint)
+				endpoint = route.NewEndpoint("appId", "1.1.1.1", uint16(9443), "id", "1",
+					map[string]string{}, 0, "", models.ModificationTag{})
+				endpoint.ServerCertDomainSAN = "instance-1"
+				routePool.Put(endpoint)
+
+				nameMismatch = &tls.CertificateVerificationError{
+					Err: x509.HostnameError{
+						Certificate: &x509.Certificate{DNSNames: []string{"other-instance"}},
+						Host:        "instance-1",
+					},
+				}
+				tlsTransport.RoundTripReturns(&http.Response{StatusCode: http.StatusOK}, nil)
+			})
+
+			It("sends the request over TLS verified against the server name", func() {
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(transport.RoundTripCallCount()).To(Equal(0))
+				Expect(tlsServerNames).To(Equal([]string{"instance-1"}))
+
+				sent := tlsTransport.RoundTripArgsForCall(0)
+				Expect(sent.URL.Scheme).To(Equal("https"))
+				Expect(sent.URL.Host).To(Equal("1.1.1.1:9443"))
+			})
+
+			It("reuses the transport of the server name", func() {
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				_, err = proxyRoundTripper.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(tlsServerNames).To(HaveLen(1))
+				Expect(tlsTransport.RoundTripCallCount()).To(Equal(2))
+			})
+
+			Context("when the endpoint presents a certificate for another name", func() {
+				var other *route.Endpoint
+
+				BeforeEach(func() {
+					other = route.NewEndpoint("appId", "2.2.2.2", uint16(9443), "id-2", "2",
+						map[string]string{}, 0, "", models.ModificationTag{})
+					other.ServerCertDomainSAN = "instance-2"
+					routePool.Put(other)
+
+					tlsTransport.RoundTripStub = func(r *http.Request) (*http.Response, error) {
+						if r.URL.Host == "1.1.1.1:9443" {
+							return nil, nameMismatch
+						}
+						return &http.Response{StatusCode: http.StatusOK}, nil
+					}
+				})
+
+				It("unregisters the stale endpoint and retries on another endpoint", func() {
+					res, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+					Expect(registry.UnregisterCallCount()).To(Equal(1))
+					uri, stale := registry.UnregisterArgsForCall(0)
+					Expect(uri).To(Equal(route.Uri("myapp.com")))
+					Expect(stale).To(Equal(endpoint))
+					Expect(logger.Buffer()).To(gbytes.Say(`backend-endpoint-name-mismatch`))
+				})
+
+				It("retries even if the policy does not retry connect failures", func() {
+					retryPolicy.RetryOn = []string{}
+
+					res, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+				})
+			})
+
+			Context("when every endpoint presents a certificate for another name", func() {
+				BeforeEach(func() {
+					tlsTransport.RoundTripReturns(nil, nameMismatch)
+				})
+
+				It("responds with a service unavailable error", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(Equal(nameMismatch))
+
+					Expect(resp.Code).To(Equal(http.StatusServiceUnavailable))
+					Expect(resp.Header().Get(router_http.CfRouterError)).To(Equal("endpoint_name_mismatch"))
+					Expect(resp.Body).To(ContainSubstring(round_tripper.NameMismatchMessage))
+					Expect(alr.StatusCode).To(Equal(http.StatusServiceUnavailable))
+					Expect(alr.RouterError).To(Equal("endpoint_name_mismatch"))
+				})
+
+				It("does not capture a bad gateway", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(HaveOccurred())
+					Expect(combinedReporter.CaptureBadGatewayCallCount()).To(Equal(0))
+				})
+
+				It("removes the endpoint from the pool", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(HaveOccurred())
+					Expect(routePool.IsEmpty()).To(BeTrue())
+				})
+			})
+		})
+
 		Context("when the request succeeds", func() {
 			BeforeEach(func() {
 				transport.RoundTripReturns(
@@ -0,0 +1,94 @@
+package round_tripper
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// retryBudgetWindow is the period over which retries are compared to
+// requests.
+const retryBudgetWindow = 10 * time.Second
+
+// RetryBudget caps the retries of all routes at a percentage of the requests
+// proxied over the last retryBudgetWindow, so that retries cannot multiply
+// the load on backends that are failing. A nil budget allows every retry.
+type RetryBudget struct {
+	percent float64
+	// minRetries are the retries allowed over a window whatever the
+	// percentage, so that routes with little traffic can still retry.
+	minRetries float64
+
+	lock        sync.Mutex
+	windowStart time.Time
+	current     budgetWindow
+	previous    budgetWindow
+}
+
+type budgetWindow struct {
+	requests float64
+	retries  float64
+}
+
+func NewRetryBudget(percent float64, minRetries int) *RetryBudget {
+	return &RetryBudget{
+		percent:     percent,
+		minRetries:  float64(minRetries),
+		windowStart: time.Now(),
+	}
+}
+
+// RecordRequest counts a request towards the budget.
+func (b *RetryBudget) RecordRequest() {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.rotate(time.Now())
+	b.current.requests++
+}
+
+// AllowRetry returns true and counts the retry if it stays within the budget.
+func (b *RetryBudget) AllowRetry() bool {
+	if b == nil {
+		return true
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	b.rotate(now)
+
+	// the previous window counts in proportion to its overlap with the
+	// sliding window ending now
+	overlap := 1 - float64(now.Sub(b.windowStart))/float64(retryBudgetWindow)
+	requests := b.current.requests + b.previous.requests*overlap
+	retries := b.current.retries + b.previous.retries*overlap
+
+	if retries+1 > math.Max(requests*b.percent/100, b.minRetries) {
+		return false
+	}
+	b.current.retries++
+	return true
+}
+
+// rotate starts a new window once the current one is over.
+// budget lock must be held
+func (b *RetryBudget) rotate(now time.Time) {
+	elapsed := now.Sub(b.windowStart)
+	if elapsed < retryBudgetWindow {
+		return
+	}
+
+	if elapsed < 2*retryBudgetWindow {
+		b.previous = b.current
+	} else {
+		b.previous = budgetWindow{}
+	}
+	b.current = budgetWindow{}
+	b.windowStart = now.Add(-elapsed % retryBudgetWindow)
+}
@@ -0,0 +1,50 @@
+package round_tripper_test
+
+import (
+	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RetryBudget", func() {
+	It("allows retries up to the percentage of requests", func() {
+		budget := round_tripper.NewRetryBudget(20, 0)
+		for i := 0; i < 100; i++ {
+			budget.RecordRequest()
+		}
+
+		for i := 0; i < 20; i++ {
+			Expect(budget.AllowRetry()).To(BeTrue())
+		}
+		Expect(budget.AllowRetry()).To(BeFalse())
+
+		for i := 0; i < 5; i++ {
+			budget.RecordRequest()
+		}
+		Expect(budget.AllowRetry()).To(BeTrue())
+	})
+
+	It("does not retry the first failure of a window with few requests", func() {
+		budget := round_tripper.NewRetryBudget(10, 0)
+		budget.RecordRequest()
+
+		Expect(budget.AllowRetry()).To(BeFalse())
+	})
+
+	It("allows the minimum of retries while there are few requests", func() {
+		budget := round_tripper.NewRetryBudget(20, 10)
+		budget.RecordRequest()
+
+		for i := 0; i < 10; i++ {
+			Expect(budget.AllowRetry()).To(BeTrue())
+		}
+		Expect(budget.AllowRetry()).To(BeFalse())
+	})
+
+	It("allows every retry without a budget", func() {
+		var budget *round_tripper.RetryBudget
+		budget.RecordRequest()
+		Expect(budget.AllowRetry()).To(BeTrue())
+	})
+})
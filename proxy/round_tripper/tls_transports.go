@@ -0,0 +1,71 @@
+package round_tripper
+
+import (
+	"sync"
+	"time"
+)
+
+// tlsTransportIdleTimeout is the time after which the transport of a server
+// name no endpoint has been sent a request for is dropped.
+const tlsTransportIdleTimeout = 5 * time.Minute
+
+// TLSTransports holds the transports to endpoints registered with a TLS port,
+// one per server certificate name. Connections are pooled per transport, so a
+// connection verified for one name is never reused for another endpoint that
+// happens to share its address.
+type TLSTransports struct {
+	newTransport func(serverName string) ProxyRoundTripper
+
+	lock       sync.Mutex
+	transports map[string]*tlsTransport
+	lastSweep  time.Time
+}
+
+type tlsTransport struct {
+	ProxyRoundTripper
+	lastUsed time.Time
+}
+
+// NewTLSTransports returns transports created by newTransport, which verifies
+// the certificates of endpoints against serverName.
+func NewTLSTransports(newTransport func(serverName string) ProxyRoundTripper) *TLSTransports {
+	return &TLSTransports{
+		newTransport: newTransport,
+		transports:   make(map[string]*tlsTransport),
+		lastSweep:    time.Now(),
+	}
+}
+
+// Get returns the transport for endpoints verified against serverName.
+func (t *TLSTransports) Get(serverName string) ProxyRoundTripper {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(t.lastSweep) > tlsTransportIdleTimeout {
+		t.sweep(now)
+	}
+
+	transport, ok := t.transports[serverName]
+	if !ok {
+		transport = &tlsTransport{ProxyRoundTripper: t.newTransport(serverName)}
+		t.transports[serverName] = transport
+	}
+	transport.lastUsed = now
+	return transport.ProxyRoundTripper
+}
+
+// sweep drops the transports of names no request has been sent to recently,
+// such as those of instances that have stopped, and closes their idle
+// connections. Requests in flight keep the transport they were sent with;
+// their connections close once idle for the idle timeout of the transport.
+// transports lock must be held
+func (t *TLSTransports) sweep(now time.Time) {
+	for name, transport := range t.transports {
+		if now.Sub(transport.lastUsed) > tlsTransportIdleTimeout {
+			delete(t.transports, name)
+			closeIdleConnections(transport.ProxyRoundTripper)
+		}
+	}
+	t.lastSweep = now
+}
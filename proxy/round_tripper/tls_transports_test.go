@@ -0,0 +1,38 @@
+package round_tripper_test
+
+import (
+	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
+	roundtripperfakes "code.cloudfoundry.org/gorouter/proxy/round_tripper/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TLSTransports", func() {
+	var (
+		transports *round_tripper.TLSTransports
+		created    []string
+	)
+
+	BeforeEach(func() {
+		created = nil
+		transports = round_tripper.NewTLSTransports(func(serverName string) round_tripper.ProxyRoundTripper {
+			created = append(created, serverName)
+			return new(roundtripperfakes.FakeProxyRoundTripper)
+		})
+	})
+
+	It("creates a transport per server name", func() {
+		first := transports.Get("instance-1")
+		second := transports.Get("instance-2")
+
+		Expect(created).To(Equal([]string{"instance-1", "instance-2"}))
+		Expect(first).NotTo(BeIdenticalTo(second))
+	})
+
+	It("reuses the transport of a server name", func() {
+		first := transports.Get("instance-1")
+		Expect(transports.Get("instance-1")).To(BeIdenticalTo(first))
+		Expect(created).To(HaveLen(1))
+	})
+})
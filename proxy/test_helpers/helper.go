@@ -16,9 +16,17 @@ func (_ NullVarz) MarshalJSON() ([]byte, error)            { return json.Marshal
 func (_ NullVarz) ActiveApps() *stats.ActiveApps           { return stats.NewActiveApps() }
 func (_ NullVarz) CaptureBadRequest()                      {}
 func (_ NullVarz) CaptureBadGateway()                      {}
+func (_ NullVarz) CaptureOverloadedRequest()               {}
+func (_ NullVarz) CaptureRateLimitedRequest()              {}
+func (_ NullVarz) CaptureOversizedRequest()                {}
+func (_ NullVarz) CaptureConnectionTimeout(string)         {}
+func (_ NullVarz) CaptureRejectedConnection()              {}
 func (_ NullVarz) CaptureRoutingRequest(b *route.Endpoint) {}
 func (_ NullVarz) CaptureRoutingResponse(int)              {}
 func (_ NullVarz) CaptureRoutingResponseLatency(*route.Endpoint, int, time.Time, time.Duration) {
 }
 func (_ NullVarz) CaptureRouteServiceResponse(*http.Response)         {}
+func (_ NullVarz) CaptureHedgedRequest()                              {}
+func (_ NullVarz) CaptureHedgeWon()                                   {}
+func (_ NullVarz) CaptureCacheResponse(string)                        {}
 func (_ NullVarz) CaptureRegistryMessage(msg metrics.ComponentTagged) {}
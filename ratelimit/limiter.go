@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter holds a token bucket per key. At most maxKeys buckets are kept:
+// adding a bucket beyond that drops the bucket of the key seen least
+// recently, which starts full again when the key comes back.
+type Limiter struct {
+	lock    sync.Mutex
+	maxKeys int
+	order   *list.List
+	buckets map[string]*list.Element
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates a limiter keeping at most maxKeys buckets.
+func NewLimiter(maxKeys int) *Limiter {
+	return &Limiter{
+		maxKeys: maxKeys,
+		order:   list.New(),
+		buckets: map[string]*list.Element{},
+	}
+}
+
+// Allow takes a token from the bucket of key, which holds up to burst tokens
+// and refills at rate tokens per second. When the bucket is empty it returns
+// false and how long until a token is available. A burst below one allows
+// the rate rounded up.
+func (l *Limiter) Allow(key string, rate float64, burst int, now time.Time) (bool, time.Duration) {
+	capacity := float64(burst)
+	if burst < 1 {
+		capacity = math.Ceil(rate)
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	b := l.bucket(key, capacity, now)
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed.Seconds()*rate)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// Len returns the number of buckets kept.
+func (l *Limiter) Len() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return len(l.buckets)
+}
+
+func (l *Limiter) bucket(key string, capacity float64, now time.Time) *bucket {
+	if element, ok := l.buckets[key]; ok {
+		l.order.MoveToFront(element)
+		return element.Value.(*bucket)
+	}
+
+	for len(l.buckets) >= l.maxKeys && l.order.Len() > 0 {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.buckets, oldest.Value.(*bucket).key)
+	}
+
+	b := &bucket{key: key, tokens: capacity, last: now}
+	l.buckets[key] = l.order.PushFront(b)
+	return b
+}
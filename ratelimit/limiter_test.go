@@ -0,0 +1,79 @@
+package ratelimit_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/ratelimit"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limiter", func() {
+	var (
+		limiter *ratelimit.Limiter
+		now     time.Time
+	)
+
+	BeforeEach(func() {
+		limiter = ratelimit.NewLimiter(3)
+		now = time.Now()
+	})
+
+	It("allows a burst of requests and then the rate", func() {
+		for i := 0; i < 5; i++ {
+			allowed, _ := limiter.Allow("key", 2, 5, now)
+			Expect(allowed).To(BeTrue())
+		}
+
+		allowed, wait := limiter.Allow("key", 2, 5, now)
+		Expect(allowed).To(BeFalse())
+		Expect(wait).To(Equal(500 * time.Millisecond))
+
+		allowed, _ = limiter.Allow("key", 2, 5, now.Add(500*time.Millisecond))
+		Expect(allowed).To(BeTrue())
+		allowed, _ = limiter.Allow("key", 2, 5, now.Add(500*time.Millisecond))
+		Expect(allowed).To(BeFalse())
+	})
+
+	It("refills buckets up to the burst", func() {
+		limiter.Allow("key", 1, 2, now)
+		limiter.Allow("key", 1, 2, now)
+
+		later := now.Add(time.Hour)
+		Expect(limiter.Allow("key", 1, 2, later)).To(BeTrue())
+		Expect(limiter.Allow("key", 1, 2, later)).To(BeTrue())
+		allowed, _ := limiter.Allow("key", 1, 2, later)
+		Expect(allowed).To(BeFalse())
+	})
+
+	It("allows the rate rounded up without a burst", func() {
+		Expect(limiter.Allow("key", 1.5, 0, now)).To(BeTrue())
+		Expect(limiter.Allow("key", 1.5, 0, now)).To(BeTrue())
+		allowed, _ := limiter.Allow("key", 1.5, 0, now)
+		Expect(allowed).To(BeFalse())
+	})
+
+	It("keeps a bucket per key", func() {
+		limiter.Allow("a", 1, 1, now)
+		allowed, _ := limiter.Allow("a", 1, 1, now)
+		Expect(allowed).To(BeFalse())
+
+		allowed, _ = limiter.Allow("b", 1, 1, now)
+		Expect(allowed).To(BeTrue())
+	})
+
+	It("drops the buckets of the least recently seen keys", func() {
+		limiter.Allow("a", 1, 1, now)
+		limiter.Allow("b", 1, 1, now)
+		limiter.Allow("c", 1, 1, now)
+		limiter.Allow("a", 1, 1, now)
+		limiter.Allow("d", 1, 1, now)
+		Expect(limiter.Len()).To(Equal(3))
+
+		// the bucket of b was dropped and starts full again
+		allowed, _ := limiter.Allow("b", 1, 1, now)
+		Expect(allowed).To(BeTrue())
+		allowed, _ = limiter.Allow("a", 1, 1, now)
+		Expect(allowed).To(BeFalse())
+	})
+})
@@ -12,10 +12,41 @@ type Trie struct {
 	Pool       *route.Pool
 	ChildNodes map[string]*Trie
 	Parent     *Trie
+
+	// ConditionalPools hold endpoints registered with a match rule, in the
+	// order they were inserted. They take precedence over Pool for requests
+	// satisfying their rule.
+	ConditionalPools []*route.Pool
 }
 
 // Find returns a *route.Pool that matches exactly the URI parameter, nil if no match was found.
 func (r *Trie) Find(uri route.Uri) *route.Pool {
+	node := r.findNode(uri)
+	if node == nil {
+		return nil
+	}
+
+	return node.Pool
+}
+
+// FindConditional returns the conditional *route.Pool for the rule on the
+// exact URI parameter, nil if no match was found.
+func (r *Trie) FindConditional(uri route.Uri, rule route.MatchRule) *route.Pool {
+	node := r.findNode(uri)
+	if node == nil {
+		return nil
+	}
+
+	for _, pool := range node.ConditionalPools {
+		if *pool.MatchRule() == rule {
+			return pool
+		}
+	}
+
+	return nil
+}
+
+func (r *Trie) findNode(uri route.Uri) *Trie {
 	key := strings.TrimPrefix(uri.String(), "/")
 	node := r
 
@@ -37,15 +68,20 @@ func (r *Trie) Find(uri route.Uri) *route.Pool {
 		key = pathParts[1]
 	}
 
-	if nil != node.Pool {
-		return node.Pool
-	}
-
-	return nil
+	return node
 }
 
 // MatchUri returns the longest route that matches the URI parameter, nil if nothing matches.
 func (r *Trie) MatchUri(uri route.Uri) *route.Pool {
+	return r.MatchUriConditional(uri, nil)
+}
+
+// MatchUriConditional returns the longest route that matches the URI
+// parameter, nil if nothing matches. On each node, conditional pools whose
+// rule is accepted by matches are preferred over the unconditional pool. The
+// longest route is nil when it only holds conditional pools none of which
+// match.
+func (r *Trie) MatchUriConditional(uri route.Uri, matches func(route.MatchRule) bool) *route.Pool {
 	key := strings.TrimPrefix(uri.String(), "/")
 	node := r
 	var lastPool *route.Pool
@@ -61,8 +97,11 @@ func (r *Trie) MatchUri(uri route.Uri) *route.Pool {
 
 		node = matchingChild
 
-		if nil != node.Pool {
-			lastPool = node.Pool
+		// a route holding only conditional pools still ends the match when
+		// none of its rules is satisfied, so that such requests are not sent
+		// to the route of a shorter prefix
+		if pool := node.matchPool(matches); nil != pool || len(node.ConditionalPools) > 0 {
+			lastPool = pool
 		}
 
 		if len(pathParts) <= 1 {
@@ -72,15 +111,19 @@ func (r *Trie) MatchUri(uri route.Uri) *route.Pool {
 		key = pathParts[1]
 	}
 
-	if nil != node.Pool {
-		return node.Pool
-	}
+	return lastPool
+}
 
-	if nil != lastPool {
-		return lastPool
+func (r *Trie) matchPool(matches func(route.MatchRule) bool) *route.Pool {
+	if matches != nil {
+		for _, pool := range r.ConditionalPools {
+			if matches(*pool.MatchRule()) {
+				return pool
+			}
+		}
 	}
 
-	return nil
+	return r.Pool
 }
 
 func (r *Trie) Insert(uri route.Uri, value *route.Pool) *Trie {
@@ -109,7 +152,11 @@ func (r *Trie) Insert(uri route.Uri, value *route.Pool) *Trie {
 		key = pathParts[1]
 	}
 
-	node.Pool = value
+	if value.MatchRule() != nil {
+		node.ConditionalPools = append(node.ConditionalPools, value)
+	} else {
+		node.Pool = value
+	}
 	return node
 }
 
@@ -140,6 +187,25 @@ func (r *Trie) Delete(uri route.Uri) bool {
 	return true
 }
 
+// DeleteConditional removes the conditional pool for the rule from the
+// node matching the URI parameter.
+func (r *Trie) DeleteConditional(uri route.Uri, rule route.MatchRule) bool {
+	node := r.findNode(uri)
+	if node == nil {
+		return false
+	}
+
+	for i, pool := range node.ConditionalPools {
+		if *pool.MatchRule() == rule {
+			node.ConditionalPools = append(node.ConditionalPools[:i], node.ConditionalPools[i+1:]...)
+			r.deleteEmptyNodes(strings.TrimPrefix(uri.String(), "/"))
+			return true
+		}
+	}
+
+	return false
+}
+
 func (r *Trie) deleteEmptyNodes(key string) {
 	node := r
 	nodeToKeep := r
@@ -151,9 +217,9 @@ func (r *Trie) deleteEmptyNodes(key string) {
 
 		matchingChild, _ := node.ChildNodes[SegmentValue]
 
-		if nil == nodeToRemove && nil == matchingChild.Pool && len(matchingChild.ChildNodes) < 2 {
+		if nil == nodeToRemove && !matchingChild.hasPools() && len(matchingChild.ChildNodes) < 2 {
 			nodeToRemove = matchingChild
-		} else if nil != matchingChild.Pool || len(matchingChild.ChildNodes) > 1 {
+		} else if matchingChild.hasPools() || len(matchingChild.ChildNodes) > 1 {
 			nodeToKeep = matchingChild
 			nodeToRemove = nil
 		}
@@ -167,7 +233,7 @@ func (r *Trie) deleteEmptyNodes(key string) {
 		key = pathParts[1]
 	}
 
-	if node.isLeaf() {
+	if node.isLeaf() && !node.hasPools() {
 		nodeToRemove.Parent = nil
 		delete(nodeToKeep.ChildNodes, nodeToRemove.Segment)
 	}
@@ -185,8 +251,10 @@ func (r *Trie) PoolCount() int {
 	return result
 }
 
+// EachNodeWithPool calls f for every node holding an unconditional or a
+// conditional pool.
 func (r *Trie) EachNodeWithPool(f func(*Trie)) {
-	if r.Pool != nil {
+	if r.hasPools() {
 		f(r)
 	}
 
@@ -203,11 +271,11 @@ func (r *Trie) EndpointCount() int {
 
 func (r *Trie) endpointCount(m map[string]struct{}) map[string]struct{} {
 
-	if r.Pool != nil {
-		f := func(e *route.Endpoint) {
-			m[e.CanonicalAddr()] = struct{}{}
-		}
-		r.Pool.Each(f)
+	f := func(e *route.Endpoint) {
+		m[e.CanonicalAddr()] = struct{}{}
+	}
+	for _, pool := range r.Pools() {
+		pool.Each(f)
 	}
 
 	for _, child := range r.ChildNodes {
@@ -235,7 +303,16 @@ func (r *Trie) Snip() {
 	if r.Pool != nil && r.Pool.IsEmpty() {
 		r.Pool = nil
 	}
-	if (r.Pool != nil && !r.Pool.IsEmpty()) || r.isRoot() || !r.isLeaf() {
+
+	pools := r.ConditionalPools[:0]
+	for _, pool := range r.ConditionalPools {
+		if !pool.IsEmpty() {
+			pools = append(pools, pool)
+		}
+	}
+	r.ConditionalPools = pools
+
+	if r.hasPools() || r.isRoot() || !r.isLeaf() {
 		return
 	}
 	delete(r.Parent.ChildNodes, r.Segment)
@@ -271,6 +348,20 @@ func (r *Trie) toMap(segment string, m map[route.Uri]*route.Pool) map[route.Uri]
 	return m
 }
 
+// Pools returns the unconditional pool, if any, followed by the conditional
+// pools of the node.
+func (r *Trie) Pools() []*route.Pool {
+	pools := make([]*route.Pool, 0, len(r.ConditionalPools)+1)
+	if r.Pool != nil {
+		pools = append(pools, r.Pool)
+	}
+	return append(pools, r.ConditionalPools...)
+}
+
+func (r *Trie) hasPools() bool {
+	return r.Pool != nil || len(r.ConditionalPools) > 0
+}
+
 func (r *Trie) isRoot() bool {
 	return r.Parent == nil
 }
@@ -99,6 +99,68 @@ var _ = Describe("Trie", func() {
 		})
 	})
 
+	Describe(".MatchUriConditional", func() {
+		var (
+			p, canary *route.Pool
+			rule      route.MatchRule
+		)
+
+		BeforeEach(func() {
+			rule = route.MatchRule{Header: "X-Canary", Value: "true"}
+			p = route.NewPool(42, "")
+			canary = route.NewConditionalPool(42, "", rule)
+			r.Insert("/foo", p)
+			r.Insert("/foo", canary)
+		})
+
+		It("keeps conditional pools apart from the unconditional pool", func() {
+			Expect(r.Find("/foo")).To(Equal(p))
+			Expect(r.FindConditional("/foo", rule)).To(Equal(canary))
+			Expect(r.MatchUri("/foo")).To(Equal(p))
+		})
+
+		It("prefers a conditional pool whose rule matches", func() {
+			matchAll := func(route.MatchRule) bool { return true }
+			matchNone := func(route.MatchRule) bool { return false }
+
+			Expect(r.MatchUriConditional("/foo/bar", matchAll)).To(Equal(canary))
+			Expect(r.MatchUriConditional("/foo/bar", matchNone)).To(Equal(p))
+		})
+
+		It("prefers a longer unconditional route over a shorter conditional one", func() {
+			deeper := route.NewPool(42, "")
+			r.Insert("/foo/bar", deeper)
+
+			matchAll := func(route.MatchRule) bool { return true }
+			Expect(r.MatchUriConditional("/foo/bar", matchAll)).To(Equal(deeper))
+		})
+
+		It("does not fall back to a shorter route from a route of only conditional pools", func() {
+			conditional := route.NewConditionalPool(42, "", rule)
+			r.Insert("/foo/bar", conditional)
+
+			matchAll := func(route.MatchRule) bool { return true }
+			matchNone := func(route.MatchRule) bool { return false }
+
+			Expect(r.MatchUriConditional("/foo/bar/baz", matchAll)).To(Equal(conditional))
+			Expect(r.MatchUriConditional("/foo/bar/baz", matchNone)).To(BeNil())
+			Expect(r.MatchUriConditional("/foo/other", matchNone)).To(Equal(p))
+		})
+
+		It("deletes a conditional pool without touching the unconditional pool", func() {
+			Expect(r.DeleteConditional("/foo", rule)).To(BeTrue())
+			Expect(r.FindConditional("/foo", rule)).To(BeNil())
+			Expect(r.Find("/foo")).To(Equal(p))
+		})
+
+		It("keeps a node holding only conditional pools", func() {
+			r.Delete("/foo")
+			Expect(r.Find("/foo")).To(BeNil())
+			Expect(r.FindConditional("/foo", rule)).To(Equal(canary))
+			Expect(r.PoolCount()).To(Equal(1))
+		})
+	})
+
 	Describe(".Insert", func() {
 		It("adds a non-existing key", func() {
 			p := route.NewPool(0, "")
@@ -29,6 +29,15 @@ type FakeRegistry struct {
 	lookupReturns struct {
 		result1 *route.Pool
 	}
+	LookupMatchingStub        func(uri route.Uri, matches func(route.MatchRule) bool) *route.Pool
+	lookupMatchingMutex       sync.RWMutex
+	lookupMatchingArgsForCall []struct {
+		uri     route.Uri
+		matches func(route.MatchRule) bool
+	}
+	lookupMatchingReturns struct {
+		result1 *route.Pool
+	}
 	LookupWithInstanceStub        func(uri route.Uri, appId, appIndex string) *route.Pool
 	lookupWithInstanceMutex       sync.RWMutex
 	lookupWithInstanceArgsForCall []struct {
@@ -57,6 +66,18 @@ type FakeRegistry struct {
 	numEndpointsReturns     struct {
 		result1 int
 	}
+	HealthCheckTargetsStub        func() map[string]route.HealthCheckTarget
+	healthCheckTargetsMutex       sync.RWMutex
+	healthCheckTargetsArgsForCall []struct{}
+	healthCheckTargetsReturns     struct {
+		result1 map[string]route.HealthCheckTarget
+	}
+	SetEndpointHealthStub        func(addr string, healthy bool)
+	setEndpointHealthMutex       sync.RWMutex
+	setEndpointHealthArgsForCall []struct {
+		addr    string
+		healthy bool
+	}
 	MarshalJSONStub        func() ([]byte, error)
 	marshalJSONMutex       sync.RWMutex
 	marshalJSONArgsForCall []struct{}
@@ -150,6 +171,39 @@ func (fake *FakeRegistry) LookupReturns(result1 *route.Pool) {
 	}{result1}
 }
 
+func (fake *FakeRegistry) LookupMatching(uri route.Uri, matches func(route.MatchRule) bool) *route.Pool {
+	fake.lookupMatchingMutex.Lock()
+	fake.lookupMatchingArgsForCall = append(fake.lookupMatchingArgsForCall, struct {
+		uri     route.Uri
+		matches func(route.MatchRule) bool
+	}{uri, matches})
+	fake.recordInvocation("LookupMatching", []interface{}{uri, matches})
+	fake.lookupMatchingMutex.Unlock()
+	if fake.LookupMatchingStub != nil {
+		return fake.LookupMatchingStub(uri, matches)
+	}
+	return fake.lookupMatchingReturns.result1
+}
+
+func (fake *FakeRegistry) LookupMatchingCallCount() int {
+	fake.lookupMatchingMutex.RLock()
+	defer fake.lookupMatchingMutex.RUnlock()
+	return len(fake.lookupMatchingArgsForCall)
+}
+
+func (fake *FakeRegistry) LookupMatchingArgsForCall(i int) (route.Uri, func(route.MatchRule) bool) {
+	fake.lookupMatchingMutex.RLock()
+	defer fake.lookupMatchingMutex.RUnlock()
+	return fake.lookupMatchingArgsForCall[i].uri, fake.lookupMatchingArgsForCall[i].matches
+}
+
+func (fake *FakeRegistry) LookupMatchingReturns(result1 *route.Pool) {
+	fake.LookupMatchingStub = nil
+	fake.lookupMatchingReturns = struct {
+		result1 *route.Pool
+	}{result1}
+}
+
 func (fake *FakeRegistry) LookupWithInstance(uri route.Uri, appId string, appIndex string) *route.Pool {
 	fake.lookupWithInstanceMutex.Lock()
 	fake.lookupWithInstanceArgsForCall = append(fake.lookupWithInstanceArgsForCall, struct {
@@ -264,6 +318,55 @@ func (fake *FakeRegistry) NumEndpointsReturns(result1 int) {
 	}{result1}
 }
 
+func (fake *FakeRegistry) HealthCheckTargets() map[string]route.HealthCheckTarget {
+	fake.healthCheckTargetsMutex.Lock()
+	fake.healthCheckTargetsArgsForCall = append(fake.healthCheckTargetsArgsForCall, struct{}{})
+	fake.recordInvocation("HealthCheckTargets", []interface{}{})
+	fake.healthCheckTargetsMutex.Unlock()
+	if fake.HealthCheckTargetsStub != nil {
+		return fake.HealthCheckTargetsStub()
+	}
+	return fake.healthCheckTargetsReturns.result1
+}
+
+func (fake *FakeRegistry) HealthCheckTargetsCallCount() int {
+	fake.healthCheckTargetsMutex.RLock()
+	defer fake.healthCheckTargetsMutex.RUnlock()
+	return len(fake.healthCheckTargetsArgsForCall)
+}
+
+func (fake *FakeRegistry) HealthCheckTargetsReturns(result1 map[string]route.HealthCheckTarget) {
+	fake.HealthCheckTargetsStub = nil
+	fake.healthCheckTargetsReturns = struct {
+		result1 map[string]route.HealthCheckTarget
+	}{result1}
+}
+
+func (fake *FakeRegistry) SetEndpointHealth(addr string, healthy bool) {
+	fake.setEndpointHealthMutex.Lock()
+	fake.setEndpointHealthArgsForCall = append(fake.setEndpointHealthArgsForCall, struct {
+		addr    string
+		healthy bool
+	}{addr, healthy})
+	fake.recordInvocation("SetEndpointHealth", []interface{}{addr, healthy})
+	fake.setEndpointHealthMutex.Unlock()
+	if fake.SetEndpointHealthStub != nil {
+		fake.SetEndpointHealthStub(addr, healthy)
+	}
+}
+
+func (fake *FakeRegistry) SetEndpointHealthCallCount() int {
+	fake.setEndpointHealthMutex.RLock()
+	defer fake.setEndpointHealthMutex.RUnlock()
+	return len(fake.setEndpointHealthArgsForCall)
+}
+
+func (fake *FakeRegistry) SetEndpointHealthArgsForCall(i int) (string, bool) {
+	fake.setEndpointHealthMutex.RLock()
+	defer fake.setEndpointHealthMutex.RUnlock()
+	return fake.setEndpointHealthArgsForCall[i].addr, fake.setEndpointHealthArgsForCall[i].healthy
+}
+
 func (fake *FakeRegistry) MarshalJSON() ([]byte, error) {
 	fake.marshalJSONMutex.Lock()
 	fake.marshalJSONArgsForCall = append(fake.marshalJSONArgsForCall, struct{}{})
@@ -298,6 +401,8 @@ func (fake *FakeRegistry) Invocations() map[string][][]interface{} {
 	defer fake.unregisterMutex.RUnlock()
 	fake.lookupMutex.RLock()
 	defer fake.lookupMutex.RUnlock()
+	fake.lookupMatchingMutex.RLock()
+	defer fake.lookupMatchingMutex.RUnlock()
 	fake.lookupWithInstanceMutex.RLock()
 	defer fake.lookupWithInstanceMutex.RUnlock()
 	fake.startPruningCycleMutex.RLock()
@@ -308,6 +413,10 @@ func (fake *FakeRegistry) Invocations() map[string][][]interface{} {
 	defer fake.numUrisMutex.RUnlock()
 	fake.numEndpointsMutex.RLock()
 	defer fake.numEndpointsMutex.RUnlock()
+	fake.healthCheckTargetsMutex.RLock()
+	defer fake.healthCheckTargetsMutex.RUnlock()
+	fake.setEndpointHealthMutex.RLock()
+	defer fake.setEndpointHealthMutex.RUnlock()
 	fake.marshalJSONMutex.RLock()
 	defer fake.marshalJSONMutex.RUnlock()
 	return fake.invocations
@@ -20,11 +20,14 @@ type Registry interface {
 	Register(uri route.Uri, endpoint *route.Endpoint)
 	Unregister(uri route.Uri, endpoint *route.Endpoint)
 	Lookup(uri route.Uri) *route.Pool
+	LookupMatching(uri route.Uri, matches func(route.MatchRule) bool) *route.Pool
 	LookupWithInstance(uri route.Uri, appId, appIndex string) *route.Pool
 	StartPruningCycle()
 	StopPruningCycle()
 	NumUris() int
 	NumEndpoints() int
+	HealthCheckTargets() map[string]route.HealthCheckTarget
+	SetEndpointHealth(addr string, healthy bool)
 	MarshalJSON() ([]byte, error)
 }
 
@@ -50,6 +53,14 @@ type RouteRegistry struct {
 	pruneStaleDropletsInterval time.Duration
 	dropletStaleThreshold      time.Duration
 
+	zone                   string
+	zoneSpilloverThreshold float64
+	slowStartDuration      time.Duration
+	outlierDetection       route.OutlierDetection
+	circuitBreaker         route.CircuitBreaker
+	// health is nil unless active health checking is enabled
+	health *route.HealthTable
+
 	reporter metrics.RouteRegistryReporter
 
 	ticker           *time.Ticker
@@ -63,9 +74,27 @@ func NewRouteRegistry(logger logger.Logger, c *config.Config, reporter metrics.R
 
 	r.pruneStaleDropletsInterval = c.PruneStaleDropletsInterval
 	r.dropletStaleThreshold = c.DropletStaleThreshold
+	r.zone = c.Zone
+	r.zoneSpilloverThreshold = c.ZoneSpilloverThreshold
+	r.slowStartDuration = c.SlowStartDuration
 	r.suspendPruning = func() bool { return false }
 
 	r.reporter = reporter
+	r.outlierDetection = route.OutlierDetection{
+		ConsecutiveErrors:  c.OutlierDetection.ConsecutiveErrors,
+		BaseEjectionTime:   c.OutlierDetection.BaseEjectionTime,
+		MaxEjectionTime:    c.OutlierDetection.MaxEjectionTime,
+		MaxEjectionPercent: c.OutlierDetection.MaxEjectionPercent,
+		Listener:           &outlierListener{logger: logger, reporter: reporter},
+	}
+	if c.ActiveHealthCheck.Enabled {
+		r.health = route.NewHealthTable()
+	}
+	r.circuitBreaker = route.CircuitBreaker{
+		MaxConcurrentRequests: c.CircuitBreaker.MaxConcurrentRequests,
+		MaxPendingRequests:    c.CircuitBreaker.MaxPendingRequests,
+		PendingTimeout:        c.CircuitBreaker.PendingTimeout,
+	}
 	return r
 }
 
@@ -76,10 +105,22 @@ func (r *RouteRegistry) Register(uri route.Uri, endpoint *route.Endpoint) {
 
 	routekey := uri.RouteKey()
 
-	pool := r.byUri.Find(routekey)
+	pool := r.findPool(routekey, endpoint.MatchRule)
 	if pool == nil {
 		contextPath := parseContextPath(uri)
-		pool = route.NewPool(r.dropletStaleThreshold/4, contextPath)
+		if endpoint.MatchRule != nil {
+			pool = route.NewConditionalPool(r.dropletStaleThreshold/4, contextPath, *endpoint.MatchRule)
+		} else {
+			pool = route.NewPool(r.dropletStaleThreshold/4, contextPath)
+		}
+		pool.SetRouteKey(routekey)
+		pool.PreferZone(r.zone, r.zoneSpilloverThreshold)
+		pool.SetSlowStart(r.slowStartDuration)
+		pool.SetOutlierDetection(r.outlierDetection)
+		if r.health != nil {
+			pool.SetHealthTable(r.health)
+		}
+		pool.SetCircuitBreaker(r.circuitBreaker)
 		r.byUri.Insert(routekey, pool)
 		r.logger.Debug("uri-added", zap.Stringer("uri", routekey))
 	}
@@ -115,7 +156,7 @@ func (r *RouteRegistry) Unregister(uri route.Uri, endpoint *route.Endpoint) {
 
 	uri = uri.RouteKey()
 
-	pool := r.byUri.Find(uri)
+	pool := r.findPool(uri, endpoint.MatchRule)
 	if pool != nil {
 		endpointRemoved := pool.Remove(endpoint)
 		if endpointRemoved {
@@ -125,7 +166,11 @@ func (r *RouteRegistry) Unregister(uri route.Uri, endpoint *route.Endpoint) {
 		}
 
 		if pool.IsEmpty() {
-			r.byUri.Delete(uri)
+			if endpoint.MatchRule != nil {
+				r.byUri.DeleteConditional(uri, *endpoint.MatchRule)
+			} else {
+				r.byUri.Delete(uri)
+			}
 		}
 	}
 
@@ -133,17 +178,31 @@ func (r *RouteRegistry) Unregister(uri route.Uri, endpoint *route.Endpoint) {
 	r.reporter.CaptureUnregistryMessage(endpoint)
 }
 
+// findPool must be called with the registry lock held
+func (r *RouteRegistry) findPool(uri route.Uri, rule *route.MatchRule) *route.Pool {
+	if rule != nil {
+		return r.byUri.FindConditional(uri, *rule)
+	}
+	return r.byUri.Find(uri)
+}
+
 func (r *RouteRegistry) Lookup(uri route.Uri) *route.Pool {
+	return r.LookupMatching(uri, nil)
+}
+
+// LookupMatching returns the pool for the longest route matching the URI,
+// preferring conditional pools whose rule is accepted by matches.
+func (r *RouteRegistry) LookupMatching(uri route.Uri, matches func(route.MatchRule) bool) *route.Pool {
 	started := time.Now()
 
 	r.RLock()
 
 	uri = uri.RouteKey()
 	var err error
-	pool := r.byUri.MatchUri(uri)
+	pool := r.byUri.MatchUriConditional(uri, matches)
 	for pool == nil && err == nil {
 		uri, err = uri.NextWildcard()
-		pool = r.byUri.MatchUri(uri)
+		pool = r.byUri.MatchUriConditional(uri, matches)
 	}
 
 	r.RUnlock()
@@ -220,11 +279,58 @@ func (r *RouteRegistry) NumEndpoints() int {
 	return count
 }
 
+// HealthCheckTargets returns the health check target of every endpoint
+// registered with a health check path, by endpoint address. Results of endpoints that are no
+// longer registered are forgotten.
+func (r *RouteRegistry) HealthCheckTargets() map[string]route.HealthCheckTarget {
+	r.RLock()
+	defer r.RUnlock()
+
+	targets := make(map[string]route.HealthCheckTarget)
+	r.byUri.EachNodeWithPool(func(t *container.Trie) {
+		for _, pool := range t.Pools() {
+			pool.Each(func(e *route.Endpoint) {
+				if path := e.Tags[route.HealthCheckPathTag]; path != "" {
+					targets[e.CanonicalAddr()] = route.HealthCheckTarget{
+						Path:                path,
+						ServerCertDomainSAN: e.ServerCertDomainSAN,
+					}
+				}
+			})
+		}
+	})
+
+	if r.health != nil {
+		r.health.Retain(targets)
+	}
+	return targets
+}
+
+// SetEndpointHealth records the result of active health checks of the
+// endpoints at addr. Unhealthy endpoints are skipped by the load balancers.
+func (r *RouteRegistry) SetEndpointHealth(addr string, healthy bool) {
+	if r.health != nil {
+		r.health.SetHealthy(addr, healthy)
+	}
+}
+
 func (r *RouteRegistry) MarshalJSON() ([]byte, error) {
 	r.RLock()
 	defer r.RUnlock()
 
-	return json.Marshal(r.byUri.ToMap())
+	routes := make(map[route.Uri][]*route.Endpoint)
+	r.byUri.EachNodeWithPool(func(t *container.Trie) {
+		uri := route.Uri(t.ToPath())
+		endpoints := make([]*route.Endpoint, 0)
+		for _, pool := range t.Pools() {
+			pool.Each(func(e *route.Endpoint) {
+				endpoints = append(endpoints, e)
+			})
+		}
+		routes[uri] = endpoints
+	})
+
+	return json.Marshal(routes)
 }
 
 func (r *RouteRegistry) pruneStaleDroplets() {
@@ -248,7 +354,10 @@ func (r *RouteRegistry) pruneStaleDroplets() {
 	}
 
 	r.byUri.EachNodeWithPool(func(t *container.Trie) {
-		endpoints := t.Pool.PruneEndpoints(r.dropletStaleThreshold)
+		var endpoints []*route.Endpoint
+		for _, pool := range t.Pools() {
+			endpoints = append(endpoints, pool.PruneEndpoints(r.dropletStaleThreshold)...)
+		}
 		t.Snip()
 		if len(endpoints) > 0 {
 			addresses := []string{}
@@ -273,10 +382,32 @@ func (r *RouteRegistry) SuspendPruning(f func() bool) {
 func (r *RouteRegistry) freshenRoutes() {
 	now := time.Now()
 	r.byUri.EachNodeWithPool(func(t *container.Trie) {
-		t.Pool.MarkUpdated(now)
+		for _, pool := range t.Pools() {
+			pool.MarkUpdated(now)
+		}
 	})
 }
 
+// outlierListener logs and reports the endpoints ejected by outlier
+// detection.
+type outlierListener struct {
+	logger   logger.Logger
+	reporter metrics.RouteRegistryReporter
+}
+
+func (l *outlierListener) EndpointEjected(endpoint *route.Endpoint, duration time.Duration) {
+	l.logger.Info("endpoint-ejected",
+		zap.String("backend", endpoint.CanonicalAddr()),
+		zap.Duration("duration", duration),
+	)
+	l.reporter.CaptureEndpointEjected()
+}
+
+func (l *outlierListener) EndpointRestored(endpoint *route.Endpoint) {
+	l.logger.Info("endpoint-restored", zap.String("backend", endpoint.CanonicalAddr()))
+	l.reporter.CaptureEndpointRestored()
+}
+
 func parseContextPath(uri route.Uri) string {
 	contextPath := "/"
 	split := strings.SplitN(strings.TrimPrefix(uri.String(), "/"), "/", 2)
@@ -175,6 +175,17 @@ var _ = Describe("RouteRegistry", func() {
 				Expect(r.NumUris()).To(Equal(1))
 				Expect(r.NumEndpoints()).To(Equal(1))
 			})
+
+			It("records the route a pool is registered on, which its endpoints unregister from", func() {
+				r.Register("*.A.route/path?foo=bar", fooEndpoint)
+
+				p := r.Lookup("foo.a.route/path/sub")
+				Expect(p).ToNot(BeNil())
+				Expect(p.RouteKey()).To(Equal(route.Uri("*.a.route/path")))
+
+				r.Unregister(p.RouteKey(), fooEndpoint)
+				Expect(r.NumUris()).To(Equal(0))
+			})
 		})
 
 		Context("when route registration message is received", func() {
@@ -572,6 +583,123 @@ var _ = Describe("RouteRegistry", func() {
 		})
 	})
 
+	Context("LookupMatching", func() {
+		var canaryEndpoint *route.Endpoint
+
+		BeforeEach(func() {
+			canaryEndpoint = route.NewEndpoint("12345", "192.168.1.9", 1234, "id9", "0",
+				map[string]string{"match_header": "X-Canary: true"}, -1, "", modTag)
+
+			r.Register("foo", fooEndpoint)
+			r.Register("foo", canaryEndpoint)
+		})
+
+		It("keeps conditional endpoints out of the unconditional pool", func() {
+			Expect(r.NumUris()).To(Equal(1))
+			Expect(r.NumEndpoints()).To(Equal(2))
+
+			p := r.Lookup("foo")
+			Expect(p.Endpoints("", "").Next()).To(Equal(fooEndpoint))
+		})
+
+		It("returns the conditional pool when its rule matches", func() {
+			p := r.LookupMatching("foo/bar", func(rule route.MatchRule) bool {
+				return rule.Header == "X-Canary" && rule.Value == "true"
+			})
+			Expect(p.Endpoints("", "").Next()).To(Equal(canaryEndpoint))
+		})
+
+		It("removes the conditional pool once its last endpoint is unregistered", func() {
+			r.Unregister("foo", canaryEndpoint)
+
+			p := r.LookupMatching("foo", func(route.MatchRule) bool { return true })
+			Expect(p.Endpoints("", "").Next()).To(Equal(fooEndpoint))
+			Expect(r.NumEndpoints()).To(Equal(1))
+		})
+	})
+
+	Context("when the router has a zone", func() {
+		BeforeEach(func() {
+			configObj.Zone = "z1"
+			r = NewRouteRegistry(logger, configObj, reporter)
+		})
+
+		It("creates pools preferring endpoints of that zone", func() {
+			local := route.NewEndpoint("", "192.168.1.1", 1234, "", "", map[string]string{"zone": "z1"}, -1, "", modTag)
+			remote := route.NewEndpoint("", "192.168.1.2", 1234, "", "", map[string]string{"zone": "z2"}, -1, "", modTag)
+			r.Register("foo", local)
+			r.Register("foo", remote)
+
+			p := r.Lookup("foo")
+			Expect(p.IsCrossZone(local)).To(BeFalse())
+			Expect(p.IsCrossZone(remote)).To(BeTrue())
+			for i := 0; i < 5; i++ {
+				Expect(p.Endpoints("", "").Next()).To(Equal(local))
+			}
+		})
+	})
+
+	Context("when outlier detection is enabled", func() {
+		BeforeEach(func() {
+			configObj.OutlierDetection.ConsecutiveErrors = 1
+			configObj.OutlierDetection.MaxEjectionPercent = 50
+			r = NewRouteRegistry(logger, configObj, reporter)
+		})
+
+		It("reports ejected endpoints", func() {
+			e1 := route.NewEndpoint("", "192.168.1.1", 1234, "", "", nil, -1, "", modTag)
+			e2 := route.NewEndpoint("", "192.168.1.2", 1234, "", "", nil, -1, "", modTag)
+			r.Register("foo", e1)
+			r.Register("foo", e2)
+
+			r.Lookup("foo").RecordResult(e1, true)
+
+			Expect(reporter.CaptureEndpointEjectedCallCount()).To(Equal(1))
+			Expect(logger).To(gbytes.Say("endpoint-ejected"))
+		})
+	})
+
+	Context("when active health checking is enabled", func() {
+		var healthy, unchecked *route.Endpoint
+
+		BeforeEach(func() {
+			configObj.ActiveHealthCheck.Enabled = true
+			r = NewRouteRegistry(logger, configObj, reporter)
+
+			tags := map[string]string{route.HealthCheckPathTag: "/healthz"}
+			healthy = route.NewEndpoint("", "192.168.1.1", 1234, "", "", tags, -1, "", modTag)
+			unchecked = route.NewEndpoint("", "192.168.1.2", 1234, "", "", nil, -1, "", modTag)
+			r.Register("foo", healthy)
+			r.Register("bar", healthy)
+			r.Register("bar", unchecked)
+		})
+
+		It("returns the endpoints with a health check path", func() {
+			Expect(r.HealthCheckTargets()).To(Equal(map[string]route.HealthCheckTarget{
+				"192.168.1.1:1234": {Path: "/healthz"},
+			}))
+		})
+
+		It("skips unhealthy endpoints", func() {
+			r.SetEndpointHealth("192.168.1.1:1234", false)
+
+			iter := r.Lookup("bar").Endpoints("", "")
+			for i := 0; i < 5; i++ {
+				Expect(iter.Next()).To(Equal(unchecked))
+			}
+			Expect(healthy.HealthState()).To(Equal(route.HealthUnhealthy))
+		})
+
+		It("forgets the health of unregistered endpoints", func() {
+			r.SetEndpointHealth("192.168.1.1:1234", false)
+			r.Unregister("foo", healthy)
+			r.Unregister("bar", healthy)
+
+			Expect(r.HealthCheckTargets()).To(BeEmpty())
+			Expect(healthy.HealthState()).To(Equal(route.HealthUnknown))
+		})
+	})
+
 	Context("LookupWithInstance", func() {
 		var (
 			appId    string
@@ -885,7 +1013,7 @@ var _ = Describe("RouteRegistry", func() {
 
 		marshalled, err := json.Marshal(r)
 		Expect(err).NotTo(HaveOccurred())
-		Expect(string(marshalled)).To(Equal(`{"foo":[{"address":"192.168.1.1:1234","ttl":-1,"route_service_url":"https://my-routeService.com","tags":null}]}`))
+		Expect(string(marshalled)).To(Equal(`{"foo":[{"address":"192.168.1.1:1234","ttl":-1,"route_service_url":"https://my-routeService.com","tags":null,"weight":1}]}`))
 		r.Unregister("foo", m)
 		marshalled, err = json.Marshal(r)
 		Expect(err).NotTo(HaveOccurred())
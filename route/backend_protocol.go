@@ -0,0 +1,56 @@
+package route
+
+import "fmt"
+
+// BackendProtocolTag selects the protocol the router speaks to an endpoint.
+const BackendProtocolTag = "backend_protocol"
+
+const (
+	// BackendProtocolHTTP1 is HTTP/1.1, the protocol of endpoints registered
+	// without a backend_protocol tag.
+	BackendProtocolHTTP1 = "http1"
+	// BackendProtocolH2C is HTTP/2 over cleartext with prior knowledge.
+	BackendProtocolH2C = "h2c"
+	// BackendProtocolH2 is HTTP/2 over TLS.
+	BackendProtocolH2 = "h2"
+)
+
+var BackendProtocols = []string{BackendProtocolHTTP1, BackendProtocolH2C, BackendProtocolH2}
+
+// ParseBackendProtocol reads the backend protocol of an endpoint from
+// registration tags. It returns BackendProtocolHTTP1 when the tags do not
+// contain one.
+func ParseBackendProtocol(tags map[string]string) (string, error) {
+	protocol, ok := tags[BackendProtocolTag]
+	if !ok {
+		return BackendProtocolHTTP1, nil
+	}
+
+	for _, p := range BackendProtocols {
+		if protocol == p {
+			return protocol, nil
+		}
+	}
+	return "", fmt.Errorf("invalid %s tag %q, allowed values are %s", BackendProtocolTag, protocol, BackendProtocols)
+}
+
+// parseBackendProtocol falls back to HTTP/1.1 for invalid protocols;
+// registrations are validated before endpoints are created.
+func parseBackendProtocol(tags map[string]string) string {
+	protocol, err := ParseBackendProtocol(tags)
+	if err != nil {
+		return BackendProtocolHTTP1
+	}
+	return protocol
+}
+
+// IsHTTP2 returns true if the router speaks HTTP/2 to the endpoint.
+func (e *Endpoint) IsHTTP2() bool {
+	return e.BackendProtocol == BackendProtocolH2C || e.BackendProtocol == BackendProtocolH2
+}
+
+// IsTLS returns true if the router connects to the endpoint over TLS and
+// verifies its certificate against ServerCertDomainSAN.
+func (e *Endpoint) IsTLS() bool {
+	return e.ServerCertDomainSAN != ""
+}
@@ -0,0 +1,39 @@
+package route_test
+
+import (
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BackendProtocol", func() {
+	It("defaults to HTTP/1.1", func() {
+		protocol, err := route.ParseBackendProtocol(map[string]string{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(protocol).To(Equal(route.BackendProtocolHTTP1))
+	})
+
+	It("parses the supported protocols", func() {
+		for _, p := range []string{"http1", "h2c", "h2"} {
+			protocol, err := route.ParseBackendProtocol(map[string]string{route.BackendProtocolTag: p})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(protocol).To(Equal(p))
+		}
+	})
+
+	It("rejects unknown protocols", func() {
+		_, err := route.ParseBackendProtocol(map[string]string{route.BackendProtocolTag: "spdy"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("sets the protocol of endpoints", func() {
+		h2c := route.NewEndpoint("", "1.2.3.4", 5678, "", "", map[string]string{route.BackendProtocolTag: "h2c"}, -1, "", models.ModificationTag{})
+		Expect(h2c.BackendProtocol).To(Equal(route.BackendProtocolH2C))
+		Expect(h2c.IsHTTP2()).To(BeTrue())
+
+		http1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{})
+		Expect(http1.BackendProtocol).To(Equal(route.BackendProtocolHTTP1))
+		Expect(http1.IsHTTP2()).To(BeFalse())
+	})
+})
@@ -0,0 +1,39 @@
+package route
+
+import "fmt"
+
+// CacheTag opts the route an endpoint is registered on in to the shared
+// response cache. Its value is "on" or "off".
+const CacheTag = "cache"
+
+// ParseCache reads whether the responses of a route are cached from
+// registration tags. Routes without a cache tag are not cached.
+func ParseCache(tags map[string]string) (bool, error) {
+	value, ok := tags[CacheTag]
+	if !ok {
+		return false, nil
+	}
+
+	switch value {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid %s tag %q, allowed values are on and off", CacheTag, value)
+}
+
+// parseCache ignores invalid values; registrations are validated before
+// endpoints are created.
+func parseCache(tags map[string]string) bool {
+	cached, _ := ParseCache(tags)
+	return cached
+}
+
+// Caches returns true if the endpoints of the pool opted in to caching.
+func (p *Pool) Caches() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.cache
+}
@@ -0,0 +1,52 @@
+package route_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cache", func() {
+	Describe("ParseCache", func() {
+		It("does not cache without a cache tag", func() {
+			cached, err := route.ParseCache(map[string]string{"component": "x"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cached).To(BeFalse())
+		})
+
+		It("parses on and off", func() {
+			cached, err := route.ParseCache(map[string]string{route.CacheTag: "on"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cached).To(BeTrue())
+
+			cached, err = route.ParseCache(map[string]string{route.CacheTag: "off"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cached).To(BeFalse())
+		})
+
+		It("rejects other values", func() {
+			_, err := route.ParseCache(map[string]string{route.CacheTag: "true"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Pool", func() {
+		It("caches if the most recent registration opted in", func() {
+			pool := route.NewPool(2*time.Minute, "")
+			put := func(tags map[string]string) {
+				pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", tags, -1, "", models.ModificationTag{}))
+			}
+
+			Expect(pool.Caches()).To(BeFalse())
+
+			put(map[string]string{route.CacheTag: "on"})
+			Expect(pool.Caches()).To(BeTrue())
+
+			put(nil)
+			Expect(pool.Caches()).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,159 @@
+package route
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// MaxConcurrentRequestsTag is the registration tag overriding the router-wide
+// limit of concurrent requests per endpoint for the route an endpoint is
+// registered on.
+const MaxConcurrentRequestsTag = "max_concurrent_requests"
+
+// CircuitBreaker limits the requests in flight to each endpoint of a pool.
+// The limit is disabled when MaxConcurrentRequests is zero.
+type CircuitBreaker struct {
+	// MaxConcurrentRequests is the number of requests an endpoint may serve
+	// at the same time.
+	MaxConcurrentRequests int
+	// MaxPendingRequests is the number of requests that may wait for a
+	// request slot when every endpoint of the pool is at its limit. Requests
+	// beyond it are rejected right away.
+	MaxPendingRequests int
+	// PendingTimeout is the longest a pending request waits for a slot.
+	PendingTimeout time.Duration
+}
+
+func (p *Pool) SetCircuitBreaker(cb CircuitBreaker) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.circuitBreaker = cb
+}
+
+// Acquire reserves a request slot on the endpoint. It returns false if the
+// endpoint is at its limit of concurrent requests. Every successful Acquire
+// must be followed by a Release.
+func (p *Pool) Acquire(endpoint *Endpoint) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	e := p.index[endpoint.CanonicalAddr()]
+	if e == nil {
+		return true
+	}
+
+	if !p.hasCapacity(e) {
+		return false
+	}
+	e.requests++
+	return true
+}
+
+// Release frees the request slot reserved on the endpoint and wakes up
+// pending requests.
+func (p *Pool) Release(endpoint *Endpoint) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	e := p.index[endpoint.CanonicalAddr()]
+	if e != nil && e.requests > 0 {
+		e.requests--
+	}
+
+	if p.released != nil {
+		close(p.released)
+		p.released = nil
+	}
+}
+
+// WaitForCapacity blocks a request that found every endpoint at its limit
+// until a request slot is released, and returns true if the request should
+// select an endpoint again. It returns false if there is no room for another
+// pending request, if the request has waited longer than the pending timeout
+// since it first found the pool saturated, or if done is closed.
+func (p *Pool) WaitForCapacity(since time.Time, done <-chan struct{}) bool {
+	p.lock.Lock()
+
+	now := time.Now()
+	if p.filter(now).withCapacity {
+		p.lock.Unlock()
+		return true
+	}
+
+	cb := p.circuitBreaker
+	wait := cb.PendingTimeout - now.Sub(since)
+	if p.pending >= cb.MaxPendingRequests || wait <= 0 {
+		p.lock.Unlock()
+		return false
+	}
+
+	if p.released == nil {
+		p.released = make(chan struct{})
+	}
+	released := p.released
+	p.pending++
+	p.lock.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	ok := false
+	select {
+	case <-released:
+		ok = true
+	case <-timer.C:
+	case <-done:
+	}
+
+	p.lock.Lock()
+	p.pending--
+	p.lock.Unlock()
+
+	return ok
+}
+
+// requestLimit returns the limit of concurrent requests per endpoint, zero if
+// there is none.
+// pool lock must be held
+func (p *Pool) requestLimit() int {
+	if p.maxRequests > 0 {
+		return p.maxRequests
+	}
+	return p.circuitBreaker.MaxConcurrentRequests
+}
+
+// hasCapacity returns true if the endpoint is below its limit of concurrent
+// requests.
+// pool lock must be held
+func (p *Pool) hasCapacity(e *endpointElem) bool {
+	limit := p.requestLimit()
+	return limit <= 0 || e.requests < limit
+}
+
+// ParseMaxConcurrentRequests reads the limit of concurrent requests per
+// endpoint of a route from registration tags. It returns zero when the tags
+// do not contain one.
+func ParseMaxConcurrentRequests(tags map[string]string) (int, error) {
+	value, ok := tags[MaxConcurrentRequestsTag]
+	if !ok {
+		return 0, nil
+	}
+
+	max, err := strconv.Atoi(value)
+	if err != nil || max < 1 {
+		return 0, fmt.Errorf("invalid %s tag %q, expected a positive number of requests", MaxConcurrentRequestsTag, value)
+	}
+	return max, nil
+}
+
+// parseMaxConcurrentRequests ignores invalid overrides; registrations are
+// validated before endpoints are created.
+func parseMaxConcurrentRequests(tags map[string]string) int {
+	max, err := ParseMaxConcurrentRequests(tags)
+	if err != nil {
+		return 0
+	}
+	return max
+}
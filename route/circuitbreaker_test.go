@@ -0,0 +1,154 @@
+package route_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CircuitBreaker", func() {
+	var (
+		pool   *route.Pool
+		e1, e2 *route.Endpoint
+		done   chan struct{}
+	)
+
+	BeforeEach(func() {
+		pool = route.NewPool(2*time.Minute, "")
+		e1 = route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{})
+		e2 = route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", models.ModificationTag{})
+		pool.Put(e1)
+		pool.Put(e2)
+		pool.SetCircuitBreaker(route.CircuitBreaker{
+			MaxConcurrentRequests: 2,
+			PendingTimeout:        time.Second,
+		})
+		done = make(chan struct{})
+	})
+
+	It("limits the concurrent requests of an endpoint", func() {
+		Expect(pool.Acquire(e1)).To(BeTrue())
+		Expect(pool.Acquire(e1)).To(BeTrue())
+		Expect(pool.Acquire(e1)).To(BeFalse())
+
+		pool.Release(e1)
+		Expect(pool.Acquire(e1)).To(BeTrue())
+	})
+
+	It("does not limit requests when disabled", func() {
+		pool.SetCircuitBreaker(route.CircuitBreaker{})
+		for i := 0; i < 10; i++ {
+			Expect(pool.Acquire(e1)).To(BeTrue())
+		}
+	})
+
+	Describe("ParseMaxConcurrentRequests", func() {
+		It("returns zero without the tag", func() {
+			max, err := route.ParseMaxConcurrentRequests(map[string]string{"component": "x"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(max).To(BeZero())
+		})
+
+		It("parses the limit", func() {
+			max, err := route.ParseMaxConcurrentRequests(map[string]string{route.MaxConcurrentRequestsTag: "5"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(max).To(Equal(5))
+		})
+
+		It("rejects invalid values", func() {
+			_, err := route.ParseMaxConcurrentRequests(map[string]string{route.MaxConcurrentRequestsTag: "-1"})
+			Expect(err).To(HaveOccurred())
+
+			_, err = route.ParseMaxConcurrentRequests(map[string]string{route.MaxConcurrentRequestsTag: "many"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	It("lets the registration of a route override the limit", func() {
+		override := route.NewEndpoint("", "1.2.3.4", 5678, "", "", map[string]string{route.MaxConcurrentRequestsTag: "1"}, -1, "", models.ModificationTag{})
+		Expect(override.MaxConcurrentRequests).To(Equal(1))
+		pool.Put(override)
+
+		Expect(pool.Acquire(override)).To(BeTrue())
+		Expect(pool.Acquire(override)).To(BeFalse())
+	})
+
+	It("selects endpoints below their limit", func() {
+		pool.Acquire(e1)
+		pool.Acquire(e1)
+
+		for _, lb := range []string{config.LOAD_BALANCE_RR, config.LOAD_BALANCE_LC, config.LOAD_BALANCE_CH, config.LOAD_BALANCE_EWMA} {
+			iter := pool.EndpointsWithHashKey(lb, "", "key")
+			for i := 0; i < 5; i++ {
+				Expect(iter.Next()).To(Equal(e2), lb)
+			}
+		}
+	})
+
+	Context("when every endpoint is at its limit", func() {
+		BeforeEach(func() {
+			for _, e := range []*route.Endpoint{e1, e1, e2, e2} {
+				Expect(pool.Acquire(e)).To(BeTrue())
+			}
+		})
+
+		It("still selects an endpoint", func() {
+			Expect(pool.Endpoints("", "").Next()).ToNot(BeNil())
+		})
+
+		It("does not let requests wait without a pending limit", func() {
+			Expect(pool.WaitForCapacity(time.Now(), done)).To(BeFalse())
+		})
+
+		Context("with a pending limit", func() {
+			BeforeEach(func() {
+				pool.SetCircuitBreaker(route.CircuitBreaker{
+					MaxConcurrentRequests: 2,
+					MaxPendingRequests:    1,
+					PendingTimeout:        100 * time.Millisecond,
+				})
+			})
+
+			It("wakes up pending requests when a slot is released", func() {
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					pool.Release(e2)
+				}()
+
+				Expect(pool.WaitForCapacity(time.Now(), done)).To(BeTrue())
+				Expect(pool.Endpoints("", "").Next()).To(Equal(e2))
+			})
+
+			It("gives up after the pending timeout", func() {
+				started := time.Now()
+				Expect(pool.WaitForCapacity(started, done)).To(BeFalse())
+				Expect(time.Since(started)).To(BeNumerically(">=", 100*time.Millisecond))
+			})
+
+			It("gives up when the request is done", func() {
+				close(done)
+				Expect(pool.WaitForCapacity(time.Now(), done)).To(BeFalse())
+			})
+
+			It("rejects requests beyond the pending limit", func() {
+				waiting := make(chan bool)
+				go func() {
+					waiting <- pool.WaitForCapacity(time.Now(), done)
+				}()
+
+				time.Sleep(20 * time.Millisecond)
+
+				started := time.Now()
+				Expect(pool.WaitForCapacity(started, nil)).To(BeFalse())
+				Expect(time.Since(started)).To(BeNumerically("<", 50*time.Millisecond))
+
+				close(done)
+				Eventually(waiting).Should(Receive(BeFalse()))
+			})
+		})
+	})
+})
@@ -0,0 +1,50 @@
+package route
+
+import "fmt"
+
+// CompressionTag enables or disables the compression of the responses of
+// the route an endpoint is registered on, overriding the router-wide
+// setting. Its value is "on" or "off".
+const CompressionTag = "compression"
+
+// ParseCompression reads the compression override of a route from
+// registration tags. It returns nil when the tags do not contain one.
+func ParseCompression(tags map[string]string) (*bool, error) {
+	value, ok := tags[CompressionTag]
+	if !ok {
+		return nil, nil
+	}
+
+	var enabled bool
+	switch value {
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	default:
+		return nil, fmt.Errorf("invalid %s tag %q, allowed values are on and off", CompressionTag, value)
+	}
+	return &enabled, nil
+}
+
+// parseCompression ignores invalid overrides; registrations are validated
+// before endpoints are created.
+func parseCompression(tags map[string]string) *bool {
+	enabled, err := ParseCompression(tags)
+	if err != nil {
+		return nil
+	}
+	return enabled
+}
+
+// Compresses returns true if responses of the pool are compressed:
+// defaultEnabled unless the endpoints of the pool override it.
+func (p *Pool) Compresses(defaultEnabled bool) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.compression != nil {
+		return *p.compression
+	}
+	return defaultEnabled
+}
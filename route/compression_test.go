@@ -0,0 +1,51 @@
+package route_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compression", func() {
+	Describe("ParseCompression", func() {
+		It("returns nil without a compression tag", func() {
+			enabled, err := route.ParseCompression(map[string]string{"component": "x"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(enabled).To(BeNil())
+		})
+
+		It("parses on and off", func() {
+			enabled, err := route.ParseCompression(map[string]string{route.CompressionTag: "on"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*enabled).To(BeTrue())
+
+			enabled, err = route.ParseCompression(map[string]string{route.CompressionTag: "off"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*enabled).To(BeFalse())
+		})
+
+		It("rejects other values", func() {
+			_, err := route.ParseCompression(map[string]string{route.CompressionTag: "gzip"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Pool", func() {
+		It("uses the override of the most recent registration", func() {
+			pool := route.NewPool(2*time.Minute, "")
+			put := func(tags map[string]string) {
+				pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", tags, -1, "", models.ModificationTag{}))
+			}
+
+			put(nil)
+			Expect(pool.Compresses(true)).To(BeTrue())
+			Expect(pool.Compresses(false)).To(BeFalse())
+
+			put(map[string]string{route.CompressionTag: "off"})
+			Expect(pool.Compresses(true)).To(BeFalse())
+		})
+	})
+})
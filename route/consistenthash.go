@@ -0,0 +1,194 @@
+package route
+
+import (
+	"hash/crc32"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
+)
+
+// pointsPerWeight is the number of positions an endpoint of weight 1 takes on
+// the hash ring. More points give a more even distribution of keys.
+const pointsPerWeight = 100
+
+type ringPoint struct {
+	hash uint32
+	elem *endpointElem
+}
+
+// hashRing is a sorted list of ring points. It is built the first time a
+// pool is balanced by consistent hash and then kept up to date as endpoints
+// are added and removed, so only the keys owned by a changed endpoint move.
+type hashRing []ringPoint
+
+func (r hashRing) Len() int           { return len(r) }
+func (r hashRing) Less(i, j int) bool { return r[i].hash < r[j].hash }
+func (r hashRing) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+func ringPoints(e *endpointElem) hashRing {
+	n := pointsPerWeight * e.endpoint.weight()
+	points := make(hashRing, 0, n)
+	addr := e.endpoint.CanonicalAddr()
+	for i := 0; i < n; i++ {
+		points = append(points, ringPoint{
+			hash: crc32.ChecksumIEEE([]byte(addr + "-" + strconv.Itoa(i))),
+			elem: e,
+		})
+	}
+	sort.Sort(points)
+	return points
+}
+
+func (r hashRing) add(e *endpointElem) hashRing {
+	points := ringPoints(e)
+	merged := make(hashRing, 0, len(r)+len(points))
+	i, j := 0, 0
+	for i < len(r) && j < len(points) {
+		if r[i].hash <= points[j].hash {
+			merged = append(merged, r[i])
+			i++
+		} else {
+			merged = append(merged, points[j])
+			j++
+		}
+	}
+	merged = append(merged, r[i:]...)
+	return append(merged, points[j:]...)
+}
+
+func (r hashRing) remove(e *endpointElem) hashRing {
+	kept := r[:0]
+	for _, point := range r {
+		if point.elem != e {
+			kept = append(kept, point)
+		}
+	}
+	for i := len(kept); i < len(r); i++ {
+		r[i].elem = nil
+	}
+	return kept
+}
+
+// search returns the index of the first point at or after hash, wrapping
+// around to the start of the ring.
+func (r hashRing) search(hash uint32) int {
+	i := sort.Search(len(r), func(i int) bool { return r[i].hash >= hash })
+	if i == len(r) {
+		return 0
+	}
+	return i
+}
+
+// HashKey extracts the value the consistent-hash balancer is keyed on from a
+// request. source is "client_ip", "header:<name>" or "cookie:<name>". The
+// client IP is only taken from X-Forwarded-For for requests of trustedProxies.
+// An empty string is returned when the request does not carry the key.
+func HashKey(source string, request *http.Request, trustedProxies []*net.IPNet) string {
+	switch {
+	case strings.HasPrefix(source, config.HASH_KEY_HEADER_PREFIX):
+		return request.Header.Get(strings.TrimPrefix(source, config.HASH_KEY_HEADER_PREFIX))
+	case strings.HasPrefix(source, config.HASH_KEY_COOKIE_PREFIX):
+		cookie, err := request.Cookie(strings.TrimPrefix(source, config.HASH_KEY_COOKIE_PREFIX))
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	default:
+		return router_http.ClientIP(request, trustedProxies)
+	}
+}
+
+type ConsistentHash struct {
+	pool *Pool
+	key  string
+
+	initialEndpoint string
+	lastEndpoint    *Endpoint
+	fallback        EndpointIterator
+}
+
+// NewConsistentHash returns an iterator that maps key to an endpoint of the
+// pool. When that endpoint fails the next endpoint on the ring is tried.
+// Requests without a key are balanced round-robin.
+func NewConsistentHash(p *Pool, initial, key string) EndpointIterator {
+	return &ConsistentHash{
+		pool:            p,
+		key:             key,
+		initialEndpoint: initial,
+		fallback:        NewRoundRobin(p, initial),
+	}
+}
+
+func (c *ConsistentHash) Next() *Endpoint {
+	if c.key == "" {
+		return c.fallback.Next()
+	}
+
+	var e *Endpoint
+	if c.initialEndpoint != "" {
+		e = c.pool.findById(c.initialEndpoint)
+		c.initialEndpoint = ""
+	}
+
+	if e == nil {
+		e = c.next()
+	}
+
+	c.lastEndpoint = e
+
+	return e
+}
+
+func (c *ConsistentHash) next() *Endpoint {
+	c.pool.lock.Lock()
+	defer c.pool.lock.Unlock()
+
+	if len(c.pool.endpoints) == 0 {
+		return nil
+	}
+
+	ring := c.pool.hashRing()
+	start := ring.search(crc32.ChecksumIEEE([]byte(c.key)))
+	curTime := time.Now()
+	f := c.pool.filter(curTime)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		for i := 0; i < len(ring); i++ {
+			e := ring[(start+i)%len(ring)].elem
+
+			if c.pool.available(e, curTime) && c.pool.eligible(e, f, curTime) {
+				return e.endpoint
+			}
+		}
+
+		// all endpoints are marked failed so reset everything to available
+		for _, e := range c.pool.endpoints {
+			e.failedAt = nil
+		}
+	}
+
+	return ring[start].elem.endpoint
+}
+
+func (c *ConsistentHash) EndpointFailed() {
+	if c.key == "" {
+		c.fallback.EndpointFailed()
+		return
+	}
+
+	if c.lastEndpoint != nil {
+		c.pool.EndpointFailed(c.lastEndpoint)
+	}
+}
+
+func (c *ConsistentHash) PreRequest(e *Endpoint) {
+}
+
+func (c *ConsistentHash) PostRequest(e *Endpoint) {
+}
@@ -0,0 +1,193 @@
+package route_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConsistentHash", func() {
+	var pool *route.Pool
+	var modTag models.ModificationTag
+	var endpoints []*route.Endpoint
+
+	BeforeEach(func() {
+		pool = route.NewPool(2*time.Minute, "")
+		modTag = models.ModificationTag{}
+		endpoints = nil
+
+		for i := 0; i < 4; i++ {
+			e := route.NewEndpoint("", fmt.Sprintf("10.0.0.%d", i), 8080, fmt.Sprintf("id-%d", i), "", nil, -1, "", modTag)
+			endpoints = append(endpoints, e)
+			pool.Put(e)
+		}
+	})
+
+	pick := func(key string) *route.Endpoint {
+		return route.NewConsistentHash(pool, "", key).Next()
+	}
+
+	Describe("Next", func() {
+		It("returns the same endpoint for the same key", func() {
+			e := pick("user-1")
+			Expect(e).ToNot(BeNil())
+
+			for i := 0; i < 10; i++ {
+				Expect(pick("user-1")).To(Equal(e))
+			}
+		})
+
+		It("spreads different keys across the endpoints", func() {
+			counts := make(map[*route.Endpoint]int)
+			for i := 0; i < 1000; i++ {
+				counts[pick(fmt.Sprintf("user-%d", i))]++
+			}
+
+			Expect(counts).To(HaveLen(len(endpoints)))
+			for _, c := range counts {
+				Expect(c).To(BeNumerically(">", 100))
+			}
+		})
+
+		It("only moves the keys of a removed endpoint", func() {
+			before := make(map[string]*route.Endpoint)
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("user-%d", i)
+				before[key] = pick(key)
+			}
+
+			removed := endpoints[0]
+			Expect(pool.Remove(removed)).To(BeTrue())
+
+			for key, e := range before {
+				if e != removed {
+					Expect(pick(key)).To(Equal(e))
+				} else {
+					Expect(pick(key)).ToNot(Equal(removed))
+				}
+			}
+		})
+
+		It("only moves keys to an added endpoint", func() {
+			before := make(map[string]*route.Endpoint)
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("user-%d", i)
+				before[key] = pick(key)
+			}
+
+			added := route.NewEndpoint("", "10.0.0.9", 8080, "id-9", "", nil, -1, "", modTag)
+			pool.Put(added)
+
+			moved := 0
+			for key, e := range before {
+				if n := pick(key); n != e {
+					Expect(n).To(Equal(added))
+					moved++
+				}
+			}
+			Expect(moved).To(BeNumerically(">", 0))
+		})
+
+		It("returns nil when no endpoints exist", func() {
+			iter := route.NewConsistentHash(route.NewPool(2*time.Minute, ""), "", "user-1")
+			Expect(iter.Next()).To(BeNil())
+		})
+
+		It("finds the initial endpoint by private id", func() {
+			iter := route.NewConsistentHash(pool, "id-2", "user-1")
+			Expect(iter.Next()).To(Equal(endpoints[2]))
+		})
+
+		It("round-robins requests without a key", func() {
+			iter := route.NewConsistentHash(pool, "", "")
+			seen := make(map[*route.Endpoint]bool)
+			for i := 0; i < len(endpoints); i++ {
+				seen[iter.Next()] = true
+			}
+			Expect(seen).To(HaveLen(len(endpoints)))
+		})
+	})
+
+	Describe("EndpointFailed", func() {
+		It("moves to the next endpoint on the ring", func() {
+			iter := route.NewConsistentHash(pool, "", "user-1")
+			first := iter.Next()
+			iter.EndpointFailed()
+
+			second := iter.Next()
+			Expect(second).ToNot(BeNil())
+			Expect(second).ToNot(Equal(first))
+		})
+
+		It("resets when all endpoints are failed", func() {
+			iter := route.NewConsistentHash(pool, "", "user-1")
+			first := iter.Next()
+			for i := 0; i < len(endpoints); i++ {
+				iter.Next()
+				iter.EndpointFailed()
+			}
+
+			Expect(iter.Next()).To(Equal(first))
+		})
+
+		It("returns to the hashed endpoint after the failure window", func() {
+			pool = route.NewPool(50*time.Millisecond, "")
+			for _, e := range endpoints {
+				pool.Put(e)
+			}
+
+			iter := route.NewConsistentHash(pool, "", "user-1")
+			first := iter.Next()
+			iter.EndpointFailed()
+			Expect(iter.Next()).ToNot(Equal(first))
+
+			time.Sleep(100 * time.Millisecond)
+			Expect(iter.Next()).To(Equal(first))
+		})
+	})
+
+	Describe("HashKey", func() {
+		var req *http.Request
+
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "http://example.com/", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.RemoteAddr = "192.0.2.1:54321"
+		})
+
+		It("uses the client ip", func() {
+			Expect(route.HashKey("client_ip", req, nil)).To(Equal("192.0.2.1"))
+		})
+
+		It("ignores X-Forwarded-For from untrusted clients", func() {
+			req.Header.Set("X-Forwarded-For", "198.51.100.7")
+			Expect(route.HashKey("client_ip", req, nil)).To(Equal("192.0.2.1"))
+		})
+
+		It("takes the client ip from X-Forwarded-For of trusted proxies", func() {
+			_, trusted, err := net.ParseCIDR("192.0.2.0/24")
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+			Expect(route.HashKey("client_ip", req, []*net.IPNet{trusted})).To(Equal("198.51.100.7"))
+		})
+
+		It("uses a header", func() {
+			req.Header.Set("X-User-Id", "alice")
+			Expect(route.HashKey("header:X-User-Id", req, nil)).To(Equal("alice"))
+			Expect(route.HashKey("header:X-Other", req, nil)).To(BeEmpty())
+		})
+
+		It("uses a cookie", func() {
+			req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+			Expect(route.HashKey("cookie:session", req, nil)).To(Equal("abc"))
+			Expect(route.HashKey("cookie:other", req, nil)).To(BeEmpty())
+		})
+	})
+})
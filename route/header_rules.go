@@ -0,0 +1,102 @@
+package route
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/config"
+)
+
+// HeaderRulesTag holds the header rules of the route an endpoint is
+// registered on, as a JSON list of config.HeaderRule such as
+// `[{"on":"response","action":"remove","name":"Server"}]`.
+const HeaderRulesTag = "header_rules"
+
+// ParseHeaderRules reads the header rules of a route from registration tags.
+// It returns nil when the tags do not contain any.
+func ParseHeaderRules(tags map[string]string) ([]config.HeaderRule, error) {
+	value, ok := tags[HeaderRulesTag]
+	if !ok {
+		return nil, nil
+	}
+
+	var rules []config.HeaderRule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, fmt.Errorf("invalid %s tag %q: %s", HeaderRulesTag, value, err)
+	}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// parseHeaderRules ignores invalid rules; registrations are validated before
+// endpoints are created.
+func parseHeaderRules(tags map[string]string) []config.HeaderRule {
+	rules, err := ParseHeaderRules(tags)
+	if err != nil {
+		return nil
+	}
+	return rules
+}
+
+// HeaderRules returns the header rules the endpoints of the pool were
+// registered with.
+func (p *Pool) HeaderRules() []config.HeaderRule {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.headerRules
+}
+
+// ApplyRequestHeaderRules applies the request rules to header in order.
+func ApplyRequestHeaderRules(rules []config.HeaderRule, header http.Header) {
+	for _, rule := range rules {
+		if rule.On == config.HEADER_RULE_REQUEST {
+			applyHeaderRule(rule, header)
+		}
+	}
+}
+
+// ApplyResponseHeaderRules applies the response rules that match statusCode
+// to header in order.
+func ApplyResponseHeaderRules(rules []config.HeaderRule, header http.Header, statusCode int) {
+	for _, rule := range rules {
+		if rule.On == config.HEADER_RULE_RESPONSE && matchesStatus(rule, statusCode) {
+			applyHeaderRule(rule, header)
+		}
+	}
+}
+
+func matchesStatus(rule config.HeaderRule, statusCode int) bool {
+	if len(rule.StatusCodes) == 0 {
+		return true
+	}
+	for _, code := range rule.StatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+func applyHeaderRule(rule config.HeaderRule, header http.Header) {
+	switch rule.Action {
+	case config.HEADER_RULE_SET:
+		header.Set(rule.Name, rule.Value)
+	case config.HEADER_RULE_ADD:
+		header.Add(rule.Name, rule.Value)
+	case config.HEADER_RULE_REMOVE:
+		header.Del(rule.Name)
+	case config.HEADER_RULE_RENAME:
+		values := header[http.CanonicalHeaderKey(rule.Name)]
+		if len(values) == 0 {
+			return
+		}
+		header.Del(rule.Name)
+		header[http.CanonicalHeaderKey(rule.NewName)] = values
+	}
+}
@@ -0,0 +1,111 @@
+package route_test
+
+import (
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HeaderRules", func() {
+	Describe("ParseHeaderRules", func() {
+		It("returns nil without a header rules tag", func() {
+			rules, err := route.ParseHeaderRules(map[string]string{"component": "x"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rules).To(BeNil())
+		})
+
+		It("parses the rules", func() {
+			rules, err := route.ParseHeaderRules(map[string]string{
+				route.HeaderRulesTag: `[{"on":"response","action":"set","name":"X-Frame-Options","value":"DENY","status_codes":[200]},
+					{"on":"request","action":"rename","name":"X-User","new_name":"X-Remote-User"}]`,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rules).To(Equal([]config.HeaderRule{
+				{On: "response", Action: "set", Name: "X-Frame-Options", Value: "DENY", StatusCodes: []int{200}},
+				{On: "request", Action: "rename", Name: "X-User", NewName: "X-Remote-User"},
+			}))
+		})
+
+		It("rejects invalid JSON", func() {
+			_, err := route.ParseHeaderRules(map[string]string{route.HeaderRulesTag: "remove Server"})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects invalid rules", func() {
+			_, err := route.ParseHeaderRules(map[string]string{
+				route.HeaderRulesTag: `[{"on":"request","action":"set","name":"X-Id","status_codes":[200]}]`,
+			})
+			Expect(err).To(HaveOccurred())
+
+			_, err = route.ParseHeaderRules(map[string]string{
+				route.HeaderRulesTag: `[{"on":"response","action":"replace","name":"Server"}]`,
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Pool", func() {
+		It("uses the rules of the most recent registration", func() {
+			pool := route.NewPool(2*time.Minute, "")
+			put := func(tags map[string]string) {
+				pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", tags, -1, "", models.ModificationTag{}))
+			}
+
+			put(map[string]string{route.HeaderRulesTag: `[{"on":"response","action":"remove","name":"Server"}]`})
+			Expect(pool.HeaderRules()).To(Equal([]config.HeaderRule{{On: "response", Action: "remove", Name: "Server"}}))
+
+			put(nil)
+			Expect(pool.HeaderRules()).To(BeEmpty())
+		})
+	})
+
+	Describe("applying rules", func() {
+		var header http.Header
+
+		BeforeEach(func() {
+			header = http.Header{}
+			header.Set("Server", "nginx")
+			header.Set("X-User", "alice")
+			header.Set("Cache-Control", "no-cache")
+		})
+
+		It("sets, adds, removes and renames request headers in order", func() {
+			route.ApplyRequestHeaderRules([]config.HeaderRule{
+				{On: "request", Action: "set", Name: "X-Env", Value: "prod"},
+				{On: "request", Action: "add", Name: "Cache-Control", Value: "no-store"},
+				{On: "request", Action: "remove", Name: "server"},
+				{On: "request", Action: "rename", Name: "X-User", NewName: "X-Remote-User"},
+				{On: "request", Action: "rename", Name: "X-Missing", NewName: "X-Env"},
+				{On: "response", Action: "remove", Name: "X-Env"},
+			}, header)
+
+			Expect(header).To(Equal(http.Header{
+				"X-Env":         {"prod"},
+				"Cache-Control": {"no-cache", "no-store"},
+				"X-Remote-User": {"alice"},
+			}))
+		})
+
+		It("only applies response rules to responses with a listed status code", func() {
+			rules := []config.HeaderRule{
+				{On: "response", Action: "remove", Name: "Server"},
+				{On: "response", Action: "set", Name: "Cache-Control", Value: "no-store", StatusCodes: []int{404, 500}},
+				{On: "request", Action: "remove", Name: "X-User"},
+			}
+
+			route.ApplyResponseHeaderRules(rules, header, http.StatusOK)
+			Expect(header).To(Equal(http.Header{
+				"X-User":        {"alice"},
+				"Cache-Control": {"no-cache"},
+			}))
+
+			route.ApplyResponseHeaderRules(rules, header, http.StatusNotFound)
+			Expect(header.Get("Cache-Control")).To(Equal("no-store"))
+		})
+	})
+})
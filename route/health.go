@@ -0,0 +1,116 @@
+package route
+
+import "sync"
+
+// HealthCheckPathTag is the registration tag holding the path at which an
+// endpoint is probed by active health checks.
+const HealthCheckPathTag = "health_check_path"
+
+// HealthCheckTarget is what active health checks probe at an endpoint
+// address.
+type HealthCheckTarget struct {
+	Path string
+	// ServerCertDomainSAN is set for endpoints registered with a TLS port,
+	// which are probed over TLS.
+	ServerCertDomainSAN string
+}
+
+const (
+	HealthUnknown   = "unknown"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// HealthTable holds the results of active health checks by endpoint address.
+// It is shared by all pools of a registry, so an endpoint registered for many
+// routes is probed once. Endpoints without a result are considered healthy.
+type HealthTable struct {
+	lock    sync.RWMutex
+	healthy map[string]bool
+}
+
+func NewHealthTable() *HealthTable {
+	return &HealthTable{
+		healthy: make(map[string]bool),
+	}
+}
+
+func (h *HealthTable) SetHealthy(addr string, healthy bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.healthy[addr] = healthy
+}
+
+func (h *HealthTable) Healthy(addr string) bool {
+	if h == nil {
+		return true
+	}
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	healthy, found := h.healthy[addr]
+	return !found || healthy
+}
+
+// State returns HealthUnknown, HealthHealthy or HealthUnhealthy.
+func (h *HealthTable) State(addr string) string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	healthy, found := h.healthy[addr]
+	switch {
+	case !found:
+		return HealthUnknown
+	case healthy:
+		return HealthHealthy
+	default:
+		return HealthUnhealthy
+	}
+}
+
+// Retain forgets the results of all addresses not in addrs.
+func (h *HealthTable) Retain(addrs map[string]HealthCheckTarget) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for addr := range h.healthy {
+		if _, found := addrs[addr]; !found {
+			delete(h.healthy, addr)
+		}
+	}
+}
+
+// SetHealthTable makes the pool skip endpoints that fail active health
+// checks.
+func (p *Pool) SetHealthTable(h *HealthTable) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.health = h
+	for _, e := range p.endpoints {
+		e.endpoint.setHealthTable(h)
+	}
+}
+
+// healthy returns true unless the endpoint failed its active health checks.
+// pool lock must be held
+func (p *Pool) healthy(e *endpointElem) bool {
+	return p.health.Healthy(e.endpoint.CanonicalAddr())
+}
+
+func (e *Endpoint) setHealthTable(h *HealthTable) {
+	if e.Tags[HealthCheckPathTag] != "" {
+		e.health = h
+	}
+}
+
+// HealthState returns the state of the active health checks of the endpoint,
+// or an empty string if it is not checked.
+func (e *Endpoint) HealthState() string {
+	if e.health == nil {
+		return ""
+	}
+	return e.health.State(e.addr)
+}
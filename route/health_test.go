@@ -0,0 +1,86 @@
+package route_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthTable", func() {
+	var (
+		pool      *route.Pool
+		health    *route.HealthTable
+		checked   *route.Endpoint
+		unchecked *route.Endpoint
+	)
+
+	BeforeEach(func() {
+		pool = route.NewPool(2*time.Minute, "")
+		health = route.NewHealthTable()
+		pool.SetHealthTable(health)
+
+		tags := map[string]string{route.HealthCheckPathTag: "/healthz"}
+		checked = route.NewEndpoint("", "1.2.3.4", 5678, "", "", tags, -1, "", models.ModificationTag{})
+		unchecked = route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", models.ModificationTag{})
+		pool.Put(checked)
+		pool.Put(unchecked)
+	})
+
+	It("considers endpoints without results healthy", func() {
+		Expect(health.Healthy("1.2.3.4:5678")).To(BeTrue())
+		Expect(checked.HealthState()).To(Equal(route.HealthUnknown))
+		Expect(unchecked.HealthState()).To(BeEmpty())
+	})
+
+	It("skips unhealthy endpoints", func() {
+		health.SetHealthy("1.2.3.4:5678", false)
+
+		for _, lb := range []string{config.LOAD_BALANCE_RR, config.LOAD_BALANCE_LC, config.LOAD_BALANCE_CH, config.LOAD_BALANCE_EWMA} {
+			iter := pool.EndpointsWithHashKey(lb, "", "key")
+			for i := 0; i < 5; i++ {
+				Expect(iter.Next()).To(Equal(unchecked), lb)
+			}
+		}
+		Expect(checked.HealthState()).To(Equal(route.HealthUnhealthy))
+	})
+
+	It("selects unhealthy endpoints when all endpoints are unhealthy", func() {
+		pool.Remove(unchecked)
+		health.SetHealthy("1.2.3.4:5678", false)
+
+		Expect(pool.Endpoints("", "").Next()).To(Equal(checked))
+	})
+
+	It("selects endpoints again once they are healthy", func() {
+		health.SetHealthy("1.2.3.4:5678", false)
+		health.SetHealthy("1.2.3.4:5678", true)
+
+		seen := make(map[*route.Endpoint]bool)
+		iter := pool.Endpoints("", "")
+		for i := 0; i < 4; i++ {
+			seen[iter.Next()] = true
+		}
+		Expect(seen).To(HaveKey(checked))
+		Expect(checked.HealthState()).To(Equal(route.HealthHealthy))
+	})
+
+	It("forgets results of addresses that are not retained", func() {
+		health.SetHealthy("1.2.3.4:5678", false)
+		health.Retain(map[string]route.HealthCheckTarget{"5.6.7.8:5678": {Path: "/"}})
+
+		Expect(checked.HealthState()).To(Equal(route.HealthUnknown))
+	})
+
+	It("marshals the health state of checked endpoints", func() {
+		pool.Remove(unchecked)
+		health.SetHealthy("1.2.3.4:5678", false)
+
+		json, err := pool.MarshalJSON()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","ttl":-1,"tags":{"health_check_path":"/healthz"},"weight":1,"health":"unhealthy"}]`))
+	})
+})
@@ -0,0 +1,120 @@
+package route
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HedgeDelayTag opts the route an endpoint is registered on into request
+// hedging. Its value is either a duration such as "50ms", or a percentile of
+// the response latency of the route such as "p95".
+const HedgeDelayTag = "hedge_delay"
+
+const (
+	// latencySamples is the number of recent response latencies a pool keeps
+	// to compute percentile hedge delays.
+	latencySamples = 128
+	// minLatencySamples is the number of latencies needed before requests
+	// are hedged at a percentile.
+	minLatencySamples = 20
+)
+
+// HedgePolicy sets the delay after which a copy of a request that has not
+// received a response is sent to another endpoint. Either Delay or
+// Percentile is set.
+type HedgePolicy struct {
+	Delay      time.Duration
+	Percentile float64
+}
+
+// ParseHedgePolicy reads the hedge policy of a route from registration tags.
+// It returns nil when the tags do not contain one.
+func ParseHedgePolicy(tags map[string]string) (*HedgePolicy, error) {
+	value, ok := tags[HedgeDelayTag]
+	if !ok {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(value, "p") {
+		percentile, err := strconv.ParseFloat(value[1:], 64)
+		if err != nil || percentile <= 0 || percentile >= 100 {
+			return nil, fmt.Errorf("invalid %s tag %q, expected a percentile between p0 and p100", HedgeDelayTag, value)
+		}
+		return &HedgePolicy{Percentile: percentile}, nil
+	}
+
+	delay, err := time.ParseDuration(value)
+	if err != nil || delay <= 0 {
+		return nil, fmt.Errorf("invalid %s tag %q, expected a positive duration or a percentile", HedgeDelayTag, value)
+	}
+	return &HedgePolicy{Delay: delay}, nil
+}
+
+// parseHedgePolicy ignores invalid policies; registrations are validated
+// before endpoints are created.
+func parseHedgePolicy(tags map[string]string) *HedgePolicy {
+	policy, err := ParseHedgePolicy(tags)
+	if err != nil {
+		return nil
+	}
+	return policy
+}
+
+// ObserveLatency records the time an endpoint of the pool took to respond
+// with headers. Latencies are only kept for routes hedged at a percentile.
+func (p *Pool) ObserveLatency(d time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.hedgePolicy == nil || p.hedgePolicy.Percentile == 0 {
+		return
+	}
+
+	if len(p.latencies) < latencySamples {
+		p.latencies = append(p.latencies, d)
+		return
+	}
+	p.latencies[p.nextLatency] = d
+	p.nextLatency = (p.nextLatency + 1) % latencySamples
+}
+
+// HedgeDelay returns the delay after which requests to the pool are hedged.
+// It returns false if the route is not hedged, has a single endpoint, or has
+// not seen enough responses to compute its latency percentile.
+func (p *Pool) HedgeDelay() (time.Duration, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	h := p.hedgePolicy
+	if h == nil || len(p.endpoints) < 2 {
+		return 0, false
+	}
+
+	if h.Delay > 0 {
+		return h.Delay, true
+	}
+
+	if len(p.latencies) < minLatencySamples {
+		return 0, false
+	}
+
+	sorted := make(durations, len(p.latencies))
+	copy(sorted, p.latencies)
+	sort.Sort(sorted)
+
+	i := int(math.Ceil(h.Percentile/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i], true
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
@@ -0,0 +1,122 @@
+package route_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HedgePolicy", func() {
+	Describe("ParseHedgePolicy", func() {
+		It("returns nil without a hedge tag", func() {
+			policy, err := route.ParseHedgePolicy(map[string]string{"component": "x"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(policy).To(BeNil())
+		})
+
+		It("parses a delay", func() {
+			policy, err := route.ParseHedgePolicy(map[string]string{route.HedgeDelayTag: "50ms"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(policy).To(Equal(&route.HedgePolicy{Delay: 50 * time.Millisecond}))
+		})
+
+		It("parses a percentile", func() {
+			policy, err := route.ParseHedgePolicy(map[string]string{route.HedgeDelayTag: "p99.5"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(policy).To(Equal(&route.HedgePolicy{Percentile: 99.5}))
+		})
+
+		It("rejects invalid values", func() {
+			for _, value := range []string{"0s", "-1s", "soon", "p0", "p100", "pfast"} {
+				_, err := route.ParseHedgePolicy(map[string]string{route.HedgeDelayTag: value})
+				Expect(err).To(HaveOccurred(), value)
+			}
+		})
+	})
+
+	Describe("Pool", func() {
+		var pool *route.Pool
+
+		BeforeEach(func() {
+			pool = route.NewPool(2*time.Minute, "")
+		})
+
+		put := func(host string, tags map[string]string) {
+			pool.Put(route.NewEndpoint("", host, 5678, "", "", tags, -1, "", models.ModificationTag{}))
+		}
+
+		It("does not hedge routes without a policy", func() {
+			put("1.2.3.4", nil)
+			put("5.6.7.8", nil)
+
+			_, ok := pool.HedgeDelay()
+			Expect(ok).To(BeFalse())
+		})
+
+		It("does not hedge routes with a single endpoint", func() {
+			put("1.2.3.4", map[string]string{route.HedgeDelayTag: "50ms"})
+
+			_, ok := pool.HedgeDelay()
+			Expect(ok).To(BeFalse())
+		})
+
+		It("uses the delay every endpoint was registered with", func() {
+			put("1.2.3.4", map[string]string{route.HedgeDelayTag: "20ms"})
+			put("5.6.7.8", map[string]string{route.HedgeDelayTag: "20ms"})
+
+			delay, ok := pool.HedgeDelay()
+			Expect(ok).To(BeTrue())
+			Expect(delay).To(Equal(20 * time.Millisecond))
+		})
+
+		It("does not hedge while the endpoints disagree", func() {
+			put("1.2.3.4", map[string]string{route.HedgeDelayTag: "50ms"})
+			put("5.6.7.8", map[string]string{route.HedgeDelayTag: "20ms"})
+
+			_, ok := pool.HedgeDelay()
+			Expect(ok).To(BeFalse())
+		})
+
+		Context("with a percentile", func() {
+			BeforeEach(func() {
+				put("1.2.3.4", map[string]string{route.HedgeDelayTag: "p90"})
+				put("5.6.7.8", map[string]string{route.HedgeDelayTag: "p90"})
+			})
+
+			It("waits for enough latencies", func() {
+				for i := 0; i < 10; i++ {
+					pool.ObserveLatency(time.Millisecond)
+				}
+
+				_, ok := pool.HedgeDelay()
+				Expect(ok).To(BeFalse())
+			})
+
+			It("returns the percentile of the observed latencies", func() {
+				for i := 1; i <= 100; i++ {
+					pool.ObserveLatency(time.Duration(i) * time.Millisecond)
+				}
+
+				delay, ok := pool.HedgeDelay()
+				Expect(ok).To(BeTrue())
+				Expect(delay).To(Equal(90 * time.Millisecond))
+			})
+
+			It("only keeps recent latencies", func() {
+				for i := 0; i < 200; i++ {
+					pool.ObserveLatency(time.Second)
+				}
+				for i := 0; i < 128; i++ {
+					pool.ObserveLatency(time.Millisecond)
+				}
+
+				delay, ok := pool.HedgeDelay()
+				Expect(ok).To(BeTrue())
+				Expect(delay).To(Equal(time.Millisecond))
+			})
+		})
+	})
+})
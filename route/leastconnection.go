@@ -47,7 +47,7 @@ func (r *LeastConnection) next() *Endpoint {
 	r.pool.lock.Lock()
 	defer r.pool.lock.Unlock()
 
-	var selected *Endpoint
+	var selected *endpointElem
 
 	// none
 	total := len(r.pool.endpoints)
@@ -60,30 +60,46 @@ func (r *LeastConnection) next() *Endpoint {
 		return r.pool.endpoints[0].endpoint
 	}
 
+	now := time.Now()
+	f := r.pool.filter(now)
+
 	// more than 1 endpoint
-	// select the least connection endpoint OR
+	// select the least connection endpoint (relative to its weight) OR
 	// random one within the least connection endpoints
 	randIndices := randomize.Perm(total)
 
 	for i := 0; i < total; i++ {
 		randIdx := randIndices[i]
-		cur := r.pool.endpoints[randIdx].endpoint
+		cur := r.pool.endpoints[randIdx]
+		if !r.pool.eligible(cur, f, now) {
+			continue
+		}
 
 		// our first is the least
-		if i == 0 {
+		if selected == nil {
 			selected = cur
 			continue
 		}
 
-		if cur.Stats.NumberConnections.Count() < selected.Stats.NumberConnections.Count() {
+		if r.lessLoaded(cur, selected, now) {
 			selected = cur
 		}
 	}
-	return selected
+	return selected.endpoint
 }
 
 func (r *LeastConnection) EndpointFailed() {
 	if r.lastEndpoint != nil {
-		r.pool.endpointFailed(r.lastEndpoint)
+		r.pool.EndpointFailed(r.lastEndpoint)
 	}
 }
+
+// lessLoaded compares the connection count of two endpoints scaled by their
+// effective weights; with equal weights this is a plain connection count
+// comparison.
+// pool lock must be held
+func (r *LeastConnection) lessLoaded(a, b *endpointElem, now time.Time) bool {
+	aLoad := (a.endpoint.Stats.NumberConnections.Count() + 1) * r.pool.effectiveWeight(b, now)
+	bLoad := (b.endpoint.Stats.NumberConnections.Count() + 1) * r.pool.effectiveWeight(a, now)
+	return aLoad < bLoad
+}
@@ -123,6 +123,43 @@ var _ = Describe("LeastConnection", func() {
 				})
 			})
 		})
+
+		Context("when endpoints have weights", func() {
+			It("selects the endpoint with the fewest connections relative to its weight", func() {
+				heavy := route.NewEndpoint("", "10.0.2.1", 60000, "", "", map[string]string{"weight": "4"}, -1, "", models.ModificationTag{})
+				light := route.NewEndpoint("", "10.0.2.2", 60000, "", "", nil, -1, "", models.ModificationTag{})
+				pool.Put(heavy)
+				pool.Put(light)
+
+				iter := route.NewLeastConnection(pool, "")
+
+				setConnectionCount([]*route.Endpoint{heavy, light}, []int{2, 1})
+				Expect(iter.Next()).To(Equal(heavy))
+
+				setConnectionCount([]*route.Endpoint{heavy, light}, []int{9, 1})
+				Expect(iter.Next()).To(Equal(light))
+			})
+		})
+
+		Context("when an endpoint is in its slow-start window", func() {
+			It("does not flood the new endpoint with connections", func() {
+				pool.SetSlowStart(100 * time.Millisecond)
+				old := route.NewEndpoint("", "10.0.2.1", 60000, "", "", nil, -1, "", models.ModificationTag{})
+				pool.Put(old)
+				time.Sleep(150 * time.Millisecond)
+
+				fresh := route.NewEndpoint("", "10.0.2.2", 60000, "", "", nil, -1, "", models.ModificationTag{})
+				pool.Put(fresh)
+
+				iter := route.NewLeastConnection(pool, "")
+
+				setConnectionCount([]*route.Endpoint{old, fresh}, []int{5, 0})
+				Expect(iter.Next()).To(Equal(old))
+
+				time.Sleep(100 * time.Millisecond)
+				Expect(iter.Next()).To(Equal(fresh))
+			})
+		})
 	})
 })
 
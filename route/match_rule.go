@@ -0,0 +1,79 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// MatchHeaderTag restricts an endpoint to requests carrying a header,
+	// formatted like the header line itself: "X-Canary: true".
+	MatchHeaderTag = "match_header"
+	// MatchCookieTag restricts an endpoint to requests carrying a cookie,
+	// formatted like a cookie pair: "beta=1".
+	MatchCookieTag = "match_cookie"
+)
+
+// MatchRule is a condition on a request header or cookie. Endpoints
+// registered with a MatchRule are kept in a separate pool that is only
+// selected for requests satisfying the rule.
+type MatchRule struct {
+	Header string `json:"header,omitempty"`
+	Cookie string `json:"cookie,omitempty"`
+	Value  string `json:"value"`
+}
+
+// ParseMatchRule reads a MatchRule from registration tags. It returns nil
+// when the tags do not contain a rule.
+func ParseMatchRule(tags map[string]string) (*MatchRule, error) {
+	header, hasHeader := tags[MatchHeaderTag]
+	cookie, hasCookie := tags[MatchCookieTag]
+
+	switch {
+	case hasHeader && hasCookie:
+		return nil, fmt.Errorf("only one of %s and %s may be set", MatchHeaderTag, MatchCookieTag)
+	case hasHeader:
+		parts := strings.SplitN(header, ":", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, fmt.Errorf("invalid %s tag %q, expected 'Name: value'", MatchHeaderTag, header)
+		}
+		return &MatchRule{Header: http.CanonicalHeaderKey(name), Value: strings.TrimSpace(parts[1])}, nil
+	case hasCookie:
+		parts := strings.SplitN(cookie, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, fmt.Errorf("invalid %s tag %q, expected 'name=value'", MatchCookieTag, cookie)
+		}
+		return &MatchRule{Cookie: name, Value: strings.TrimSpace(parts[1])}, nil
+	}
+
+	return nil, nil
+}
+
+// Matches returns true if the request satisfies the rule.
+func (m MatchRule) Matches(request *http.Request) bool {
+	if m.Header != "" {
+		for _, v := range request.Header[m.Header] {
+			if v == m.Value {
+				return true
+			}
+		}
+		return false
+	}
+
+	if m.Cookie != "" {
+		c, err := request.Cookie(m.Cookie)
+		return err == nil && c.Value == m.Value
+	}
+
+	return false
+}
+
+func (m MatchRule) String() string {
+	if m.Header != "" {
+		return fmt.Sprintf("header %s: %s", m.Header, m.Value)
+	}
+	return fmt.Sprintf("cookie %s=%s", m.Cookie, m.Value)
+}
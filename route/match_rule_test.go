@@ -0,0 +1,72 @@
+package route_test
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/route"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MatchRule", func() {
+	Describe("ParseMatchRule", func() {
+		It("returns nil when no rule is tagged", func() {
+			rule, err := route.ParseMatchRule(map[string]string{"component": "foo"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rule).To(BeNil())
+		})
+
+		It("parses a header rule", func() {
+			rule, err := route.ParseMatchRule(map[string]string{"match_header": "x-canary: true"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*rule).To(Equal(route.MatchRule{Header: "X-Canary", Value: "true"}))
+		})
+
+		It("parses a cookie rule", func() {
+			rule, err := route.ParseMatchRule(map[string]string{"match_cookie": "beta=1"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*rule).To(Equal(route.MatchRule{Cookie: "beta", Value: "1"}))
+		})
+
+		It("rejects malformed rules", func() {
+			_, err := route.ParseMatchRule(map[string]string{"match_header": "X-Canary"})
+			Expect(err).To(HaveOccurred())
+
+			_, err = route.ParseMatchRule(map[string]string{"match_cookie": "=1"})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects both a header and a cookie rule", func() {
+			_, err := route.ParseMatchRule(map[string]string{"match_header": "X-Canary: true", "match_cookie": "beta=1"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Matches", func() {
+		var req *http.Request
+
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "http://example.com", nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("matches on a header value", func() {
+			rule := route.MatchRule{Header: "X-Canary", Value: "true"}
+			Expect(rule.Matches(req)).To(BeFalse())
+
+			req.Header.Set("X-Canary", "true")
+			Expect(rule.Matches(req)).To(BeTrue())
+		})
+
+		It("matches on a cookie value", func() {
+			rule := route.MatchRule{Cookie: "beta", Value: "1"}
+			req.AddCookie(&http.Cookie{Name: "beta", Value: "0"})
+			Expect(rule.Matches(req)).To(BeFalse())
+
+			req.Header.Del("Cookie")
+			req.AddCookie(&http.Cookie{Name: "beta", Value: "1"})
+			Expect(rule.Matches(req)).To(BeTrue())
+		})
+	})
+})
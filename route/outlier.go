@@ -0,0 +1,136 @@
+package route
+
+import "time"
+
+// OutlierDetection configures the passive ejection of endpoints that keep
+// failing requests. Detection is disabled when ConsecutiveErrors is zero.
+type OutlierDetection struct {
+	// ConsecutiveErrors is the number of failed requests in a row after which
+	// an endpoint is ejected.
+	ConsecutiveErrors int
+	// BaseEjectionTime is the duration of the first ejection of an endpoint.
+	// It doubles with every ejection that follows shortly after a restore.
+	BaseEjectionTime time.Duration
+	// MaxEjectionTime caps the duration of an ejection.
+	MaxEjectionTime time.Duration
+	// MaxEjectionPercent caps the share of the pool that can be ejected at
+	// the same time. One endpoint of a larger pool can always be ejected, and
+	// at least one endpoint always stays in rotation.
+	MaxEjectionPercent int
+
+	Listener OutlierListener
+}
+
+// OutlierListener is notified when endpoints are ejected from and restored to
+// rotation. It is called with the pool lock held and must not call back into
+// the pool.
+type OutlierListener interface {
+	EndpointEjected(endpoint *Endpoint, duration time.Duration)
+	EndpointRestored(endpoint *Endpoint)
+}
+
+func (p *Pool) SetOutlierDetection(od OutlierDetection) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.outlierDetection = od
+}
+
+// RecordResult feeds outlier detection with the outcome of a request to the
+// endpoint. failed should be true for 5xx responses and timeouts.
+func (p *Pool) RecordResult(endpoint *Endpoint, failed bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	od := p.outlierDetection
+	if od.ConsecutiveErrors <= 0 {
+		return
+	}
+
+	e := p.index[endpoint.CanonicalAddr()]
+	if e == nil {
+		return
+	}
+
+	if !failed {
+		e.consecutiveErrors = 0
+		return
+	}
+
+	e.consecutiveErrors++
+	now := time.Now()
+	if e.consecutiveErrors < od.ConsecutiveErrors || p.ejected(e, now) || !p.canEject(now) {
+		return
+	}
+
+	// forget earlier ejections once the endpoint stayed in rotation for
+	// longer than it was last ejected
+	if e.ejections > 0 && now.Sub(e.ejectedUntil) > p.ejectionTime(e.ejections) {
+		e.ejections = 0
+	}
+
+	e.ejections++
+	duration := p.ejectionTime(e.ejections)
+	e.ejectedUntil = now.Add(duration)
+	e.isEjected = true
+	e.consecutiveErrors = 0
+
+	if od.Listener != nil {
+		od.Listener.EndpointEjected(e.endpoint, duration)
+	}
+}
+
+// ejectionTime returns the duration of the nth ejection of an endpoint.
+// pool lock must be held
+func (p *Pool) ejectionTime(n int) time.Duration {
+	od := p.outlierDetection
+	d := od.BaseEjectionTime
+	for i := 1; i < n && (od.MaxEjectionTime <= 0 || d < od.MaxEjectionTime); i++ {
+		d *= 2
+	}
+	if od.MaxEjectionTime > 0 && d > od.MaxEjectionTime {
+		d = od.MaxEjectionTime
+	}
+	return d
+}
+
+// canEject returns true if another endpoint may be ejected without exceeding
+// MaxEjectionPercent or leaving the pool without endpoints. Small pools, whose
+// share rounds down to nothing, may still eject one endpoint.
+// pool lock must be held
+func (p *Pool) canEject(now time.Time) bool {
+	ejected := 0
+	for _, e := range p.endpoints {
+		if p.ejected(e, now) {
+			ejected++
+		}
+	}
+
+	limit := len(p.endpoints) * p.outlierDetection.MaxEjectionPercent / 100
+	if limit < 1 && p.outlierDetection.MaxEjectionPercent > 0 {
+		limit = 1
+	}
+	if limit > len(p.endpoints)-1 {
+		limit = len(p.endpoints) - 1
+	}
+	return ejected < limit
+}
+
+// ejected returns true if the endpoint is ejected, restoring it once its
+// ejection is over.
+// pool lock must be held
+func (p *Pool) ejected(e *endpointElem, now time.Time) bool {
+	if !e.isEjected {
+		return false
+	}
+
+	if now.Before(e.ejectedUntil) {
+		return true
+	}
+
+	e.isEjected = false
+	if p.outlierDetection.Listener != nil {
+		p.outlierDetection.Listener.EndpointRestored(e.endpoint)
+	}
+	return false
+}
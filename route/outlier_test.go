@@ -0,0 +1,164 @@
+package route_test
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type outlierEvents struct {
+	lock      sync.Mutex
+	ejected   []*route.Endpoint
+	durations []time.Duration
+	restored  []*route.Endpoint
+}
+
+func (o *outlierEvents) EndpointEjected(e *route.Endpoint, d time.Duration) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.ejected = append(o.ejected, e)
+	o.durations = append(o.durations, d)
+}
+
+func (o *outlierEvents) EndpointRestored(e *route.Endpoint) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.restored = append(o.restored, e)
+}
+
+var _ = Describe("OutlierDetection", func() {
+	var (
+		pool      *route.Pool
+		endpoints []*route.Endpoint
+		events    *outlierEvents
+		od        route.OutlierDetection
+	)
+
+	BeforeEach(func() {
+		pool = route.NewPool(2*time.Minute, "")
+		endpoints = nil
+		for i := 0; i < 4; i++ {
+			e := route.NewEndpoint("", fmt.Sprintf("10.0.0.%d", i), 8080, "", "", nil, -1, "", models.ModificationTag{})
+			endpoints = append(endpoints, e)
+			pool.Put(e)
+		}
+
+		events = &outlierEvents{}
+		od = route.OutlierDetection{
+			ConsecutiveErrors:  3,
+			BaseEjectionTime:   50 * time.Millisecond,
+			MaxEjectionTime:    time.Second,
+			MaxEjectionPercent: 50,
+			Listener:           events,
+		}
+	})
+
+	JustBeforeEach(func() {
+		pool.SetOutlierDetection(od)
+	})
+
+	fail := func(e *route.Endpoint, n int) {
+		for i := 0; i < n; i++ {
+			pool.RecordResult(e, true)
+		}
+	}
+
+	selected := func() map[*route.Endpoint]bool {
+		seen := make(map[*route.Endpoint]bool)
+		iter := route.NewRoundRobin(pool, "")
+		for i := 0; i < 20; i++ {
+			seen[iter.Next()] = true
+		}
+		return seen
+	}
+
+	It("ejects an endpoint after consecutive failures", func() {
+		fail(endpoints[0], 2)
+		Expect(events.ejected).To(BeEmpty())
+
+		fail(endpoints[0], 1)
+		Expect(events.ejected).To(ConsistOf(endpoints[0]))
+		Expect(events.durations).To(ConsistOf(50 * time.Millisecond))
+		Expect(selected()).ToNot(HaveKey(endpoints[0]))
+	})
+
+	It("resets the count on success", func() {
+		fail(endpoints[0], 2)
+		pool.RecordResult(endpoints[0], false)
+		fail(endpoints[0], 2)
+
+		Expect(events.ejected).To(BeEmpty())
+	})
+
+	It("restores the endpoint after the ejection time", func() {
+		fail(endpoints[0], 3)
+		time.Sleep(60 * time.Millisecond)
+
+		Expect(selected()).To(HaveKey(endpoints[0]))
+		Expect(events.restored).To(ConsistOf(endpoints[0]))
+	})
+
+	It("doubles the ejection time when the endpoint keeps failing", func() {
+		fail(endpoints[0], 3)
+		time.Sleep(60 * time.Millisecond)
+		selected()
+		fail(endpoints[0], 3)
+
+		Expect(events.durations).To(Equal([]time.Duration{50 * time.Millisecond, 100 * time.Millisecond}))
+	})
+
+	It("caps the share of the pool that is ejected", func() {
+		for _, e := range endpoints {
+			fail(e, 3)
+		}
+
+		Expect(events.ejected).To(HaveLen(2))
+		Expect(selected()).To(HaveLen(2))
+	})
+
+	It("ejects one endpoint of a pool too small for its share", func() {
+		pool = route.NewPool(2*time.Minute, "")
+		for _, e := range endpoints[:3] {
+			pool.Put(e)
+		}
+		od.MaxEjectionPercent = 10
+		pool.SetOutlierDetection(od)
+
+		for _, e := range endpoints[:3] {
+			fail(e, 3)
+		}
+
+		Expect(events.ejected).To(ConsistOf(endpoints[0]))
+		Expect(selected()).To(HaveLen(2))
+	})
+
+	It("never ejects every endpoint", func() {
+		pool = route.NewPool(2*time.Minute, "")
+		pool.Put(endpoints[0])
+		od.MaxEjectionPercent = 100
+		pool.SetOutlierDetection(od)
+
+		fail(endpoints[0], 3)
+
+		Expect(events.ejected).To(BeEmpty())
+		Expect(route.NewRoundRobin(pool, "").Next()).To(Equal(endpoints[0]))
+	})
+
+	Context("when detection is disabled", func() {
+		BeforeEach(func() {
+			od.ConsecutiveErrors = 0
+		})
+
+		It("does not eject endpoints", func() {
+			fail(endpoints[0], 10)
+
+			Expect(events.ejected).To(BeEmpty())
+			Expect(selected()).To(HaveKey(endpoints[0]))
+		})
+	})
+})
@@ -0,0 +1,166 @@
+package route
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// ewmaDecay is the time constant of the latency average: a sample loses
+// about two thirds of its influence after this long.
+const ewmaDecay = 10 * time.Second
+
+// EWMA is an exponentially weighted moving average of response latency.
+// Samples decay with their age rather than their count, and a sample above
+// the current average replaces it outright, so a backend that turns slow is
+// avoided immediately and only regains traffic as it recovers.
+type EWMA struct {
+	lock     sync.Mutex
+	value    float64
+	observed time.Time
+}
+
+func NewEWMA() *EWMA {
+	return &EWMA{}
+}
+
+func (e *EWMA) Observe(latency time.Duration) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	now := time.Now()
+	sample := float64(latency)
+
+	if e.observed.IsZero() || sample > e.value {
+		e.value = sample
+	} else {
+		w := math.Exp(-float64(now.Sub(e.observed)) / float64(ewmaDecay))
+		e.value = e.value*w + sample*(1-w)
+	}
+	e.observed = now
+}
+
+// Value returns the current average, zero if nothing was observed yet.
+func (e *EWMA) Value() time.Duration {
+	if e == nil {
+		return 0
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return time.Duration(e.value)
+}
+
+// PeakEWMA picks the cheaper of two random endpoints, where the cost of an
+// endpoint is its latency average scaled by its outstanding requests and
+// divided by its weight. Endpoints without latency samples cost nothing, so
+// new endpoints are probed right away.
+type PeakEWMA struct {
+	pool            *Pool
+	initialEndpoint string
+	lastEndpoint    *Endpoint
+
+	// started holds the start of the requests in flight by endpoint, as a
+	// hedged request is sent to two endpoints at once
+	lock    sync.Mutex
+	started map[*Endpoint]time.Time
+}
+
+func NewPeakEWMA(p *Pool, initial string) EndpointIterator {
+	return &PeakEWMA{
+		pool:            p,
+		initialEndpoint: initial,
+		started:         make(map[*Endpoint]time.Time),
+	}
+}
+
+func (r *PeakEWMA) Next() *Endpoint {
+	var e *Endpoint
+	if r.initialEndpoint != "" {
+		e = r.pool.findById(r.initialEndpoint)
+		r.initialEndpoint = ""
+	}
+
+	if e == nil {
+		e = r.next()
+	}
+
+	r.lastEndpoint = e
+	return e
+}
+
+func (r *PeakEWMA) PreRequest(e *Endpoint) {
+	e.Stats.NumberConnections.Increment()
+
+	r.lock.Lock()
+	r.started[e] = time.Now()
+	r.lock.Unlock()
+}
+
+func (r *PeakEWMA) PostRequest(e *Endpoint) {
+	e.Stats.NumberConnections.Decrement()
+
+	r.lock.Lock()
+	started, ok := r.started[e]
+	delete(r.started, e)
+	r.lock.Unlock()
+
+	if ok && e.Stats.Latency != nil {
+		e.Stats.Latency.Observe(time.Since(started))
+	}
+}
+
+func (r *PeakEWMA) next() *Endpoint {
+	r.pool.lock.Lock()
+	defer r.pool.lock.Unlock()
+
+	available := make([]*Endpoint, 0, len(r.pool.endpoints))
+	curTime := time.Now()
+	f := r.pool.filter(curTime)
+	for _, e := range r.pool.endpoints {
+		if r.pool.available(e, curTime) && r.pool.eligible(e, f, curTime) {
+			available = append(available, e.endpoint)
+		}
+	}
+
+	if len(available) == 0 {
+		// all endpoints are marked failed so reset everything to available
+		for _, e := range r.pool.endpoints {
+			e.failedAt = nil
+			if r.pool.eligible(e, f, curTime) {
+				available = append(available, e.endpoint)
+			}
+		}
+	}
+
+	switch len(available) {
+	case 0:
+		return nil
+	case 1:
+		return available[0]
+	}
+
+	i := randomize.Intn(len(available))
+	j := randomize.Intn(len(available) - 1)
+	if j >= i {
+		j++
+	}
+
+	a, b := available[i], available[j]
+	if latencyCost(b) < latencyCost(a) {
+		return b
+	}
+	return a
+}
+
+func (r *PeakEWMA) EndpointFailed() {
+	if r.lastEndpoint != nil {
+		r.pool.EndpointFailed(r.lastEndpoint)
+	}
+}
+
+func latencyCost(e *Endpoint) float64 {
+	load := float64(e.Stats.NumberConnections.Count() + 1)
+	return float64(e.Stats.Latency.Value()) * load / float64(e.weight())
+}
@@ -0,0 +1,134 @@
+package route_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EWMA", func() {
+	var ewma *route.EWMA
+
+	BeforeEach(func() {
+		ewma = route.NewEWMA()
+	})
+
+	It("is zero before any observation", func() {
+		Expect(ewma.Value()).To(BeZero())
+	})
+
+	It("starts at the first observation", func() {
+		ewma.Observe(10 * time.Millisecond)
+		Expect(ewma.Value()).To(Equal(10 * time.Millisecond))
+	})
+
+	It("jumps to a higher observation", func() {
+		ewma.Observe(10 * time.Millisecond)
+		ewma.Observe(50 * time.Millisecond)
+		Expect(ewma.Value()).To(Equal(50 * time.Millisecond))
+	})
+
+	It("decays slowly towards lower observations", func() {
+		ewma.Observe(50 * time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		ewma.Observe(10 * time.Millisecond)
+		Expect(ewma.Value()).To(BeNumerically("<", 50*time.Millisecond))
+		Expect(ewma.Value()).To(BeNumerically(">", 40*time.Millisecond))
+	})
+})
+
+var _ = Describe("PeakEWMA", func() {
+	var pool *route.Pool
+	var modTag models.ModificationTag
+
+	BeforeEach(func() {
+		pool = route.NewPool(2*time.Minute, "")
+		modTag = models.ModificationTag{}
+	})
+
+	Describe("Next", func() {
+		It("returns nil when no endpoints exist", func() {
+			iter := route.NewPeakEWMA(pool, "")
+			Expect(iter.Next()).To(BeNil())
+		})
+
+		It("returns the only endpoint", func() {
+			e := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag)
+			pool.Put(e)
+
+			iter := route.NewPeakEWMA(pool, "")
+			Expect(iter.Next()).To(Equal(e))
+		})
+
+		It("finds the initial endpoint by private id", func() {
+			b := route.NewEndpoint("", "1.2.3.4", 1235, "b", "", nil, -1, "", modTag)
+			pool.Put(route.NewEndpoint("", "1.2.3.4", 1234, "a", "", nil, -1, "", modTag))
+			pool.Put(b)
+
+			iter := route.NewPeakEWMA(pool, "b")
+			Expect(iter.Next()).To(Equal(b))
+		})
+
+		It("prefers the faster of two endpoints", func() {
+			slow := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag)
+			fast := route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", modTag)
+			slow.Stats.Latency.Observe(500 * time.Millisecond)
+			fast.Stats.Latency.Observe(5 * time.Millisecond)
+			pool.Put(slow)
+			pool.Put(fast)
+
+			iter := route.NewPeakEWMA(pool, "")
+			for i := 0; i < 10; i++ {
+				Expect(iter.Next()).To(Equal(fast))
+			}
+		})
+
+		It("accounts for outstanding requests", func() {
+			busy := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag)
+			idle := route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", modTag)
+			busy.Stats.Latency.Observe(10 * time.Millisecond)
+			idle.Stats.Latency.Observe(20 * time.Millisecond)
+			for i := 0; i < 5; i++ {
+				busy.Stats.NumberConnections.Increment()
+			}
+			pool.Put(busy)
+			pool.Put(idle)
+
+			iter := route.NewPeakEWMA(pool, "")
+			Expect(iter.Next()).To(Equal(idle))
+		})
+
+		It("skips failed endpoints", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag)
+			e2 := route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", modTag)
+			e1.Stats.Latency.Observe(5 * time.Millisecond)
+			e2.Stats.Latency.Observe(500 * time.Millisecond)
+			pool.Put(e1)
+			pool.Put(e2)
+
+			iter := route.NewPeakEWMA(pool, "")
+			Expect(iter.Next()).To(Equal(e1))
+			iter.EndpointFailed()
+			Expect(iter.Next()).To(Equal(e2))
+		})
+	})
+
+	Describe("PreRequest and PostRequest", func() {
+		It("track connections and observe the latency of the request", func() {
+			e := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag)
+			pool.Put(e)
+
+			iter := route.NewPeakEWMA(pool, "")
+			iter.PreRequest(e)
+			Expect(e.Stats.NumberConnections.Count()).To(BeEquivalentTo(1))
+
+			time.Sleep(10 * time.Millisecond)
+			iter.PostRequest(e)
+			Expect(e.Stats.NumberConnections.Count()).To(BeEquivalentTo(0))
+			Expect(e.Stats.Latency.Value()).To(BeNumerically(">=", 10*time.Millisecond))
+		})
+	})
+})
@@ -3,6 +3,8 @@ package route
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,6 +15,25 @@ import (
 	"code.cloudfoundry.org/routing-api/models"
 )
 
+const (
+	// WeightTag is the registration tag used to set the relative share of
+	// traffic an endpoint receives within its pool.
+	WeightTag     = "weight"
+	DefaultWeight = 1
+
+	// LoadBalancingAlgorithmTag overrides the router-wide balancing algorithm
+	// for the route an endpoint is registered on.
+	LoadBalancingAlgorithmTag = "balancing_algorithm"
+
+	// weightScale is the fixed point precision of effective weights, which
+	// are reduced for endpoints in their slow-start window.
+	weightScale = 100
+
+	// ZoneTag is the registration tag naming the availability zone of an
+	// endpoint.
+	ZoneTag = "zone"
+)
+
 type Counter struct {
 	value int64
 }
@@ -33,11 +54,13 @@ func (c *Counter) Count() int64 {
 
 type Stats struct {
 	NumberConnections *Counter
+	Latency           *EWMA
 }
 
 func NewStats() *Stats {
 	return &Stats{
 		NumberConnections: &Counter{},
+		Latency:           NewEWMA(),
 	}
 }
 
@@ -51,6 +74,25 @@ type Endpoint struct {
 	PrivateInstanceIndex string
 	ModificationTag      models.ModificationTag
 	Stats                *Stats
+	Weight               int
+	MatchRule            *MatchRule
+	Zone                 string
+
+	LoadBalancingAlgorithm string
+	MaxConcurrentRequests  int
+	RetryPolicy            *RetryPolicy
+	HedgePolicy            *HedgePolicy
+	BackendProtocol        string
+	HeaderRules            []config.HeaderRule
+	Compression            *bool
+	Cache                  bool
+	RateLimit              *RateLimit
+	MaxRequestBodySize     int64
+	// ServerCertDomainSAN is the name the certificate of an endpoint
+	// registered with a TLS port is verified against.
+	ServerCertDomainSAN string
+
+	health *HealthTable
 }
 
 //go:generate counterfeiter -o fakes/fake_endpoint_iterator.go . EndpointIterator
@@ -62,10 +104,23 @@ type EndpointIterator interface {
 }
 
 type endpointElem struct {
-	endpoint *Endpoint
-	index    int
-	updated  time.Time
-	failedAt *time.Time
+	endpoint      *Endpoint
+	index         int
+	updated       time.Time
+	failedAt      *time.Time
+	currentWeight int64
+	// firstSeen is the time the endpoint was added to the pool; unlike
+	// updated it is not reset by heartbeats.
+	firstSeen time.Time
+
+	// outlier detection state
+	consecutiveErrors int
+	ejections         int
+	ejectedUntil      time.Time
+	isEjected         bool
+
+	// requests is the number of requests in flight to the endpoint
+	requests int
 }
 
 type Pool struct {
@@ -74,7 +129,40 @@ type Pool struct {
 	index     map[string]*endpointElem
 
 	contextPath     string
+	routeKey        Uri
 	routeServiceUrl string
+	matchRule       *MatchRule
+
+	loadBalancingAlgorithm string
+	ring                   hashRing
+
+	zone                   string
+	zoneSpilloverThreshold float64
+	slowStart              time.Duration
+	outlierDetection       OutlierDetection
+	health                 *HealthTable
+
+	circuitBreaker CircuitBreaker
+	// maxRequests overrides circuitBreaker.MaxConcurrentRequests if set
+	maxRequests int
+	pending     int
+	released    chan struct{}
+
+	// retryPolicy overrides the router-wide retry policy if set
+	retryPolicy *RetryPolicy
+
+	headerRules []config.HeaderRule
+	// compression overrides the router-wide compression setting if set
+	compression *bool
+	cache       bool
+	// rateLimit overrides the router-wide rate limit if set
+	rateLimit *RateLimit
+	// maxRequestBodySize overrides the router-wide maximum if positive
+	maxRequestBodySize int64
+
+	hedgePolicy *HedgePolicy
+	latencies   []time.Duration
+	nextLatency int
 
 	retryAfterFailure time.Duration
 	nextIdx           int
@@ -92,7 +180,39 @@ func NewEndpoint(appId, host string, port uint16, privateInstanceId string, priv
 		RouteServiceUrl:      routeServiceUrl,
 		ModificationTag:      modificationTag,
 		Stats:                NewStats(),
+		Weight:               parseWeight(tags),
+		MatchRule:            parseMatchRule(tags),
+		Zone:                 tags[ZoneTag],
+
+		LoadBalancingAlgorithm: tags[LoadBalancingAlgorithmTag],
+		MaxConcurrentRequests:  parseMaxConcurrentRequests(tags),
+		RetryPolicy:            parseRetryPolicy(tags),
+		HedgePolicy:            parseHedgePolicy(tags),
+		BackendProtocol:        parseBackendProtocol(tags),
+		HeaderRules:            parseHeaderRules(tags),
+		Compression:            parseCompression(tags),
+		Cache:                  parseCache(tags),
+		RateLimit:              parseRateLimit(tags),
+		MaxRequestBodySize:     parseMaxRequestBodySize(tags),
+	}
+}
+
+// parseMatchRule ignores invalid rules; registrations are validated before
+// endpoints are created.
+func parseMatchRule(tags map[string]string) *MatchRule {
+	rule, err := ParseMatchRule(tags)
+	if err != nil {
+		return nil
 	}
+	return rule
+}
+
+func parseWeight(tags map[string]string) int {
+	weight, err := strconv.Atoi(tags[WeightTag])
+	if err != nil || weight < 1 {
+		return DefaultWeight
+	}
+	return weight
 }
 
 func NewPool(retryAfterFailure time.Duration, contextPath string) *Pool {
@@ -105,10 +225,70 @@ func NewPool(retryAfterFailure time.Duration, contextPath string) *Pool {
 	}
 }
 
+// NewConditionalPool returns a pool that only serves requests satisfying the
+// given rule.
+func NewConditionalPool(retryAfterFailure time.Duration, contextPath string, rule MatchRule) *Pool {
+	p := NewPool(retryAfterFailure, contextPath)
+	p.matchRule = &rule
+	return p
+}
+
 func (p *Pool) ContextPath() string {
 	return p.contextPath
 }
 
+// SetRouteKey records the route the pool is registered on.
+func (p *Pool) SetRouteKey(uri Uri) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.routeKey = uri
+}
+
+// RouteKey returns the route the pool is registered on, which endpoints of
+// the pool are unregistered from.
+func (p *Pool) RouteKey() Uri {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.routeKey
+}
+
+// MatchRule returns the rule of a conditional pool, nil otherwise.
+func (p *Pool) MatchRule() *MatchRule {
+	return p.matchRule
+}
+
+// PreferZone makes the load balancers of the pool select endpoints of the
+// given zone. Other zones are used when no local endpoint is available, or
+// when local endpoints make up less than spilloverThreshold of the available
+// endpoints.
+func (p *Pool) PreferZone(zone string, spilloverThreshold float64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.zone = zone
+	p.zoneSpilloverThreshold = spilloverThreshold
+}
+
+// SetSlowStart makes newly added endpoints receive a share of traffic that
+// grows linearly from almost nothing to their full share over window.
+func (p *Pool) SetSlowStart(window time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.slowStart = window
+}
+
+// IsCrossZone returns true if the endpoint is known to be in a different
+// zone than the one preferred by the pool.
+func (p *Pool) IsCrossZone(endpoint *Endpoint) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.zone != "" && endpoint.Zone != "" && endpoint.Zone != p.zone
+}
+
 // Returns true if endpoint was added or updated, false otherwise
 func (p *Pool) Put(endpoint *Endpoint) bool {
 	p.lock.Lock()
@@ -122,8 +302,14 @@ func (p *Pool) Put(endpoint *Endpoint) bool {
 			}
 
 			oldEndpoint := e.endpoint
+			// the stats belong to the instance, not to its registration
+			endpoint.Stats = oldEndpoint.Stats
 			e.endpoint = endpoint
 
+			if p.ring != nil && oldEndpoint.weight() != endpoint.weight() {
+				p.ring = p.ring.remove(e).add(e)
+			}
+
 			if oldEndpoint.PrivateInstanceId != endpoint.PrivateInstanceId {
 				delete(p.index, oldEndpoint.PrivateInstanceId)
 				p.index[endpoint.PrivateInstanceId] = e
@@ -131,21 +317,90 @@ func (p *Pool) Put(endpoint *Endpoint) bool {
 		}
 	} else {
 		e = &endpointElem{
-			endpoint: endpoint,
-			index:    len(p.endpoints),
+			endpoint:  endpoint,
+			index:     len(p.endpoints),
+			firstSeen: time.Now(),
 		}
 
 		p.endpoints = append(p.endpoints, e)
 
 		p.index[endpoint.CanonicalAddr()] = e
 		p.index[endpoint.PrivateInstanceId] = e
+
+		if p.ring != nil {
+			p.ring = p.ring.add(e)
+		}
+	}
+
+	if p.health != nil {
+		endpoint.setHealthTable(p.health)
 	}
 
 	e.updated = time.Now()
+	p.applyOverrides()
 
 	return true
 }
 
+// applyOverrides sets the overrides of the route from the registrations of
+// its endpoints. An override only applies while every endpoint carries the
+// same value, so that a route whose instances disagree, e.g. during a
+// rolling deploy, keeps the router-wide defaults instead of following
+// whichever instance registered last.
+func (p *Pool) applyOverrides() {
+	p.loadBalancingAlgorithm = ""
+	p.maxRequests = 0
+	p.retryPolicy = nil
+	p.hedgePolicy = nil
+	p.headerRules = nil
+	p.compression = nil
+	p.cache = false
+	p.rateLimit = nil
+	p.maxRequestBodySize = 0
+
+	if len(p.endpoints) == 0 {
+		return
+	}
+
+	first := p.endpoints[0].endpoint
+	agree := func(value func(e *Endpoint) interface{}) bool {
+		for _, e := range p.endpoints[1:] {
+			if !reflect.DeepEqual(value(e.endpoint), value(first)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if agree(func(e *Endpoint) interface{} { return e.LoadBalancingAlgorithm }) {
+		p.loadBalancingAlgorithm = first.LoadBalancingAlgorithm
+	}
+	if agree(func(e *Endpoint) interface{} { return e.MaxConcurrentRequests }) {
+		p.maxRequests = first.MaxConcurrentRequests
+	}
+	if agree(func(e *Endpoint) interface{} { return e.RetryPolicy }) {
+		p.retryPolicy = first.RetryPolicy
+	}
+	if agree(func(e *Endpoint) interface{} { return e.HedgePolicy }) {
+		p.hedgePolicy = first.HedgePolicy
+	}
+	if agree(func(e *Endpoint) interface{} { return e.HeaderRules }) {
+		p.headerRules = first.HeaderRules
+	}
+	if agree(func(e *Endpoint) interface{} { return e.Compression }) {
+		p.compression = first.Compression
+	}
+	if agree(func(e *Endpoint) interface{} { return e.Cache }) {
+		p.cache = first.Cache
+	}
+	if agree(func(e *Endpoint) interface{} { return e.RateLimit }) {
+		p.rateLimit = first.RateLimit
+	}
+	if agree(func(e *Endpoint) interface{} { return e.MaxRequestBodySize }) {
+		p.maxRequestBodySize = first.MaxRequestBodySize
+	}
+}
+
 func (p *Pool) RouteServiceUrl() string {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -158,6 +413,17 @@ func (p *Pool) RouteServiceUrl() string {
 	}
 }
 
+// ApplicationId returns the app the endpoints of the pool belong to.
+func (p *Pool) ApplicationId() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if len(p.endpoints) > 0 {
+		return p.endpoints[0].endpoint.ApplicationId
+	}
+	return ""
+}
+
 func (p *Pool) PruneEndpoints(defaultThreshold time.Duration) []*Endpoint {
 	p.lock.Lock()
 
@@ -218,12 +484,58 @@ func (p *Pool) removeEndpoint(e *endpointElem) {
 
 	delete(p.index, e.endpoint.CanonicalAddr())
 	delete(p.index, e.endpoint.PrivateInstanceId)
+
+	if p.ring != nil {
+		p.ring = p.ring.remove(e)
+	}
+
+	p.applyOverrides()
+}
+
+// hashRing returns the consistent-hash ring of the pool, building it on
+// first use.
+// pool lock must be held
+func (p *Pool) hashRing() hashRing {
+	if p.ring == nil {
+		ring := hashRing{}
+		for _, e := range p.endpoints {
+			ring = ring.add(e)
+		}
+		p.ring = ring
+	}
+	return p.ring
 }
 
+// LoadBalancingAlgorithm returns the algorithm requested by the endpoints of
+// the pool, or an empty string if they did not request one.
+func (p *Pool) LoadBalancingAlgorithm() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.loadBalancingAlgorithm
+}
+
+// Endpoints returns an iterator using the algorithm of the pool, falling back
+// to defaultLoadBalance when the pool does not specify one.
 func (p *Pool) Endpoints(defaultLoadBalance, initial string) EndpointIterator {
-	switch defaultLoadBalance {
+	return p.EndpointsWithHashKey(defaultLoadBalance, initial, "")
+}
+
+// EndpointsWithHashKey is like Endpoints, and additionally passes the request
+// hash key to the consistent-hash iterator.
+func (p *Pool) EndpointsWithHashKey(defaultLoadBalance, initial, hashKey string) EndpointIterator {
+	loadBalance := p.LoadBalancingAlgorithm()
+	if loadBalance == "" {
+		loadBalance = defaultLoadBalance
+	}
+
+	switch loadBalance {
 	case config.LOAD_BALANCE_LC:
 		return NewLeastConnection(p, initial)
+	case config.LOAD_BALANCE_CH:
+		return NewConsistentHash(p, initial, hashKey)
+	case config.LOAD_BALANCE_EWMA:
+		return NewPeakEWMA(p, initial)
 	default:
 		return NewRoundRobin(p, initial)
 	}
@@ -257,7 +569,9 @@ func (p *Pool) MarkUpdated(t time.Time) {
 	p.lock.Unlock()
 }
 
-func (p *Pool) endpointFailed(endpoint *Endpoint) {
+// EndpointFailed marks the endpoint failed, so that iterators of the pool
+// skip it until the failure expires.
+func (p *Pool) EndpointFailed(endpoint *Endpoint) {
 	p.lock.Lock()
 	e := p.index[endpoint.CanonicalAddr()]
 	if e != nil {
@@ -266,6 +580,133 @@ func (p *Pool) endpointFailed(endpoint *Endpoint) {
 	p.lock.Unlock()
 }
 
+// filter restricts the endpoints an iterator may select. A restriction is
+// only applied when some endpoint satisfies it, so that an iterator always
+// finds an endpoint once failures are reset.
+type filter struct {
+	skipEjected  bool
+	healthyOnly  bool
+	withCapacity bool
+	localOnly    bool
+}
+
+// filter returns the restrictions for the next selection.
+// pool lock must be held
+func (p *Pool) filter(now time.Time) filter {
+	var f filter
+
+	for _, e := range p.endpoints {
+		if !p.ejected(e, now) {
+			f.skipEjected = true
+			break
+		}
+	}
+
+	if p.health != nil {
+		for _, e := range p.endpoints {
+			if (!f.skipEjected || !p.ejected(e, now)) && p.healthy(e) {
+				f.healthyOnly = true
+				break
+			}
+		}
+	}
+
+	if p.requestLimit() > 0 {
+		for _, e := range p.endpoints {
+			if p.eligible(e, f, now) && p.hasCapacity(e) {
+				f.withCapacity = true
+				break
+			}
+		}
+	}
+
+	f.localOnly = p.localOnly(f, now)
+	return f
+}
+
+// localOnly returns true if selection should be restricted to endpoints of
+// the preferred zone.
+// pool lock must be held
+func (p *Pool) localOnly(f filter, now time.Time) bool {
+	if p.zone == "" {
+		return false
+	}
+
+	local, available := 0, 0
+	for _, e := range p.endpoints {
+		if !p.available(e, now) || !p.eligible(e, f, now) {
+			continue
+		}
+		available++
+		if e.endpoint.Zone == p.zone {
+			local++
+		}
+	}
+
+	if local == 0 {
+		return false
+	}
+	return float64(local)/float64(available) >= p.zoneSpilloverThreshold
+}
+
+// available clears an expired failure and returns true if the endpoint is not
+// marked failed.
+// pool lock must be held
+func (p *Pool) available(e *endpointElem, now time.Time) bool {
+	if e.failedAt != nil && now.Sub(*e.failedAt) > p.retryAfterFailure {
+		// expired failure window
+		e.failedAt = nil
+	}
+	return e.failedAt == nil
+}
+
+// eligible returns true if the endpoint passes the filter.
+// pool lock must be held
+func (p *Pool) eligible(e *endpointElem, f filter, now time.Time) bool {
+	if f.skipEjected && p.ejected(e, now) {
+		return false
+	}
+	if f.healthyOnly && !p.healthy(e) {
+		return false
+	}
+	if f.withCapacity && !p.hasCapacity(e) {
+		return false
+	}
+	return !f.localOnly || e.endpoint.Zone == p.zone
+}
+
+// weighted returns true if any endpoint carries a non-default weight or is
+// in its slow-start window.
+// pool lock must be held
+func (p *Pool) weighted(now time.Time) bool {
+	for _, e := range p.endpoints {
+		if e.endpoint.weight() != DefaultWeight || p.warming(e, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// warming returns true if the endpoint is in its slow-start window.
+// pool lock must be held
+func (p *Pool) warming(e *endpointElem, now time.Time) bool {
+	return p.slowStart > 0 && now.Sub(e.firstSeen) < p.slowStart
+}
+
+// effectiveWeight returns the weight of the endpoint in units of weightScale,
+// reduced in proportion to the elapsed part of its slow-start window.
+// pool lock must be held
+func (p *Pool) effectiveWeight(e *endpointElem, now time.Time) int64 {
+	weight := int64(e.endpoint.weight()) * weightScale
+	if p.warming(e, now) {
+		weight = weight * int64(now.Sub(e.firstSeen)) / int64(p.slowStart)
+		if weight < 1 {
+			weight = 1
+		}
+	}
+	return weight
+}
+
 func (p *Pool) Each(f func(endpoint *Endpoint)) {
 	p.lock.Lock()
 	for _, e := range p.endpoints {
@@ -296,12 +737,26 @@ func (e *Endpoint) MarshalJSON() ([]byte, error) {
 		TTL             int               `json:"ttl"`
 		RouteServiceUrl string            `json:"route_service_url,omitempty"`
 		Tags            map[string]string `json:"tags"`
+		Weight          int               `json:"weight"`
+		Match           *MatchRule        `json:"match,omitempty"`
+		Zone            string            `json:"zone,omitempty"`
+		Health          string            `json:"health,omitempty"`
+		LatencyEWMA     float64           `json:"latency_ewma_ms,omitempty"`
+		ServerCertName  string            `json:"server_cert_domain_san,omitempty"`
 	}
 
 	jsonObj.Address = e.addr
 	jsonObj.RouteServiceUrl = e.RouteServiceUrl
 	jsonObj.TTL = int(e.staleThreshold.Seconds())
 	jsonObj.Tags = e.Tags
+	jsonObj.Weight = e.weight()
+	jsonObj.Match = e.MatchRule
+	jsonObj.Zone = e.Zone
+	jsonObj.Health = e.HealthState()
+	jsonObj.ServerCertName = e.ServerCertDomainSAN
+	if e.Stats != nil {
+		jsonObj.LatencyEWMA = e.Stats.Latency.Value().Seconds() * 1000
+	}
 	return json.Marshal(jsonObj)
 }
 
@@ -309,6 +764,13 @@ func (e *Endpoint) CanonicalAddr() string {
 	return e.addr
 }
 
+func (e *Endpoint) weight() int {
+	if e.Weight < 1 {
+		return DefaultWeight
+	}
+	return e.Weight
+}
+
 func (rm *Endpoint) Component() string {
 	return rm.Tags["component"]
 }
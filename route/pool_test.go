@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/routing-api/models"
 	. "github.com/onsi/ginkgo"
@@ -340,6 +341,122 @@ var _ = Describe("Pool", func() {
 		})
 	})
 
+	Context("Endpoints", func() {
+		It("uses the default load balancing algorithm", func() {
+			pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag))
+
+			Expect(pool.LoadBalancingAlgorithm()).To(BeEmpty())
+			Expect(pool.Endpoints(config.LOAD_BALANCE_RR, "")).To(BeAssignableToTypeOf(&route.RoundRobin{}))
+			Expect(pool.Endpoints(config.LOAD_BALANCE_LC, "")).To(BeAssignableToTypeOf(&route.LeastConnection{}))
+			Expect(pool.Endpoints(config.LOAD_BALANCE_CH, "")).To(BeAssignableToTypeOf(&route.ConsistentHash{}))
+			Expect(pool.Endpoints(config.LOAD_BALANCE_EWMA, "")).To(BeAssignableToTypeOf(&route.PeakEWMA{}))
+		})
+
+		Context("when an endpoint requests a load balancing algorithm", func() {
+			It("overrides the default load balancing algorithm", func() {
+				tags := map[string]string{"balancing_algorithm": config.LOAD_BALANCE_LC}
+				pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", tags, -1, "", modTag))
+
+				Expect(pool.LoadBalancingAlgorithm()).To(Equal(config.LOAD_BALANCE_LC))
+				Expect(pool.Endpoints(config.LOAD_BALANCE_RR, "")).To(BeAssignableToTypeOf(&route.LeastConnection{}))
+			})
+
+			It("follows the most recent registration", func() {
+				tags := map[string]string{"balancing_algorithm": config.LOAD_BALANCE_LC}
+				pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", tags, -1, "", modTag))
+				pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag))
+
+				Expect(pool.Endpoints(config.LOAD_BALANCE_RR, "")).To(BeAssignableToTypeOf(&route.RoundRobin{}))
+			})
+
+			It("keeps the default algorithm while the endpoints disagree", func() {
+				tags := map[string]string{"balancing_algorithm": config.LOAD_BALANCE_LC}
+				oldEndpoint := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag)
+				pool.Put(oldEndpoint)
+				pool.Put(route.NewEndpoint("", "1.2.3.5", 5678, "", "", tags, -1, "", modTag))
+
+				// heartbeats of either endpoint do not flip the algorithm
+				pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag))
+				Expect(pool.LoadBalancingAlgorithm()).To(BeEmpty())
+				pool.Put(route.NewEndpoint("", "1.2.3.5", 5678, "", "", tags, -1, "", modTag))
+				Expect(pool.LoadBalancingAlgorithm()).To(BeEmpty())
+
+				pool.Remove(oldEndpoint)
+				Expect(pool.LoadBalancingAlgorithm()).To(Equal(config.LOAD_BALANCE_LC))
+			})
+		})
+	})
+
+	Context("PreferZone", func() {
+		var local1, local2, remote *route.Endpoint
+
+		BeforeEach(func() {
+			local1 = route.NewEndpoint("", "1.2.3.4", 5678, "", "", map[string]string{"zone": "z1"}, -1, "", modTag)
+			local2 = route.NewEndpoint("", "1.2.3.5", 5678, "", "", map[string]string{"zone": "z1"}, -1, "", modTag)
+			remote = route.NewEndpoint("", "5.6.7.8", 5678, "", "", map[string]string{"zone": "z2"}, -1, "", modTag)
+			pool.Put(local1)
+			pool.Put(local2)
+			pool.Put(remote)
+			pool.PreferZone("z1", 0)
+		})
+
+		It("reads the zone of an endpoint from its tags", func() {
+			Expect(local1.Zone).To(Equal("z1"))
+			Expect(remote.Zone).To(Equal("z2"))
+		})
+
+		It("knows which endpoints are in another zone", func() {
+			Expect(pool.IsCrossZone(local1)).To(BeFalse())
+			Expect(pool.IsCrossZone(remote)).To(BeTrue())
+			Expect(pool.IsCrossZone(route.NewEndpoint("", "9.9.9.9", 5678, "", "", nil, -1, "", modTag))).To(BeFalse())
+		})
+
+		It("selects endpoints of the local zone", func() {
+			for _, lb := range []string{config.LOAD_BALANCE_RR, config.LOAD_BALANCE_LC, config.LOAD_BALANCE_CH, config.LOAD_BALANCE_EWMA} {
+				for i := 0; i < 20; i++ {
+					iter := pool.EndpointsWithHashKey(lb, "", fmt.Sprintf("key-%d", i))
+					Expect(iter.Next().Zone).To(Equal("z1"), lb)
+				}
+			}
+		})
+
+		It("spills over when all local endpoints are failed", func() {
+			iter := pool.Endpoints(config.LOAD_BALANCE_RR, "")
+			for i := 0; i < 2; i++ {
+				Expect(iter.Next().Zone).To(Equal("z1"))
+				iter.EndpointFailed()
+			}
+
+			Expect(iter.Next()).To(Equal(remote))
+		})
+
+		It("spills over when the local share is below the threshold", func() {
+			pool.PreferZone("z1", 0.6)
+			iter := pool.Endpoints(config.LOAD_BALANCE_RR, "")
+			Expect(iter.Next().Zone).To(Equal("z1"))
+			iter.EndpointFailed()
+
+			zones := make(map[string]int)
+			for i := 0; i < 10; i++ {
+				zones[iter.Next().Zone]++
+			}
+			Expect(zones).To(HaveKeyWithValue("z1", 5))
+			Expect(zones).To(HaveKeyWithValue("z2", 5))
+		})
+
+		It("does not prefer a zone when none is configured", func() {
+			pool.PreferZone("", 0)
+			Expect(pool.IsCrossZone(remote)).To(BeFalse())
+
+			iter := pool.Endpoints(config.LOAD_BALANCE_RR, "")
+			zones := make(map[string]int)
+			for i := 0; i < 3; i++ {
+				zones[iter.Next().Zone]++
+			}
+			Expect(zones).To(HaveKey("z2"))
+		})
+	})
+
 	Context("Stats", func() {
 		Context("NumberConnections", func() {
 			It("increments number of connections", func() {
@@ -368,6 +485,20 @@ var _ = Describe("Pool", func() {
 				Expect(e1.Stats.NumberConnections.Count()).To(Equal(int64(0)))
 			})
 		})
+
+		It("keeps the stats of an endpoint registered again", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag)
+			pool.Put(e1)
+			e1.Stats.Latency.Observe(200 * time.Millisecond)
+			e1.Stats.NumberConnections.Increment()
+
+			modTag2 := models.ModificationTag{Guid: modTag.Guid, Index: modTag.Index + 1}
+			e2 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag2)
+			Expect(pool.Put(e2)).To(BeTrue())
+
+			Expect(e2.Stats.Latency.Value()).To(Equal(200 * time.Millisecond))
+			Expect(e2.Stats.NumberConnections.Count()).To(Equal(int64(1)))
+		})
 	})
 
 	It("marshals json", func() {
@@ -379,7 +510,7 @@ var _ = Describe("Pool", func() {
 		json, err := pool.MarshalJSON()
 		Expect(err).ToNot(HaveOccurred())
 
-		Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","ttl":-1,"route_service_url":"https://my-rs.com","tags":null},{"address":"5.6.7.8:5678","ttl":-1,"tags":null}]`))
+		Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","ttl":-1,"route_service_url":"https://my-rs.com","tags":null,"weight":1},{"address":"5.6.7.8:5678","ttl":-1,"tags":null,"weight":1}]`))
 	})
 
 	Context("when endpoints do not have empty tags", func() {
@@ -393,7 +524,33 @@ var _ = Describe("Pool", func() {
 			pool.Put(e)
 			json, err := pool.MarshalJSON()
 			Expect(err).ToNot(HaveOccurred())
-			Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","ttl":-1,"route_service_url":"https://my-rs.com","tags":{"some-key":"some-value"}}]`))
+			Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","ttl":-1,"route_service_url":"https://my-rs.com","tags":{"some-key":"some-value"},"weight":1}]`))
+		})
+	})
+
+	Context("when endpoints have a weight tag", func() {
+		It("marshals the weight", func() {
+			e := route.NewEndpoint("", "1.2.3.4", 5678, "", "", map[string]string{"weight": "5"}, -1, "", modTag)
+			pool.Put(e)
+			json, err := pool.MarshalJSON()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","ttl":-1,"tags":{"weight":"5"},"weight":5}]`))
+		})
+
+		It("falls back to the default weight when the tag is invalid", func() {
+			e := route.NewEndpoint("", "1.2.3.4", 5678, "", "", map[string]string{"weight": "-3"}, -1, "", modTag)
+			Expect(e.Weight).To(Equal(route.DefaultWeight))
+		})
+	})
+
+	Context("when endpoints have served requests", func() {
+		It("marshals the latency average once requests were observed", func() {
+			e := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag)
+			e.Stats.Latency.Observe(1500 * time.Microsecond)
+			pool.Put(e)
+			json, err := pool.MarshalJSON()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","ttl":-1,"tags":null,"weight":1,"latency_ewma_ms":1.5}]`))
 		})
 	})
 
@@ -407,7 +564,7 @@ var _ = Describe("Pool", func() {
 			pool.Put(e)
 			json, err := pool.MarshalJSON()
 			Expect(err).ToNot(HaveOccurred())
-			Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","ttl":-1,"route_service_url":"https://my-rs.com","tags":{}}]`))
+			Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","ttl":-1,"route_service_url":"https://my-rs.com","tags":{},"weight":1}]`))
 		})
 	})
 })
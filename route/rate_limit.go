@@ -0,0 +1,113 @@
+package route
+
+import (
+	"fmt"
+	"strconv"
+
+	"code.cloudfoundry.org/gorouter/config"
+)
+
+const (
+	// RateLimitTag overrides the router-wide rate of requests per second for
+	// the route an endpoint is registered on. A value of "off" disables rate
+	// limiting for the route.
+	RateLimitTag = "rate_limit"
+	// RateLimitBurstTag overrides the router-wide burst of requests.
+	RateLimitBurstTag = "rate_limit_burst"
+	// RateLimitKeyTag overrides what requests are counted by, one of
+	// config.RateLimitKeys.
+	RateLimitKeyTag = "rate_limit_key"
+)
+
+// RateLimit bounds the rate of the requests of each key with a token bucket.
+type RateLimit struct {
+	// Rate is the number of requests per second allowed, and Burst the
+	// number allowed at once.
+	Rate  float64
+	Burst int
+	// Key is one of config.RateLimitKeys.
+	Key string
+	// Disabled turns rate limiting off whatever the rate.
+	Disabled bool
+}
+
+// ParseRateLimit reads the rate limit override of a route from registration
+// tags. It returns nil when the tags do not contain one. Fields that are not
+// overridden are left empty.
+func ParseRateLimit(tags map[string]string) (*RateLimit, error) {
+	rate, hasRate := tags[RateLimitTag]
+	burst, hasBurst := tags[RateLimitBurstTag]
+	key, hasKey := tags[RateLimitKeyTag]
+	if !hasRate && !hasBurst && !hasKey {
+		return nil, nil
+	}
+
+	limit := &RateLimit{}
+	if hasRate {
+		if rate == "off" {
+			limit.Disabled = true
+		} else {
+			r, err := strconv.ParseFloat(rate, 64)
+			if err != nil || r <= 0 {
+				return nil, fmt.Errorf("invalid %s tag %q, expected a positive number or off", RateLimitTag, rate)
+			}
+			limit.Rate = r
+		}
+	}
+
+	if hasBurst {
+		b, err := strconv.Atoi(burst)
+		if err != nil || b < 1 {
+			return nil, fmt.Errorf("invalid %s tag %q, expected a positive number", RateLimitBurstTag, burst)
+		}
+		limit.Burst = b
+	}
+
+	if hasKey {
+		if !config.IsRateLimitKeyValid(key) {
+			return nil, fmt.Errorf("invalid %s tag %q, allowed values are %s", RateLimitKeyTag, key, config.RateLimitKeys)
+		}
+		limit.Key = key
+	}
+
+	return limit, nil
+}
+
+// parseRateLimit ignores invalid overrides; registrations are validated
+// before endpoints are created.
+func parseRateLimit(tags map[string]string) *RateLimit {
+	limit, err := ParseRateLimit(tags)
+	if err != nil {
+		return nil
+	}
+	return limit
+}
+
+// RateLimit returns the rate limit of the pool: defaultLimit with the
+// overrides of the endpoints of the pool applied.
+func (p *Pool) RateLimit(defaultLimit RateLimit) RateLimit {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	limit := defaultLimit
+	if p.rateLimit != nil {
+		if p.rateLimit.Disabled {
+			limit.Disabled = true
+		}
+		if p.rateLimit.Rate > 0 {
+			limit.Rate = p.rateLimit.Rate
+		}
+		if p.rateLimit.Burst > 0 {
+			limit.Burst = p.rateLimit.Burst
+		}
+		if p.rateLimit.Key != "" {
+			limit.Key = p.rateLimit.Key
+		}
+	}
+	return limit
+}
+
+// Enabled returns true if requests are limited.
+func (r RateLimit) Enabled() bool {
+	return !r.Disabled && r.Rate > 0
+}
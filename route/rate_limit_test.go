@@ -0,0 +1,92 @@
+package route_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RateLimit", func() {
+	Describe("ParseRateLimit", func() {
+		It("returns nil without rate limit tags", func() {
+			limit, err := route.ParseRateLimit(map[string]string{"component": "x"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(limit).To(BeNil())
+		})
+
+		It("parses the rate, burst and key", func() {
+			limit, err := route.ParseRateLimit(map[string]string{
+				route.RateLimitTag:      "2.5",
+				route.RateLimitBurstTag: "10",
+				route.RateLimitKeyTag:   "route",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(limit).To(Equal(&route.RateLimit{Rate: 2.5, Burst: 10, Key: config.RATE_LIMIT_KEY_ROUTE}))
+		})
+
+		It("disables the limit with off", func() {
+			limit, err := route.ParseRateLimit(map[string]string{route.RateLimitTag: "off"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(limit).To(Equal(&route.RateLimit{Disabled: true}))
+		})
+
+		It("rejects invalid values", func() {
+			_, err := route.ParseRateLimit(map[string]string{route.RateLimitTag: "0"})
+			Expect(err).To(HaveOccurred())
+
+			_, err = route.ParseRateLimit(map[string]string{route.RateLimitBurstTag: "many"})
+			Expect(err).To(HaveOccurred())
+
+			_, err = route.ParseRateLimit(map[string]string{route.RateLimitKeyTag: "user"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Pool", func() {
+		var (
+			pool         *route.Pool
+			defaultLimit route.RateLimit
+		)
+
+		BeforeEach(func() {
+			pool = route.NewPool(2*time.Minute, "")
+			defaultLimit = route.RateLimit{Rate: 10, Burst: 20, Key: config.RATE_LIMIT_KEY_CLIENT_IP}
+		})
+
+		put := func(tags map[string]string) {
+			pool.Put(route.NewEndpoint("app-id", "1.2.3.4", 5678, "", "", tags, -1, "", models.ModificationTag{}))
+		}
+
+		It("uses the default limit without overrides", func() {
+			put(nil)
+			Expect(pool.RateLimit(defaultLimit)).To(Equal(defaultLimit))
+			Expect(pool.RateLimit(defaultLimit).Enabled()).To(BeTrue())
+		})
+
+		It("applies the overrides of the most recent registration", func() {
+			put(map[string]string{route.RateLimitTag: "1", route.RateLimitKeyTag: "app_id"})
+			Expect(pool.RateLimit(defaultLimit)).To(Equal(route.RateLimit{Rate: 1, Burst: 20, Key: config.RATE_LIMIT_KEY_APP_ID}))
+
+			put(map[string]string{route.RateLimitTag: "off"})
+			Expect(pool.RateLimit(defaultLimit).Enabled()).To(BeFalse())
+
+			put(nil)
+			Expect(pool.RateLimit(defaultLimit)).To(Equal(defaultLimit))
+		})
+
+		It("enables a limit for the route when there is no default rate", func() {
+			put(map[string]string{route.RateLimitTag: "5"})
+			Expect(pool.RateLimit(route.RateLimit{Key: config.RATE_LIMIT_KEY_CLIENT_IP}).Enabled()).To(BeTrue())
+		})
+
+		It("returns the app of its endpoints", func() {
+			Expect(pool.ApplicationId()).To(BeEmpty())
+			put(nil)
+			Expect(pool.ApplicationId()).To(Equal("app-id"))
+		})
+	})
+})
@@ -0,0 +1,48 @@
+package route
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MaxRequestBodySizeTag overrides the router-wide maximum size in bytes of
+// the request bodies of the route an endpoint is registered on.
+const MaxRequestBodySizeTag = "max_request_body_size"
+
+// ParseMaxRequestBodySize reads the maximum request body size of a route
+// from registration tags. It returns zero when the tags do not contain one.
+func ParseMaxRequestBodySize(tags map[string]string) (int64, error) {
+	value, ok := tags[MaxRequestBodySizeTag]
+	if !ok {
+		return 0, nil
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 1 {
+		return 0, fmt.Errorf("invalid %s tag %q, expected a positive number of bytes", MaxRequestBodySizeTag, value)
+	}
+	return size, nil
+}
+
+// parseMaxRequestBodySize ignores invalid overrides; registrations are
+// validated before endpoints are created.
+func parseMaxRequestBodySize(tags map[string]string) int64 {
+	size, err := ParseMaxRequestBodySize(tags)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// MaxRequestBodySize returns the maximum size of request bodies of the pool:
+// defaultSize unless the endpoints of the pool override it. Zero means
+// unlimited.
+func (p *Pool) MaxRequestBodySize(defaultSize int64) int64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.maxRequestBodySize > 0 {
+		return p.maxRequestBodySize
+	}
+	return defaultSize
+}
@@ -0,0 +1,60 @@
+package route_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MaxRequestBodySize", func() {
+	Describe("ParseMaxRequestBodySize", func() {
+		It("returns zero without the tag", func() {
+			size, err := route.ParseMaxRequestBodySize(map[string]string{"component": "x"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(size).To(BeZero())
+		})
+
+		It("parses the size", func() {
+			size, err := route.ParseMaxRequestBodySize(map[string]string{route.MaxRequestBodySizeTag: "1024"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(size).To(Equal(int64(1024)))
+		})
+
+		It("rejects invalid values", func() {
+			_, err := route.ParseMaxRequestBodySize(map[string]string{route.MaxRequestBodySizeTag: "0"})
+			Expect(err).To(HaveOccurred())
+
+			_, err = route.ParseMaxRequestBodySize(map[string]string{route.MaxRequestBodySizeTag: "1MB"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Pool", func() {
+		var pool *route.Pool
+
+		BeforeEach(func() {
+			pool = route.NewPool(2*time.Minute, "")
+		})
+
+		put := func(tags map[string]string) {
+			pool.Put(route.NewEndpoint("app-id", "1.2.3.4", 5678, "", "", tags, -1, "", models.ModificationTag{}))
+		}
+
+		It("uses the default size without an override", func() {
+			put(nil)
+			Expect(pool.MaxRequestBodySize(100)).To(Equal(int64(100)))
+		})
+
+		It("applies the override of the most recent registration", func() {
+			put(map[string]string{route.MaxRequestBodySizeTag: "10"})
+			Expect(pool.MaxRequestBodySize(100)).To(Equal(int64(10)))
+			Expect(pool.MaxRequestBodySize(0)).To(Equal(int64(10)))
+
+			put(nil)
+			Expect(pool.MaxRequestBodySize(100)).To(Equal(int64(100)))
+		})
+	})
+})
@@ -0,0 +1,133 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+)
+
+const (
+	// RetryOnTag overrides the router-wide retry conditions for the route an
+	// endpoint is registered on, as a comma separated list such as
+	// "connect-failure,503".
+	RetryOnTag = "retry_on"
+	// RetryMaxAttemptsTag overrides the router-wide number of attempts for
+	// the route an endpoint is registered on.
+	RetryMaxAttemptsTag = "retry_max_attempts"
+)
+
+// RetryPolicy decides which failed attempts of a request are retried on
+// another endpoint.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one.
+	MaxAttempts int
+	// RetryOn lists the conditions from config.RetryConditions.
+	RetryOn []string
+}
+
+// ParseRetryPolicy reads the retry policy override of a route from
+// registration tags. It returns nil when the tags do not contain one. Fields
+// that are not overridden are left empty.
+func ParseRetryPolicy(tags map[string]string) (*RetryPolicy, error) {
+	retryOn, hasRetryOn := tags[RetryOnTag]
+	attempts, hasAttempts := tags[RetryMaxAttemptsTag]
+	if !hasRetryOn && !hasAttempts {
+		return nil, nil
+	}
+
+	policy := &RetryPolicy{}
+	if hasRetryOn {
+		policy.RetryOn = []string{}
+		for _, condition := range strings.Split(retryOn, ",") {
+			condition = strings.TrimSpace(condition)
+			if condition == "" {
+				continue
+			}
+			if !config.IsRetryConditionValid(condition) {
+				return nil, fmt.Errorf("invalid %s tag %q, allowed values are %s", RetryOnTag, retryOn, config.RetryConditions)
+			}
+			policy.RetryOn = append(policy.RetryOn, condition)
+		}
+	}
+
+	if hasAttempts {
+		n, err := strconv.Atoi(attempts)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid %s tag %q, expected a positive number", RetryMaxAttemptsTag, attempts)
+		}
+		policy.MaxAttempts = n
+	}
+
+	return policy, nil
+}
+
+// parseRetryPolicy ignores invalid policies; registrations are validated
+// before endpoints are created.
+func parseRetryPolicy(tags map[string]string) *RetryPolicy {
+	policy, err := ParseRetryPolicy(tags)
+	if err != nil {
+		return nil
+	}
+	return policy
+}
+
+// RetryPolicy returns the retry policy of the pool: defaultPolicy with the
+// overrides of the endpoints of the pool applied.
+func (p *Pool) RetryPolicy(defaultPolicy RetryPolicy) RetryPolicy {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	policy := defaultPolicy
+	if p.retryPolicy != nil {
+		if p.retryPolicy.MaxAttempts > 0 {
+			policy.MaxAttempts = p.retryPolicy.MaxAttempts
+		}
+		if p.retryPolicy.RetryOn != nil {
+			policy.RetryOn = p.retryPolicy.RetryOn
+		}
+	}
+	return policy
+}
+
+// RetriesConnectFailure returns true if attempts that failed to connect to
+// the endpoint are retried.
+func (r RetryPolicy) RetriesConnectFailure() bool {
+	return r.has(config.RETRY_ON_CONNECT_FAILURE)
+}
+
+// RetriesReset returns true if attempts reset by the endpoint are retried.
+func (r RetryPolicy) RetriesReset(request *http.Request) bool {
+	return r.has(config.RETRY_ON_RESET) && r.allows(request)
+}
+
+// RetriesStatus returns true if attempts answered with the status code are
+// retried. Requests with a body are not, as the body has been consumed.
+func (r RetryPolicy) RetriesStatus(request *http.Request, statusCode int) bool {
+	return r.has(strconv.Itoa(statusCode)) && request.ContentLength == 0 && r.allows(request)
+}
+
+// allows returns true if a request that may have reached the endpoint can be
+// sent again.
+func (r RetryPolicy) allows(request *http.Request) bool {
+	if !r.has(config.RETRY_ON_IDEMPOTENT_ONLY) {
+		return true
+	}
+
+	switch request.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+func (r RetryPolicy) has(condition string) bool {
+	for _, c := range r.RetryOn {
+		if c == condition {
+			return true
+		}
+	}
+	return false
+}
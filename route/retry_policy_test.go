@@ -0,0 +1,125 @@
+package route_test
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RetryPolicy", func() {
+	Describe("ParseRetryPolicy", func() {
+		It("returns nil without retry tags", func() {
+			policy, err := route.ParseRetryPolicy(map[string]string{"component": "x"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(policy).To(BeNil())
+		})
+
+		It("parses the conditions and attempts", func() {
+			policy, err := route.ParseRetryPolicy(map[string]string{
+				route.RetryOnTag:          "connect-failure, 503,idempotent-only",
+				route.RetryMaxAttemptsTag: "5",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(policy).To(Equal(&route.RetryPolicy{
+				MaxAttempts: 5,
+				RetryOn:     []string{"connect-failure", "503", "idempotent-only"},
+			}))
+		})
+
+		It("rejects unknown conditions", func() {
+			_, err := route.ParseRetryPolicy(map[string]string{route.RetryOnTag: "always"})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects invalid attempts", func() {
+			_, err := route.ParseRetryPolicy(map[string]string{route.RetryMaxAttemptsTag: "0"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Pool", func() {
+		var (
+			pool          *route.Pool
+			defaultPolicy route.RetryPolicy
+		)
+
+		BeforeEach(func() {
+			pool = route.NewPool(2*time.Minute, "")
+			defaultPolicy = route.RetryPolicy{
+				MaxAttempts: 3,
+				RetryOn:     []string{config.RETRY_ON_CONNECT_FAILURE},
+			}
+		})
+
+		put := func(tags map[string]string) {
+			pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", tags, -1, "", models.ModificationTag{}))
+		}
+
+		It("uses the default policy without overrides", func() {
+			put(nil)
+			Expect(pool.RetryPolicy(defaultPolicy)).To(Equal(defaultPolicy))
+		})
+
+		It("applies the overrides of the most recent registration", func() {
+			put(map[string]string{route.RetryOnTag: "503"})
+			Expect(pool.RetryPolicy(defaultPolicy)).To(Equal(route.RetryPolicy{
+				MaxAttempts: 3,
+				RetryOn:     []string{"503"},
+			}))
+
+			put(map[string]string{route.RetryMaxAttemptsTag: "1"})
+			Expect(pool.RetryPolicy(defaultPolicy)).To(Equal(route.RetryPolicy{
+				MaxAttempts: 1,
+				RetryOn:     []string{config.RETRY_ON_CONNECT_FAILURE},
+			}))
+		})
+
+		It("can disable all retry conditions", func() {
+			put(map[string]string{route.RetryOnTag: ""})
+			Expect(pool.RetryPolicy(defaultPolicy).RetriesConnectFailure()).To(BeFalse())
+		})
+	})
+
+	Describe("conditions", func() {
+		var (
+			policy route.RetryPolicy
+			get    *http.Request
+			post   *http.Request
+		)
+
+		BeforeEach(func() {
+			policy = route.RetryPolicy{
+				MaxAttempts: 3,
+				RetryOn:     []string{config.RETRY_ON_RESET, "503", config.RETRY_ON_IDEMPOTENT_ONLY},
+			}
+			get, _ = http.NewRequest("GET", "http://example.com", nil)
+			post, _ = http.NewRequest("POST", "http://example.com", nil)
+		})
+
+		It("retries idempotent requests only", func() {
+			Expect(policy.RetriesReset(get)).To(BeTrue())
+			Expect(policy.RetriesReset(post)).To(BeFalse())
+			Expect(policy.RetriesStatus(get, 503)).To(BeTrue())
+			Expect(policy.RetriesStatus(post, 503)).To(BeFalse())
+		})
+
+		It("only retries listed status codes", func() {
+			Expect(policy.RetriesStatus(get, 502)).To(BeFalse())
+		})
+
+		It("does not retry status codes of requests with a body", func() {
+			put, _ := http.NewRequest("PUT", "http://example.com", strings.NewReader("data"))
+			Expect(policy.RetriesStatus(put, 503)).To(BeFalse())
+		})
+
+		It("does not retry connect failures unless listed", func() {
+			Expect(policy.RetriesConnectFailure()).To(BeFalse())
+		})
+	})
+})
@@ -46,6 +46,13 @@ func (r *RoundRobin) next() *Endpoint {
 		return nil
 	}
 
+	curTime := time.Now()
+	f := r.pool.filter(curTime)
+
+	if r.pool.weighted(curTime) {
+		return r.nextWeighted(f)
+	}
+
 	if r.pool.nextIdx == -1 {
 		r.pool.nextIdx = random.Intn(last)
 	} else if r.pool.nextIdx >= last {
@@ -62,15 +69,7 @@ func (r *RoundRobin) next() *Endpoint {
 			curIdx = 0
 		}
 
-		if e.failedAt != nil {
-			curTime := time.Now()
-			if curTime.Sub(*e.failedAt) > r.pool.retryAfterFailure {
-				// exipired failure window
-				e.failedAt = nil
-			}
-		}
-
-		if e.failedAt == nil {
+		if r.pool.available(e, curTime) && r.pool.eligible(e, f, curTime) {
 			r.pool.nextIdx = curIdx
 			return e.endpoint
 		}
@@ -84,9 +83,44 @@ func (r *RoundRobin) next() *Endpoint {
 	}
 }
 
+// nextWeighted implements smooth weighted round-robin: every available
+// endpoint gains its weight on each pick, the one with the highest current
+// weight is selected and then loses the total weight of the pool.
+// pool lock must be held
+func (r *RoundRobin) nextWeighted(f filter) *Endpoint {
+	var selected *endpointElem
+	var total int64
+	curTime := time.Now()
+
+	for _, e := range r.pool.endpoints {
+		if !r.pool.available(e, curTime) || !r.pool.eligible(e, f, curTime) {
+			continue
+		}
+
+		weight := r.pool.effectiveWeight(e, curTime)
+		e.currentWeight += weight
+		total += weight
+
+		if selected == nil || e.currentWeight > selected.currentWeight {
+			selected = e
+		}
+	}
+
+	if selected == nil {
+		// all endpoints are marked failed so reset everything to available
+		for _, e := range r.pool.endpoints {
+			e.failedAt = nil
+		}
+		return r.nextWeighted(f)
+	}
+
+	selected.currentWeight -= total
+	return selected.endpoint
+}
+
 func (r *RoundRobin) EndpointFailed() {
 	if r.lastEndpoint != nil {
-		r.pool.endpointFailed(r.lastEndpoint)
+		r.pool.EndpointFailed(r.lastEndpoint)
 	}
 }
 
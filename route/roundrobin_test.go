@@ -134,6 +134,83 @@ var _ = Describe("RoundRobin", func() {
 		})
 	})
 
+	Describe("weighted endpoints", func() {
+		It("distributes requests in proportion to endpoint weights", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", map[string]string{"weight": "19"}, -1, "", modTag)
+			e2 := route.NewEndpoint("", "5.6.7.8", 1234, "", "", map[string]string{"weight": "1"}, -1, "", modTag)
+			pool.Put(e1)
+			pool.Put(e2)
+
+			counts := map[*route.Endpoint]int{}
+			iter := route.NewRoundRobin(pool, "")
+			for i := 0; i < 200; i++ {
+				counts[iter.Next()]++
+			}
+
+			Expect(counts[e1]).To(Equal(190))
+			Expect(counts[e2]).To(Equal(10))
+		})
+
+		It("skips failed weighted endpoints", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", map[string]string{"weight": "3"}, -1, "", modTag)
+			e2 := route.NewEndpoint("", "5.6.7.8", 1234, "", "", nil, -1, "", modTag)
+			pool.Put(e1)
+			pool.Put(e2)
+
+			iter := route.NewRoundRobin(pool, "")
+			n := iter.Next()
+			Expect(n).To(Equal(e1))
+			iter.EndpointFailed()
+
+			for i := 0; i < 5; i++ {
+				Expect(iter.Next()).To(Equal(e2))
+			}
+		})
+	})
+
+	Describe("slow start", func() {
+		It("ramps up the share of a new endpoint over the slow-start window", func() {
+			pool.SetSlowStart(100 * time.Millisecond)
+			old := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag)
+			pool.Put(old)
+			time.Sleep(150 * time.Millisecond)
+
+			fresh := route.NewEndpoint("", "5.6.7.8", 1234, "", "", nil, -1, "", modTag)
+			pool.Put(fresh)
+
+			counts := map[*route.Endpoint]int{}
+			iter := route.NewRoundRobin(pool, "")
+			for i := 0; i < 100; i++ {
+				counts[iter.Next()]++
+			}
+			Expect(counts[fresh]).To(BeNumerically("<", 10))
+
+			time.Sleep(100 * time.Millisecond)
+			counts = map[*route.Endpoint]int{}
+			for i := 0; i < 100; i++ {
+				counts[iter.Next()]++
+			}
+			Expect(counts[fresh]).To(BeNumerically("~", 50, 1))
+		})
+
+		It("does not restart the window when an endpoint heartbeats", func() {
+			pool.SetSlowStart(100 * time.Millisecond)
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag)
+			e2 := route.NewEndpoint("", "5.6.7.8", 1234, "", "", nil, -1, "", modTag)
+			pool.Put(e1)
+			pool.Put(e2)
+			time.Sleep(150 * time.Millisecond)
+			pool.Put(e1)
+
+			counts := map[*route.Endpoint]int{}
+			iter := route.NewRoundRobin(pool, "")
+			for i := 0; i < 100; i++ {
+				counts[iter.Next()]++
+			}
+			Expect(counts[e1]).To(Equal(50))
+		})
+	})
+
 	Describe("Failed", func() {
 		It("skips failed endpoints", func() {
 			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag)
@@ -0,0 +1,251 @@
+package router
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+// Timeouts of the listeners closing client connections, as reported to
+// metrics.
+const (
+	readHeaderTimeout = "read_header"
+	readTimeout       = "read"
+	writeTimeout      = "write"
+	idleTimeout       = "idle"
+)
+
+// Phases of a client connection, telling which timeout a failed read belongs
+// to.
+const (
+	phaseHeader int32 = iota
+	phaseRequest
+	phaseIdle
+	phaseHijacked
+)
+
+// errTooManyConnections fails the reads of a connection beyond the limit of
+// its client IP.
+var errTooManyConnections = errors.New("too many connections from client IP")
+
+// clientConns tracks the client connections of the listeners: how many each
+// client IP holds, and what each connection is doing, to report which
+// timeout closed it.
+type clientConns struct {
+	lock     sync.Mutex
+	maxPerIP int
+	perIP    map[string]int
+	conns    map[net.Conn]*clientConn
+	reporter metrics.CombinedReporter
+}
+
+func newClientConns(maxPerIP int, reporter metrics.CombinedReporter) *clientConns {
+	return &clientConns{
+		maxPerIP: maxPerIP,
+		perIP:    make(map[string]int),
+		conns:    make(map[net.Conn]*clientConn),
+		reporter: reporter,
+	}
+}
+
+// add counts a connection of ip. It returns false when ip already holds as
+// many connections as allowed.
+func (c *clientConns) add(ip string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.maxPerIP > 0 && c.perIP[ip] >= c.maxPerIP {
+		return false
+	}
+	c.perIP[ip]++
+	return true
+}
+
+func (c *clientConns) remove(ip string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.perIP[ip] > 1 {
+		c.perIP[ip]--
+	} else {
+		delete(c.perIP, ip)
+	}
+}
+
+// track associates the connection handed to the server, which wraps conn
+// on the SSL port, with conn.
+func (c *clientConns) track(served net.Conn, conn *clientConn) {
+	c.lock.Lock()
+	c.conns[served] = conn
+	c.lock.Unlock()
+}
+
+// setState follows the state of a connection as reported by the server.
+func (c *clientConns) setState(served net.Conn, state http.ConnState) {
+	c.lock.Lock()
+	conn, ok := c.conns[served]
+	if state == http.StateHijacked || state == http.StateClosed {
+		delete(c.conns, served)
+	}
+	c.lock.Unlock()
+	if !ok {
+		return
+	}
+
+	switch state {
+	case http.StateActive:
+		atomic.StoreInt32(&conn.phase, phaseRequest)
+		// HTTP/2 connections keep the read deadline of their handshake
+		// otherwise, as the server only resets it with its read timeout
+		if tlsConn, ok := served.(*tls.Conn); ok && tlsConn.ConnectionState().NegotiatedProtocol == "h2" {
+			conn.SetReadDeadline(noDeadline)
+		}
+	case http.StateIdle:
+		atomic.StoreInt32(&conn.phase, phaseIdle)
+	case http.StateHijacked:
+		atomic.StoreInt32(&conn.phase, phaseHijacked)
+	}
+}
+
+// clientListener accepts the client connections of a port. Connections of
+// client IPs holding as many connections as allowed are closed on their first
+// read: the client IP of a connection using the PROXY protocol is only known
+// once its header is read, which must not hold up the accept loop.
+type clientListener struct {
+	net.Listener
+	conns *clientConns
+	// readHeaderTimeout also bounds the TLS handshake, which the server
+	// leaves unbounded without a read timeout
+	readHeaderTimeout time.Duration
+	// tlsConfig is set on the SSL port
+	tlsConfig *tls.Config
+}
+
+func (l *clientListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &clientConn{Conn: conn, conns: l.conns}
+	if l.readHeaderTimeout > 0 {
+		c.SetReadDeadline(time.Now().Add(l.readHeaderTimeout))
+	}
+
+	var served net.Conn = c
+	if l.tlsConfig != nil {
+		served = tls.Server(c, l.tlsConfig)
+	}
+	l.conns.track(served, c)
+	return served, nil
+}
+
+// clientConn counts a connection of its client IP until it is closed, and
+// reports its first timeout.
+type clientConn struct {
+	net.Conn
+	conns    *clientConns
+	phase    int32
+	timedOut int32
+
+	admission sync.Once
+	// admitErr is set when the connection is beyond the limit of its client
+	// IP
+	admitErr error
+
+	lock    sync.Mutex
+	ip      string
+	counted bool
+	closed  bool
+}
+
+// admit counts the connection of its client IP, which with the PROXY
+// protocol reads the header of the connection.
+func (c *clientConn) admit() error {
+	c.admission.Do(func() {
+		ip := clientIP(c.Conn.RemoteAddr())
+
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		if c.closed {
+			return
+		}
+		if !c.conns.add(ip) {
+			c.conns.reporter.CaptureRejectedConnection()
+			c.admitErr = errTooManyConnections
+			c.Conn.Close()
+			return
+		}
+		c.ip = ip
+		c.counted = true
+	})
+	return c.admitErr
+}
+
+func (c *clientConn) Read(b []byte) (int, error) {
+	if err := c.admit(); err != nil {
+		return 0, err
+	}
+
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		// the next request of an idle connection has begun
+		atomic.CompareAndSwapInt32(&c.phase, phaseIdle, phaseHeader)
+	}
+	if isTimeout(err) {
+		switch atomic.LoadInt32(&c.phase) {
+		case phaseHeader:
+			c.timeout(readHeaderTimeout)
+		case phaseRequest:
+			c.timeout(readTimeout)
+		case phaseIdle:
+			c.timeout(idleTimeout)
+		}
+	}
+	return n, err
+}
+
+func (c *clientConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if isTimeout(err) && atomic.LoadInt32(&c.phase) != phaseHijacked {
+		c.timeout(writeTimeout)
+	}
+	return n, err
+}
+
+func (c *clientConn) Close() error {
+	c.lock.Lock()
+	if !c.closed && c.counted {
+		c.conns.remove(c.ip)
+	}
+	c.closed = true
+	c.lock.Unlock()
+
+	return c.Conn.Close()
+}
+
+func (c *clientConn) timeout(timeout string) {
+	if atomic.CompareAndSwapInt32(&c.timedOut, 0, 1) {
+		c.conns.reporter.CaptureConnectionTimeout(timeout)
+	}
+}
+
+func clientIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
@@ -18,12 +18,15 @@ import (
 	"net/http"
 	"time"
 
+	"code.cloudfoundry.org/gorouter/cache"
+	"code.cloudfoundry.org/gorouter/certstore"
 	"code.cloudfoundry.org/gorouter/common"
 	"code.cloudfoundry.org/gorouter/common/health"
 	"code.cloudfoundry.org/gorouter/common/schema"
 	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
 	"code.cloudfoundry.org/gorouter/metrics/monitor"
 	"code.cloudfoundry.org/gorouter/proxy"
 	"code.cloudfoundry.org/gorouter/registry"
@@ -39,6 +42,12 @@ var DrainTimeout = errors.New("router: Drain timeout")
 const (
 	emitInterval               = 1 * time.Second
 	proxyProtocolHeaderTimeout = 100 * time.Millisecond
+
+	// maxHeaderBytesSlack is added to max_header_bytes for the servers, so
+	// that requests just over the limit reach the request limits handler of
+	// the proxy, which counts and logs the requests it rejects. The servers
+	// reject larger requests by themselves.
+	maxHeaderBytesSlack = 4096
 )
 
 var noDeadline = time.Time{}
@@ -51,8 +60,12 @@ type Router struct {
 	varz       varz.Varz
 	component  *common.VcapComponent
 
+	// certificates are served on the SSL port
+	certificates *certstore.Store
+
 	listener         net.Listener
 	tlsListener      net.Listener
+	clientConns      *clientConns
 	closeConnections bool
 	connLock         sync.Mutex
 	idleConns        map[net.Conn]struct{}
@@ -70,7 +83,7 @@ type Router struct {
 }
 
 func NewRouter(logger logger.Logger, cfg *config.Config, p proxy.Proxy, mbusClient *nats.Conn, r *registry.RouteRegistry,
-	v varz.Varz, heartbeatOK *int32, logCounter *schema.LogCounter, errChan chan error) (*Router, error) {
+	v varz.Varz, reporter metrics.CombinedReporter, heartbeatOK *int32, logCounter *schema.LogCounter, errChan chan error, responseCache *cache.Store) (*Router, error) {
 
 	var host string
 	if cfg.Status.Port != 0 {
@@ -100,6 +113,11 @@ func NewRouter(logger logger.Logger, cfg *config.Config, p proxy.Proxy, mbusClie
 		},
 		Logger: logger,
 	}
+	if responseCache != nil {
+		component.Handlers = map[string]http.Handler{
+			"/cache": cache.NewPurgeHandler(responseCache),
+		}
+	}
 
 	routerErrChan := errChan
 	if routerErrChan == nil {
@@ -117,12 +135,22 @@ func NewRouter(logger logger.Logger, cfg *config.Config, p proxy.Proxy, mbusClie
 		tlsServeDone: make(chan struct{}),
 		idleConns:    make(map[net.Conn]struct{}),
 		activeConns:  make(map[net.Conn]struct{}),
+		clientConns:  newClientConns(cfg.MaxConnsPerClientIP, reporter),
 		logger:       logger,
 		errChan:      routerErrChan,
 		HeartbeatOK:  heartbeatOK,
 		stopping:     false,
 	}
 
+	if cfg.EnableSSL {
+		certificates, err := certstore.New(cfg.SSLCertificates, cfg.DefaultTLSCertificate)
+		if err != nil {
+			return nil, err
+		}
+		router.certificates = certificates
+		v.SetCertificates(certificates)
+	}
+
 	if err := router.component.Start(); err != nil {
 		return nil, err
 	}
@@ -132,11 +160,18 @@ func NewRouter(logger logger.Logger, cfg *config.Config, p proxy.Proxy, mbusClie
 }
 
 type gorouterHandler struct {
-	handler http.Handler
-	logger  logger.Logger
+	handler  http.Handler
+	logger   logger.Logger
+	draining func() bool
 }
 
 func (h *gorouterHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	// A multiplexed HTTP/2 connection may not become idle while the router
+	// drains. Closing the connection makes the server send a GOAWAY, so that
+	// the client opens no more streams on it.
+	if req.ProtoMajor == 2 && h.draining() {
+		res.Header().Set("Connection", "close")
+	}
 	h.handler.ServeHTTP(res, req)
 }
 
@@ -171,23 +206,25 @@ func (r *Router) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 
 	r.logger.Info("completed-wait")
 
-	handler := gorouterHandler{handler: dropsonde.InstrumentedHandler(r.proxy), logger: r.logger}
-
-	server := &http.Server{
-		Handler:   &handler,
-		ConnState: r.HandleConnState,
+	handler := gorouterHandler{
+		handler:  dropsonde.InstrumentedHandler(r.proxy),
+		logger:   r.logger,
+		draining: r.closingConnections,
 	}
 
-	err := r.serveHTTP(server, r.errChan)
+	err := r.serveHTTP(r.newServer(&handler, r.config.HTTPListener), r.errChan)
 	if err != nil {
 		r.errChan <- err
 		return err
 	}
-	err = r.serveHTTPS(server, r.errChan)
+	err = r.serveHTTPS(r.newServer(&handler, r.config.HTTPSListener), r.errChan)
 	if err != nil {
 		r.errChan <- err
 		return err
 	}
+	if r.certificates != nil && r.config.TLSCertificatesPollInterval > 0 {
+		go r.watchCertificates(r.config.TLSCertificatesPollInterval)
+	}
 
 	// create pid file
 	err = r.writePidFile(r.config.PidFile)
@@ -205,6 +242,24 @@ func (r *Router) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	return nil
 }
 
+// newServer creates the server of a port, with the timeouts of its listener.
+func (r *Router) newServer(handler http.Handler, listener config.ListenerConfig) *http.Server {
+	idleTimeout := listener.IdleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = r.config.EndpointTimeout
+	}
+
+	return &http.Server{
+		Handler:           handler,
+		ConnState:         r.HandleConnState,
+		MaxHeaderBytes:    r.config.MaxHeaderBytes + maxHeaderBytesSlack,
+		ReadHeaderTimeout: listener.ReadHeaderTimeout,
+		ReadTimeout:       listener.ReadTimeout,
+		WriteTimeout:      listener.WriteTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+}
+
 func (r *Router) writePidFile(pidFile string) error {
 	if pidFile != "" {
 		pid := strconv.Itoa(os.Getpid())
@@ -241,6 +296,69 @@ func (r *Router) OnErrOrSignal(signals <-chan os.Signal, errChan chan error) {
 	}
 }
 
+// ReloadCertificates swaps the certificates served on the SSL port for the
+// ones currently in the configured files. New handshakes get the new
+// certificates while established connections are left alone. The current
+// certificates are kept if the files cannot be loaded.
+func (r *Router) ReloadCertificates() {
+	if r.certificates == nil {
+		return
+	}
+
+	certs, err := r.config.LoadTLSCertificates()
+	if err == nil {
+		err = r.certificates.Update(certs, r.config.DefaultTLSCertificate)
+	}
+	if err != nil {
+		r.logger.Error("tls-certificates-reload-failed", zap.Error(err))
+		return
+	}
+	r.logger.Info("tls-certificates-reloaded", zap.Int("certificates", len(certs)))
+}
+
+// watchCertificates reloads the certificates whenever their files change,
+// until the router stops.
+func (r *Router) watchCertificates(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	files := r.certificateFiles()
+	for range t.C {
+		r.stopLock.Lock()
+		stopping := r.stopping
+		r.stopLock.Unlock()
+		if stopping {
+			return
+		}
+
+		if current := r.certificateFiles(); current != files {
+			files = current
+			r.ReloadCertificates()
+		}
+	}
+}
+
+// certificateFiles describes the paths, sizes and modification times of the
+// certificate files, so that writing, replacing or removing one changes it.
+func (r *Router) certificateFiles() string {
+	pems, err := r.config.TLSPems()
+	if err != nil {
+		return ""
+	}
+
+	var b bytes.Buffer
+	for _, pem := range pems {
+		for _, path := range []string{pem.CertPath, pem.KeyPath} {
+			b.WriteString(path)
+			if info, err := os.Stat(path); err == nil {
+				fmt.Fprintf(&b, " %d %d", info.Size(), info.ModTime().UnixNano())
+			}
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
 func (r *Router) DrainAndStop() {
 	drainWait := r.config.DrainWait
 	drainTimeout := r.config.DrainTimeout
@@ -258,9 +376,26 @@ func (r *Router) DrainAndStop() {
 func (r *Router) serveHTTPS(server *http.Server, errChan chan error) error {
 	if r.config.EnableSSL {
 		tlsConfig := &tls.Config{
-			Certificates: []tls.Certificate{r.config.SSLCertificate},
-			CipherSuites: r.config.CipherSuites,
-			MinVersion:   tls.VersionTLS12,
+			GetCertificate:   r.getCertificate,
+			CipherSuites:     r.config.CipherSuites,
+			MinVersion:       r.config.MinTLSVersion,
+			MaxVersion:       r.config.MaxTLSVersion,
+			CurvePreferences: r.config.CurvePreferences,
+		}
+
+		switch r.config.ClientCertValidation {
+		case config.CLIENT_CERT_VALIDATION_REQUEST:
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+			tlsConfig.ClientCAs = r.config.ClientCAPool
+		case config.CLIENT_CERT_VALIDATION_REQUIRE:
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+			tlsConfig.ClientCAs = r.config.ClientCAPool
+		}
+
+		// the server handles connections negotiating h2 with its built-in
+		// HTTP/2 support
+		if r.config.EnableHTTP2 {
+			tlsConfig.NextProtos = []string{"h2", "http/1.1"}
 		}
 
 		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", r.config.SSLPort))
@@ -276,7 +411,12 @@ func (r *Router) serveHTTPS(server *http.Server, errChan chan error) error {
 			}
 		}
 
-		r.tlsListener = tls.NewListener(listener, tlsConfig)
+		r.tlsListener = &clientListener{
+			Listener:          listener,
+			conns:             r.clientConns,
+			readHeaderTimeout: r.config.HTTPSListener.ReadHeaderTimeout,
+			tlsConfig:         tlsConfig,
+		}
 
 		r.logger.Info("tls-listener-started", zap.Object("address", r.tlsListener.Addr()))
 
@@ -293,6 +433,23 @@ func (r *Router) serveHTTPS(server *http.Server, errChan chan error) error {
 	return nil
 }
 
+// getCertificate picks the certificate for the cipher suites both the
+// client and the SSL port support, so that clients offering ECDSA suites the
+// port does not allow get an RSA certificate.
+func (r *Router) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	shared := *hello
+	shared.CipherSuites = nil
+	for _, suite := range hello.CipherSuites {
+		for _, allowed := range r.config.CipherSuites {
+			if suite == allowed {
+				shared.CipherSuites = append(shared.CipherSuites, suite)
+				break
+			}
+		}
+	}
+	return r.certificates.GetCertificate(&shared)
+}
+
 func (r *Router) serveHTTP(server *http.Server, errChan chan error) error {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", r.config.Port))
 	if err != nil {
@@ -300,14 +457,19 @@ func (r *Router) serveHTTP(server *http.Server, errChan chan error) error {
 		return err
 	}
 
-	r.listener = listener
 	if r.config.EnablePROXY {
-		r.listener = &proxyproto.Listener{
+		listener = &proxyproto.Listener{
 			Listener:           listener,
 			ProxyHeaderTimeout: proxyProtocolHeaderTimeout,
 		}
 	}
 
+	r.listener = &clientListener{
+		Listener:          listener,
+		conns:             r.clientConns,
+		readHeaderTimeout: r.config.HTTPListener.ReadHeaderTimeout,
+	}
+
 	r.logger.Info("tcp-listener-started", zap.Object("address", r.listener.Addr()))
 
 	go func() {
@@ -375,6 +537,15 @@ func (r *Router) Stop() {
 	)
 }
 
+// closingConnections returns true once the router closes connections as they
+// become idle.
+func (r *Router) closingConnections() bool {
+	r.connLock.Lock()
+	defer r.connLock.Unlock()
+
+	return r.closeConnections
+}
+
 // connLock must be locked
 func (r *Router) closeIdleConns() {
 	r.closeConnections = true
@@ -423,8 +594,11 @@ func (r *Router) ScheduleFlushApps() {
 	}()
 }
 
+// HandleConnState tracks the client connections of the servers. Their
+// deadlines are left to the servers, which apply the timeouts of the
+// listeners.
 func (r *Router) HandleConnState(conn net.Conn, state http.ConnState) {
-	endpointTimeout := r.config.EndpointTimeout
+	r.clientConns.setState(conn, state)
 
 	r.connLock.Lock()
 
@@ -432,21 +606,12 @@ func (r *Router) HandleConnState(conn net.Conn, state http.ConnState) {
 	case http.StateActive:
 		r.activeConns[conn] = struct{}{}
 		delete(r.idleConns, conn)
-
-		conn.SetDeadline(noDeadline)
 	case http.StateIdle:
 		delete(r.activeConns, conn)
 		r.idleConns[conn] = struct{}{}
 
 		if r.closeConnections {
 			conn.Close()
-		} else {
-			deadline := noDeadline
-			if endpointTimeout > 0 {
-				deadline = time.Now().Add(endpointTimeout)
-			}
-
-			conn.SetDeadline(deadline)
 		}
 	case http.StateHijacked, http.StateClosed:
 		i := len(r.idleConns)
@@ -204,7 +204,7 @@ var _ = Describe("Router", func() {
 		config = test_util.SpecConfig(statusPort, proxyPort, natsPort)
 		config.EnableSSL = true
 		config.SSLPort = sslPort
-		config.SSLCertificate = cert
+		config.SSLCertificates = []tls.Certificate{cert}
 		config.CipherSuites = []uint16{tls.TLS_RSA_WITH_AES_256_CBC_SHA}
 		config.EndpointTimeout = 5 * time.Second
 
@@ -220,10 +220,10 @@ var _ = Describe("Router", func() {
 		combinedReporter = metrics.NewCompositeReporter(varz, metricReporter)
 		config.HealthCheckUserAgent = "HTTP-Monitor/1.1"
 		p = proxy.NewProxy(logger, &access_log.NullAccessLogger{}, config, registry, combinedReporter,
-			&routeservice.RouteServiceConfig{}, &tls.Config{}, &healthCheck)
+			&routeservice.RouteServiceConfig{}, &tls.Config{}, &healthCheck, nil)
 
 		errChan := make(chan error, 2)
-		rtr, err = router.NewRouter(logger, config, p, mbusClient, registry, varz, &healthCheck, logcounter, errChan)
+		rtr, err = router.NewRouter(logger, config, p, mbusClient, registry, varz, combinedReporter, &healthCheck, logcounter, errChan, nil)
 		Expect(err).ToNot(HaveOccurred())
 
 		opts := &mbus.SubscriberOpts{
@@ -468,7 +468,7 @@ var _ = Describe("Router", func() {
 				errChan = make(chan error, 2)
 				config.LoadBalancerHealthyThreshold = 2 * time.Second
 				config.Port = 8347
-				rtr, err = router.NewRouter(logger, config, p, mbusClient, registry, varz, &healthCheck, logcounter, errChan)
+				rtr, err = router.NewRouter(logger, config, p, mbusClient, registry, varz, combinedReporter, &healthCheck, logcounter, errChan, nil)
 				Expect(err).ToNot(HaveOccurred())
 				runRouterHealthcheck := func(r *router.Router) {
 					signals := make(chan os.Signal)
@@ -562,7 +562,7 @@ var _ = Describe("Router", func() {
 				config.LoadBalancerHealthyThreshold = 2 * time.Second
 				config.StartResponseDelayInterval = 4 * time.Second
 				config.Port = 9348
-				rtr, err = router.NewRouter(logger, config, p, mbusClient, registry, varz, &healthCheck, logcounter, errChan)
+				rtr, err = router.NewRouter(logger, config, p, mbusClient, registry, varz, combinedReporter, &healthCheck, logcounter, errChan, nil)
 				Expect(err).ToNot(HaveOccurred())
 
 				signals := make(chan os.Signal)
@@ -595,11 +595,11 @@ var _ = Describe("Router", func() {
 				healthCheck = 0
 				config.HealthCheckUserAgent = "HTTP-Monitor/1.1"
 				proxy := proxy.NewProxy(logger, &access_log.NullAccessLogger{}, config, registry, combinedReporter,
-					&routeservice.RouteServiceConfig{}, &tls.Config{}, &healthCheck)
+					&routeservice.RouteServiceConfig{}, &tls.Config{}, &healthCheck, nil)
 
 				errChan = make(chan error, 2)
 				var err error
-				rtr, err = router.NewRouter(logger, config, proxy, mbusClient, registry, varz, &healthCheck, logcounter, errChan)
+				rtr, err = router.NewRouter(logger, config, proxy, mbusClient, registry, varz, combinedReporter, &healthCheck, logcounter, errChan, nil)
 				Expect(err).ToNot(HaveOccurred())
 				runRouter(rtr)
 			})
@@ -4,13 +4,17 @@ import (
 	"bufio"
 	"bytes"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"os"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -70,7 +74,7 @@ var _ = Describe("Router", func() {
 		config = test_util.SpecConfig(statusPort, proxyPort, natsPort)
 		config.EnableSSL = true
 		config.SSLPort = 4443 + uint16(gConfig.GinkgoConfig.ParallelNode)
-		config.SSLCertificate = cert
+		config.SSLCertificates = []tls.Certificate{cert}
 		config.CipherSuites = []uint16{tls.TLS_RSA_WITH_AES_256_CBC_SHA}
 	})
 
@@ -93,12 +97,12 @@ var _ = Describe("Router", func() {
 		combinedReporter := metrics.NewCompositeReporter(varz, metricReporter)
 
 		proxy := proxy.NewProxy(logger, &access_log.NullAccessLogger{}, config, registry, combinedReporter,
-			&routeservice.RouteServiceConfig{}, &tls.Config{}, nil)
+			&routeservice.RouteServiceConfig{}, &tls.Config{}, nil, nil)
 
 		var healthCheck int32
 		healthCheck = 0
 		logcounter := schema.NewLogCounter()
-		router, err = NewRouter(logger, config, proxy, mbusClient, registry, varz, &healthCheck, logcounter, nil)
+		router, err = NewRouter(logger, config, proxy, mbusClient, registry, varz, combinedReporter, &healthCheck, logcounter, nil, nil)
 
 		Expect(err).ToNot(HaveOccurred())
 
@@ -500,6 +504,78 @@ var _ = Describe("Router", func() {
 			Expect(rr).To(Equal("192.168.0.1"))
 		})
 
+		It("accepts connections while others have not sent their PROXY header", func() {
+			app := test.NewGreetApp([]route.Uri{"slow-proxy.vcap.me"}, config.Port, mbusClient, nil)
+			app.Listen()
+			Eventually(func() bool {
+				return appRegistered(registry, app)
+			}).Should(BeTrue())
+
+			host := fmt.Sprintf("slow-proxy.vcap.me:%d", config.Port)
+			for i := 0; i < 5; i++ {
+				silent, err := net.Dial("tcp", host)
+				Expect(err).ToNot(HaveOccurred())
+				defer silent.Close()
+			}
+
+			conn, err := net.Dial("tcp", host)
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			started := time.Now()
+			fmt.Fprintf(conn, "PROXY TCP4 192.168.0.1 192.168.0.2 12345 80\r\n"+
+				"GET / HTTP/1.0\r\n"+
+				"Host: %s\r\n"+
+				"\r\n", host)
+
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(time.Since(started)).To(BeNumerically("<", 300*time.Millisecond))
+		})
+
+		Context("with a limit of connections per client IP", func() {
+			BeforeEach(func() {
+				config.MaxConnsPerClientIP = 1
+			})
+
+			It("limits the connections of the client IP in the PROXY header", func() {
+				app := test.NewGreetApp([]route.Uri{"proxy-limit.vcap.me"}, config.Port, mbusClient, nil)
+				app.Listen()
+				Eventually(func() bool {
+					return appRegistered(registry, app)
+				}).Should(BeTrue())
+
+				host := fmt.Sprintf("proxy-limit.vcap.me:%d", config.Port)
+				request := func(clientIP string) (net.Conn, error) {
+					conn, err := net.Dial("tcp", host)
+					Expect(err).ToNot(HaveOccurred())
+
+					fmt.Fprintf(conn, "PROXY TCP4 %s 192.168.0.2 12345 80\r\n"+
+						"GET / HTTP/1.1\r\n"+
+						"Host: %s\r\n"+
+						"\r\n", clientIP, host)
+					resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+					if err == nil {
+						resp.Body.Close()
+					}
+					return conn, err
+				}
+
+				first, err := request("192.168.0.1")
+				Expect(err).ToNot(HaveOccurred())
+				defer first.Close()
+
+				other, err := request("192.168.0.3")
+				Expect(err).ToNot(HaveOccurred())
+				defer other.Close()
+
+				extra, err := request("192.168.0.1")
+				Expect(err).To(HaveOccurred())
+				defer extra.Close()
+			})
+		})
+
 		It("sets the x-Forwarded-Proto header to https", func() {
 			app := test.NewGreetApp([]route.Uri{"test.vcap.me"}, config.Port, mbusClient, nil)
 			app.Listen()
@@ -790,6 +866,163 @@ var _ = Describe("Router", func() {
 		})
 	})
 
+	Context("with a limit on the size of request headers", func() {
+		var host string
+
+		BeforeEach(func() {
+			config.MaxHeaderBytes = 1024
+		})
+
+		JustBeforeEach(func() {
+			app := test.NewGreetApp([]route.Uri{"headers.vcap.me"}, config.Port, mbusClient, nil)
+			app.Listen()
+			Eventually(func() bool {
+				return appRegistered(registry, app)
+			}).Should(BeTrue())
+
+			host = fmt.Sprintf("headers.vcap.me:%d", config.Port)
+		})
+
+		sendHeader := func(size int) *http.Response {
+			req, err := http.NewRequest("GET", "http://"+host, nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("X-Large", strings.Repeat("a", size))
+
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+			return resp
+		}
+
+		It("rejects and counts requests with headers over the limit", func() {
+			resp := sendHeader(2000)
+			Expect(resp.StatusCode).To(Equal(http.StatusRequestHeaderFieldsTooLarge))
+			Expect(resp.Header.Get("X-Cf-RouterError")).To(Equal("request_header_too_large"))
+
+			Expect(fetchRecursively(readVarz(varz), "oversized_requests")).To(Equal(float64(1)))
+		})
+
+		It("rejects requests with headers well over the limit", func() {
+			resp := sendHeader(64 * 1024)
+			Expect(resp.StatusCode).To(Equal(http.StatusRequestHeaderFieldsTooLarge))
+		})
+	})
+
+	Context("client connections", func() {
+		var host string
+
+		JustBeforeEach(func() {
+			app := test.NewGreetApp([]route.Uri{"conns.vcap.me"}, config.Port, mbusClient, nil)
+			app.Listen()
+			Eventually(func() bool {
+				return appRegistered(registry, app)
+			}).Should(BeTrue())
+
+			host = fmt.Sprintf("conns.vcap.me:%d", config.Port)
+		})
+
+		expectClosed := func(conn net.Conn) {
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, err := conn.Read(make([]byte, 1))
+			Expect(err).To(MatchError(io.EOF))
+		}
+
+		connectionTimeouts := func(timeout string) func() float64 {
+			return func() float64 {
+				timeouts := fetchRecursively(readVarz(varz), "connection_timeouts").(map[string]interface{})
+				count, _ := timeouts[timeout].(float64)
+				return count
+			}
+		}
+
+		Context("with a read header timeout", func() {
+			BeforeEach(func() {
+				config.HTTPListener.ReadHeaderTimeout = 200 * time.Millisecond
+				config.HTTPSListener.ReadHeaderTimeout = 200 * time.Millisecond
+			})
+
+			It("closes connections sending their headers too slowly", func() {
+				conn, err := net.Dial("tcp", host)
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: %s\r\n", host)
+				expectClosed(conn)
+
+				Eventually(connectionTimeouts("read_header")).Should(Equal(float64(1)))
+			})
+
+			It("closes connections not completing the TLS handshake", func() {
+				conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", config.SSLPort))
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				expectClosed(conn)
+
+				Eventually(connectionTimeouts("read_header")).Should(Equal(float64(1)))
+			})
+		})
+
+		Context("with an idle timeout", func() {
+			BeforeEach(func() {
+				config.HTTPListener.IdleTimeout = 200 * time.Millisecond
+			})
+
+			It("closes keep-alive connections waiting too long for their next request", func() {
+				conn, err := net.Dial("tcp", host)
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				client := httputil.NewClientConn(conn, nil)
+				req, _ := http.NewRequest("GET", "http://"+host, nil)
+				assertServerResponse(client, req)
+
+				time.Sleep(400 * time.Millisecond)
+				_, err = client.Do(req)
+				Expect(err).To(HaveOccurred())
+
+				Eventually(connectionTimeouts("idle")).Should(BeNumerically(">=", 1))
+			})
+		})
+
+		Context("with a limit of connections per client IP", func() {
+			BeforeEach(func() {
+				config.MaxConnsPerClientIP = 1
+			})
+
+			It("closes connections beyond the limit", func() {
+				conn, err := net.Dial("tcp", host)
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				client := httputil.NewClientConn(conn, nil)
+				req, _ := http.NewRequest("GET", "http://"+host, nil)
+				assertServerResponse(client, req)
+
+				extraConn, err := net.Dial("tcp", host)
+				Expect(err).ToNot(HaveOccurred())
+				defer extraConn.Close()
+				expectClosed(extraConn)
+
+				Eventually(func() interface{} {
+					return fetchRecursively(readVarz(varz), "rejected_connections")
+				}).Should(Equal(float64(1)))
+
+				conn.Close()
+				Eventually(func() error {
+					newConn, err := net.Dial("tcp", host)
+					if err != nil {
+						return err
+					}
+					defer newConn.Close()
+
+					_, err = httputil.NewClientConn(newConn, nil).Do(req)
+					return err
+				}).ShouldNot(HaveOccurred())
+			})
+		})
+	})
+
 	Context("serving https", func() {
 		It("serves ssl traffic", func() {
 			app := test.NewGreetApp([]route.Uri{"test.vcap.me"}, config.Port, mbusClient, nil)
@@ -863,6 +1096,308 @@ var _ = Describe("Router", func() {
 			resp.Body.Close()
 		})
 
+		It("does not negotiate HTTP/2", func() {
+			conn, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", config.SSLPort), &tls.Config{
+				InsecureSkipVerify: true,
+				NextProtos:         []string{"h2", "http/1.1"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			Expect(conn.ConnectionState().NegotiatedProtocol).To(BeEmpty())
+		})
+
+		Context("with several certificates", func() {
+			var wildcardCert tls.Certificate
+
+			BeforeEach(func() {
+				wildcardCert = test_util.CreateSignedCertWithRootCA("*.apps.example.com").TLSCert()
+				config.SSLCertificates = append(config.SSLCertificates, wildcardCert)
+			})
+
+			serverCert := func(serverName string) *x509.Certificate {
+				conn, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", config.SSLPort), &tls.Config{
+					InsecureSkipVerify: true,
+					ServerName:         serverName,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				return conn.ConnectionState().PeerCertificates[0]
+			}
+
+			It("serves the certificate matching the requested server name", func() {
+				Expect(serverCert("app.apps.example.com").DNSNames).To(Equal([]string{"*.apps.example.com"}))
+			})
+
+			It("serves the first certificate to clients without a matching name", func() {
+				Expect(serverCert("").Subject.CommonName).To(Equal("127.0.0.1"))
+				Expect(serverCert("unknown.org").Subject.CommonName).To(Equal("127.0.0.1"))
+			})
+
+			It("reports the expiry of every certificate in varz", func() {
+				certs := fetchRecursively(readVarz(varz), "tls_certificates").([]interface{})
+				Expect(certs).To(HaveLen(2))
+			})
+
+			Context("when a default certificate is configured", func() {
+				BeforeEach(func() {
+					config.DefaultTLSCertificate = "*.apps.example.com"
+				})
+
+				It("serves it to clients without a matching name", func() {
+					Expect(serverCert("unknown.org").DNSNames).To(Equal([]string{"*.apps.example.com"}))
+				})
+			})
+		})
+
+		Context("with a TLS policy", func() {
+			dial := func(clientConfig *tls.Config) (tls.ConnectionState, error) {
+				clientConfig.InsecureSkipVerify = true
+				conn, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", config.SSLPort), clientConfig)
+				if err != nil {
+					return tls.ConnectionState{}, err
+				}
+				defer conn.Close()
+				return conn.ConnectionState(), nil
+			}
+
+			It("rejects clients below the minimum TLS version", func() {
+				_, err := dial(&tls.Config{MaxVersion: tls.VersionTLS11})
+				Expect(err).To(HaveOccurred())
+
+				state, err := dial(&tls.Config{MaxVersion: tls.VersionTLS12})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(state.Version).To(Equal(uint16(tls.VersionTLS12)))
+			})
+
+			Context("when the maximum TLS version is lowered", func() {
+				BeforeEach(func() {
+					config.MinTLSVersion = tls.VersionTLS10
+					config.MaxTLSVersion = tls.VersionTLS11
+				})
+
+				It("negotiates at most that version", func() {
+					state, err := dial(&tls.Config{})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(state.Version).To(Equal(uint16(tls.VersionTLS11)))
+				})
+			})
+
+			Context("when curves are configured", func() {
+				BeforeEach(func() {
+					config.CipherSuites = []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+					config.CurvePreferences = []tls.CurveID{tls.CurveP384}
+				})
+
+				It("only negotiates those curves", func() {
+					_, err := dial(&tls.Config{CurvePreferences: []tls.CurveID{tls.CurveP256}})
+					Expect(err).To(HaveOccurred())
+
+					_, err = dial(&tls.Config{CurvePreferences: []tls.CurveID{tls.CurveP256, tls.CurveP384}})
+					Expect(err).ToNot(HaveOccurred())
+				})
+			})
+
+			Context("with ECDSA and RSA certificates", func() {
+				BeforeEach(func() {
+					config.SSLCertificates = []tls.Certificate{
+						test_util.CreateSignedCertWithRootCA("example.com").TLSCert(),
+						test_util.CreateECDSASignedCertWithRootCA("example.com").TLSCert(),
+					}
+					config.CipherSuites = []uint16{
+						tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+						tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+					}
+				})
+
+				It("serves the ECDSA certificate to clients offering an ECDSA cipher suite", func() {
+					state, err := dial(&tls.Config{ServerName: "example.com", MaxVersion: tls.VersionTLS12})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(state.CipherSuite).To(Equal(tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256))
+					Expect(state.PeerCertificates[0].PublicKeyAlgorithm).To(Equal(x509.ECDSA))
+				})
+
+				It("serves the RSA certificate to other clients", func() {
+					state, err := dial(&tls.Config{
+						ServerName:   "example.com",
+						CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+						MaxVersion:   tls.VersionTLS12,
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(state.PeerCertificates[0].PublicKeyAlgorithm).To(Equal(x509.RSA))
+				})
+			})
+		})
+
+		Context("when client certificates are required", func() {
+			var clientChain test_util.CertChain
+
+			BeforeEach(func() {
+				clientChain = test_util.CreateSignedClientCertWithRootCA("client.example.com")
+				config.ClientCertValidation = cfg.CLIENT_CERT_VALIDATION_REQUIRE
+				config.ClientCAPool = clientChain.CACertPool()
+			})
+
+			get := func(tlsConfig *tls.Config) (*http.Response, error) {
+				app := testcommon.NewTestApp([]route.Uri{"test.vcap.me"}, config.Port, mbusClient, nil, "")
+				app.AddHandler("/", func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(r.Header.Get(handlers.ForwardedClientCertHeader)))
+				})
+				app.Listen()
+				Eventually(func() bool {
+					return appRegistered(registry, app)
+				}).Should(BeTrue())
+
+				tlsConfig.InsecureSkipVerify = true
+				client := http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+				req, _ := http.NewRequest("GET", fmt.Sprintf("https://test.vcap.me:%d/", config.SSLPort), nil)
+				req.Header.Set(handlers.ForwardedClientCertHeader, "spoofed")
+				return client.Do(req)
+			}
+
+			It("forwards the verified client certificate to the backend", func() {
+				resp, err := get(&tls.Config{Certificates: []tls.Certificate{clientChain.TLSCert()}})
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				body, err := ioutil.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(ContainSubstring(`Subject="CN=client.example.com"`))
+				Expect(string(body)).ToNot(ContainSubstring("spoofed"))
+			})
+
+			It("rejects clients without a certificate", func() {
+				_, err := get(&tls.Config{})
+				Expect(err).To(HaveOccurred())
+			})
+
+			It("rejects certificates signed by another authority", func() {
+				otherChain := test_util.CreateSignedClientCertWithRootCA("client.example.com")
+				_, err := get(&tls.Config{Certificates: []tls.Certificate{otherChain.TLSCert()}})
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Context("when the certificate files change", func() {
+			var (
+				certDir string
+				newCert test_util.CertChain
+			)
+
+			writeCert := func(chain test_util.CertChain) {
+				Expect(ioutil.WriteFile(config.SSLCertPath, chain.CertPEM, 0600)).To(Succeed())
+				Expect(ioutil.WriteFile(config.SSLKeyPath, chain.KeyPEM, 0600)).To(Succeed())
+			}
+
+			serverCert := func() *x509.Certificate {
+				conn, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", config.SSLPort), &tls.Config{
+					InsecureSkipVerify: true,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				return conn.ConnectionState().PeerCertificates[0]
+			}
+
+			BeforeEach(func() {
+				var err error
+				certDir, err = ioutil.TempDir("", "gorouter-test-certs-")
+				Expect(err).ToNot(HaveOccurred())
+
+				config.SSLCertPath = filepath.Join(certDir, "router.crt")
+				config.SSLKeyPath = filepath.Join(certDir, "router.key")
+				oldCert := test_util.CreateSignedCertWithRootCA("old.example.com")
+				writeCert(oldCert)
+				config.SSLCertificates = []tls.Certificate{oldCert.TLSCert()}
+				config.TLSCertificatesPollInterval = 50 * time.Millisecond
+
+				newCert = test_util.CreateSignedCertWithRootCA("new.example.com")
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(certDir)
+			})
+
+			It("serves the new certificate to new connections", func() {
+				Expect(serverCert().DNSNames).To(Equal([]string{"old.example.com"}))
+
+				writeCert(newCert)
+
+				Eventually(func() []string {
+					return serverCert().DNSNames
+				}).Should(Equal([]string{"new.example.com"}))
+				Expect(logger).To(gbytes.Say("tls-certificates-reloaded"))
+			})
+
+			It("leaves established connections alone", func() {
+				conn, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", config.SSLPort), &tls.Config{
+					InsecureSkipVerify: true,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				writeCert(newCert)
+				router.ReloadCertificates()
+
+				Expect(serverCert().DNSNames).To(Equal([]string{"new.example.com"}))
+				Expect(conn.ConnectionState().PeerCertificates[0].DNSNames).To(Equal([]string{"old.example.com"}))
+
+				_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: unknown.example.com\r\n\r\n"))
+				Expect(err).ToNot(HaveOccurred())
+				resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			})
+
+			It("keeps the current certificate when the new files cannot be loaded", func() {
+				Expect(ioutil.WriteFile(config.SSLCertPath, []byte("not a certificate"), 0600)).To(Succeed())
+				router.ReloadCertificates()
+
+				Expect(logger).To(gbytes.Say("tls-certificates-reload-failed"))
+				Expect(serverCert().DNSNames).To(Equal([]string{"old.example.com"}))
+			})
+		})
+
+		Context("when HTTP/2 is enabled", func() {
+			BeforeEach(func() {
+				config.EnableHTTP2 = true
+				config.CipherSuites = []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_256_CBC_SHA}
+			})
+
+			It("negotiates h2 with clients that support it", func() {
+				conn, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", config.SSLPort), &tls.Config{
+					InsecureSkipVerify: true,
+					NextProtos:         []string{"h2", "http/1.1"},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				Expect(conn.ConnectionState().NegotiatedProtocol).To(Equal("h2"))
+			})
+
+			It("serves HTTP/1.1 clients", func() {
+				app := test.NewGreetApp([]route.Uri{"test.vcap.me"}, config.Port, mbusClient, nil)
+				app.Listen()
+				Eventually(func() bool {
+					return appRegistered(registry, app)
+				}).Should(BeTrue())
+
+				uri := fmt.Sprintf("https://test.vcap.me:%d/", config.SSLPort)
+				req, _ := http.NewRequest("GET", uri, nil)
+				tr := &http.Transport{
+					TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+				}
+				client := http.Client{Transport: tr}
+
+				resp, err := client.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(resp.ProtoMajor).To(Equal(1))
+				resp.Body.Close()
+			})
+		})
 	})
 })
 
@@ -0,0 +1,120 @@
+package test_util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// CertChain is a certificate for a server name signed by a generated CA.
+type CertChain struct {
+	CertPEM   []byte
+	KeyPEM    []byte
+	CACertPEM []byte
+}
+
+// CreateSignedCertWithRootCA generates a CA and a certificate for serverName
+// signed by it. IP addresses are added to the certificate as IP SANs.
+func CreateSignedCertWithRootCA(serverName string) CertChain {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+	return createSignedCert(serverName, x509.ExtKeyUsageServerAuth, key)
+}
+
+// CreateECDSASignedCertWithRootCA is CreateSignedCertWithRootCA with a P-256
+// ECDSA key.
+func CreateECDSASignedCertWithRootCA(serverName string) CertChain {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	return createSignedCert(serverName, x509.ExtKeyUsageServerAuth, key)
+}
+
+// CreateSignedClientCertWithRootCA generates a CA and a client certificate
+// for name signed by it.
+func CreateSignedClientCertWithRootCA(name string) CertChain {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+	return createSignedCert(name, x509.ExtKeyUsageClientAuth, key)
+}
+
+func createSignedCert(name string, extKeyUsage x509.ExtKeyUsage, key crypto.Signer) CertChain {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+
+	caTemplate := certTemplate("test CA")
+	caTemplate.IsCA = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	Expect(err).ToNot(HaveOccurred())
+	caCert, err := x509.ParseCertificate(caDER)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := certTemplate(name)
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	template.ExtKeyUsage = []x509.ExtKeyUsage{extKeyUsage}
+	if ip := net.ParseIP(name); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{name}
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, key.Public(), caKey)
+	Expect(err).ToNot(HaveOccurred())
+
+	return CertChain{
+		CertPEM:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		KeyPEM:    pem.EncodeToMemory(keyPEMBlock(key)),
+		CACertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+	}
+}
+
+// TLSCert returns the certificate and key of the chain.
+func (c CertChain) TLSCert() tls.Certificate {
+	cert, err := tls.X509KeyPair(c.CertPEM, c.KeyPEM)
+	Expect(err).ToNot(HaveOccurred())
+	return cert
+}
+
+// CACertPool returns a pool holding the CA of the chain.
+func (c CertChain) CACertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	Expect(pool.AppendCertsFromPEM(c.CACertPEM)).To(BeTrue())
+	return pool
+}
+
+func keyPEMBlock(key crypto.Signer) *pem.Block {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		Expect(err).ToNot(HaveOccurred())
+		return &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	case *rsa.PrivateKey:
+		return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+	}
+	Fail("unsupported key type")
+	return nil
+}
+
+func certTemplate(commonName string) *x509.Certificate {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	Expect(err).ToNot(HaveOccurred())
+
+	return &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		BasicConstraintsValid: true,
+	}
+}
@@ -3,9 +3,11 @@ package varz
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
+	"code.cloudfoundry.org/gorouter/certstore"
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/stats"
@@ -27,12 +29,23 @@ type varz struct {
 	Urls     int `json:"urls"`
 	Droplets int `json:"droplets"`
 
-	BadRequests    int     `json:"bad_requests"`
-	BadGateways    int     `json:"bad_gateways"`
-	RequestsPerSec float64 `json:"requests_per_sec"`
+	BadRequests         int     `json:"bad_requests"`
+	BadGateways         int     `json:"bad_gateways"`
+	OverloadedRequests  int     `json:"overloaded_requests"`
+	RateLimitedRequests int     `json:"rate_limited_requests"`
+	OversizedRequests   int     `json:"oversized_requests"`
+	RejectedConnections int     `json:"rejected_connections"`
+	HedgedRequests      int     `json:"hedged_requests"`
+	HedgedRequestsWon   int     `json:"hedged_requests_won"`
+	RequestsPerSec      float64 `json:"requests_per_sec"`
+
+	CacheResponses     map[string]int `json:"cache_responses"`
+	ConnectionTimeouts map[string]int `json:"connection_timeouts"`
 
 	TopApps []topAppsEntry `json:"top10_app_requests"`
 
+	TLSCertificates []certstore.Expiry `json:"tls_certificates"`
+
 	MillisSinceLastRegistryUpdate int64 `json:"ms_since_last_registry_update"`
 }
 
@@ -160,15 +173,26 @@ type Varz interface {
 
 	CaptureBadRequest()
 	CaptureBadGateway()
+	CaptureOverloadedRequest()
+	CaptureRateLimitedRequest()
+	CaptureOversizedRequest()
+	CaptureConnectionTimeout(timeout string)
+	CaptureRejectedConnection()
 	CaptureRoutingRequest(b *route.Endpoint)
 	CaptureRoutingResponseLatency(b *route.Endpoint, statusCode int, startedAt time.Time, d time.Duration)
+	CaptureHedgedRequest()
+	CaptureHedgeWon()
+	CaptureCacheResponse(status string)
+
+	SetCertificates(certificates *certstore.Store)
 }
 
 type RealVarz struct {
 	sync.Mutex
-	r          *registry.RouteRegistry
-	activeApps *stats.ActiveApps
-	topApps    *stats.TopApps
+	r            *registry.RouteRegistry
+	activeApps   *stats.ActiveApps
+	topApps      *stats.TopApps
+	certificates *certstore.Store
 	varz
 }
 
@@ -180,6 +204,8 @@ func NewVarz(r *registry.RouteRegistry) Varz {
 
 	x.All = NewHttpMetric()
 	x.Tags.Component = make(map[string]*HttpMetric)
+	x.CacheResponses = make(map[string]int)
+	x.ConnectionTimeouts = make(map[string]int)
 
 	return x
 }
@@ -197,6 +223,11 @@ func (x *RealVarz) MarshalJSON() ([]byte, error) {
 
 	x.updateTop()
 
+	x.varz.TLSCertificates = []certstore.Expiry{}
+	if x.certificates != nil {
+		x.varz.TLSCertificates = x.certificates.Expiries()
+	}
+
 	d := make(map[string]interface{})
 	transform(x.varz.All, d)
 	transform(x.varz, d)
@@ -219,6 +250,14 @@ func (x *RealVarz) updateTop() {
 	}
 }
 
+// SetCertificates reports the expiry of the certificates served on the SSL
+// port.
+func (x *RealVarz) SetCertificates(certificates *certstore.Store) {
+	x.Lock()
+	x.certificates = certificates
+	x.Unlock()
+}
+
 func (x *RealVarz) ActiveApps() *stats.ActiveApps {
 	return x.activeApps
 }
@@ -235,6 +274,59 @@ func (x *RealVarz) CaptureBadGateway() {
 	x.Unlock()
 }
 
+func (x *RealVarz) CaptureOverloadedRequest() {
+	x.Lock()
+	x.OverloadedRequests++
+	x.Unlock()
+}
+
+func (x *RealVarz) CaptureRateLimitedRequest() {
+	x.Lock()
+	x.RateLimitedRequests++
+	x.Unlock()
+}
+
+func (x *RealVarz) CaptureOversizedRequest() {
+	x.Lock()
+	x.OversizedRequests++
+	x.Unlock()
+}
+
+// CaptureConnectionTimeout counts client connections closed by a timeout of
+// the listeners, by timeout.
+func (x *RealVarz) CaptureConnectionTimeout(timeout string) {
+	x.Lock()
+	x.ConnectionTimeouts[timeout]++
+	x.Unlock()
+}
+
+func (x *RealVarz) CaptureRejectedConnection() {
+	x.Lock()
+	x.RejectedConnections++
+	x.Unlock()
+}
+
+// CaptureHedgedRequest counts a copy of a request sent to a second endpoint.
+func (x *RealVarz) CaptureHedgedRequest() {
+	x.Lock()
+	x.HedgedRequests++
+	x.Unlock()
+}
+
+// CaptureHedgeWon counts a hedged copy that responded before the original.
+func (x *RealVarz) CaptureHedgeWon() {
+	x.Lock()
+	x.HedgedRequestsWon++
+	x.Unlock()
+}
+
+// CaptureCacheResponse counts requests to cached routes by cache status.
+func (x *RealVarz) CaptureCacheResponse(status string) {
+	x.Lock()
+	x.CacheResponses[strings.ToLower(status)]++
+	x.Unlock()
+}
+
 func (x *RealVarz) CaptureAppStats(b *route.Endpoint, t time.Time) {
 	if b.ApplicationId != "" {
 		x.activeApps.Mark(b.ApplicationId, t)
@@ -1,6 +1,7 @@
 package varz_test
 
 import (
+	"code.cloudfoundry.org/gorouter/certstore"
 	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/metrics/fakes"
@@ -12,6 +13,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -46,6 +48,15 @@ var _ = Describe("Varz", func() {
 			"requests",
 			"bad_requests",
 			"bad_gateways",
+			"overloaded_requests",
+			"rate_limited_requests",
+			"oversized_requests",
+			"rejected_connections",
+			"hedged_requests",
+			"hedged_requests_won",
+			"cache_responses",
+			"connection_timeouts",
+			"tls_certificates",
 			"requests_per_sec",
 			"top10_app_requests",
 			"ms_since_last_registry_update",
@@ -64,6 +75,21 @@ var _ = Describe("Varz", func() {
 		}
 	})
 
+	It("reports the expiry of the TLS certificates", func() {
+		cert := test_util.CreateSignedCertWithRootCA("example.com").TLSCert()
+		store, err := certstore.New([]tls.Certificate{cert}, "")
+		Expect(err).ToNot(HaveOccurred())
+		Varz.SetCertificates(store)
+
+		certs := findValue(Varz, "tls_certificates").([]interface{})
+		Expect(certs).To(HaveLen(1))
+
+		c := certs[0].(map[string]interface{})
+		Expect(c["names"]).To(Equal([]interface{}{"example.com"}))
+		Expect(c["not_after"]).NotTo(BeEmpty())
+		Expect(c["expires_in_seconds"]).To(BeNumerically(">", 0))
+	})
+
 	It("reports seconds since last registry update", func() {
 		Registry.Register("foo", &route.Endpoint{})
 
@@ -102,6 +128,53 @@ var _ = Describe("Varz", func() {
 		Expect(findValue(Varz, "bad_gateways")).To(Equal(float64(2)))
 	})
 
+	It("updates overloaded requests", func() {
+		Varz.CaptureOverloadedRequest()
+		Expect(findValue(Varz, "overloaded_requests")).To(Equal(float64(1)))
+
+		Varz.CaptureOverloadedRequest()
+		Expect(findValue(Varz, "overloaded_requests")).To(Equal(float64(2)))
+	})
+
+	It("updates rate limited requests", func() {
+		Varz.CaptureRateLimitedRequest()
+		Expect(findValue(Varz, "rate_limited_requests")).To(Equal(float64(1)))
+	})
+
+	It("updates oversized requests", func() {
+		Varz.CaptureOversizedRequest()
+		Expect(findValue(Varz, "oversized_requests")).To(Equal(float64(1)))
+	})
+
+	It("updates hedged requests", func() {
+		Varz.CaptureHedgedRequest()
+		Varz.CaptureHedgedRequest()
+		Varz.CaptureHedgeWon()
+		Expect(findValue(Varz, "hedged_requests")).To(Equal(float64(2)))
+		Expect(findValue(Varz, "hedged_requests_won")).To(Equal(float64(1)))
+	})
+
+	It("updates cache responses", func() {
+		Varz.CaptureCacheResponse("HIT")
+		Varz.CaptureCacheResponse("HIT")
+		Varz.CaptureCacheResponse("MISS")
+		Expect(findValue(Varz, "cache_responses", "hit")).To(Equal(float64(2)))
+		Expect(findValue(Varz, "cache_responses", "miss")).To(Equal(float64(1)))
+	})
+
+	It("updates connection timeouts", func() {
+		Varz.CaptureConnectionTimeout("read_header")
+		Varz.CaptureConnectionTimeout("read_header")
+		Varz.CaptureConnectionTimeout("idle")
+		Expect(findValue(Varz, "connection_timeouts", "read_header")).To(Equal(float64(2)))
+		Expect(findValue(Varz, "connection_timeouts", "idle")).To(Equal(float64(1)))
+	})
+
+	It("updates rejected connections", func() {
+		Varz.CaptureRejectedConnection()
+		Expect(findValue(Varz, "rejected_connections")).To(Equal(float64(1)))
+	})
+
 	It("updates requests", func() {
 		b := &route.Endpoint{}
 